@@ -0,0 +1,234 @@
+package rangepool
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/giantswarm/microerror"
+)
+
+// AdoptReport describes the outcome of adopting items, see Service.Adopt.
+type AdoptReport struct {
+	// Adopted are the items which are owned by the ID now, in ascending order.
+	Adopted []int
+	// Namespace is the namespace the report belongs to.
+	Namespace string
+	// OutOfRange are the items which have not been adopted, because they are
+	// outside of the given range, in ascending order.
+	OutOfRange []int
+	// Owned are the items which have not been adopted, because they are used
+	// already, mapped to the ID owning them. The owner is empty for items used
+	// without being owned by any ID, see Service.GC.
+	Owned map[int]string
+}
+
+// Adopt registers the given items as owned by the given ID within the given
+// namespace, e.g. items which were assigned by legacy systems before the range
+// pool took over. Adopted items are never handed out again and are managed
+// like items allocated using Service.Create, so they are searched and released
+// the same way. Conflicting items do not fail the whole batch. Items outside
+// of the range in between min and max, both inclusive, and items which are
+// used already are skipped and listed in the returned report instead. The
+// quota of the ID applies to the adopted items, see Config.IDQuota, but the
+// blocked items of the namespace do not, since the items exist already.
+func (s *Service) Adopt(ctx context.Context, namespace, ID string, items []int, min, max int) (AdoptReport, error) {
+	ctx = withOperation(ctx, "Adopt", namespace, ID)
+	ctx = NewReadVersionsContext(ctx, NewReadVersions())
+
+	err := s.checkReadOnly()
+	if err != nil {
+		return AdoptReport{}, microerror.Mask(err)
+	}
+
+	err = s.checkLeader()
+	if err != nil {
+		return AdoptReport{}, microerror.Mask(err)
+	}
+
+	s, err = s.withPolicy(ctx, namespace)
+	if err != nil {
+		return AdoptReport{}, microerror.Mask(err)
+	}
+
+	err = s.ensureSchema(ctx, namespace)
+	if err != nil {
+		return AdoptReport{}, microerror.Mask(err)
+	}
+
+	err = validateBoundaries(min, max, latestItemException)
+	if err != nil {
+		return AdoptReport{}, microerror.Mask(err)
+	}
+
+	err = s.checkRange(namespace, min, max)
+	if err != nil {
+		return AdoptReport{}, microerror.Mask(err)
+	}
+
+	err = s.checkLimits(len(items), min, max)
+	if err != nil {
+		return AdoptReport{}, microerror.Mask(err)
+	}
+
+	report := AdoptReport{
+		Namespace: namespace,
+		Owned:     map[int]string{},
+	}
+
+	var candidates []int
+	{
+		seen := map[int]bool{}
+		for _, item := range items {
+			if seen[item] {
+				return AdoptReport{}, microerror.Maskf(invalidArgumentError, "item %d must not be given twice", item)
+			}
+			seen[item] = true
+
+			if item < min || item > max {
+				report.OutOfRange = append(report.OutOfRange, item)
+			} else {
+				candidates = append(candidates, item)
+			}
+		}
+		sort.Ints(report.OutOfRange)
+	}
+
+	// The cached items might be outdated, so conflicts are looked up in the
+	// storage.
+	var bm bitmap
+	isUsed := map[int]bool{}
+	if s.bitmap {
+		bm, err = s.searchBitmap(ctx, namespace)
+		if err != nil {
+			return AdoptReport{}, microerror.Mask(err)
+		}
+		for _, item := range candidates {
+			isUsed[item] = bm.IsSet(item)
+		}
+	} else {
+		used, err := s.searchUsed(NewConsistencyContext(ctx, ConsistencyLinearizable), namespace)
+		if err != nil {
+			return AdoptReport{}, microerror.Mask(err)
+		}
+		for _, item := range used {
+			isUsed[item] = true
+		}
+	}
+
+	{
+		conflicts := map[int]bool{}
+		for _, item := range candidates {
+			if isUsed[item] {
+				conflicts[item] = true
+			} else {
+				report.Adopted = append(report.Adopted, item)
+			}
+		}
+		sort.Ints(report.Adopted)
+
+		if len(conflicts) != 0 {
+			owners, err := s.searchOwners(ctx, namespace, conflicts)
+			if err != nil {
+				return AdoptReport{}, microerror.Mask(err)
+			}
+			for item := range conflicts {
+				report.Owned[item] = owners[item]
+			}
+		}
+	}
+
+	if len(report.Adopted) == 0 {
+		return report, nil
+	}
+
+	err = s.checkIDQuota(ctx, namespace, ID, len(report.Adopted), true)
+	if err != nil {
+		return AdoptReport{}, microerror.Mask(err)
+	}
+
+	if s.bitmap {
+		err = s.adoptBitmap(ctx, namespace, ID, bm, report.Adopted)
+		if err != nil {
+			return AdoptReport{}, microerror.Mask(err)
+		}
+	} else {
+		err = s.create(ctx, namespace, ID, report.Adopted, latestItemException, callOptions{})
+		if err != nil {
+			// Some of the items might have been persisted, see Service.Create.
+			s.cache.Invalidate(namespace)
+			return AdoptReport{}, microerror.Mask(err)
+		}
+
+		s.cache.Add(namespace, report.Adopted)
+	}
+
+	s.logger.LogCtx(ctx, "level", "info", "message", fmt.Sprintf("adopted items %v for ID '%s' of namespace '%s'", report.Adopted, ID, namespace))
+	s.notify(ctx, EventTypeAllocated, namespace, ID, report.Adopted)
+
+	return report, nil
+}
+
+// adoptBitmap persists the given adopted items in case the items of the
+// namespace are persisted as bitmap. used is the current bitmap of the
+// namespace.
+func (s *Service) adoptBitmap(ctx context.Context, namespace, ID string, used bitmap, items []int) error {
+	for _, item := range items {
+		used.Set(item)
+	}
+
+	var kvs []KV
+	{
+		kvs = append(kvs, KV{Key: s.key(BitmapKeyFormat, namespace), Value: s.encodeBitmap(used)})
+
+		for _, item := range items {
+			kvs = append(kvs, KV{Key: s.key(IDKeyFormat, namespace, ID, s.encodeItem(item)), Value: strconv.Itoa(item)})
+		}
+
+		kv, ok, err := s.auditKV(ctx, AuditActionAllocate, namespace, ID, items)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+		if ok {
+			kvs = append(kvs, kv)
+		}
+	}
+
+	err := createBatch(ctx, s.storage, kvs)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// searchOwners fetches the IDs owning the given items of the given namespace.
+// Items without owner are missing in the returned map.
+func (s *Service) searchOwners(ctx context.Context, namespace string, items map[int]bool) (map[int]string, error) {
+	owners := map[int]string{}
+
+	err := walk(ctx, s.storage, s.key(IDPrefixKeyFormat, namespace), func(kv KV) error {
+		// The keys are relative to the ID prefix, e.g. ${id1}/item/${item1}.
+		i := strings.LastIndex(kv.Key, "/item/")
+		if i == -1 {
+			return nil
+		}
+
+		item, err := strconv.Atoi(kv.Value)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+		if _, ok := owners[item]; items[item] && !ok {
+			owners[item] = kv.Key[:i]
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return owners, nil
+}
@@ -0,0 +1,128 @@
+package rangepool
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/giantswarm/micrologger/microloggertest"
+)
+
+func Test_Service_Adopt(t *testing.T) {
+	for _, b := range []bool{false, true} {
+		var err error
+		var newService *Service
+		{
+			newStorage, err := newMemoryStorage()
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+
+			config := DefaultConfig()
+			config.Logger = microloggertest.New()
+			config.Storage = newStorage
+			config.Bitmap = b
+			config.LatestMode = LatestModeLowestFree
+			newService, err = New(config)
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+		}
+
+		ctx := context.TODO()
+
+		_, err = newService.Create(ctx, namespace, "test-id-1", 1, 1, 5)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		// Adopted items must be owned by their ID, while conflicting items must
+		// only be reported.
+		{
+			report, err := newService.Adopt(ctx, namespace, "legacy-id", []int{3, 1, 9, 2}, 1, 5)
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+			expected := AdoptReport{
+				Adopted:    []int{2, 3},
+				Namespace:  namespace,
+				OutOfRange: []int{9},
+				Owned:      map[int]string{1: "test-id-1"},
+			}
+			if !reflect.DeepEqual(report, expected) {
+				t.Fatal("expected", expected, "got", report)
+			}
+
+			items, err := newService.Search(ctx, namespace, "legacy-id")
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+			if !reflect.DeepEqual(items, expected.Adopted) {
+				t.Fatal("expected", expected.Adopted, "got", items)
+			}
+		}
+
+		// Adopted items must never be handed out.
+		{
+			items, err := newService.Create(ctx, namespace, "test-id-2", 2, 1, 5)
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+			expected := []int{4, 5}
+			if !reflect.DeepEqual(items, expected) {
+				t.Fatal("expected", expected, "got", items)
+			}
+		}
+
+		// Adopting only conflicting items must not adopt anything.
+		{
+			report, err := newService.Adopt(ctx, namespace, "other-id", []int{4, 7}, 1, 5)
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+			expected := AdoptReport{
+				Namespace:  namespace,
+				OutOfRange: []int{7},
+				Owned:      map[int]string{4: "test-id-2"},
+			}
+			if !reflect.DeepEqual(report, expected) {
+				t.Fatal("expected", expected, "got", report)
+			}
+
+			_, err = newService.Search(ctx, namespace, "other-id")
+			if !IsItemsNotFound(err) {
+				t.Fatal("expected", true, "got", false)
+			}
+		}
+
+		// Invalid arguments must be rejected.
+		{
+			_, err = newService.Adopt(ctx, namespace, "other-id", []int{7, 7}, 1, 9)
+			if !IsInvalidArgument(err) {
+				t.Fatal("expected", true, "got", false)
+			}
+
+			_, err = newService.Adopt(ctx, namespace, "other-id", []int{7}, 9, 1)
+			if !IsInvalidRange(err) {
+				t.Fatal("expected", true, "got", false)
+			}
+		}
+
+		// Adopted items must be released like allocated items.
+		{
+			err = newService.Delete(ctx, namespace, "legacy-id")
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+
+			items, err := newService.Create(ctx, namespace, "test-id-3", 2, 1, 5)
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+			expected := []int{2, 3}
+			if !reflect.DeepEqual(items, expected) {
+				t.Fatal("expected", expected, "got", items)
+			}
+		}
+	}
+}
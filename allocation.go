@@ -0,0 +1,52 @@
+package rangepool
+
+import (
+	"context"
+	"time"
+
+	"github.com/giantswarm/microerror"
+)
+
+// Allocation describes the items allocated to an ID, see Service.Allocate.
+type Allocation struct {
+	// CreatedAt is the time the allocation was created.
+	CreatedAt time.Time
+	// ID is the ID the items are allocated to.
+	ID string
+	// Items are the items allocated.
+	Items []int
+	// Lease is the duration the allocation is valid for. It is zero for
+	// allocations which never expire.
+	Lease time.Duration
+	// Namespace is the namespace the items are allocated in.
+	Namespace string
+	// Revision is the revision of the ID after the allocation, covering all
+	// items of the ID, see Service.SearchRevision.
+	Revision string
+}
+
+// Allocate works like Create, but returns an Allocation describing the
+// allocated items instead of the bare items. Information about allocations is
+// added to Allocation over time without breaking the signature of Allocate.
+func (s *Service) Allocate(ctx context.Context, namespace, ID string, num, min, max int) (Allocation, error) {
+	items, err := s.Create(ctx, namespace, ID, num, min, max)
+	if err != nil {
+		return Allocation{}, microerror.Mask(err)
+	}
+
+	_, revision, err := s.SearchRevision(ctx, namespace, ID)
+	if err != nil {
+		return Allocation{}, microerror.Mask(err)
+	}
+
+	a := Allocation{
+		CreatedAt: s.clock.Now().UTC(),
+		ID:        ID,
+		Items:     items,
+		Lease:     0,
+		Namespace: namespace,
+		Revision:  revision,
+	}
+
+	return a, nil
+}
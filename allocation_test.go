@@ -0,0 +1,53 @@
+package rangepool
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/giantswarm/micrologger/microloggertest"
+)
+
+func Test_Service_Allocate(t *testing.T) {
+	newStorage, err := newMemoryStorage()
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+
+	config := DefaultConfig()
+	config.Logger = microloggertest.New()
+	config.Storage = newStorage
+	newService, err := New(config)
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+
+	ctx := context.TODO()
+	start := time.Now()
+
+	a, err := newService.Allocate(ctx, namespace, "test-id", 2, 2, 3)
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+	if a.Namespace != namespace {
+		t.Fatal("expected", namespace, "got", a.Namespace)
+	}
+	if a.ID != "test-id" {
+		t.Fatal("expected", "test-id", "got", a.ID)
+	}
+	if len(a.Items) != 2 || a.Items[0] != 2 || a.Items[1] != 3 {
+		t.Fatal("expected", []int{2, 3}, "got", a.Items)
+	}
+	if a.CreatedAt.Before(start) {
+		t.Fatal("expected", "creation time after", start, "got", a.CreatedAt)
+	}
+	if a.Revision != revision([]int{2, 3}) {
+		t.Fatal("expected", revision([]int{2, 3}), "got", a.Revision)
+	}
+
+	// Failures of Create must be returned as they are.
+	_, err = newService.Allocate(ctx, namespace, "test-id", 1, 2, 3)
+	if !IsCapacityReached(err) {
+		t.Fatal("expected", true, "got", false)
+	}
+}
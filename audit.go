@@ -0,0 +1,125 @@
+package rangepool
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/giantswarm/microerror"
+)
+
+const (
+	// AuditActionAllocate is the action of audit entries recorded for items
+	// being allocated by Service.Create.
+	AuditActionAllocate = "allocate"
+	// AuditActionRelease is the action of audit entries recorded for items
+	// being released by Service.Delete.
+	AuditActionRelease = "release"
+)
+
+// AuditEntry is a single record of the audit trail of a namespace, see
+// Config.Audit.
+type AuditEntry struct {
+	Action    string    `json:"action"`
+	Caller    string    `json:"caller,omitempty"`
+	ID        string    `json:"id"`
+	Items     []int     `json:"items"`
+	Namespace string    `json:"namespace"`
+	Time      time.Time `json:"time"`
+}
+
+type callerContextKey struct{}
+
+// NewCallerContext returns a new context carrying the identity of the caller,
+// e.g. the name of a controller or user. The identity is recorded within the
+// audit trail of allocations and releases executed using the returned context.
+func NewCallerContext(ctx context.Context, caller string) context.Context {
+	return context.WithValue(ctx, callerContextKey{}, caller)
+}
+
+// CallerFromContext returns the identity of the caller carried by the given
+// context, see NewCallerContext.
+func CallerFromContext(ctx context.Context) (string, bool) {
+	caller, ok := ctx.Value(callerContextKey{}).(string)
+	return caller, ok
+}
+
+// AuditLog returns the audit entries of the given namespace which have been
+// recorded at or after since, ordered by time. See Config.Audit.
+func (s *Service) AuditLog(ctx context.Context, namespace string, since time.Time) ([]AuditEntry, error) {
+	var entries []AuditEntry
+
+	err := walk(ctx, s.storage, s.key(AuditListKeyFormat, namespace), func(kv KV) error {
+		var e AuditEntry
+		err := json.Unmarshal([]byte(kv.Value), &e)
+		if err != nil {
+			return microerror.Maskf(executionFailedError, "decoding audit entry '%s': %s", kv.Key, err.Error())
+		}
+
+		if e.Time.Before(since) {
+			return nil
+		}
+		entries = append(entries, e)
+
+		return nil
+	})
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Time.Before(entries[j].Time)
+	})
+
+	return entries, nil
+}
+
+// auditKV returns the key-value pair persisting the audit entry of the given
+// action in case auditing is enabled. The key starts with the zero padded
+// timestamp of the entry, so that entries are never overwritten and their keys
+// are ordered by time.
+func (s *Service) auditKV(ctx context.Context, action, namespace, ID string, items []int) (KV, bool, error) {
+	if !s.audit {
+		return KV{}, false, nil
+	}
+
+	e := AuditEntry{
+		Action:    action,
+		ID:        ID,
+		Items:     items,
+		Namespace: namespace,
+		Time:      s.clock.Now().UTC(),
+	}
+	e.Caller, _ = CallerFromContext(ctx)
+
+	b, err := json.Marshal(e)
+	if err != nil {
+		return KV{}, false, microerror.Mask(err)
+	}
+
+	k := fmt.Sprintf("%020d-%s-%s", e.Time.UnixNano(), action, ID)
+	kv := KV{Key: s.key(AuditKeyFormat, namespace, k), Value: string(b)}
+
+	return kv, true, nil
+}
+
+// recordAudit persists the audit entry of the given action in case auditing
+// is enabled.
+func (s *Service) recordAudit(ctx context.Context, action, namespace, ID string, items []int) error {
+	kv, ok, err := s.auditKV(ctx, action, namespace, ID, items)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	if !ok {
+		return nil
+	}
+
+	err = s.storage.Create(ctx, kv.Key, kv.Value)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
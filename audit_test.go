@@ -0,0 +1,97 @@
+package rangepool
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/giantswarm/micrologger/microloggertest"
+)
+
+func Test_Service_AuditLog(t *testing.T) {
+	var err error
+	var newService *Service
+	{
+		newStorage, err := newMemoryStorage()
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		config := DefaultConfig()
+		config.Logger = microloggertest.New()
+		config.Storage = newStorage
+		config.Audit = true
+		newService, err = New(config)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+	}
+
+	ctx := NewCallerContext(context.TODO(), "test-caller")
+	start := time.Now()
+
+	// Allocate and release items of two IDs.
+	{
+		_, err = newService.Create(ctx, namespace, "test-id-1", 2, 2, 10)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		_, err = newService.Create(ctx, namespace, "test-id-2", 1, 2, 10)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		err = newService.Delete(ctx, namespace, "test-id-1")
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+	}
+
+	// All actions must be recorded in order.
+	{
+		entries, err := newService.AuditLog(ctx, namespace, start)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		if len(entries) != 3 {
+			t.Fatal("expected", 3, "got", len(entries))
+		}
+
+		expected := []struct {
+			Action string
+			ID     string
+			Items  int
+		}{
+			{Action: AuditActionAllocate, ID: "test-id-1", Items: 2},
+			{Action: AuditActionAllocate, ID: "test-id-2", Items: 1},
+			{Action: AuditActionRelease, ID: "test-id-1", Items: 2},
+		}
+		for i, e := range entries {
+			if e.Action != expected[i].Action {
+				t.Fatal("entry", i, "expected", expected[i].Action, "got", e.Action)
+			}
+			if e.ID != expected[i].ID {
+				t.Fatal("entry", i, "expected", expected[i].ID, "got", e.ID)
+			}
+			if len(e.Items) != expected[i].Items {
+				t.Fatal("entry", i, "expected", expected[i].Items, "got", len(e.Items))
+			}
+			if e.Caller != "test-caller" {
+				t.Fatal("entry", i, "expected", "test-caller", "got", e.Caller)
+			}
+			if e.Namespace != namespace {
+				t.Fatal("entry", i, "expected", namespace, "got", e.Namespace)
+			}
+		}
+	}
+
+	// Entries recorded before since must be omitted.
+	{
+		entries, err := newService.AuditLog(ctx, namespace, time.Now().Add(time.Hour))
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		if len(entries) != 0 {
+			t.Fatal("expected", 0, "got", len(entries))
+		}
+	}
+}
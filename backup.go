@@ -0,0 +1,113 @@
+package rangepool
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/giantswarm/microerror"
+)
+
+// Backup writes the snapshots of all namespaces to the given writer, one JSON
+// encoded Snapshot per line, ordered by namespace. Namespaces are discovered by
+// listing all keys below the configured key prefix, so the storage must support
+// listing the key prefix itself. This is not the case for the storages of the
+// storage/configmap, storage/crd and storage/postgres packages, which organize
+// their keys by namespace. Use Export for them instead.
+func (s *Service) Backup(ctx context.Context, w io.Writer) error {
+	ctx = withOperation(ctx, "Backup", "", "")
+
+	namespaces, err := s.searchNamespaces(ctx)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	e := json.NewEncoder(w)
+	for _, namespace := range namespaces {
+		snapshot, err := s.Export(ctx, namespace)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
+		// Namespaces which only hold audit entries do not have any state worth
+		// restoring.
+		if len(snapshot.IDs) == 0 && snapshot.Latest == latestItemException && len(snapshot.Burned) == 0 && snapshot.Policy == nil && len(snapshot.Reservations) == 0 {
+			continue
+		}
+
+		err = e.Encode(snapshot)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	s.logger.LogCtx(ctx, "level", "debug", "message", fmt.Sprintf("backed up %d namespaces", len(namespaces)))
+
+	return nil
+}
+
+// Restore imports all snapshots read from the given reader, as written by
+// Backup. Restoring stops at the first snapshot which cannot be imported, e.g.
+// because its namespace is not empty. Namespaces restored up to then are kept.
+func (s *Service) Restore(ctx context.Context, r io.Reader) error {
+	ctx = withOperation(ctx, "Restore", "", "")
+
+	var n int
+
+	d := json.NewDecoder(r)
+	for {
+		var snapshot Snapshot
+		err := d.Decode(&snapshot)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return microerror.Maskf(invalidSnapshotError, "decoding snapshot %d: %s", n+1, err.Error())
+		}
+
+		err = s.Import(ctx, snapshot)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+		n++
+	}
+
+	s.logger.LogCtx(ctx, "level", "debug", "message", fmt.Sprintf("restored %d namespaces", n))
+
+	return nil
+}
+
+// searchNamespaces fetches the names of all namespaces holding keys below the
+// configured key prefix, in ascending order.
+func (s *Service) searchNamespaces(ctx context.Context) ([]string, error) {
+	healthz := s.key(HealthzKeyFormat)
+
+	seen := map[string]struct{}{}
+	err := walk(ctx, s.storage, s.keyPrefix, func(kv KV) error {
+		// The keys are relative to the key prefix, e.g. ${namespace}/latest.
+		if s.keyPrefix+"/"+kv.Key == healthz {
+			return nil
+		}
+
+		i := strings.Index(kv.Key, "/")
+		if i == -1 {
+			return nil
+		}
+		seen[kv.Key[:i]] = struct{}{}
+
+		return nil
+	})
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	var namespaces []string
+	for namespace := range seen {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Strings(namespaces)
+
+	return namespaces, nil
+}
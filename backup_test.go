@@ -0,0 +1,191 @@
+package rangepool
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/giantswarm/micrologger/microloggertest"
+)
+
+func Test_Service_BackupRestore(t *testing.T) {
+	newTestService := func() *Service {
+		newStorage, err := newMemoryStorage()
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		config := DefaultConfig()
+		config.Logger = microloggertest.New()
+		config.Storage = newStorage
+		newService, err := New(config)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		return newService
+	}
+
+	ctx := context.TODO()
+
+	source := newTestService()
+	target := newTestService()
+
+	_, err := source.Create(ctx, "test-namespace-1", "test-id-1", 2, 2, 10)
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+	_, err = source.Create(ctx, "test-namespace-2", "test-id-2", 1, 5, 10)
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+
+	var buf bytes.Buffer
+	{
+		err = source.Backup(ctx, &buf)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		expected := `{"ids":{"test-id-1":[2,3]},"latest":3,"namespace":"test-namespace-1","version":1}
+{"ids":{"test-id-2":[5]},"latest":5,"namespace":"test-namespace-2","version":1}
+`
+		if buf.String() != expected {
+			t.Fatal("expected", expected, "got", buf.String())
+		}
+	}
+
+	// All namespaces must be restored.
+	{
+		err = target.Restore(ctx, strings.NewReader(buf.String()))
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		for _, namespace := range []string{"test-namespace-1", "test-namespace-2"} {
+			s, err := source.Dump(ctx, namespace)
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+			d, err := target.Dump(ctx, namespace)
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+			if !reflect.DeepEqual(d, s) {
+				t.Fatal("expected", s, "got", d)
+			}
+		}
+	}
+
+	// Restoring into namespaces holding items must fail.
+	{
+		err = target.Restore(ctx, strings.NewReader(buf.String()))
+		if !IsNamespaceNotEmpty(err) {
+			t.Fatal("expected", true, "got", false)
+		}
+	}
+
+	// Malformed backups must fail.
+	{
+		err = newTestService().Restore(ctx, strings.NewReader("{"))
+		if !IsInvalidSnapshot(err) {
+			t.Fatal("expected", true, "got", false)
+		}
+	}
+}
+
+func Test_Service_BackupRestore_State(t *testing.T) {
+	newTestService := func() *Service {
+		newStorage, err := newMemoryStorage()
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		config := DefaultConfig()
+		config.Logger = microloggertest.New()
+		config.Storage = newStorage
+		newService, err := New(config)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		return newService
+	}
+
+	ctx := context.TODO()
+
+	source := newTestService()
+	target := newTestService()
+
+	policy := Policy{Exclusions: []int{5}}
+
+	var r Reservation
+	{
+		err := source.Burn(ctx, namespace, []int{4})
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		err = source.SetPolicy(ctx, namespace, policy)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		r, err = source.Reserve(ctx, namespace, "test-id-1", 1, 2, 10)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+	}
+
+	var buf bytes.Buffer
+	{
+		err := source.Backup(ctx, &buf)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		err = target.Restore(ctx, &buf)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+	}
+
+	// The policy must be restored.
+	{
+		p, err := target.Policy(ctx, namespace)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		if !reflect.DeepEqual(p, policy) {
+			t.Fatal("expected", policy, "got", p)
+		}
+	}
+
+	// The reservation must be restored, so that it can be committed.
+	{
+		err := target.Commit(ctx, r.Token)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		items, err := target.Search(ctx, namespace, "test-id-1")
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		if !reflect.DeepEqual(items, r.Items) {
+			t.Fatal("expected", r.Items, "got", items)
+		}
+	}
+
+	// Burned and excluded items must not be handed out.
+	{
+		items, err := target.Create(ctx, namespace, "test-id-2", 2, 2, 10)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		expected := []int{3, 6}
+		if !reflect.DeepEqual(items, expected) {
+			t.Fatal("expected", expected, "got", items)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package rangepool
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"github.com/giantswarm/micrologger/microloggertest"
+)
+
+var benchmarkSizes = []int{1000, 100000, 1000000}
+
+func Benchmark_Service_Create(b *testing.B) {
+	for _, size := range benchmarkSizes {
+		b.Run(strconv.Itoa(size), func(b *testing.B) {
+			newService, newStorage := newBenchmarkService(b, size)
+			ctx := context.TODO()
+
+			var calls int64
+			newStorage.Reset()
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				_, err := newService.Create(ctx, namespace, "benchmark-id", 1, 0, 2*size)
+				if err != nil {
+					b.Fatal("expected", nil, "got", err)
+				}
+
+				b.StopTimer()
+				calls += newStorage.Calls()
+				err = newService.Delete(ctx, namespace, "benchmark-id")
+				if err != nil {
+					b.Fatal("expected", nil, "got", err)
+				}
+				newStorage.Reset()
+				b.StartTimer()
+			}
+
+			b.ReportMetric(float64(calls)/float64(b.N), "calls/op")
+		})
+	}
+}
+
+func Benchmark_Service_Delete(b *testing.B) {
+	for _, size := range benchmarkSizes {
+		b.Run(strconv.Itoa(size), func(b *testing.B) {
+			newService, newStorage := newBenchmarkService(b, size)
+			ctx := context.TODO()
+
+			var calls int64
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				_, err := newService.Create(ctx, namespace, "benchmark-id", 1, 0, 2*size)
+				if err != nil {
+					b.Fatal("expected", nil, "got", err)
+				}
+				newStorage.Reset()
+				b.StartTimer()
+
+				err = newService.Delete(ctx, namespace, "benchmark-id")
+				if err != nil {
+					b.Fatal("expected", nil, "got", err)
+				}
+
+				b.StopTimer()
+				calls += newStorage.Calls()
+				b.StartTimer()
+			}
+
+			b.ReportMetric(float64(calls)/float64(b.N), "calls/op")
+		})
+	}
+}
+
+func Benchmark_Service_Search(b *testing.B) {
+	for _, size := range benchmarkSizes {
+		b.Run(strconv.Itoa(size), func(b *testing.B) {
+			newService, newStorage := newBenchmarkService(b, size)
+			ctx := context.TODO()
+
+			newStorage.Reset()
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				_, err := newService.Search(ctx, namespace, "benchmark-prefill")
+				if err != nil {
+					b.Fatal("expected", nil, "got", err)
+				}
+			}
+
+			b.ReportMetric(float64(newStorage.Calls())/float64(b.N), "calls/op")
+		})
+	}
+}
+
+func Benchmark_nextItem(b *testing.B) {
+	for _, size := range benchmarkSizes {
+		b.Run(strconv.Itoa(size), func(b *testing.B) {
+			used := make([]int, size)
+			for i := range used {
+				used[i] = i
+			}
+
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				_, err := nextItem(used, 0, 2*size, size/2)
+				if err != nil {
+					b.Fatal("expected", nil, "got", err)
+				}
+			}
+		})
+	}
+}
+
+// newBenchmarkService creates a Service whose namespace already holds the
+// given number of items, all of them allocated for a single ID.
+func newBenchmarkService(b *testing.B, size int) (*Service, *countingStorage) {
+	memoryStorage, err := newMemoryStorage()
+	if err != nil {
+		b.Fatal("expected", nil, "got", err)
+	}
+	newStorage := &countingStorage{Storage: memoryStorage}
+
+	config := DefaultConfig()
+	config.Logger = microloggertest.New()
+	config.Storage = newStorage
+	newService, err := New(config)
+	if err != nil {
+		b.Fatal("expected", nil, "got", err)
+	}
+
+	var kvs []KV
+	for i := 0; i < size; i++ {
+		v := strconv.Itoa(i)
+		kvs = append(kvs, KV{Key: fmt.Sprintf(ItemKeyFormat, namespace, v), Value: v})
+		kvs = append(kvs, KV{Key: fmt.Sprintf(IDKeyFormat, namespace, "benchmark-prefill", v), Value: v})
+	}
+	kvs = append(kvs, KV{Key: fmt.Sprintf(LatestKeyFormat, namespace), Value: strconv.Itoa(size - 1)})
+
+	err = createBatch(context.TODO(), memoryStorage, kvs)
+	if err != nil {
+		b.Fatal("expected", nil, "got", err)
+	}
+
+	return newService, newStorage
+}
+
+// countingStorage counts the calls to the given Storage, which makes the cost
+// of backend round trips visible.
+type countingStorage struct {
+	Storage
+
+	calls int64
+}
+
+func (s *countingStorage) Calls() int64 {
+	return atomic.LoadInt64(&s.calls)
+}
+
+func (s *countingStorage) Create(ctx context.Context, key, value string) error {
+	atomic.AddInt64(&s.calls, 1)
+	return s.Storage.Create(ctx, key, value)
+}
+
+func (s *countingStorage) CreateBatch(ctx context.Context, kvs []KV) error {
+	atomic.AddInt64(&s.calls, 1)
+	return createBatch(ctx, s.Storage, kvs)
+}
+
+func (s *countingStorage) Delete(ctx context.Context, key string) error {
+	atomic.AddInt64(&s.calls, 1)
+	return s.Storage.Delete(ctx, key)
+}
+
+func (s *countingStorage) DeleteBatch(ctx context.Context, keys []string) error {
+	atomic.AddInt64(&s.calls, 1)
+	return deleteBatch(ctx, s.Storage, keys)
+}
+
+func (s *countingStorage) List(ctx context.Context, key string) ([]KV, error) {
+	atomic.AddInt64(&s.calls, 1)
+	return s.Storage.List(ctx, key)
+}
+
+func (s *countingStorage) Reset() {
+	atomic.StoreInt64(&s.calls, 0)
+}
+
+func (s *countingStorage) Search(ctx context.Context, key string) (string, error) {
+	atomic.AddInt64(&s.calls, 1)
+	return s.Storage.Search(ctx, key)
+}
@@ -0,0 +1,196 @@
+package rangepool
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/binary"
+	"io/ioutil"
+	"math/bits"
+	"strings"
+
+	"github.com/giantswarm/microerror"
+)
+
+// compressedBitmapPrefix marks bitmaps compressed using gzip, see
+// bitmap.Compress. It cannot be confused with the base64 encoding of
+// uncompressed bitmaps, since colons are not part of the base64 alphabet.
+const compressedBitmapPrefix = "gz:"
+
+// bitmap is a set of non-negative items where each item is represented by a
+// single bit. It is used to persist the used items of a namespace as a single
+// value, see BitmapKeyFormat.
+type bitmap []uint64
+
+// newBitmap decodes the given value as created by bitmap.String or
+// bitmap.Compress.
+func newBitmap(s string) (bitmap, error) {
+	compressed := strings.HasPrefix(s, compressedBitmapPrefix)
+
+	b, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(s, compressedBitmapPrefix))
+	if err != nil {
+		return nil, microerror.Maskf(invalidBitmapError, "%s", err.Error())
+	}
+	if compressed {
+		r, err := gzip.NewReader(bytes.NewReader(b))
+		if err != nil {
+			return nil, microerror.Maskf(invalidBitmapError, "%s", err.Error())
+		}
+		b, err = ioutil.ReadAll(r)
+		if err != nil {
+			return nil, microerror.Maskf(invalidBitmapError, "%s", err.Error())
+		}
+	}
+	if len(b)%8 != 0 {
+		return nil, microerror.Maskf(invalidBitmapError, "length must be a multiple of 8 bytes")
+	}
+
+	m := make(bitmap, len(b)/8)
+	for i := range m {
+		m[i] = binary.LittleEndian.Uint64(b[i*8:])
+	}
+
+	return m, nil
+}
+
+// IsSet returns whether the given item is part of the set.
+func (m bitmap) IsSet(item int) bool {
+	w := item / 64
+	if item < 0 || w >= len(m) {
+		return false
+	}
+
+	return m[w]&(1<<uint(item%64)) != 0
+}
+
+// Items returns the items of the set in ascending order.
+func (m bitmap) Items() []int {
+	var items []int
+	for w, word := range m {
+		for word != 0 {
+			b := bits.TrailingZeros64(word)
+			items = append(items, w*64+b)
+			word &^= 1 << uint(b)
+		}
+	}
+
+	return items
+}
+
+// Len returns the number of items in the set.
+func (m bitmap) Len() int {
+	var n int
+	for _, w := range m {
+		n += bits.OnesCount64(w)
+	}
+
+	return n
+}
+
+// NextUnset returns the first item in between from and to, both inclusive,
+// which is not part of the set. In case all items are set -1 is returned.
+func (m bitmap) NextUnset(from, to int) int {
+	for i := from; i <= to; {
+		w := i / 64
+		if w >= len(m) {
+			return i
+		}
+
+		// Invert the word so we can look for set bits, and mask out all bits
+		// below the current item.
+		free := ^m[w] >> uint(i%64)
+		if free != 0 {
+			item := i + bits.TrailingZeros64(free)
+			if item > to {
+				return -1
+			}
+			return item
+		}
+
+		i = (w + 1) * 64
+	}
+
+	return -1
+}
+
+// PrevUnset returns the last item in between from and to, both inclusive,
+// which is not part of the set. The set is scanned from to downwards. In case
+// all items are set -1 is returned.
+func (m bitmap) PrevUnset(from, to int) int {
+	for i := to; i >= from; {
+		w := i / 64
+		if w >= len(m) {
+			return i
+		}
+
+		// Invert the word so we can look for set bits, and mask out all bits
+		// above the current item.
+		free := ^m[w] << uint(63-i%64)
+		if free != 0 {
+			item := i - bits.LeadingZeros64(free)
+			if item < from {
+				return -1
+			}
+			return item
+		}
+
+		i = w*64 - 1
+	}
+
+	return -1
+}
+
+// Set adds the given item to the set.
+func (m *bitmap) Set(item int) {
+	w := item / 64
+	for len(*m) <= w {
+		*m = append(*m, 0)
+	}
+
+	(*m)[w] |= 1 << uint(item%64)
+}
+
+// Compress works like String, but compresses the encoded set using gzip,
+// which shrinks the sparse or dense sets of huge ranges considerably.
+func (m bitmap) Compress() string {
+	var buf bytes.Buffer
+
+	// Writing to a bytes.Buffer never fails, so neither does the gzip writer.
+	w := gzip.NewWriter(&buf)
+	w.Write(m.bytes())
+	w.Close()
+
+	return compressedBitmapPrefix + base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+// String encodes the set so that it can be persisted. Trailing empty words
+// are omitted.
+func (m bitmap) String() string {
+	return base64.StdEncoding.EncodeToString(m.bytes())
+}
+
+// bytes returns the words of the set in little endian byte order. Trailing
+// empty words are omitted.
+func (m bitmap) bytes() []byte {
+	n := len(m)
+	for n > 0 && m[n-1] == 0 {
+		n--
+	}
+
+	b := make([]byte, n*8)
+	for i := 0; i < n; i++ {
+		binary.LittleEndian.PutUint64(b[i*8:], m[i])
+	}
+
+	return b
+}
+
+// Unset removes the given item from the set.
+func (m bitmap) Unset(item int) {
+	w := item / 64
+	if item < 0 || w >= len(m) {
+		return
+	}
+
+	m[w] &^= 1 << uint(item%64)
+}
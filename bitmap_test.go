@@ -0,0 +1,363 @@
+package rangepool
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_bitmap(t *testing.T) {
+	var m bitmap
+
+	m.Set(3)
+	m.Set(64)
+	m.Set(130)
+
+	if m.Len() != 3 {
+		t.Fatal("expected", 3, "got", m.Len())
+	}
+	if !m.IsSet(64) {
+		t.Fatal("expected", true, "got", false)
+	}
+	if m.IsSet(65) {
+		t.Fatal("expected", false, "got", true)
+	}
+	if items := m.Items(); len(items) != 3 || items[0] != 3 || items[1] != 64 || items[2] != 130 {
+		t.Fatal("expected", []int{3, 64, 130}, "got", items)
+	}
+
+	// The encoded bitmap must decode to the same set.
+	{
+		d, err := newBitmap(m.String())
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		for _, i := range []int{3, 64, 130} {
+			if !d.IsSet(i) {
+				t.Fatal("expected", true, "got", false)
+			}
+		}
+		if d.Len() != 3 {
+			t.Fatal("expected", 3, "got", d.Len())
+		}
+	}
+
+	// The compressed bitmap must decode to the same set.
+	{
+		c := m.Compress()
+		if !strings.HasPrefix(c, compressedBitmapPrefix) {
+			t.Fatal("expected", compressedBitmapPrefix, "got", c)
+		}
+		d, err := newBitmap(c)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		if !reflect.DeepEqual(d.Items(), m.Items()) {
+			t.Fatal("expected", m.Items(), "got", d.Items())
+		}
+	}
+
+	m.Unset(130)
+	if m.IsSet(130) {
+		t.Fatal("expected", false, "got", true)
+	}
+
+	_, err := newBitmap("foo")
+	if !IsInvalidBitmap(err) {
+		t.Fatal("expected", true, "got", false)
+	}
+	_, err = newBitmap(compressedBitmapPrefix + "AQAAAAAAAAA=")
+	if !IsInvalidBitmap(err) {
+		t.Fatal("expected", true, "got", false)
+	}
+}
+
+func Test_Service_CompressBitmaps(t *testing.T) {
+	newStorage, err := newMemoryStorage()
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+
+	ctx := context.TODO()
+
+	var compressing *Service
+	{
+		compressing, err = NewWithOptions(newStorage, WithBitmap(true), WithCompressBitmaps(true))
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+	}
+
+	_, err = compressing.Create(ctx, namespace, "test-id", 3, 1, 1000)
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+
+	// The bitmap must be persisted compressed.
+	{
+		v, err := newStorage.Search(ctx, fmt.Sprintf(BitmapKeyFormat, namespace))
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		if !strings.HasPrefix(v, compressedBitmapPrefix) {
+			t.Fatal("expected", compressedBitmapPrefix, "got", v)
+		}
+	}
+
+	// Services not compressing bitmaps must still read compressed bitmaps.
+	{
+		s, err := NewWithOptions(newStorage, WithBitmap(true))
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		items, err := s.Create(ctx, namespace, "test-id", 1, 1, 1000)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		expected := []int{4}
+		if !reflect.DeepEqual(items, expected) {
+			t.Fatal("expected", expected, "got", items)
+		}
+	}
+}
+
+func Test_bitmap_NextUnset(t *testing.T) {
+	var m bitmap
+	for i := 0; i < 200; i++ {
+		if i != 70 && i != 199 {
+			m.Set(i)
+		}
+	}
+
+	testCases := []struct {
+		From     int
+		To       int
+		Expected int
+	}{
+		{
+			From:     0,
+			To:       300,
+			Expected: 70,
+		},
+		{
+			From:     70,
+			To:       70,
+			Expected: 70,
+		},
+		{
+			From:     71,
+			To:       198,
+			Expected: -1,
+		},
+		{
+			From:     71,
+			To:       300,
+			Expected: 199,
+		},
+		{
+			From:     200,
+			To:       300,
+			Expected: 200,
+		},
+		{
+			From:     5,
+			To:       4,
+			Expected: -1,
+		},
+	}
+
+	for i, tc := range testCases {
+		item := m.NextUnset(tc.From, tc.To)
+		if item != tc.Expected {
+			t.Fatal("case", i+1, "expected", tc.Expected, "got", item)
+		}
+	}
+}
+
+func Test_nextBitmapItem(t *testing.T) {
+	var used bitmap
+	used.Set(3)
+	used.Set(4)
+	used.Set(6)
+	var min int = 2
+	var max int = 9
+
+	testCases := []struct {
+		Latest       int
+		Expected     int
+		ErrorMatcher func(error) bool
+	}{
+		{
+			Latest:       0,
+			Expected:     0,
+			ErrorMatcher: IsExecutionFailed,
+		},
+		{
+			Latest:       -1,
+			Expected:     2,
+			ErrorMatcher: nil,
+		},
+		{
+			Latest:       2,
+			Expected:     5,
+			ErrorMatcher: nil,
+		},
+		{
+			Latest:       5,
+			Expected:     7,
+			ErrorMatcher: nil,
+		},
+		{
+			Latest:       8,
+			Expected:     9,
+			ErrorMatcher: nil,
+		},
+		{
+			Latest:       9,
+			Expected:     2,
+			ErrorMatcher: nil,
+		},
+		{
+			Latest:       10,
+			Expected:     0,
+			ErrorMatcher: IsExecutionFailed,
+		},
+	}
+
+	for i, tc := range testCases {
+		item, err := nextBitmapItem(used, min, max, tc.Latest)
+
+		if err != nil && tc.ErrorMatcher == nil {
+			t.Fatal("case", i+1, "expected", nil, "got", err)
+		}
+		if tc.ErrorMatcher != nil && !tc.ErrorMatcher(err) {
+			t.Fatal("case", i+1, "expected", true, "got", false)
+		}
+		if tc.Expected != item {
+			t.Fatal("case", i+1, "expected", tc.Expected, "got", item)
+		}
+	}
+
+	// Saturated ranges must result in a capacity reached error.
+	{
+		var full bitmap
+		for i := min; i <= max; i++ {
+			full.Set(i)
+		}
+
+		_, err := nextBitmapItem(full, min, max, 5)
+		if !IsCapacityReached(err) {
+			t.Fatal("expected", true, "got", false)
+		}
+	}
+}
+
+func Test_bitmap_PrevUnset(t *testing.T) {
+	var m bitmap
+	for i := 60; i < 130; i++ {
+		m.Set(i)
+	}
+	m.Unset(64)
+
+	testCases := []struct {
+		From     int
+		To       int
+		Expected int
+	}{
+		// Case 1 ensures the last free item below a full word boundary is
+		// found.
+		{
+			From:     0,
+			To:       129,
+			Expected: 64,
+		},
+		// Case 2 ensures items beyond the persisted words are free.
+		{
+			From:     0,
+			To:       200,
+			Expected: 200,
+		},
+		// Case 3 ensures items below from are not returned.
+		{
+			From:     65,
+			To:       129,
+			Expected: -1,
+		},
+		// Case 4 ensures the scan continues into lower words.
+		{
+			From:     0,
+			To:       63,
+			Expected: 59,
+		},
+	}
+
+	for i, tc := range testCases {
+		item := m.PrevUnset(tc.From, tc.To)
+		if item != tc.Expected {
+			t.Fatal("case", i+1, "expected", tc.Expected, "got", item)
+		}
+	}
+}
+
+func Test_prevBitmapItem(t *testing.T) {
+	var used bitmap
+	used.Set(3)
+	used.Set(4)
+	used.Set(6)
+	var min int = 2
+	var max int = 9
+
+	testCases := []struct {
+		Latest       int
+		Expected     int
+		ErrorMatcher func(error) bool
+	}{
+		{
+			Latest:       -1,
+			Expected:     9,
+			ErrorMatcher: nil,
+		},
+		{
+			Latest:       9,
+			Expected:     8,
+			ErrorMatcher: nil,
+		},
+		{
+			Latest:       7,
+			Expected:     5,
+			ErrorMatcher: nil,
+		},
+		{
+			Latest:       5,
+			Expected:     2,
+			ErrorMatcher: nil,
+		},
+		{
+			Latest:       2,
+			Expected:     9,
+			ErrorMatcher: nil,
+		},
+		{
+			Latest:       10,
+			Expected:     0,
+			ErrorMatcher: IsExecutionFailed,
+		},
+	}
+
+	for i, tc := range testCases {
+		item, err := prevBitmapItem(used, min, max, tc.Latest)
+
+		if err != nil && tc.ErrorMatcher == nil {
+			t.Fatal("case", i+1, "expected", nil, "got", err)
+		}
+		if tc.ErrorMatcher != nil && !tc.ErrorMatcher(err) {
+			t.Fatal("case", i+1, "expected", true, "got", false)
+		}
+		if tc.Expected != item {
+			t.Fatal("case", i+1, "expected", tc.Expected, "got", item)
+		}
+	}
+}
@@ -0,0 +1,252 @@
+package rangepool
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/giantswarm/microerror"
+)
+
+// BlockPoolConfig represents the configuration used to create a new block
+// pool.
+type BlockPoolConfig struct {
+	// Dependencies.
+	Service *Service
+
+	// Settings.
+
+	// BlockSize is the number of items of every block delegated from the
+	// parent namespace to the block pool. It limits the number of items a
+	// single allocation may request.
+	BlockSize int
+	// Max is the max boundary of the range of the parent namespace, inclusive.
+	Max int
+	// Min is the min boundary of the range of the parent namespace, inclusive.
+	// The size of the range must be a multiple of BlockSize.
+	Min int
+	// Namespace is the local namespace the block pool allocates items in. It
+	// is also the ID the delegated blocks are owned by within the parent
+	// namespace, so it must be unique among all block pools of the parent.
+	Namespace string
+	// Parent is the namespace the blocks are delegated from.
+	Parent string
+}
+
+// DefaultBlockPoolConfig provides a default configuration to create a new
+// block pool by best effort.
+func DefaultBlockPoolConfig() BlockPoolConfig {
+	return BlockPoolConfig{
+		// Dependencies.
+		Service: nil,
+
+		// Settings.
+		BlockSize: 0,
+		Max:       0,
+		Min:       0,
+		Namespace: "",
+		Parent:    "",
+	}
+}
+
+// NewBlockPool creates a new configured block pool.
+func NewBlockPool(config BlockPoolConfig) (*BlockPool, error) {
+	// Dependencies.
+	if config.Service == nil {
+		return nil, microerror.Maskf(invalidConfigError, "service must not be empty")
+	}
+
+	// Settings.
+	if config.BlockSize < 2 {
+		return nil, microerror.Maskf(invalidConfigError, "block size must be at least 2")
+	}
+	if config.Min < 0 || config.Max < config.Min {
+		return nil, microerror.Maskf(invalidConfigError, "range must define 0 <= min <= max")
+	}
+	if (config.Max-config.Min+1)%config.BlockSize != 0 {
+		return nil, microerror.Maskf(invalidConfigError, "range size must be a multiple of block size")
+	}
+	if (config.Max-config.Min+1)/config.BlockSize < 2 {
+		return nil, microerror.Maskf(invalidConfigError, "range must span at least 2 blocks")
+	}
+	if config.Namespace == "" || strings.Contains(config.Namespace, "/") {
+		return nil, microerror.Maskf(invalidConfigError, "namespace must not be empty and must not contain slashes")
+	}
+	if config.Parent == "" || config.Parent == config.Namespace {
+		return nil, microerror.Maskf(invalidConfigError, "parent must not be empty and must differ from namespace")
+	}
+
+	// Items are always allocated from the lowest free item of a block, since
+	// a single latest item cannot be shared across blocks.
+	local := *config.Service
+	local.latestMode = LatestModeLowestFree
+
+	p := &BlockPool{
+		// Dependencies.
+		local:  &local,
+		parent: config.Service,
+
+		// Internals.
+		mutex: sync.Mutex{},
+
+		// Settings.
+		blockSize:       config.BlockSize,
+		max:             config.Max,
+		min:             config.Min,
+		namespace:       config.Namespace,
+		parentNamespace: config.Parent,
+	}
+
+	return p, nil
+}
+
+// BlockPool allocates items locally from blocks delegated by a parent
+// namespace. The parent hands out blocks of BlockPoolConfig.BlockSize items on
+// demand and takes them back once they are empty, so that many controllers
+// sharing one range only contend on the parent namespace when they run out of
+// blocks. Every block is identified by its index within the parent namespace,
+// so block b covers the items min+b*size up to min+(b+1)*size-1. A BlockPool
+// is meant to be used by a single process.
+type BlockPool struct {
+	// Dependencies.
+	local  *Service
+	parent *Service
+
+	// Internals.
+	mutex sync.Mutex
+
+	// Settings.
+	blockSize       int
+	max             int
+	min             int
+	namespace       string
+	parentNamespace string
+}
+
+// Create allocates num items for the given ID within a single block. In case
+// none of the blocks of the pool has enough free items, a new block is
+// delegated from the parent namespace. In case the parent namespace has no
+// free blocks left, an error is returned which can be asserted using
+// IsCapacityReached.
+func (p *BlockPool) Create(ctx context.Context, ID string, num int) ([]int, error) {
+	if num < 1 || num > p.blockSize {
+		return nil, microerror.Maskf(invalidArgumentError, "num must be in between 1 and the block size %d", p.blockSize)
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	blocks, err := p.Blocks(ctx)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	for _, b := range blocks {
+		items, err := p.createInBlock(ctx, ID, num, b)
+		if IsCapacityReached(err) {
+			continue
+		} else if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		return items, nil
+	}
+
+	l, err := p.parent.Create(ctx, p.parentNamespace, p.namespace, 1, 0, (p.max-p.min+1)/p.blockSize-1)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	items, err := p.createInBlock(ctx, ID, num, l[0])
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return items, nil
+}
+
+// Delete releases the items of the given ID. Blocks running empty are handed
+// back to the parent namespace.
+func (p *BlockPool) Delete(ctx context.Context, ID string) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	items, err := p.local.Search(ctx, p.namespace, ID)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = p.local.Delete(ctx, p.namespace, ID)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	released := map[int]bool{}
+	for _, item := range items {
+		b := (item - p.min) / p.blockSize
+		if released[b] {
+			continue
+		}
+		released[b] = true
+
+		min, max := p.blockRange(b)
+		st, err := p.local.Status(ctx, p.namespace, min, max)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+		if st.Used != 0 {
+			continue
+		}
+
+		err = p.parent.ForceRelease(ctx, p.parentNamespace, b)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	return nil
+}
+
+// Blocks returns the indexes of the blocks currently delegated to the pool in
+// ascending order.
+func (p *BlockPool) Blocks(ctx context.Context) ([]int, error) {
+	blocks, err := p.parent.Search(ctx, p.parentNamespace, p.namespace)
+	if IsItemsNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	sort.Ints(blocks)
+
+	return blocks, nil
+}
+
+// Search returns the items of the given ID. In case the ID does not own any
+// items, an error is returned which can be asserted using IsItemsNotFound.
+func (p *BlockPool) Search(ctx context.Context, ID string) ([]int, error) {
+	items, err := p.local.Search(ctx, p.namespace, ID)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return items, nil
+}
+
+// blockRange returns the boundaries of the given block, both inclusive.
+func (p *BlockPool) blockRange(block int) (int, int) {
+	min := p.min + block*p.blockSize
+	return min, min + p.blockSize - 1
+}
+
+func (p *BlockPool) createInBlock(ctx context.Context, ID string, num, block int) ([]int, error) {
+	min, max := p.blockRange(block)
+
+	items, err := p.local.Create(ctx, p.namespace, ID, num, min, max)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return items, nil
+}
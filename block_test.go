@@ -0,0 +1,112 @@
+package rangepool
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/giantswarm/micrologger/microloggertest"
+)
+
+func Test_BlockPool(t *testing.T) {
+	var newService *Service
+	{
+		newStorage, err := newMemoryStorage()
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		config := DefaultConfig()
+		config.Logger = microloggertest.New()
+		config.Storage = newStorage
+		newService, err = New(config)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+	}
+
+	newBlockPool := func(namespace string) *BlockPool {
+		c := DefaultBlockPoolConfig()
+		c.Service = newService
+		c.BlockSize = 4
+		c.Min = 100
+		c.Max = 111
+		c.Namespace = namespace
+		c.Parent = "parent"
+		p, err := NewBlockPool(c)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		return p
+	}
+
+	ctx := context.TODO()
+
+	p1 := newBlockPool("child-1")
+	p2 := newBlockPool("child-2")
+
+	create := func(p *BlockPool, ID string, num int, expected []int) {
+		items, err := p.Create(ctx, ID, num)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		if !reflect.DeepEqual(items, expected) {
+			t.Fatal("expected", expected, "got", items)
+		}
+	}
+	blocks := func(p *BlockPool, expected []int) {
+		l, err := p.Blocks(ctx)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		if !reflect.DeepEqual(l, expected) {
+			t.Fatal("expected", expected, "got", l)
+		}
+	}
+
+	// Allocations must not exceed a single block.
+	{
+		_, err := p1.Create(ctx, "test-id-1", 5)
+		if !IsInvalidArgument(err) {
+			t.Fatal("expected", true, "got", false)
+		}
+	}
+
+	// Every pool must get its own blocks and allocate within them.
+	create(p1, "test-id-1", 3, []int{100, 101, 102})
+	create(p2, "test-id-2", 1, []int{104})
+	create(p1, "test-id-3", 1, []int{103})
+	blocks(p1, []int{0})
+	blocks(p2, []int{1})
+
+	// Allocations not fitting into the current blocks must get a new block.
+	create(p1, "test-id-4", 2, []int{108, 109})
+	blocks(p1, []int{0, 2})
+
+	// The parent must refuse blocks once all of them are delegated.
+	{
+		_, err := p2.Create(ctx, "test-id-5", 4)
+		if !IsCapacityReached(err) {
+			t.Fatal("expected", true, "got", false)
+		}
+	}
+
+	// Blocks running empty must be handed back to the parent.
+	{
+		err := p1.Delete(ctx, "test-id-4")
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		blocks(p1, []int{0})
+
+		err = p1.Delete(ctx, "test-id-1")
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		blocks(p1, []int{0})
+	}
+
+	create(p2, "test-id-5", 4, []int{108, 109, 110, 111})
+	blocks(p2, []int{1, 2})
+}
@@ -0,0 +1,203 @@
+package rangepool
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+)
+
+// breakerStorage fast-fails operations of the given Storage once it failed a
+// number of times in a row, so that callers shed load instead of piling up
+// timeouts during storage outages. After the configured timeout a single probe
+// operation is let through. The breaker closes again in case the probe
+// succeeds, otherwise it stays open for another timeout. Errors asserted by
+// IsNotFound are part of the Storage contract and never count as failures.
+type breakerStorage struct {
+	// Dependencies.
+	clock   Clock
+	logger  micrologger.Logger
+	storage Storage
+
+	// Internals.
+	failures int
+	mutex    sync.Mutex
+	openedAt time.Time
+	probing  bool
+
+	// Settings.
+	threshold int
+	timeout   time.Duration
+}
+
+func (b *breakerStorage) Apply(ctx context.Context, kvs []KV, keys []string) error {
+	err := b.call(ctx, func() error {
+		return apply(ctx, b.storage, kvs, keys)
+	})
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+func (b *breakerStorage) Create(ctx context.Context, key, value string) error {
+	err := b.call(ctx, func() error {
+		return b.storage.Create(ctx, key, value)
+	})
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+func (b *breakerStorage) CreateBatch(ctx context.Context, kvs []KV) error {
+	err := b.call(ctx, func() error {
+		return createBatch(ctx, b.storage, kvs)
+	})
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+func (b *breakerStorage) Delete(ctx context.Context, key string) error {
+	err := b.call(ctx, func() error {
+		return b.storage.Delete(ctx, key)
+	})
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+func (b *breakerStorage) DeleteBatch(ctx context.Context, keys []string) error {
+	err := b.call(ctx, func() error {
+		return deleteBatch(ctx, b.storage, keys)
+	})
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+func (b *breakerStorage) List(ctx context.Context, key string) ([]KV, error) {
+	var kvs []KV
+	err := b.call(ctx, func() error {
+		var err error
+		kvs, err = b.storage.List(ctx, key)
+		return err
+	})
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return kvs, nil
+}
+
+func (b *breakerStorage) Search(ctx context.Context, key string) (string, error) {
+	var v string
+	err := b.call(ctx, func() error {
+		var err error
+		v, err = b.storage.Search(ctx, key)
+		return err
+	})
+	if err != nil {
+		return "", microerror.Mask(err)
+	}
+
+	return v, nil
+}
+
+// Walk only counts errors of the storage as failures. Errors returned by fn,
+// e.g. to stop walking early, are passed through.
+func (b *breakerStorage) Walk(ctx context.Context, key string, fn func(kv KV) error) error {
+	var fnErr error
+	err := b.call(ctx, func() error {
+		err := walk(ctx, b.storage, key, func(kv KV) error {
+			fnErr = fn(kv)
+			return fnErr
+		})
+		if fnErr != nil {
+			return nil
+		}
+		return err
+	})
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	if fnErr != nil {
+		return microerror.Mask(fnErr)
+	}
+
+	return nil
+}
+
+// call executes o in case the breaker is closed or o is the probe of the half
+// open breaker. Otherwise an error is returned which can be asserted using
+// IsCircuitOpen.
+func (b *breakerStorage) call(ctx context.Context, o func() error) error {
+	err := b.allow()
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = o()
+	b.record(ctx, err)
+
+	return err
+}
+
+// allow checks whether an operation may be executed, which turns the breaker
+// half open once the timeout passed.
+func (b *breakerStorage) allow() error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.failures < b.threshold {
+		return nil
+	}
+
+	until := b.openedAt.Add(b.timeout)
+	if b.probing || b.clock.Now().Before(until) {
+		return microerror.Maskf(circuitOpenError, "storage failed %d times in a row, retry after %s", b.failures, until.UTC().Format(time.RFC3339))
+	}
+
+	b.probing = true
+
+	return nil
+}
+
+// record tracks the outcome of an operation. Errors caused by the caller, like
+// cancelled contexts, do not count as failures.
+func (b *breakerStorage) record(ctx context.Context, err error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	probe := b.probing
+	b.probing = false
+
+	if err == nil || IsNotFound(err) || IsConflict(err) {
+		if b.failures >= b.threshold {
+			b.logger.LogCtx(ctx, "level", "info", "message", "closed storage circuit breaker")
+		}
+		b.failures = 0
+		return
+	}
+	if ctx.Err() != nil {
+		return
+	}
+
+	b.failures++
+	if probe || b.failures == b.threshold {
+		b.openedAt = b.clock.Now()
+		b.logger.LogCtx(ctx, "level", "warning", "message", fmt.Sprintf("opened storage circuit breaker for %s after %d failures in a row", b.timeout, b.failures), "stack", fmt.Sprintf("%#v", err))
+	}
+}
@@ -0,0 +1,93 @@
+package rangepool
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/giantswarm/micrologger/microloggertest"
+)
+
+func Test_Service_Breaker(t *testing.T) {
+	s, err := newMemoryStorage()
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+	newStorage := &testFlakyStorage{Storage: s, Failures: 3}
+
+	clock := &testClock{now: time.Unix(0, 0)}
+
+	var newService *Service
+	{
+		config := DefaultConfig()
+		config.Clock = clock
+		config.Logger = microloggertest.New()
+		config.Storage = newStorage
+		config.BreakerThreshold = 2
+		config.BreakerTimeout = time.Minute
+		newService, err = New(config)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+	}
+
+	ctx := context.TODO()
+
+	// Storage errors must be returned until the threshold is reached.
+	for i := 0; i < 2; i++ {
+		_, err := newService.Create(ctx, namespace, "test-id", 1, 2, 3)
+		if !IsExecutionFailed(err) {
+			t.Fatal("expected", true, "got", false)
+		}
+	}
+
+	// The open breaker must fail fast without calling the storage.
+	{
+		_, err := newService.Create(ctx, namespace, "test-id", 1, 2, 3)
+		if !IsCircuitOpen(err) {
+			t.Fatal("expected", true, "got", false)
+		}
+		if newStorage.Failures != 1 {
+			t.Fatal("expected", 1, "got", newStorage.Failures)
+		}
+	}
+
+	// A failing probe must keep the breaker open for another timeout.
+	{
+		clock.now = clock.now.Add(time.Minute)
+
+		_, err := newService.Create(ctx, namespace, "test-id", 1, 2, 3)
+		if !IsExecutionFailed(err) {
+			t.Fatal("expected", true, "got", false)
+		}
+
+		_, err = newService.Create(ctx, namespace, "test-id", 1, 2, 3)
+		if !IsCircuitOpen(err) {
+			t.Fatal("expected", true, "got", false)
+		}
+	}
+
+	// A succeeding probe must close the breaker again.
+	{
+		clock.now = clock.now.Add(time.Minute)
+
+		_, err := newService.Create(ctx, namespace, "test-id", 1, 2, 3)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		_, err = newService.Search(ctx, namespace, "test-id")
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+	}
+}
+
+// testClock is a Clock returning a fixed time, which tests move forward.
+type testClock struct {
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	return c.now
+}
@@ -0,0 +1,69 @@
+package rangepool
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/giantswarm/microerror"
+)
+
+// Burn permanently retires the given items of the given namespace, e.g. items
+// involved in security incidents or protocol conflicts. Burned items are never
+// handed out again, even when they are free. Items which are currently used
+// stay allocated to their IDs until they are released. Burning cannot be
+// undone.
+func (s *Service) Burn(ctx context.Context, namespace string, items []int) error {
+	ctx = withOperation(ctx, "Burn", namespace, "")
+
+	err := s.checkReadOnly()
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = s.checkLeader()
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	var kvs []KV
+	for _, item := range items {
+		if item < 0 {
+			return microerror.Maskf(invalidArgumentError, "item %d must not be negative", item)
+		}
+
+		kvs = append(kvs, KV{Key: s.key(BurnedKeyFormat, namespace, s.encodeItem(item)), Value: strconv.Itoa(item)})
+	}
+
+	if len(kvs) == 0 {
+		return nil
+	}
+
+	err = createBatch(ctx, s.storage, kvs)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	s.burned.Add(namespace, items)
+
+	s.logger.LogCtx(ctx, "level", "info", "message", fmt.Sprintf("burned items %v of namespace '%s'", items, namespace))
+
+	return nil
+}
+
+// searchBurned fetches the burned items of the given namespace, see
+// Service.Burn. The burned items are cached in case Config.BurnedCacheTTL is
+// greater than zero.
+func (s *Service) searchBurned(ctx context.Context, namespace string) ([]int, error) {
+	burned, ok := s.burned.Get(namespace)
+	if ok {
+		return burned, nil
+	}
+
+	burned, err := s.searchItems(ctx, s.key(BurnedListKeyFormat, namespace))
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+	s.burned.Set(namespace, burned)
+
+	return burned, nil
+}
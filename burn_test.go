@@ -0,0 +1,175 @@
+package rangepool
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/giantswarm/micrologger/microloggertest"
+)
+
+func Test_Service_Burn(t *testing.T) {
+	for _, b := range []bool{false, true} {
+		var err error
+		var newService *Service
+		{
+			newStorage, err := newMemoryStorage()
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+
+			config := DefaultConfig()
+			config.Logger = microloggertest.New()
+			config.Storage = newStorage
+			config.Bitmap = b
+			config.LatestMode = LatestModeLowestFree
+			newService, err = New(config)
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+		}
+
+		ctx := context.TODO()
+
+		_, err = newService.Create(ctx, namespace, "test-id-1", 2, 2, 6)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		// Burning a used item must not release it, but prevent it from being
+		// reused after its release.
+		{
+			err = newService.Burn(ctx, namespace, []int{3, 5})
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+
+			items, err := newService.Search(ctx, namespace, "test-id-1")
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+			expected := []int{2, 3}
+			if !reflect.DeepEqual(items, expected) {
+				t.Fatal("expected", expected, "got", items)
+			}
+
+			err = newService.Delete(ctx, namespace, "test-id-1")
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+		}
+
+		// Burned items must never be handed out.
+		{
+			items, err := newService.Create(ctx, namespace, "test-id-2", 3, 2, 6)
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+			expected := []int{2, 4, 6}
+			if !reflect.DeepEqual(items, expected) {
+				t.Fatal("expected", expected, "got", items)
+			}
+
+			_, err = newService.Create(ctx, namespace, "test-id-3", 1, 2, 6)
+			if !IsCapacityReached(err) {
+				t.Fatal("expected", true, "got", false)
+			}
+		}
+
+		// Burned items must not be persisted as used.
+		{
+			d, err := newService.Dump(ctx, namespace)
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+			expected := []int{2, 4, 6}
+			if !reflect.DeepEqual(d.Used, expected) {
+				t.Fatal("expected", expected, "got", d.Used)
+			}
+		}
+
+		// Negative items must be rejected.
+		{
+			err = newService.Burn(ctx, namespace, []int{-1})
+			if !IsInvalidArgument(err) {
+				t.Fatal("expected", true, "got", false)
+			}
+		}
+	}
+}
+
+func Test_Service_Burn_Cache(t *testing.T) {
+	var err error
+
+	clock := &testClock{now: time.Unix(0, 0)}
+
+	var newService *Service
+	var otherService *Service
+	{
+		newStorage, err := newMemoryStorage()
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		config := DefaultConfig()
+		config.Clock = clock
+		config.Logger = microloggertest.New()
+		config.Storage = newStorage
+		config.BurnedCacheTTL = time.Minute
+		config.LatestMode = LatestModeLowestFree
+		newService, err = New(config)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		otherService, err = New(config)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+	}
+
+	ctx := context.TODO()
+
+	_, err = newService.Create(ctx, namespace, "test-id-1", 1, 2, 6)
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+
+	// Items burned by the Service itself must never be handed out, even though
+	// its burned items are cached.
+	{
+		err = newService.Burn(ctx, namespace, []int{3})
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		items, err := newService.Create(ctx, namespace, "test-id-2", 1, 2, 6)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		expected := []int{4}
+		if !reflect.DeepEqual(items, expected) {
+			t.Fatal("expected", expected, "got", items)
+		}
+	}
+
+	// Items burned by other Services must not be handed out once the cached
+	// burned items expired.
+	{
+		err = otherService.Burn(ctx, namespace, []int{5})
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		clock.now = clock.now.Add(2 * time.Minute)
+
+		items, err := newService.Create(ctx, namespace, "test-id-3", 1, 2, 6)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		expected := []int{6}
+		if !reflect.DeepEqual(items, expected) {
+			t.Fatal("expected", expected, "got", items)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package rangepool
+
+import (
+	"sync"
+	"time"
+)
+
+// usedCache caches the used items of namespaces for a limited amount of time.
+// All methods are safe to be called on a nil cache, which disables caching.
+type usedCache struct {
+	clock   Clock
+	entries map[string]usedCacheEntry
+	mutex   sync.Mutex
+	ttl     time.Duration
+}
+
+type usedCacheEntry struct {
+	created time.Time
+	used    map[int]struct{}
+}
+
+func newUsedCache(clock Clock, ttl time.Duration) *usedCache {
+	if ttl <= 0 {
+		return nil
+	}
+
+	c := &usedCache{
+		clock:   clock,
+		entries: map[string]usedCacheEntry{},
+		mutex:   sync.Mutex{},
+		ttl:     ttl,
+	}
+
+	return c
+}
+
+// Add adds the given items to the cached items of the namespace, in case the
+// namespace is cached.
+func (c *usedCache) Add(namespace string, items []int) {
+	if c == nil {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	e, ok := c.entries[namespace]
+	if !ok {
+		return
+	}
+	for _, i := range items {
+		e.used[i] = struct{}{}
+	}
+}
+
+// Get returns a copy of the cached items of the namespace. The second return
+// value is false in case the namespace is not cached or its entry expired.
+func (c *usedCache) Get(namespace string) ([]int, bool) {
+	if c == nil {
+		return nil, false
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	e, ok := c.entries[namespace]
+	if !ok {
+		return nil, false
+	}
+	if c.clock.Now().Sub(e.created) > c.ttl {
+		delete(c.entries, namespace)
+		return nil, false
+	}
+
+	used := make([]int, 0, len(e.used))
+	for i := range e.used {
+		used = append(used, i)
+	}
+
+	return used, true
+}
+
+// Invalidate drops the cached items of the namespace.
+func (c *usedCache) Invalidate(namespace string) {
+	if c == nil {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	delete(c.entries, namespace)
+}
+
+// Remove removes the given items from the cached items of the namespace, in
+// case the namespace is cached.
+func (c *usedCache) Remove(namespace string, items []int) {
+	if c == nil {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	e, ok := c.entries[namespace]
+	if !ok {
+		return
+	}
+	for _, i := range items {
+		delete(e.used, i)
+	}
+}
+
+// Set caches the given items of the namespace.
+func (c *usedCache) Set(namespace string, used []int) {
+	if c == nil {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	e := usedCacheEntry{
+		created: c.clock.Now(),
+		used:    map[int]struct{}{},
+	}
+	for _, i := range used {
+		e.used[i] = struct{}{}
+	}
+
+	c.entries[namespace] = e
+}
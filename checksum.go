@@ -0,0 +1,138 @@
+package rangepool
+
+import (
+	"context"
+	"fmt"
+	"hash/crc32"
+	"strconv"
+	"strings"
+
+	"github.com/giantswarm/microerror"
+)
+
+// checksumSeparator separates values describing the aggregate state of a
+// namespace from their checksum, see Config.Checksums. It is neither part of
+// encoded bitmaps nor of items.
+const checksumSeparator = "#"
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// encodeLatest encodes the given latest item so that it can be persisted, see
+// Config.Checksums.
+func (s *Service) encodeLatest(latest int) string {
+	return s.withChecksum(strconv.Itoa(latest))
+}
+
+// withChecksum appends the checksum of the given value in case
+// Config.Checksums is set.
+func (s *Service) withChecksum(value string) string {
+	if !s.checksums {
+		return value
+	}
+
+	return value + checksumSeparator + checksum(value)
+}
+
+// checksum returns the CRC-32C of the given value in hexadecimal form.
+func checksum(value string) string {
+	return fmt.Sprintf("%08x", crc32.Checksum([]byte(value), castagnoli))
+}
+
+// verifyChecksum strips the checksum from the given value of the given key
+// and returns an error which can be asserted using IsCorrupted in case it does
+// not match the value. Values without checksum are refused as well in case
+// required is set, see Config.Checksums, since they could have been written by
+// anybody. Otherwise they are returned as they are.
+func verifyChecksum(key, value string, required bool) (string, error) {
+	i := strings.LastIndex(value, checksumSeparator)
+	if i == -1 {
+		if required {
+			return "", microerror.Maskf(corruptedError, "value of key '%s' has no checksum, see Service.MigrateChecksums", key)
+		}
+
+		return value, nil
+	}
+
+	v, sum := value[:i], value[i+1:]
+	if checksum(v) != sum {
+		return "", microerror.Maskf(corruptedError, "checksum of key '%s' does not match its value", key)
+	}
+
+	return v, nil
+}
+
+// MigrateChecksums appends checksums to the bitmap and the latest items of the
+// given namespace which were persisted without, see Config.Checksums. It must
+// be executed once for namespaces which already hold items after enabling
+// checksums, since values without checksum are refused afterwards. The current
+// values are trusted, so they should be verified beforehand. Values which
+// carry a checksum already are left untouched. MigrateChecksums must not be
+// executed concurrently with other operations on the same namespace.
+func (s *Service) MigrateChecksums(ctx context.Context, namespace string) error {
+	ctx = withOperation(ctx, "MigrateChecksums", namespace, "")
+
+	if !s.checksums {
+		return microerror.Maskf(invalidConfigError, "checksums must be enabled")
+	}
+
+	err := s.checkReadOnly()
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = s.checkLeader()
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	s, err = s.withPolicy(ctx, namespace)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	// The latest items of IDs are migrated no matter the latest mode, since
+	// the mode might change again, see LatestModePerID.
+	keys := append([]string{s.key(BitmapKeyFormat, namespace)}, s.latestKeys(namespace)...)
+	{
+		prefix := s.key(IDPrefixKeyFormat, namespace)
+		err := walk(ctx, s.storage, prefix, func(kv KV) error {
+			// The keys are relative to the ID prefix, e.g. ${id1}/latest.
+			if strings.HasSuffix(kv.Key, "/latest") {
+				keys = append(keys, prefix+"/"+kv.Key)
+			}
+			return nil
+		})
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	var kvs []KV
+	for _, k := range keys {
+		v, err := s.storage.Search(ctx, k)
+		if IsNotFound(err) {
+			continue
+		} else if err != nil {
+			return microerror.Mask(err)
+		}
+
+		if strings.Contains(v, checksumSeparator) {
+			continue
+		}
+
+		kvs = append(kvs, KV{Key: k, Value: s.withChecksum(v)})
+	}
+
+	if len(kvs) == 0 {
+		return nil
+	}
+
+	err = createBatch(ctx, s.storage, kvs)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	s.logger.LogCtx(ctx, "level", "debug", "message", fmt.Sprintf("migrated checksums of %d keys of namespace '%s'", len(kvs), namespace))
+
+	return nil
+}
@@ -0,0 +1,226 @@
+package rangepool
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_Service_Checksums(t *testing.T) {
+	newStorage, err := newMemoryStorage()
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+
+	ctx := context.TODO()
+
+	var newService *Service
+	{
+		newService, err = NewWithOptions(newStorage, WithBitmap(true), WithChecksums(true))
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+	}
+
+	_, err = newService.Create(ctx, namespace, "test-id", 3, 1, 100)
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+
+	bitmapKey := fmt.Sprintf(BitmapKeyFormat, namespace)
+	latestKey := fmt.Sprintf(LatestKeyFormat, namespace)
+
+	// The aggregate state must be persisted with checksum and stay readable.
+	{
+		for _, key := range []string{bitmapKey, latestKey} {
+			v, err := newStorage.Search(ctx, key)
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+			if !strings.Contains(v, checksumSeparator) {
+				t.Fatal("expected", checksumSeparator, "got", v)
+			}
+		}
+
+		items, err := newService.Create(ctx, namespace, "test-id", 1, 1, 100)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		expected := []int{4}
+		if !reflect.DeepEqual(items, expected) {
+			t.Fatal("expected", expected, "got", items)
+		}
+	}
+
+	// Manually edited values must be refused.
+	{
+		v, err := newStorage.Search(ctx, latestKey)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		err = newStorage.Create(ctx, latestKey, "1"+v[1:])
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		_, err = newService.Create(ctx, namespace, "test-id", 1, 1, 100)
+		if !IsCorrupted(err) {
+			t.Fatal("expected", true, "got", err)
+		}
+	}
+
+	// Truncated values must be refused.
+	{
+		v, err := newStorage.Search(ctx, bitmapKey)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		err = newStorage.Create(ctx, bitmapKey, v[:len(v)-1])
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		_, err = newService.Create(ctx, namespace, "test-id", 1, 1, 100)
+		if !IsCorrupted(err) {
+			t.Fatal("expected", true, "got", err)
+		}
+	}
+}
+
+func Test_Service_MigrateChecksums(t *testing.T) {
+	newStorage, err := newMemoryStorage()
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+
+	ctx := context.TODO()
+
+	// Values persisted before checksums were enabled have no checksum.
+	{
+		plain, err := NewWithOptions(newStorage, WithBitmap(true))
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		_, err = plain.Create(ctx, namespace, "test-id", 3, 1, 100)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+	}
+
+	var newService *Service
+	{
+		newService, err = NewWithOptions(newStorage, WithBitmap(true), WithChecksums(true))
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+	}
+
+	// Values without checksum must be refused until they are migrated.
+	{
+		_, err = newService.Create(ctx, namespace, "test-id", 1, 1, 100)
+		if !IsCorrupted(err) {
+			t.Fatal("expected", true, "got", err)
+		}
+	}
+
+	// Migrated values must be readable.
+	{
+		err = newService.MigrateChecksums(ctx, namespace)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		items, err := newService.Create(ctx, namespace, "test-id", 1, 1, 100)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		expected := []int{4}
+		if !reflect.DeepEqual(items, expected) {
+			t.Fatal("expected", expected, "got", items)
+		}
+	}
+
+	// Values replaced without checksum after the migration must be refused.
+	{
+		err = newStorage.Create(ctx, fmt.Sprintf(LatestKeyFormat, namespace), "1")
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		_, err = newService.Create(ctx, namespace, "test-id", 1, 1, 100)
+		if !IsCorrupted(err) {
+			t.Fatal("expected", true, "got", err)
+		}
+	}
+}
+
+func Test_verifyChecksum(t *testing.T) {
+	testCases := []struct {
+		Value         string
+		Required      bool
+		ExpectedValue string
+		ErrorMatcher  func(error) bool
+	}{
+		// Case 1 ensures values without checksum are read as they are in case
+		// checksums are not required.
+		{
+			Value:         "42",
+			Required:      false,
+			ExpectedValue: "42",
+			ErrorMatcher:  nil,
+		},
+		// Case 2 ensures the checksum is stripped from valid values.
+		{
+			Value:         "42" + checksumSeparator + checksum("42"),
+			Required:      true,
+			ExpectedValue: "42",
+			ErrorMatcher:  nil,
+		},
+		// Case 3 ensures edited values are refused.
+		{
+			Value:         "43" + checksumSeparator + checksum("42"),
+			Required:      true,
+			ExpectedValue: "",
+			ErrorMatcher:  IsCorrupted,
+		},
+		// Case 4 ensures truncated checksums are refused.
+		{
+			Value:         "42" + checksumSeparator,
+			Required:      true,
+			ExpectedValue: "",
+			ErrorMatcher:  IsCorrupted,
+		},
+		// Case 5 ensures values without checksum are refused in case checksums
+		// are required.
+		{
+			Value:         "42",
+			Required:      true,
+			ExpectedValue: "",
+			ErrorMatcher:  IsCorrupted,
+		},
+		// Case 6 ensures checksums are verified even if they are not required.
+		{
+			Value:         "43" + checksumSeparator + checksum("42"),
+			Required:      false,
+			ExpectedValue: "",
+			ErrorMatcher:  IsCorrupted,
+		},
+	}
+
+	for i, tc := range testCases {
+		v, err := verifyChecksum("key", tc.Value, tc.Required)
+		if tc.ErrorMatcher != nil {
+			if !tc.ErrorMatcher(err) {
+				t.Fatal("case", i+1, "expected", true, "got", false)
+			}
+		} else if err != nil {
+			t.Fatal("case", i+1, "expected", nil, "got", err)
+		}
+		if v != tc.ExpectedValue {
+			t.Fatal("case", i+1, "expected", tc.ExpectedValue, "got", v)
+		}
+	}
+}
@@ -0,0 +1,22 @@
+package rangepool
+
+import (
+	"time"
+)
+
+// Clock provides the current time to all time based features, i.e. leases,
+// cooldowns, audit entries, allocations, reservations and the cache. It can be
+// replaced in tests, so that expiry logic is deterministic, see
+// rangepooltest.Clock.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+}
+
+// realClock is the clock used by DefaultConfig. It returns the wall clock
+// time.
+type realClock struct{}
+
+func (c realClock) Now() time.Time {
+	return time.Now()
+}
@@ -0,0 +1,111 @@
+package rangepool
+
+import (
+	"context"
+	"sync"
+
+	"github.com/giantswarm/microerror"
+)
+
+// closer tracks the background goroutines of a Service, so that they can be
+// stopped on Service.Close. It is shared by all copies of the Service.
+type closer struct {
+	closed bool
+	done   chan struct{}
+	group  sync.WaitGroup
+	mutex  sync.Mutex
+}
+
+func newCloser() *closer {
+	c := &closer{
+		done: make(chan struct{}),
+	}
+
+	return c
+}
+
+// add registers a new background goroutine. It returns false in case the
+// closer is closed already.
+func (c *closer) add() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.closed {
+		return false
+	}
+	c.group.Add(1)
+
+	return true
+}
+
+// close signals all background goroutines to stop. Goroutines cannot be
+// registered anymore afterwards.
+func (c *closer) close() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if !c.closed {
+		c.closed = true
+		close(c.done)
+	}
+}
+
+// Close stops the background goroutines started by the Service, e.g. the
+// ones emitting the events of Service.Watch, waits for them to return, and
+// releases the lease of Config.Elector in case it is held. Writes are never
+// deferred, so there are no pending writes to flush. Operations which start
+// background goroutines fail with an error asserted by IsClosed afterwards,
+// all other operations keep working. Closing the Service cannot be undone.
+// Close returns once the given context is done, even in case goroutines are
+// still running.
+func (s *Service) Close(ctx context.Context) error {
+	s.closer.close()
+
+	stopped := make(chan struct{})
+	go func() {
+		s.closer.group.Wait()
+		close(stopped)
+	}()
+
+	select {
+	case <-ctx.Done():
+		return microerror.Mask(ctx.Err())
+	case <-stopped:
+	}
+
+	if s.elector != nil {
+		err := s.elector.Close(ctx)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	return nil
+}
+
+// goContext returns a context derived from the given context which is
+// cancelled once the Service is closed. The returned function must be called
+// from the background goroutine using the context once it returns. In case
+// the Service is closed already, an error is returned which can be asserted
+// using IsClosed.
+func (s *Service) goContext(ctx context.Context) (context.Context, func(), error) {
+	if !s.closer.add() {
+		return nil, nil, microerror.Maskf(closedError, "service is closed")
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-s.closer.done:
+			cancel()
+		}
+	}()
+
+	done := func() {
+		cancel()
+		s.closer.group.Done()
+	}
+
+	return ctx, done, nil
+}
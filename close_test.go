@@ -0,0 +1,126 @@
+package rangepool
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/giantswarm/micrologger/microloggertest"
+)
+
+func Test_Service_Close(t *testing.T) {
+	s, err := newMemoryStorage()
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+	newStorage := &testSwapStorage{Storage: s}
+
+	var elector *Elector
+	{
+		c := DefaultElectorConfig()
+		c.Identity = "replica-1"
+		c.Logger = microloggertest.New()
+		c.Storage = newStorage
+		elector, err = NewElector(c)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+	}
+
+	var newService *Service
+	{
+		config := DefaultConfig()
+		config.Elector = elector
+		config.Logger = microloggertest.New()
+		config.Storage = newStorage
+		config.WatchInterval = time.Millisecond
+		newService, err = New(config)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+	}
+
+	ctx := context.TODO()
+
+	ran := make(chan error, 1)
+	go func() {
+		ran <- elector.Run(ctx)
+	}()
+
+	for !elector.IsLeader() {
+		time.Sleep(time.Millisecond)
+	}
+
+	events, err := newService.Watch(ctx, namespace)
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+
+	err = newService.Close(ctx)
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+
+	// Closing must stop the watchers.
+	{
+		select {
+		case _, ok := <-events:
+			if ok {
+				t.Fatal("expected", false, "got", true)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("expected", "closed channel", "got", "timeout")
+		}
+	}
+
+	// Closing must stop the elector and release its lease.
+	{
+		select {
+		case err := <-ran:
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("expected", "returned elector", "got", "timeout")
+		}
+
+		if elector.IsLeader() {
+			t.Fatal("expected", false, "got", true)
+		}
+		v, err := newStorage.Search(ctx, elector.key)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		var l lease
+		err = json.Unmarshal([]byte(v), &l)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		if l.Holder != "" {
+			t.Fatal("expected", "", "got", l.Holder)
+		}
+	}
+
+	// Closed services must not start watchers anymore, but keep allocating
+	// items as far as the elector permits.
+	{
+		_, err := newService.Watch(ctx, namespace)
+		if !IsClosed(err) {
+			t.Fatal("expected", true, "got", false)
+		}
+
+		_, err = newService.Create(ctx, namespace, "test-id", 1, 1, 5)
+		if !IsNotLeader(err) {
+			t.Fatal("expected", true, "got", false)
+		}
+	}
+
+	// Closing must be idempotent.
+	{
+		err = newService.Close(ctx)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+	}
+}
@@ -0,0 +1,227 @@
+// Command rangepool-load generates allocation load against a range pool to
+// measure its end-to-end performance. Concurrent workers allocate items for
+// random IDs, search them and release them again until the configured
+// duration passed. Latency percentiles and the storage round trips of every
+// operation are printed at the end.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+
+	"github.com/giantswarm/rangepool"
+	"github.com/giantswarm/rangepool/storage/memory"
+)
+
+type flags struct {
+	Bitmap      bool
+	Concurrency int
+	Duration    time.Duration
+	IDs         int
+	Max         int
+	Min         int
+	Namespace   string
+	Num         int
+}
+
+func main() {
+	var f flags
+	flag.BoolVar(&f.Bitmap, "bitmap", false, "Persist the items of the namespace as bitmap.")
+	flag.IntVar(&f.Concurrency, "concurrency", 4, "Number of concurrent workers.")
+	flag.DurationVar(&f.Duration, "duration", 10*time.Second, "Duration of the load test.")
+	flag.IntVar(&f.IDs, "ids", 100, "Number of distinct IDs the workers allocate items for.")
+	flag.IntVar(&f.Max, "max", 65535, "Max boundary of the range pool.")
+	flag.IntVar(&f.Min, "min", 1, "Min boundary of the range pool.")
+	flag.StringVar(&f.Namespace, "namespace", "load", "Namespace of the range pool.")
+	flag.IntVar(&f.Num, "num", 1, "Number of items allocated per operation.")
+	flag.Parse()
+
+	err := mainE(context.Background(), f)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%#v\n", err)
+		os.Exit(1)
+	}
+}
+
+func mainE(ctx context.Context, f flags) error {
+	var err error
+
+	var logger micrologger.Logger
+	{
+		logger, err = micrologger.New(micrologger.Config{})
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	dir, err := ioutil.TempDir("", "rangepool-load")
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var newStorage *memory.Storage
+	{
+		c := memory.DefaultConfig()
+		c.Logger = logger
+		c.SnapshotFile = filepath.Join(dir, "snapshot.json")
+		newStorage, err = memory.New(c)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+		defer newStorage.Close()
+	}
+
+	counter := &countingStorage{BatchStorage: newStorage}
+
+	var newService *rangepool.Service
+	{
+		c := rangepool.DefaultConfig()
+		c.Logger = logger
+		c.Storage = counter
+		c.Bitmap = f.Bitmap
+		newService, err = rangepool.New(c)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	r := &recorder{}
+	deadline := time.Now().Add(f.Duration)
+
+	var wg sync.WaitGroup
+	for w := 0; w < f.Concurrency; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+
+			rnd := rand.New(rand.NewSource(int64(w)))
+			for time.Now().Before(deadline) {
+				ID := fmt.Sprintf("load-%d-%d", w, rnd.Intn(f.IDs))
+
+				start := time.Now()
+				_, err := newService.Create(ctx, f.Namespace, ID, f.Num, f.Min, f.Max)
+				r.Record("create", time.Since(start), err)
+
+				start = time.Now()
+				_, err = newService.Search(ctx, f.Namespace, ID)
+				r.Record("search", time.Since(start), err)
+
+				start = time.Now()
+				err = newService.Delete(ctx, f.Namespace, ID)
+				r.Record("delete", time.Since(start), err)
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	r.Print(f.Duration, counter.Calls())
+
+	return nil
+}
+
+// countingStorage counts the calls to the underlying storage.
+type countingStorage struct {
+	rangepool.BatchStorage
+
+	calls int64
+}
+
+func (s *countingStorage) Calls() int64 {
+	return atomic.LoadInt64(&s.calls)
+}
+
+func (s *countingStorage) Create(ctx context.Context, key, value string) error {
+	atomic.AddInt64(&s.calls, 1)
+	return s.BatchStorage.Create(ctx, key, value)
+}
+
+func (s *countingStorage) CreateBatch(ctx context.Context, kvs []rangepool.KV) error {
+	atomic.AddInt64(&s.calls, 1)
+	return s.BatchStorage.CreateBatch(ctx, kvs)
+}
+
+func (s *countingStorage) Delete(ctx context.Context, key string) error {
+	atomic.AddInt64(&s.calls, 1)
+	return s.BatchStorage.Delete(ctx, key)
+}
+
+func (s *countingStorage) DeleteBatch(ctx context.Context, keys []string) error {
+	atomic.AddInt64(&s.calls, 1)
+	return s.BatchStorage.DeleteBatch(ctx, keys)
+}
+
+func (s *countingStorage) List(ctx context.Context, key string) ([]rangepool.KV, error) {
+	atomic.AddInt64(&s.calls, 1)
+	return s.BatchStorage.List(ctx, key)
+}
+
+func (s *countingStorage) Search(ctx context.Context, key string) (string, error) {
+	atomic.AddInt64(&s.calls, 1)
+	return s.BatchStorage.Search(ctx, key)
+}
+
+// recorder collects the latencies and errors of all operations.
+type recorder struct {
+	errors    map[string]int
+	latencies map[string][]time.Duration
+	mutex     sync.Mutex
+}
+
+func (r *recorder) Print(d time.Duration, calls int64) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	var ops []string
+	var total int
+	for op, l := range r.latencies {
+		ops = append(ops, op)
+		total += len(l)
+	}
+	sort.Strings(ops)
+
+	fmt.Printf("%-8s %10s %10s %12s %12s %12s\n", "op", "count", "errors", "p50", "p90", "p99")
+	for _, op := range ops {
+		l := r.latencies[op]
+		sort.Slice(l, func(i, j int) bool { return l[i] < l[j] })
+
+		fmt.Printf("%-8s %10d %10d %12s %12s %12s\n", op, len(l), r.errors[op], percentile(l, 50), percentile(l, 90), percentile(l, 99))
+	}
+
+	fmt.Printf("\n%d operations in %s (%.0f ops/s), %.2f storage calls/op\n", total, d, float64(total)/d.Seconds(), float64(calls)/float64(total))
+}
+
+func (r *recorder) Record(op string, d time.Duration, err error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.latencies == nil {
+		r.errors = map[string]int{}
+		r.latencies = map[string][]time.Duration{}
+	}
+
+	r.latencies[op] = append(r.latencies[op], d)
+	if err != nil {
+		r.errors[op]++
+	}
+}
+
+func percentile(l []time.Duration, p int) time.Duration {
+	if len(l) == 0 {
+		return 0
+	}
+
+	return l[(len(l)-1)*p/100]
+}
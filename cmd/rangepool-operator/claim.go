@@ -0,0 +1,251 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/giantswarm/microerror"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/giantswarm/rangepool"
+)
+
+const (
+	// ClaimPhaseBound is the phase of claims whose items have been allocated.
+	ClaimPhaseBound = "Bound"
+	// ClaimPhasePending is the phase of claims which could not be satisfied
+	// yet, e.g. because their pool does not exist or is exhausted. The status
+	// message describes the reason.
+	ClaimPhasePending = "Pending"
+)
+
+// claimSpec is the spec of a RangePoolClaim resource.
+type claimSpec struct {
+	// Num is the number of items claimed. It defaults to 1. Changing it after
+	// the claim is bound has no effect.
+	Num int
+	// Pool is the name of the RangePool the items are claimed from. The pool
+	// must live in the same Kubernetes namespace as the claim.
+	Pool string
+}
+
+// reconcileClaim allocates the items of the given RangePoolClaim and writes
+// them into its status. The items are allocated for the UID of the claim, so
+// that re-created claims of the same name never inherit items by accident.
+// Deleted claims release their items before their finalizer is removed.
+func (c *Controller) reconcileClaim(ctx context.Context, namespace, name string) error {
+	obj, err := c.k8sClient.Resource(ClaimResource).Namespace(namespace).Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return microerror.Mask(err)
+	}
+
+	if obj.GetDeletionTimestamp() != nil {
+		err = c.releaseClaim(ctx, obj)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
+		return nil
+	}
+
+	if !hasFinalizer(obj) {
+		obj = obj.DeepCopy()
+		obj.SetFinalizers(append(obj.GetFinalizers(), Finalizer))
+
+		obj, err = c.k8sClient.Resource(ClaimResource).Namespace(namespace).Update(ctx, obj, metav1.UpdateOptions{})
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	spec, err := readClaimSpec(obj)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	var pool poolSpec
+	{
+		p, err := c.k8sClient.Resource(PoolResource).Namespace(namespace).Get(ctx, spec.Pool, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			err = c.pendClaim(ctx, obj, "", fmt.Sprintf("RangePool '%s' not found", spec.Pool))
+			if err != nil {
+				return microerror.Mask(err)
+			}
+
+			return microerror.Maskf(poolNotFoundError, "RangePool '%s/%s'", namespace, spec.Pool)
+		} else if err != nil {
+			return microerror.Mask(err)
+		}
+
+		pool, err = readPoolSpec(p)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	ID := string(obj.GetUID())
+
+	items, err := c.service.Search(ctx, pool.Namespace, ID)
+	if rangepool.IsItemsNotFound(err) {
+		items, err = c.service.Create(ctx, pool.Namespace, ID, spec.Num, pool.Min, pool.Max)
+		if rangepool.IsCapacityReached(err) || rangepool.IsQuotaExceeded(err) || rangepool.IsNamespaceQuotaExceeded(err) {
+			// The claim is retried with backoff until items are released.
+			perr := c.pendClaim(ctx, obj, pool.Namespace, err.Error())
+			if perr != nil {
+				return microerror.Mask(perr)
+			}
+
+			return microerror.Mask(err)
+		} else if err != nil {
+			return microerror.Mask(err)
+		}
+
+		c.logger.LogCtx(ctx, "level", "info", "message", fmt.Sprintf("allocated items %v for RangePoolClaim '%s/%s'", items, namespace, name))
+	} else if err != nil {
+		return microerror.Mask(err)
+	}
+
+	var l []interface{}
+	for _, item := range items {
+		l = append(l, int64(item))
+	}
+
+	status := map[string]interface{}{
+		"id":        ID,
+		"items":     l,
+		"namespace": pool.Namespace,
+		"phase":     ClaimPhaseBound,
+	}
+
+	err = c.updateStatus(ctx, ClaimResource, obj, status)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// pendClaim marks the given claim as pending for the given reason. The range
+// pool namespace is recorded as soon as it is known, so that items allocated
+// right before a failed status update are still released on deletion.
+func (c *Controller) pendClaim(ctx context.Context, obj *unstructured.Unstructured, namespace, message string) error {
+	status := map[string]interface{}{
+		"message": message,
+		"phase":   ClaimPhasePending,
+	}
+	if namespace != "" {
+		status["namespace"] = namespace
+	}
+
+	err := c.updateStatus(ctx, ClaimResource, obj, status)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// releaseClaim releases the items of the given deleted claim and removes its
+// finalizer afterwards.
+func (c *Controller) releaseClaim(ctx context.Context, obj *unstructured.Unstructured) error {
+	if !hasFinalizer(obj) {
+		return nil
+	}
+
+	namespace, _, err := unstructured.NestedString(obj.Object, "status", "namespace")
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	// The status might not have been written after the items were allocated,
+	// so we fall back to the pool of the claim in case it still exists.
+	if namespace == "" {
+		spec, err := readClaimSpec(obj)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
+		// Without a pool no items can have been allocated.
+		p, err := c.k8sClient.Resource(PoolResource).Namespace(obj.GetNamespace()).Get(ctx, spec.Pool, metav1.GetOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return microerror.Mask(err)
+		}
+		if err == nil {
+			pool, err := readPoolSpec(p)
+			if err != nil {
+				return microerror.Mask(err)
+			}
+			namespace = pool.Namespace
+		}
+	}
+
+	if namespace != "" {
+		err := c.service.Delete(ctx, namespace, string(obj.GetUID()))
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
+		c.logger.LogCtx(ctx, "level", "info", "message", fmt.Sprintf("released items of RangePoolClaim '%s/%s'", obj.GetNamespace(), obj.GetName()))
+	}
+
+	obj = obj.DeepCopy()
+
+	var finalizers []string
+	for _, f := range obj.GetFinalizers() {
+		if f != Finalizer {
+			finalizers = append(finalizers, f)
+		}
+	}
+	obj.SetFinalizers(finalizers)
+
+	_, err = c.k8sClient.Resource(ClaimResource).Namespace(obj.GetNamespace()).Update(ctx, obj, metav1.UpdateOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+func hasFinalizer(obj *unstructured.Unstructured) bool {
+	for _, f := range obj.GetFinalizers() {
+		if f == Finalizer {
+			return true
+		}
+	}
+
+	return false
+}
+
+func readClaimSpec(obj *unstructured.Unstructured) (claimSpec, error) {
+	pool, _, err := unstructured.NestedString(obj.Object, "spec", "pool")
+	if err != nil {
+		return claimSpec{}, microerror.Maskf(invalidSpecError, "RangePoolClaim '%s/%s': %s", obj.GetNamespace(), obj.GetName(), err.Error())
+	}
+	num, ok, err := unstructured.NestedInt64(obj.Object, "spec", "num")
+	if err != nil {
+		return claimSpec{}, microerror.Maskf(invalidSpecError, "RangePoolClaim '%s/%s': %s", obj.GetNamespace(), obj.GetName(), err.Error())
+	}
+	if !ok {
+		num = 1
+	}
+
+	if pool == "" {
+		return claimSpec{}, microerror.Maskf(invalidSpecError, "RangePoolClaim '%s/%s' must define a pool", obj.GetNamespace(), obj.GetName())
+	}
+	if num < 1 {
+		return claimSpec{}, microerror.Maskf(invalidSpecError, "RangePoolClaim '%s/%s' must claim at least 1 item", obj.GetNamespace(), obj.GetName())
+	}
+
+	spec := claimSpec{
+		Num:  int(num),
+		Pool: pool,
+	}
+
+	return spec, nil
+}
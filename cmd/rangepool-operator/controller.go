@@ -0,0 +1,254 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+
+	"github.com/giantswarm/rangepool"
+)
+
+const (
+	// Group is the API group of the RangePool and RangePoolClaim custom
+	// resources.
+	Group = "rangepool.giantswarm.io"
+	// Version is the API version of the RangePool and RangePoolClaim custom
+	// resources.
+	Version = "v1alpha1"
+)
+
+const (
+	// Finalizer is set on claims holding items, so that their items are
+	// released before the claims are gone.
+	Finalizer = "rangepool.giantswarm.io/release"
+)
+
+var (
+	// ClaimResource is the group version resource used to access
+	// RangePoolClaim custom resources.
+	ClaimResource = schema.GroupVersionResource{
+		Group:    Group,
+		Version:  Version,
+		Resource: "rangepoolclaims",
+	}
+	// PoolResource is the group version resource used to access RangePool
+	// custom resources.
+	PoolResource = schema.GroupVersionResource{
+		Group:    Group,
+		Version:  Version,
+		Resource: "rangepools",
+	}
+)
+
+// ControllerConfig represents the configuration used to create a new
+// controller.
+type ControllerConfig struct {
+	// Dependencies.
+	K8sClient dynamic.Interface
+	Logger    micrologger.Logger
+	Service   rangepool.Interface
+
+	// Settings.
+
+	// Namespace is the Kubernetes namespace watched for RangePool and
+	// RangePoolClaim resources. All namespaces are watched in case it is
+	// empty.
+	Namespace string
+	// ResyncPeriod is the interval in which all resources are reconciled
+	// again, e.g. to retry claims which could not be satisfied yet.
+	ResyncPeriod time.Duration
+	// Workers is the number of resources reconciled concurrently.
+	Workers int
+}
+
+// DefaultControllerConfig provides a default configuration to create a new
+// controller by best effort.
+func DefaultControllerConfig() ControllerConfig {
+	return ControllerConfig{
+		// Dependencies.
+		K8sClient: nil,
+		Logger:    nil,
+		Service:   nil,
+
+		// Settings.
+		Namespace:    "",
+		ResyncPeriod: 5 * time.Minute,
+		Workers:      2,
+	}
+}
+
+// NewController creates a new configured controller.
+func NewController(config ControllerConfig) (*Controller, error) {
+	// Dependencies.
+	if config.K8sClient == nil {
+		return nil, microerror.Maskf(invalidConfigError, "k8s client must not be empty")
+	}
+	if config.Logger == nil {
+		return nil, microerror.Maskf(invalidConfigError, "logger must not be empty")
+	}
+	if config.Service == nil {
+		return nil, microerror.Maskf(invalidConfigError, "service must not be empty")
+	}
+
+	// Settings.
+	if config.ResyncPeriod <= 0 {
+		return nil, microerror.Maskf(invalidConfigError, "resync period must be greater than zero")
+	}
+	if config.Workers < 1 {
+		return nil, microerror.Maskf(invalidConfigError, "workers must be at least 1")
+	}
+
+	newController := &Controller{
+		// Dependencies.
+		k8sClient: config.K8sClient,
+		logger:    config.Logger,
+		service:   config.Service,
+
+		// Internals.
+		queue: workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "rangepool-operator"),
+
+		// Settings.
+		namespace:    config.Namespace,
+		resyncPeriod: config.ResyncPeriod,
+		workers:      config.Workers,
+	}
+
+	return newController, nil
+}
+
+// Controller reconciles RangePool and RangePoolClaim resources. The informers
+// only feed the work queue. Reconciliation always reads the current state of a
+// resource from the API, so that it never acts on outdated objects.
+type Controller struct {
+	// Dependencies.
+	k8sClient dynamic.Interface
+	logger    micrologger.Logger
+	service   rangepool.Interface
+
+	// Internals.
+	queue workqueue.RateLimitingInterface
+
+	// Settings.
+	namespace    string
+	resyncPeriod time.Duration
+	workers      int
+}
+
+// queueKey identifies a resource within the work queue.
+type queueKey struct {
+	Resource schema.GroupVersionResource
+	Key      string
+}
+
+// Run starts the informers and workers and blocks until the given context is
+// done.
+func (c *Controller) Run(ctx context.Context) error {
+	defer c.queue.ShutDown()
+
+	factory := dynamicinformer.NewFilteredDynamicSharedInformerFactory(c.k8sClient, c.resyncPeriod, c.namespace, nil)
+
+	var synced []cache.InformerSynced
+	for _, r := range []schema.GroupVersionResource{ClaimResource, PoolResource} {
+		informer := factory.ForResource(r).Informer()
+		informer.AddEventHandler(c.eventHandler(r))
+		synced = append(synced, informer.HasSynced)
+	}
+
+	factory.Start(ctx.Done())
+
+	if !cache.WaitForCacheSync(ctx.Done(), synced...) {
+		return microerror.Maskf(executionFailedError, "informer caches did not sync")
+	}
+
+	c.logger.LogCtx(ctx, "level", "info", "message", "started reconciling range pools")
+
+	var wg sync.WaitGroup
+	for i := 0; i < c.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for c.processNext(ctx) {
+			}
+		}()
+	}
+
+	<-ctx.Done()
+	c.queue.ShutDown()
+	wg.Wait()
+
+	return nil
+}
+
+// Reconcile reconciles the resource identified by the given namespace/name
+// key.
+func (c *Controller) Reconcile(ctx context.Context, resource schema.GroupVersionResource, key string) error {
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	switch resource {
+	case ClaimResource:
+		err = c.reconcileClaim(ctx, namespace, name)
+	case PoolResource:
+		err = c.reconcilePool(ctx, namespace, name)
+	}
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+func (c *Controller) eventHandler(resource schema.GroupVersionResource) cache.ResourceEventHandler {
+	enqueue := func(obj interface{}) {
+		key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+		if err != nil {
+			c.logger.Log("level", "error", "message", "failed computing queue key", "stack", fmt.Sprintf("%#v", err))
+			return
+		}
+
+		c.queue.Add(queueKey{Resource: resource, Key: key})
+	}
+
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc: enqueue,
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			enqueue(newObj)
+		},
+		DeleteFunc: enqueue,
+	}
+}
+
+// processNext reconciles the next resource of the work queue. Failed
+// resources are requeued with backoff. It returns false once the queue is
+// shut down.
+func (c *Controller) processNext(ctx context.Context) bool {
+	item, shutdown := c.queue.Get()
+	if shutdown {
+		return false
+	}
+	defer c.queue.Done(item)
+
+	k := item.(queueKey)
+
+	err := c.Reconcile(ctx, k.Resource, k.Key)
+	if err != nil {
+		c.logger.LogCtx(ctx, "level", "error", "message", fmt.Sprintf("failed reconciling %s '%s'", k.Resource.Resource, k.Key), "stack", fmt.Sprintf("%#v", err))
+		c.queue.AddRateLimited(item)
+		return true
+	}
+
+	c.queue.Forget(item)
+
+	return true
+}
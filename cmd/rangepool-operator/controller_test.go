@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/giantswarm/micrologger/microloggertest"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+
+	"github.com/giantswarm/rangepool"
+	"github.com/giantswarm/rangepool/rangepooltest"
+)
+
+func Test_Controller_Reconcile(t *testing.T) {
+	var err error
+
+	k8sClient := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+
+	var fake *rangepooltest.Fake
+	{
+		fake, err = rangepooltest.NewFake(rangepooltest.DefaultFakeConfig())
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+	}
+
+	var controller *Controller
+	{
+		c := DefaultControllerConfig()
+		c.K8sClient = k8sClient
+		c.Logger = microloggertest.New()
+		c.Service = fake
+		controller, err = NewController(c)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+	}
+
+	ctx := context.TODO()
+
+	{
+		obj := newObject("RangePool", "vni", "")
+		obj.Object["spec"] = map[string]interface{}{"min": int64(1), "max": int64(2)}
+		_, err := k8sClient.Resource(PoolResource).Namespace("default").Create(ctx, obj, metav1.CreateOptions{})
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+	}
+	for i, name := range []string{"claim-1", "claim-2"} {
+		obj := newObject("RangePoolClaim", name, types.UID("uid-"+name))
+		obj.Object["spec"] = map[string]interface{}{"pool": "vni", "num": int64(2 - i)}
+		_, err := k8sClient.Resource(ClaimResource).Namespace("default").Create(ctx, obj, metav1.CreateOptions{})
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+	}
+
+	// The first claim must be bound and protected by the finalizer.
+	{
+		err := controller.Reconcile(ctx, ClaimResource, "default/claim-1")
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		obj := getObject(t, k8sClient, ClaimResource, "claim-1")
+		if !hasFinalizer(obj) {
+			t.Fatal("expected", true, "got", false)
+		}
+		phase, _, _ := unstructured.NestedString(obj.Object, "status", "phase")
+		if phase != ClaimPhaseBound {
+			t.Fatal("expected", ClaimPhaseBound, "got", phase)
+		}
+		items, _, _ := unstructured.NestedSlice(obj.Object, "status", "items")
+		expected := []interface{}{int64(1), int64(2)}
+		if !reflect.DeepEqual(items, expected) {
+			t.Fatal("expected", expected, "got", items)
+		}
+	}
+
+	// The second claim must stay pending while the pool is exhausted.
+	{
+		err := controller.Reconcile(ctx, ClaimResource, "default/claim-2")
+		if !rangepool.IsCapacityReached(err) {
+			t.Fatal("expected", true, "got", false)
+		}
+
+		obj := getObject(t, k8sClient, ClaimResource, "claim-2")
+		phase, _, _ := unstructured.NestedString(obj.Object, "status", "phase")
+		if phase != ClaimPhasePending {
+			t.Fatal("expected", ClaimPhasePending, "got", phase)
+		}
+	}
+
+	// The pool must report its utilization.
+	{
+		err := controller.Reconcile(ctx, PoolResource, "default/vni")
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		obj := getObject(t, k8sClient, PoolResource, "vni")
+		free, _, _ := unstructured.NestedInt64(obj.Object, "status", "free")
+		if free != 0 {
+			t.Fatal("expected", 0, "got", free)
+		}
+	}
+
+	// Deleting the first claim must release its items and remove the
+	// finalizer, so that the second claim can be bound.
+	{
+		obj := getObject(t, k8sClient, ClaimResource, "claim-1")
+		now := metav1.Now()
+		obj.SetDeletionTimestamp(&now)
+		_, err := k8sClient.Resource(ClaimResource).Namespace("default").Update(ctx, obj, metav1.UpdateOptions{})
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		err = controller.Reconcile(ctx, ClaimResource, "default/claim-1")
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		obj = getObject(t, k8sClient, ClaimResource, "claim-1")
+		if hasFinalizer(obj) {
+			t.Fatal("expected", false, "got", true)
+		}
+		_, err = fake.Search(ctx, "default-vni", "uid-claim-1")
+		if !rangepool.IsItemsNotFound(err) {
+			t.Fatal("expected", true, "got", false)
+		}
+
+		err = controller.Reconcile(ctx, ClaimResource, "default/claim-2")
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		obj = getObject(t, k8sClient, ClaimResource, "claim-2")
+		phase, _, _ := unstructured.NestedString(obj.Object, "status", "phase")
+		if phase != ClaimPhaseBound {
+			t.Fatal("expected", ClaimPhaseBound, "got", phase)
+		}
+	}
+
+	// Claims of missing pools must stay pending.
+	{
+		obj := newObject("RangePoolClaim", "claim-3", "uid-claim-3")
+		obj.Object["spec"] = map[string]interface{}{"pool": "missing"}
+		_, err := k8sClient.Resource(ClaimResource).Namespace("default").Create(ctx, obj, metav1.CreateOptions{})
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		err = controller.Reconcile(ctx, ClaimResource, "default/claim-3")
+		if !IsPoolNotFound(err) {
+			t.Fatal("expected", true, "got", false)
+		}
+	}
+}
+
+func getObject(t *testing.T, k8sClient *dynamicfake.FakeDynamicClient, resource schema.GroupVersionResource, name string) *unstructured.Unstructured {
+	obj, err := k8sClient.Resource(resource).Namespace("default").Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+
+	return obj
+}
+
+func newObject(kind, name string, uid types.UID) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion(Group + "/" + Version)
+	obj.SetKind(kind)
+	obj.SetName(name)
+	obj.SetNamespace("default")
+	obj.SetUID(uid)
+
+	return obj
+}
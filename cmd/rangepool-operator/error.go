@@ -0,0 +1,41 @@
+package main
+
+import (
+	"github.com/giantswarm/microerror"
+)
+
+var executionFailedError = &microerror.Error{
+	Kind: "executionFailedError",
+}
+
+// IsExecutionFailed asserts executionFailedError.
+func IsExecutionFailed(err error) bool {
+	return microerror.Cause(err) == executionFailedError
+}
+
+var invalidConfigError = &microerror.Error{
+	Kind: "invalidConfigError",
+}
+
+// IsInvalidConfig asserts invalidConfigError.
+func IsInvalidConfig(err error) bool {
+	return microerror.Cause(err) == invalidConfigError
+}
+
+var invalidSpecError = &microerror.Error{
+	Kind: "invalidSpecError",
+}
+
+// IsInvalidSpec asserts invalidSpecError.
+func IsInvalidSpec(err error) bool {
+	return microerror.Cause(err) == invalidSpecError
+}
+
+var poolNotFoundError = &microerror.Error{
+	Kind: "poolNotFoundError",
+}
+
+// IsPoolNotFound asserts poolNotFoundError.
+func IsPoolNotFound(err error) bool {
+	return microerror.Cause(err) == poolNotFoundError
+}
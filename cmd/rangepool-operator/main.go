@@ -0,0 +1,154 @@
+// Command rangepool-operator makes range pools consumable declaratively. It
+// watches RangePool and RangePoolClaim custom resources and allocates the
+// items of claims from their pools, writing the allocated items back into
+// the claim status. Items are released once a claim is deleted. Allocations
+// are persisted as RangePoolAllocation custom resources, see the storage/crd
+// package. The custom resource definitions can be found in rangepool.yaml and
+// rangepoolclaim.yaml.
+//
+//     rangepool-operator -storage-namespace giantswarm
+//
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+
+	"github.com/giantswarm/rangepool"
+	"github.com/giantswarm/rangepool/storage/crd"
+)
+
+type flags struct {
+	Bitmap           bool
+	KeyPrefix        string
+	Kubeconfig       string
+	Namespace        string
+	ResyncPeriod     time.Duration
+	StorageNamespace string
+	Workers          int
+}
+
+func main() {
+	var f flags
+	flag.BoolVar(&f.Bitmap, "bitmap", false, "Persist the items of the pools as bitmap.")
+	flag.StringVar(&f.KeyPrefix, "key-prefix", rangepool.DefaultKeyPrefix, "Prefix of all storage keys.")
+	flag.StringVar(&f.Kubeconfig, "kubeconfig", "", "Kubeconfig used to access the cluster. Defaults to the in-cluster config.")
+	flag.StringVar(&f.Namespace, "namespace", "", "Kubernetes namespace watched for RangePool and RangePoolClaim resources. Defaults to all namespaces.")
+	flag.DurationVar(&f.ResyncPeriod, "resync-period", 5*time.Minute, "Interval in which all resources are reconciled again.")
+	flag.StringVar(&f.StorageNamespace, "storage-namespace", "default", "Kubernetes namespace the RangePoolAllocation resources are persisted in.")
+	flag.IntVar(&f.Workers, "workers", 2, "Number of resources reconciled concurrently.")
+	flag.Parse()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		c := make(chan os.Signal, 1)
+		signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+		<-c
+		cancel()
+	}()
+
+	err := mainE(ctx, f)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%#v\n", err)
+		os.Exit(1)
+	}
+}
+
+func mainE(ctx context.Context, f flags) error {
+	var err error
+
+	var logger micrologger.Logger
+	{
+		logger, err = micrologger.New(micrologger.Config{})
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	var restConfig *rest.Config
+	{
+		if f.Kubeconfig != "" {
+			restConfig, err = clientcmd.BuildConfigFromFlags("", f.Kubeconfig)
+		} else {
+			restConfig, err = rest.InClusterConfig()
+		}
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	var k8sClient dynamic.Interface
+	{
+		k8sClient, err = dynamic.NewForConfig(restConfig)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	var storage rangepool.Storage
+	{
+		c := crd.DefaultConfig()
+		c.K8sClient = k8sClient
+		c.Logger = logger
+		c.Namespace = f.StorageNamespace
+		s, err := crd.New(c)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
+		mc := rangepool.DefaultMicrostorageConfig()
+		mc.Storage = s
+		storage, err = rangepool.NewMicrostorage(mc)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	var service *rangepool.Service
+	{
+		c := rangepool.DefaultConfig()
+		c.Bitmap = f.Bitmap
+		c.KeyPrefix = f.KeyPrefix
+		c.Logger = logger
+		c.Storage = storage
+		service, err = rangepool.New(c)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	var controller *Controller
+	{
+		c := DefaultControllerConfig()
+		c.K8sClient = k8sClient
+		c.Logger = logger
+		c.Service = service
+		c.Namespace = f.Namespace
+		c.ResyncPeriod = f.ResyncPeriod
+		c.Workers = f.Workers
+		controller, err = NewController(c)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	err = controller.Run(ctx)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
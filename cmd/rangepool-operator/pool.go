@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/giantswarm/microerror"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// poolSpec is the spec of a RangePool resource.
+type poolSpec struct {
+	// Max is the max boundary of the range, inclusive.
+	Max int
+	// Min is the min boundary of the range, inclusive.
+	Min int
+	// Namespace is the range pool namespace the items are allocated in. It
+	// defaults to the Kubernetes namespace and name of the RangePool joined by
+	// a dash.
+	Namespace string
+}
+
+// reconcilePool writes the utilization of the given RangePool into its
+// status.
+func (c *Controller) reconcilePool(ctx context.Context, namespace, name string) error {
+	obj, err := c.k8sClient.Resource(PoolResource).Namespace(namespace).Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return microerror.Mask(err)
+	}
+
+	spec, err := readPoolSpec(obj)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	st, err := c.service.Status(ctx, spec.Namespace, spec.Min, spec.Max)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	status := map[string]interface{}{
+		"capacity":         int64(st.Capacity),
+		"free":             int64(st.Free),
+		"freeBlocks":       int64(st.FreeBlocks),
+		"largestFreeBlock": int64(st.LargestFreeBlock),
+		"namespace":        spec.Namespace,
+		"used":             int64(st.Used),
+	}
+
+	err = c.updateStatus(ctx, PoolResource, obj, status)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// updateStatus replaces the status of the given object in case it differs
+// from the given status. Unchanged statuses are not written, so that status
+// updates do not trigger reconciliations endlessly.
+func (c *Controller) updateStatus(ctx context.Context, resource schema.GroupVersionResource, obj *unstructured.Unstructured, status map[string]interface{}) error {
+	current, _, err := unstructured.NestedMap(obj.Object, "status")
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	if reflect.DeepEqual(current, status) {
+		return nil
+	}
+
+	obj = obj.DeepCopy()
+
+	err = unstructured.SetNestedMap(obj.Object, status, "status")
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	_, err = c.k8sClient.Resource(resource).Namespace(obj.GetNamespace()).UpdateStatus(ctx, obj, metav1.UpdateOptions{})
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+func readPoolSpec(obj *unstructured.Unstructured) (poolSpec, error) {
+	var spec poolSpec
+
+	min, _, err := unstructured.NestedInt64(obj.Object, "spec", "min")
+	if err != nil {
+		return poolSpec{}, microerror.Maskf(invalidSpecError, "RangePool '%s/%s': %s", obj.GetNamespace(), obj.GetName(), err.Error())
+	}
+	max, _, err := unstructured.NestedInt64(obj.Object, "spec", "max")
+	if err != nil {
+		return poolSpec{}, microerror.Maskf(invalidSpecError, "RangePool '%s/%s': %s", obj.GetNamespace(), obj.GetName(), err.Error())
+	}
+	namespace, _, err := unstructured.NestedString(obj.Object, "spec", "namespace")
+	if err != nil {
+		return poolSpec{}, microerror.Maskf(invalidSpecError, "RangePool '%s/%s': %s", obj.GetNamespace(), obj.GetName(), err.Error())
+	}
+	if namespace == "" {
+		namespace = obj.GetNamespace() + "-" + obj.GetName()
+	}
+
+	if min < 0 || max < min {
+		return poolSpec{}, microerror.Maskf(invalidSpecError, "RangePool '%s/%s' must define 0 <= min <= max", obj.GetNamespace(), obj.GetName())
+	}
+
+	spec.Max = int(max)
+	spec.Min = int(min)
+	spec.Namespace = namespace
+
+	return spec, nil
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+
+	"github.com/giantswarm/rangepool"
+	"github.com/giantswarm/rangepool/storage/memory"
+)
+
+const (
+	backendConfigMap = "configmap"
+	backendCRD       = "crd"
+	backendFile      = "file"
+)
+
+// backendFlags are the global flags selecting and configuring the storage
+// backend.
+type backendFlags struct {
+	Backend        string
+	Bitmap         bool
+	File           string
+	K8sNamespace   string
+	KeyPrefix      string
+	Kubeconfig     string
+	ZeroPaddedKeys bool
+}
+
+func (f *backendFlags) Register(fs *flag.FlagSet) {
+	fs.StringVar(&f.Backend, "backend", backendFile, "Storage backend, one of file, configmap or crd.")
+	fs.BoolVar(&f.Bitmap, "bitmap", false, "Whether the namespaces are persisted as bitmap.")
+	fs.StringVar(&f.File, "file", "rangepool.json", "Snapshot file used by the file backend.")
+	fs.StringVar(&f.K8sNamespace, "k8s-namespace", "default", "Kubernetes namespace used by the configmap and crd backends.")
+	fs.StringVar(&f.KeyPrefix, "key-prefix", rangepool.DefaultKeyPrefix, "Prefix of all storage keys.")
+	fs.StringVar(&f.Kubeconfig, "kubeconfig", "", "Kubeconfig used by the configmap and crd backends. Defaults to the in-cluster config.")
+	fs.BoolVar(&f.ZeroPaddedKeys, "zero-padded-keys", false, "Whether items are encoded as zero padded numbers within storage keys.")
+}
+
+// backend is the range pool service configured for the selected storage
+// backend. Close must be called once the service is not used anymore.
+type backend struct {
+	Service *rangepool.Service
+
+	close func() error
+}
+
+func (b *backend) Close() error {
+	if b.close == nil {
+		return nil
+	}
+
+	err := b.close()
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+func newBackend(ctx context.Context, f backendFlags) (*backend, error) {
+	var err error
+
+	var logger micrologger.Logger
+	{
+		c := micrologger.Config{
+			// Logs are written to stderr so they do not interfere with the
+			// output of the commands.
+			IOWriter: os.Stderr,
+		}
+		logger, err = micrologger.New(c)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
+	b := &backend{}
+
+	var storage rangepool.Storage
+	switch f.Backend {
+	case backendFile:
+		c := memory.DefaultConfig()
+		c.Logger = logger
+		c.SnapshotFile = f.File
+		s, err := memory.New(c)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		storage = s
+		b.close = s.Close
+	case backendConfigMap, backendCRD:
+		storage, err = newK8sStorage(f, logger)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	default:
+		return nil, microerror.Maskf(invalidFlagError, "unknown backend '%s'", f.Backend)
+	}
+
+	{
+		c := rangepool.DefaultConfig()
+		c.Logger = logger
+		c.Storage = storage
+		c.Bitmap = f.Bitmap
+		c.KeyPrefix = f.KeyPrefix
+		c.ZeroPaddedKeys = f.ZeroPaddedKeys
+		b.Service, err = rangepool.New(c)
+		if err != nil {
+			b.Close()
+			return nil, microerror.Mask(err)
+		}
+	}
+
+	return b, nil
+}
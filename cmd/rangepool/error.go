@@ -0,0 +1,23 @@
+package main
+
+import (
+	"github.com/giantswarm/microerror"
+)
+
+var invalidFlagError = &microerror.Error{
+	Kind: "invalidFlagError",
+}
+
+// IsInvalidFlag asserts invalidFlagError.
+func IsInvalidFlag(err error) bool {
+	return microerror.Cause(err) == invalidFlagError
+}
+
+var verificationFailedError = &microerror.Error{
+	Kind: "verificationFailedError",
+}
+
+// IsVerificationFailed asserts verificationFailedError.
+func IsVerificationFailed(err error) bool {
+	return microerror.Cause(err) == verificationFailedError
+}
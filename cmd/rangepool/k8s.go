@@ -0,0 +1,76 @@
+package main
+
+import (
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+	"github.com/giantswarm/microstorage"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+
+	"github.com/giantswarm/rangepool"
+	"github.com/giantswarm/rangepool/storage/configmap"
+	"github.com/giantswarm/rangepool/storage/crd"
+)
+
+// newK8sStorage creates the storage of the configmap and crd backends using
+// the configured kubeconfig, or the in-cluster config in case no kubeconfig
+// is configured.
+func newK8sStorage(f backendFlags, logger micrologger.Logger) (rangepool.Storage, error) {
+	var err error
+
+	var restConfig *rest.Config
+	if f.Kubeconfig != "" {
+		restConfig, err = clientcmd.BuildConfigFromFlags("", f.Kubeconfig)
+	} else {
+		restConfig, err = rest.InClusterConfig()
+	}
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	var s microstorage.Storage
+	switch f.Backend {
+	case backendConfigMap:
+		k8sClient, err := kubernetes.NewForConfig(restConfig)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		c := configmap.DefaultConfig()
+		c.K8sClient = k8sClient
+		c.Logger = logger
+		c.Namespace = f.K8sNamespace
+		s, err = configmap.New(c)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	case backendCRD:
+		k8sClient, err := dynamic.NewForConfig(restConfig)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		c := crd.DefaultConfig()
+		c.K8sClient = k8sClient
+		c.Logger = logger
+		c.Namespace = f.K8sNamespace
+		s, err = crd.New(c)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
+	var storage rangepool.Storage
+	{
+		c := rangepool.DefaultMicrostorageConfig()
+		c.Storage = s
+		storage, err = rangepool.NewMicrostorage(c)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
+	return storage, nil
+}
@@ -0,0 +1,390 @@
+// Command rangepool inspects and modifies range pools from a terminal, e.g.
+// to fix allocations during incidents. The storage backend is selected using
+// global flags, which precede the subcommand.
+//
+//     rangepool -backend file -file pool.json search -namespace vni -id cluster-1
+//     rangepool -backend configmap -k8s-namespace giantswarm status -namespace vni -min 1 -max 4096
+//
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/giantswarm/microerror"
+
+	"github.com/giantswarm/rangepool"
+)
+
+// command is a subcommand of the CLI. Run parses the given arguments and
+// executes the subcommand against the given service.
+type command struct {
+	Description string
+	Run         func(ctx context.Context, service *rangepool.Service, args []string, out io.Writer) error
+}
+
+var commands = map[string]command{
+	"burn": {
+		Description: "Permanently retire items, so they are never handed out again.",
+		Run:         runBurn,
+	},
+	"create": {
+		Description: "Allocate items for an ID.",
+		Run:         runCreate,
+	},
+	"delete": {
+		Description: "Release all items of an ID.",
+		Run:         runDelete,
+	},
+	"forecast": {
+		Description: "Estimate when a namespace runs out of free items.",
+		Run:         runForecast,
+	},
+	"force-release": {
+		Description: "Release a single item, no matter which ID owns it.",
+		Run:         runForceRelease,
+	},
+	"list-ids": {
+		Description: "List all IDs of a namespace and their items.",
+		Run:         runListIDs,
+	},
+	"search": {
+		Description: "Print the items of an ID.",
+		Run:         runSearch,
+	},
+	"status": {
+		Description: "Print the utilization of a namespace.",
+		Run:         runStatus,
+	},
+	"verify": {
+		Description: "Check the invariants of a namespace and print the JSON report.",
+		Run:         runVerify,
+	},
+}
+
+func main() {
+	err := mainE(context.Background(), os.Args[1:], os.Stdout)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%#v\n", err)
+		os.Exit(1)
+	}
+}
+
+func mainE(ctx context.Context, args []string, out io.Writer) error {
+	var f backendFlags
+
+	fs := flag.NewFlagSet("rangepool", flag.ContinueOnError)
+	f.Register(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: rangepool [flags] <command> [command flags]\n\nCommands:\n")
+
+		var names []string
+		for n := range commands {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		for _, n := range names {
+			fmt.Fprintf(fs.Output(), "  %-12s %s\n", n, commands[n].Description)
+		}
+
+		fmt.Fprintf(fs.Output(), "\nFlags:\n")
+		fs.PrintDefaults()
+	}
+
+	err := fs.Parse(args)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return microerror.Maskf(invalidFlagError, "command must not be empty")
+	}
+
+	c, ok := commands[fs.Arg(0)]
+	if !ok {
+		fs.Usage()
+		return microerror.Maskf(invalidFlagError, "unknown command '%s'", fs.Arg(0))
+	}
+
+	b, err := newBackend(ctx, f)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	defer b.Close()
+
+	err = c.Run(ctx, b.Service, fs.Args()[1:], out)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+func runBurn(ctx context.Context, service *rangepool.Service, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("burn", flag.ContinueOnError)
+	namespace := fs.String("namespace", "", "Namespace of the range pool.")
+	items := fs.String("items", "", "Comma separated items to burn.")
+	err := fs.Parse(args)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	if *namespace == "" || *items == "" {
+		return microerror.Maskf(invalidFlagError, "-namespace and -items must not be empty")
+	}
+
+	var l []int
+	for _, v := range strings.Split(*items, ",") {
+		i, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil {
+			return microerror.Maskf(invalidFlagError, "-items must be comma separated numbers: %s", err.Error())
+		}
+		l = append(l, i)
+	}
+
+	err = service.Burn(ctx, *namespace, l)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+func runCreate(ctx context.Context, service *rangepool.Service, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("create", flag.ContinueOnError)
+	namespace := fs.String("namespace", "", "Namespace of the range pool.")
+	ID := fs.String("id", "", "ID to allocate the items for.")
+	num := fs.Int("num", 1, "Number of items to allocate.")
+	min := fs.Int("min", 0, "Min boundary of the range.")
+	max := fs.Int("max", 0, "Max boundary of the range.")
+	err := fs.Parse(args)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = rangepool.Validate(*namespace, *ID, *num, *min, *max)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	items, err := service.Create(ctx, *namespace, *ID, *num, *min, *max)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	fmt.Fprintln(out, formatItems(items))
+
+	return nil
+}
+
+func runDelete(ctx context.Context, service *rangepool.Service, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("delete", flag.ContinueOnError)
+	namespace := fs.String("namespace", "", "Namespace of the range pool.")
+	ID := fs.String("id", "", "ID to release the items of.")
+	err := fs.Parse(args)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	if *namespace == "" || *ID == "" {
+		return microerror.Maskf(invalidFlagError, "-namespace and -id must not be empty")
+	}
+
+	err = service.Delete(ctx, *namespace, *ID)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+func runForecast(ctx context.Context, service *rangepool.Service, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("forecast", flag.ContinueOnError)
+	namespace := fs.String("namespace", "", "Namespace of the range pool.")
+	min := fs.Int("min", 0, "Min boundary of the range.")
+	max := fs.Int("max", 0, "Max boundary of the range.")
+	rate := fs.Float64("rate", 0, "Items used additionally per day. Derived from the audit trail if 0.")
+	err := fs.Parse(args)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	if *namespace == "" {
+		return microerror.Maskf(invalidFlagError, "-namespace must not be empty")
+	}
+
+	f, err := service.Forecast(ctx, *namespace, *min, *max, *rate)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	exhaustion := "never"
+	if !f.Exhaustion.IsZero() {
+		exhaustion = f.Exhaustion.Format(time.RFC3339)
+	}
+
+	fmt.Fprintf(out, "free\t%d\n", f.Free)
+	fmt.Fprintf(out, "rate per day\t%.2f\n", f.RatePerDay)
+	fmt.Fprintf(out, "exhaustion\t%s\n", exhaustion)
+
+	return nil
+}
+
+func runForceRelease(ctx context.Context, service *rangepool.Service, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("force-release", flag.ContinueOnError)
+	namespace := fs.String("namespace", "", "Namespace of the range pool.")
+	item := fs.Int("item", -1, "Item to release.")
+	err := fs.Parse(args)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	if *namespace == "" || *item < 0 {
+		return microerror.Maskf(invalidFlagError, "-namespace must not be empty and -item must not be negative")
+	}
+
+	err = service.ForceRelease(ctx, *namespace, *item)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+func runListIDs(ctx context.Context, service *rangepool.Service, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("list-ids", flag.ContinueOnError)
+	namespace := fs.String("namespace", "", "Namespace of the range pool.")
+	err := fs.Parse(args)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	if *namespace == "" {
+		return microerror.Maskf(invalidFlagError, "-namespace must not be empty")
+	}
+
+	d, err := service.Dump(ctx, *namespace)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	var IDs []string
+	for ID := range d.IDs {
+		IDs = append(IDs, ID)
+	}
+	sort.Strings(IDs)
+
+	for _, ID := range IDs {
+		fmt.Fprintf(out, "%s\t%s\n", ID, formatItems(d.IDs[ID]))
+	}
+
+	return nil
+}
+
+func runSearch(ctx context.Context, service *rangepool.Service, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("search", flag.ContinueOnError)
+	namespace := fs.String("namespace", "", "Namespace of the range pool.")
+	ID := fs.String("id", "", "ID to search the items of.")
+	err := fs.Parse(args)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	if *namespace == "" || *ID == "" {
+		return microerror.Maskf(invalidFlagError, "-namespace and -id must not be empty")
+	}
+
+	items, err := service.Search(ctx, *namespace, *ID)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	fmt.Fprintln(out, formatItems(items))
+
+	return nil
+}
+
+func runStatus(ctx context.Context, service *rangepool.Service, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("status", flag.ContinueOnError)
+	namespace := fs.String("namespace", "", "Namespace of the range pool.")
+	min := fs.Int("min", 0, "Min boundary of the range.")
+	max := fs.Int("max", 0, "Max boundary of the range.")
+	err := fs.Parse(args)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	if *namespace == "" {
+		return microerror.Maskf(invalidFlagError, "-namespace must not be empty")
+	}
+
+	st, err := service.Status(ctx, *namespace, *min, *max)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	d, err := service.Dump(ctx, *namespace)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	fmt.Fprintf(out, "capacity\t%d\n", st.Capacity)
+	fmt.Fprintf(out, "used\t%d\n", st.Used)
+	fmt.Fprintf(out, "free\t%d\n", st.Free)
+	fmt.Fprintf(out, "free blocks\t%d\n", st.FreeBlocks)
+	fmt.Fprintf(out, "largest free block\t%d\n", st.LargestFreeBlock)
+	fmt.Fprintf(out, "utilization\t%.2f\n", st.Utilization)
+	fmt.Fprintf(out, "ids\t%d\n", len(d.IDs))
+	fmt.Fprintf(out, "latest\t%d\n", d.Latest)
+
+	return nil
+}
+
+func runVerify(ctx context.Context, service *rangepool.Service, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("verify", flag.ContinueOnError)
+	namespace := fs.String("namespace", "", "Namespace of the range pool.")
+	min := fs.Int("min", 0, "Min boundary of the range.")
+	max := fs.Int("max", 0, "Max boundary of the range.")
+	err := fs.Parse(args)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	if *namespace == "" {
+		return microerror.Maskf(invalidFlagError, "-namespace must not be empty")
+	}
+
+	r, err := service.Verify(ctx, *namespace, *min, *max)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = json.NewEncoder(out).Encode(r)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	// Monitoring jobs rely on the exit code to detect violations.
+	if !r.Valid {
+		return microerror.Maskf(verificationFailedError, "namespace '%s' violates invariants", *namespace)
+	}
+
+	return nil
+}
+
+func formatItems(items []int) string {
+	var l []string
+	for _, i := range items {
+		l = append(l, strconv.Itoa(i))
+	}
+
+	return strings.Join(l, " ")
+}
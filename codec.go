@@ -0,0 +1,138 @@
+package rangepool
+
+import (
+	"context"
+
+	"github.com/giantswarm/microerror"
+)
+
+// valueCodec transforms the values written to the storage and reverts the
+// transformation when they are read, e.g. to encrypt them.
+type valueCodec interface {
+	Decode(ctx context.Context, value string) (string, error)
+	Encode(ctx context.Context, value string) (string, error)
+}
+
+// codecStorage transforms all values written to the given Storage using the
+// given codec. Keys are never transformed, so that they can still be listed.
+type codecStorage struct {
+	// Dependencies.
+	codec   valueCodec
+	storage Storage
+}
+
+func (c *codecStorage) Create(ctx context.Context, key, value string) error {
+	v, err := c.codec.Encode(ctx, value)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = c.storage.Create(ctx, key, v)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+func (c *codecStorage) Apply(ctx context.Context, kvs []KV, keys []string) error {
+	encoded := make([]KV, 0, len(kvs))
+	for _, kv := range kvs {
+		v, err := c.codec.Encode(ctx, kv.Value)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+		encoded = append(encoded, KV{Key: kv.Key, Value: v})
+	}
+
+	err := apply(ctx, c.storage, encoded, keys)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+func (c *codecStorage) CreateBatch(ctx context.Context, kvs []KV) error {
+	encoded := make([]KV, 0, len(kvs))
+	for _, kv := range kvs {
+		v, err := c.codec.Encode(ctx, kv.Value)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+		encoded = append(encoded, KV{Key: kv.Key, Value: v})
+	}
+
+	err := createBatch(ctx, c.storage, encoded)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+func (c *codecStorage) Delete(ctx context.Context, key string) error {
+	err := c.storage.Delete(ctx, key)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+func (c *codecStorage) DeleteBatch(ctx context.Context, keys []string) error {
+	err := deleteBatch(ctx, c.storage, keys)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+func (c *codecStorage) List(ctx context.Context, key string) ([]KV, error) {
+	kvs, err := c.storage.List(ctx, key)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	decoded := make([]KV, 0, len(kvs))
+	for _, kv := range kvs {
+		v, err := c.codec.Decode(ctx, kv.Value)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+		decoded = append(decoded, KV{Key: kv.Key, Value: v})
+	}
+
+	return decoded, nil
+}
+
+func (c *codecStorage) Search(ctx context.Context, key string) (string, error) {
+	v, err := c.storage.Search(ctx, key)
+	if err != nil {
+		return "", microerror.Mask(err)
+	}
+
+	v, err = c.codec.Decode(ctx, v)
+	if err != nil {
+		return "", microerror.Mask(err)
+	}
+
+	return v, nil
+}
+
+func (c *codecStorage) Walk(ctx context.Context, key string, fn func(kv KV) error) error {
+	err := walk(ctx, c.storage, key, func(kv KV) error {
+		v, err := c.codec.Decode(ctx, kv.Value)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
+		return fn(KV{Key: kv.Key, Value: v})
+	})
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
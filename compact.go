@@ -0,0 +1,150 @@
+package rangepool
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/giantswarm/microerror"
+)
+
+// Compact rewrites the given namespace into its most compact storage
+// representation. The relationships between IDs and items are the source of
+// truth. Item keys or bitmap bits which are not owned by any ID are dropped.
+// In case Config.Bitmap is enabled, remaining item keys are migrated into the
+// bitmap. In case the namespace does not hold any items anymore, its latest
+// pointer is removed as well. The schema marker of the namespace is updated
+// accordingly, see SchemaKeyFormat. Compact must not be executed concurrently with
+// other operations on the same namespace.
+func (s *Service) Compact(ctx context.Context, namespace string) error {
+	ctx = withOperation(ctx, "Compact", namespace, "")
+
+	err := s.checkReadOnly()
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = s.checkLeader()
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	s.logger.LogCtx(ctx, "level", "debug", "message", fmt.Sprintf("compacting namespace '%s'", namespace))
+
+	// The cached items of the namespace are going to be rewritten.
+	defer s.InvalidateCache(namespace)
+
+	owned, err := s.searchOwned(ctx, namespace)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	// Collect the item keys of the namespace. Keys are tracked as they are
+	// persisted, since their encoding may differ from the configured one, see
+	// Config.ZeroPaddedKeys.
+	itemKeys := map[int]string{}
+	{
+		prefix := s.key(ItemListKeyFormat, namespace)
+		err := walk(ctx, s.storage, prefix, func(kv KV) error {
+			i, err := strconv.Atoi(kv.Value)
+			if err != nil {
+				return microerror.Mask(err)
+			}
+			itemKeys[i] = prefix + "/" + kv.Key
+
+			return nil
+		})
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	var kvs []KV
+	var keys []string
+	if s.bitmap {
+		var used bitmap
+		for item := range owned {
+			used.Set(item)
+		}
+
+		if used.Len() == 0 {
+			keys = append(keys, s.key(BitmapKeyFormat, namespace))
+		} else {
+			kvs = append(kvs, KV{Key: s.key(BitmapKeyFormat, namespace), Value: s.encodeBitmap(used)})
+		}
+
+		for _, k := range itemKeys {
+			keys = append(keys, k)
+		}
+	} else {
+		for item, k := range itemKeys {
+			_, ok := owned[item]
+			if !ok {
+				keys = append(keys, k)
+			}
+		}
+
+		for item := range owned {
+			_, ok := itemKeys[item]
+			if !ok {
+				kvs = append(kvs, KV{Key: s.key(ItemKeyFormat, namespace, s.encodeItem(item)), Value: strconv.Itoa(item)})
+			}
+		}
+
+		keys = append(keys, s.key(BitmapKeyFormat, namespace))
+	}
+
+	if len(owned) == 0 {
+		keys = append(keys, s.key(ItemListKeyFormat, namespace))
+		keys = append(keys, s.key(LatestKeyFormat, namespace))
+	}
+
+	if len(kvs) != 0 {
+		err = createBatch(ctx, s.storage, kvs)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	err = deleteBatch(ctx, s.storage, keys)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = s.updateSchema(ctx, namespace, func(l *schema) {
+		l.Bitmap = s.bitmap
+	})
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	s.logger.LogCtx(ctx, "level", "debug", "message", fmt.Sprintf("compacted namespace '%s' holding %d items", namespace, len(owned)))
+
+	return nil
+}
+
+// searchOwned fetches the items of all IDs of the given namespace.
+func (s *Service) searchOwned(ctx context.Context, namespace string) (map[int]struct{}, error) {
+	owned := map[int]struct{}{}
+
+	err := walk(ctx, s.storage, s.key(IDPrefixKeyFormat, namespace), func(kv KV) error {
+		// The keys are relative to the ID prefix, e.g. ${id1}/item/${item1}.
+		if !strings.Contains(kv.Key, "/item/") {
+			return nil
+		}
+
+		i, err := strconv.Atoi(kv.Value)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+		owned[i] = struct{}{}
+
+		return nil
+	})
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return owned, nil
+}
@@ -0,0 +1,110 @@
+package rangepool
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/giantswarm/micrologger/microloggertest"
+)
+
+func Test_Service_Compact(t *testing.T) {
+	var err error
+	var newStorage Storage
+	{
+		newStorage, err = newMemoryStorage()
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+	}
+
+	newService := func(bitmap bool) *Service {
+		config := DefaultConfig()
+		config.Logger = microloggertest.New()
+		config.Storage = newStorage
+		config.Bitmap = bitmap
+		s, err := New(config)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		return s
+	}
+
+	ctx := context.TODO()
+
+	// Allocate items using one key per item and put an orphaned item key in
+	// place, which is not owned by any ID.
+	{
+		_, err := newService(false).Create(ctx, namespace, "test-id", 2, 2, 9)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		err = newStorage.Create(ctx, fmt.Sprintf(ItemKeyFormat, namespace, "7"), "7")
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+	}
+
+	// Compacting must drop the orphaned item key.
+	{
+		err := newService(false).Compact(ctx, namespace)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		items, err := newService(false).searchItems(ctx, fmt.Sprintf(ItemListKeyFormat, namespace))
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		sort.Ints(items)
+		if len(items) != 2 || items[0] != 2 || items[1] != 3 {
+			t.Fatal("expected", []int{2, 3}, "got", items)
+		}
+	}
+
+	// Compacting with bitmap enabled must migrate the item keys into the
+	// bitmap.
+	{
+		err := newService(true).Compact(ctx, namespace)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		items, err := newService(true).searchItems(ctx, fmt.Sprintf(ItemListKeyFormat, namespace))
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		if len(items) != 0 {
+			t.Fatal("expected", 0, "got", len(items))
+		}
+
+		used, err := newService(true).searchBitmap(ctx, namespace)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		if used.Len() != 2 || !used.IsSet(2) || !used.IsSet(3) {
+			t.Fatal("expected", 2, "got", used.Len())
+		}
+	}
+
+	// Compacting an empty namespace must remove the latest pointer.
+	{
+		err := newService(true).Delete(ctx, namespace, "test-id")
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		err = newService(true).Compact(ctx, namespace)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		_, err = newStorage.Search(ctx, fmt.Sprintf(LatestKeyFormat, namespace))
+		if !IsNotFound(err) {
+			t.Fatal("expected", true, "got", false)
+		}
+	}
+}
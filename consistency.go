@@ -0,0 +1,42 @@
+package rangepool
+
+import "context"
+
+const (
+	// ConsistencyCached accepts possibly stale results of caches configured
+	// for the Service, see Config.CacheTTL, Config.CoalesceLists and
+	// Config.ReadCacheTTL. It is the consistency level of calls not carrying
+	// one, see NewConsistencyContext.
+	ConsistencyCached = "cached"
+	// ConsistencyLinearizable bypasses all caches of the Service, so that
+	// calls always observe the current state of the storage, at the expense of
+	// latency.
+	ConsistencyLinearizable = "linearizable"
+)
+
+type consistencyContextKey struct{}
+
+// NewConsistencyContext returns a new context carrying the given consistency
+// level, either ConsistencyCached or ConsistencyLinearizable. Operations
+// executed using the returned context read the storage accordingly, which
+// trades correctness for latency explicitly per call.
+func NewConsistencyContext(ctx context.Context, level string) context.Context {
+	return context.WithValue(ctx, consistencyContextKey{}, level)
+}
+
+// ConsistencyFromContext returns the consistency level carried by the given
+// context, see NewConsistencyContext. It defaults to ConsistencyCached.
+func ConsistencyFromContext(ctx context.Context) string {
+	level, ok := ctx.Value(consistencyContextKey{}).(string)
+	if !ok || level == "" {
+		return ConsistencyCached
+	}
+
+	return level
+}
+
+// isLinearizable returns whether the given context requests bypassing all
+// caches, see ConsistencyLinearizable.
+func isLinearizable(ctx context.Context) bool {
+	return ConsistencyFromContext(ctx) == ConsistencyLinearizable
+}
@@ -0,0 +1,85 @@
+package rangepool
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/giantswarm/micrologger/microloggertest"
+)
+
+func Test_Service_Consistency(t *testing.T) {
+	newStorage, err := newMemoryStorage()
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+
+	newTestService := func() *Service {
+		config := DefaultConfig()
+		config.Logger = microloggertest.New()
+		config.Storage = newStorage
+		config.CacheTTL = time.Hour
+		config.ReadCacheTTL = time.Hour
+		newService, err := New(config)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		return newService
+	}
+
+	ctx := context.TODO()
+	linearizable := NewConsistencyContext(ctx, ConsistencyLinearizable)
+
+	reader := newTestService()
+	writer := newTestService()
+
+	search := func(ctx context.Context, expected []int) {
+		items, err := reader.Search(ctx, namespace, "test-id-1")
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		if !reflect.DeepEqual(items, expected) {
+			t.Fatal("expected", expected, "got", items)
+		}
+	}
+
+	status := func(ctx context.Context, expected int) {
+		st, err := reader.Status(ctx, namespace, 2, 10)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		if st.Used != expected {
+			t.Fatal("expected", expected, "got", st.Used)
+		}
+	}
+
+	_, err = writer.Create(ctx, namespace, "test-id-1", 1, 2, 10)
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+	search(ctx, []int{2})
+	status(ctx, 1)
+
+	_, err = writer.Create(ctx, namespace, "test-id-1", 1, 2, 10)
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+
+	// Calls without consistency level must accept cached results.
+	{
+		if ConsistencyFromContext(ctx) != ConsistencyCached {
+			t.Fatal("expected", ConsistencyCached, "got", ConsistencyFromContext(ctx))
+		}
+
+		search(ctx, []int{2})
+		status(ctx, 1)
+	}
+
+	// Linearizable calls must observe the current state of the storage.
+	{
+		search(linearizable, []int{2, 3})
+		status(linearizable, 2)
+	}
+}
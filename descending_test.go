@@ -0,0 +1,61 @@
+package rangepool
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/giantswarm/micrologger/microloggertest"
+)
+
+func Test_Service_Create_Descending(t *testing.T) {
+	for _, b := range []bool{false, true} {
+		var err error
+		var newService *Service
+		{
+			newStorage, err := newMemoryStorage()
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+
+			config := DefaultConfig()
+			config.Logger = microloggertest.New()
+			config.Storage = newStorage
+			config.Bitmap = b
+			config.Descending = true
+			newService, err = New(config)
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+		}
+
+		ctx := context.TODO()
+
+		create := func(ID string, num int, expected []int) {
+			items, err := newService.Create(ctx, namespace, ID, num, 2, 7)
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+			if !reflect.DeepEqual(items, expected) {
+				t.Fatal("expected", expected, "got", items)
+			}
+		}
+
+		// Items must be handed out from max downwards.
+		create("test-id-1", 2, []int{7, 6})
+		create("test-id-2", 1, []int{5})
+
+		err = newService.Delete(ctx, namespace, "test-id-1")
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		// Released items must only be reused once the range wraps around.
+		create("test-id-3", 4, []int{4, 3, 2, 7})
+
+		_, err = newService.Create(ctx, namespace, "test-id-4", 2, 2, 7)
+		if !IsCapacityReached(err) {
+			t.Fatal("expected", true, "got", false)
+		}
+	}
+}
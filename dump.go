@@ -0,0 +1,63 @@
+package rangepool
+
+import (
+	"context"
+	"sort"
+
+	"github.com/giantswarm/microerror"
+)
+
+// Dump is the full state of a namespace, see Service.Dump.
+type Dump struct {
+	// IDs maps the IDs of the namespace to their items.
+	IDs map[string][]int
+	// Latest is the latest item allocated within the namespace. It is -1 in
+	// case no item has ever been allocated.
+	Latest int
+	// Namespace is the namespace the state belongs to.
+	Namespace string
+	// Used are the items being used within the namespace, in ascending order.
+	Used []int
+}
+
+// Dump returns the full state of the given namespace as persisted in the
+// storage. It is meant for debugging, e.g. to find out why an ID got a certain
+// item, and bypasses the cache configured using Config.CacheTTL.
+func (s *Service) Dump(ctx context.Context, namespace string) (Dump, error) {
+	var err error
+
+	var used []int
+	if s.bitmap {
+		b, err := s.searchBitmap(ctx, namespace)
+		if err != nil {
+			return Dump{}, microerror.Mask(err)
+		}
+
+		used = b.Items()
+	} else {
+		used, err = s.searchItems(ctx, s.key(ItemListKeyFormat, namespace))
+		if err != nil {
+			return Dump{}, microerror.Mask(err)
+		}
+		sort.Ints(used)
+	}
+
+	IDs, err := s.searchOwnedByID(ctx, namespace)
+	if err != nil {
+		return Dump{}, microerror.Mask(err)
+	}
+
+	latest, err := s.searchLatest(ctx, namespace, callOptions{})
+	if err != nil {
+		return Dump{}, microerror.Mask(err)
+	}
+
+	d := Dump{
+		IDs:       IDs,
+		Latest:    latest,
+		Namespace: namespace,
+		Used:      used,
+	}
+
+	return d, nil
+}
@@ -0,0 +1,74 @@
+package rangepool
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/giantswarm/micrologger/microloggertest"
+)
+
+func Test_Service_Dump(t *testing.T) {
+	for _, b := range []bool{false, true} {
+		var err error
+		var newService *Service
+		{
+			newStorage, err := newMemoryStorage()
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+
+			config := DefaultConfig()
+			config.Logger = microloggertest.New()
+			config.Storage = newStorage
+			config.Bitmap = b
+			newService, err = New(config)
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+		}
+
+		ctx := context.TODO()
+
+		// An empty namespace must not have a latest item.
+		{
+			d, err := newService.Dump(ctx, namespace)
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+			expected := Dump{IDs: map[string][]int{}, Latest: -1, Namespace: namespace}
+			if !reflect.DeepEqual(d, expected) {
+				t.Fatal("expected", expected, "got", d)
+			}
+		}
+
+		// The dump must reflect the allocations of all IDs.
+		{
+			_, err = newService.Create(ctx, namespace, "test-id-1", 2, 2, 10)
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+			_, err = newService.Create(ctx, namespace, "test-id-2", 1, 2, 10)
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+
+			d, err := newService.Dump(ctx, namespace)
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+			expected := Dump{
+				IDs: map[string][]int{
+					"test-id-1": {2, 3},
+					"test-id-2": {4},
+				},
+				Latest:    4,
+				Namespace: namespace,
+				Used:      []int{2, 3, 4},
+			}
+			if !reflect.DeepEqual(d, expected) {
+				t.Fatal("expected", expected, "got", d)
+			}
+		}
+	}
+}
@@ -0,0 +1,237 @@
+package rangepool
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/giantswarm/microerror"
+)
+
+// encryptedValuePrefix marks values encrypted by the Service, see
+// Config.KeyEncrypter. Encrypted values are formatted as follows. The
+// encrypted data key and the nonce followed by the ciphertext are base64
+// encoded.
+//
+//     enc1:${encrypted data key}:${nonce}${ciphertext}
+//
+const encryptedValuePrefix = "enc1:"
+
+// KeyEncrypter encrypts and decrypts the data keys the values written to the
+// storage are encrypted with, see Config.KeyEncrypter. Implementations may
+// delegate to a key management service, so that the key encryption key never
+// leaves it. See also NewAESKeyEncrypter.
+type KeyEncrypter interface {
+	// DecryptKey returns the data key encrypted by EncryptKey.
+	DecryptKey(ctx context.Context, encrypted []byte) ([]byte, error)
+	// EncryptKey encrypts the given data key.
+	EncryptKey(ctx context.Context, key []byte) ([]byte, error)
+}
+
+// NewAESKeyEncrypter returns a KeyEncrypter encrypting data keys using
+// AES-GCM with the given key encryption key, which must be 16, 24 or 32 bytes
+// long.
+func NewAESKeyEncrypter(key []byte) (KeyEncrypter, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, microerror.Maskf(invalidConfigError, "key encryption key: %s", err.Error())
+	}
+
+	return aesKeyEncrypter{aead: aead}, nil
+}
+
+type aesKeyEncrypter struct {
+	aead cipher.AEAD
+}
+
+func (e aesKeyEncrypter) DecryptKey(ctx context.Context, encrypted []byte) ([]byte, error) {
+	key, err := open(e.aead, encrypted)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return key, nil
+}
+
+func (e aesKeyEncrypter) EncryptKey(ctx context.Context, key []byte) ([]byte, error) {
+	encrypted, err := seal(e.aead, key)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return encrypted, nil
+}
+
+// encrypter is the valueCodec encrypting values using envelope encryption.
+// Every Service encrypts with a data key of its own, which is generated on
+// the first write and persisted alongside the values in encrypted form. Data
+// keys of other Services are decrypted once and kept in memory afterwards,
+// so that the KeyEncrypter is not asked on every read.
+type encrypter struct {
+	// Dependencies.
+	keyEncrypter KeyEncrypter
+
+	// Internals.
+	aead      cipher.AEAD
+	encrypted string
+	keys      map[string]cipher.AEAD
+	mutex     sync.Mutex
+}
+
+func newEncrypter(keyEncrypter KeyEncrypter) *encrypter {
+	e := &encrypter{
+		keyEncrypter: keyEncrypter,
+
+		keys: map[string]cipher.AEAD{},
+	}
+
+	return e
+}
+
+// Decode decrypts the given value. Values persisted before encryption was
+// enabled are returned as they are, so that existing namespaces stay
+// readable. They are encrypted on their next write.
+func (e *encrypter) Decode(ctx context.Context, value string) (string, error) {
+	if !strings.HasPrefix(value, encryptedValuePrefix) {
+		return value, nil
+	}
+
+	parts := strings.Split(strings.TrimPrefix(value, encryptedValuePrefix), ":")
+	if len(parts) != 2 {
+		return "", microerror.Maskf(executionFailedError, "decrypting value: malformed value")
+	}
+
+	aead, err := e.dataKey(ctx, parts[0])
+	if err != nil {
+		return "", microerror.Mask(err)
+	}
+
+	b, err := base64.RawStdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", microerror.Maskf(executionFailedError, "decrypting value: %s", err.Error())
+	}
+	b, err = open(aead, b)
+	if err != nil {
+		return "", microerror.Mask(err)
+	}
+
+	return string(b), nil
+}
+
+// Encode encrypts the given value using the data key of the Service.
+func (e *encrypter) Encode(ctx context.Context, value string) (string, error) {
+	aead, encrypted, err := e.ownDataKey(ctx)
+	if err != nil {
+		return "", microerror.Mask(err)
+	}
+
+	b, err := seal(aead, []byte(value))
+	if err != nil {
+		return "", microerror.Mask(err)
+	}
+
+	return encryptedValuePrefix + encrypted + ":" + base64.RawStdEncoding.EncodeToString(b), nil
+}
+
+// dataKey returns the cipher of the given base64 encoded, encrypted data key.
+func (e *encrypter) dataKey(ctx context.Context, encrypted string) (cipher.AEAD, error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	aead, ok := e.keys[encrypted]
+	if ok {
+		return aead, nil
+	}
+
+	b, err := base64.RawStdEncoding.DecodeString(encrypted)
+	if err != nil {
+		return nil, microerror.Maskf(executionFailedError, "decrypting data key: %s", err.Error())
+	}
+	key, err := e.keyEncrypter.DecryptKey(ctx, b)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+	aead, err = newAEAD(key)
+	if err != nil {
+		return nil, microerror.Maskf(executionFailedError, "decrypting data key: %s", err.Error())
+	}
+	e.keys[encrypted] = aead
+
+	return aead, nil
+}
+
+// ownDataKey returns the cipher of the data key of the Service and the data
+// key in encrypted, base64 encoded form. The data key is generated on the
+// first call.
+func (e *encrypter) ownDataKey(ctx context.Context) (cipher.AEAD, string, error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if e.aead != nil {
+		return e.aead, e.encrypted, nil
+	}
+
+	key := make([]byte, 32)
+	_, err := io.ReadFull(rand.Reader, key)
+	if err != nil {
+		return nil, "", microerror.Mask(err)
+	}
+	b, err := e.keyEncrypter.EncryptKey(ctx, key)
+	if err != nil {
+		return nil, "", microerror.Mask(err)
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, "", microerror.Mask(err)
+	}
+
+	e.aead = aead
+	e.encrypted = base64.RawStdEncoding.EncodeToString(b)
+	e.keys[e.encrypted] = aead
+
+	return e.aead, e.encrypted, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return aead, nil
+}
+
+// open decrypts the given nonce and ciphertext, see seal.
+func open(aead cipher.AEAD, b []byte) ([]byte, error) {
+	if len(b) < aead.NonceSize() {
+		return nil, microerror.Maskf(executionFailedError, "decrypting: ciphertext too short")
+	}
+
+	plaintext, err := aead.Open(nil, b[:aead.NonceSize()], b[aead.NonceSize():], nil)
+	if err != nil {
+		return nil, microerror.Maskf(executionFailedError, "decrypting: %s", err.Error())
+	}
+
+	return plaintext, nil
+}
+
+// seal encrypts the given plaintext with a random nonce, which is prepended to
+// the returned ciphertext.
+func seal(aead cipher.AEAD, plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	_, err := io.ReadFull(rand.Reader, nonce)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return aead.Seal(nonce, nonce, plaintext, nil), nil
+}
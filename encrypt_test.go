@@ -0,0 +1,145 @@
+package rangepool
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/giantswarm/microstorage"
+	"github.com/giantswarm/microstorage/memory"
+)
+
+func Test_NewAESKeyEncrypter(t *testing.T) {
+	_, err := NewAESKeyEncrypter([]byte("too short"))
+	if !IsInvalidConfig(err) {
+		t.Fatal("expected", true, "got", false)
+	}
+}
+
+func Test_Service_KeyEncrypter(t *testing.T) {
+	newStorage, err := newMemoryStorage()
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+
+	newEncrypted := func(key []byte) *Service {
+		keyEncrypter, err := NewAESKeyEncrypter(key)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		s, err := NewWithOptions(newStorage, WithKeyEncrypter(keyEncrypter))
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		return s
+	}
+
+	key := bytes.Repeat([]byte{1}, 32)
+
+	ctx := context.TODO()
+
+	// Values persisted before encryption was enabled must stay readable.
+	{
+		plain, err := NewWithOptions(newStorage)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		_, err = plain.Create(ctx, namespace, "test-id-1", 1, 1, 5)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		items, err := newEncrypted(key).Search(ctx, namespace, "test-id-1")
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		expected := []int{1}
+		if !reflect.DeepEqual(items, expected) {
+			t.Fatal("expected", expected, "got", items)
+		}
+	}
+
+	// Written values must be encrypted and readable by other Services using
+	// the same key encryption key.
+	{
+		_, err = newEncrypted(key).Create(ctx, namespace, "test-id-2", 2, 1, 5)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		kvs, err := newStorage.List(ctx, DefaultKeyPrefix+"/"+namespace+"/id/test-id-2")
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		if len(kvs) != 2 {
+			t.Fatal("expected", 2, "got", len(kvs))
+		}
+		for _, kv := range kvs {
+			if !strings.HasPrefix(kv.Value, encryptedValuePrefix) {
+				t.Fatal("expected", encryptedValuePrefix, "got", kv.Value)
+			}
+		}
+
+		items, err := newEncrypted(key).Search(ctx, namespace, "test-id-2")
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		expected := []int{2, 3}
+		if !reflect.DeepEqual(items, expected) {
+			t.Fatal("expected", expected, "got", items)
+		}
+	}
+
+	// Values must not be readable using another key encryption key.
+	{
+		_, err := newEncrypted(bytes.Repeat([]byte{2}, 32)).Search(ctx, namespace, "test-id-2")
+		if !IsExecutionFailed(err) {
+			t.Fatal("expected", true, "got", false)
+		}
+	}
+}
+
+func Test_Service_KeyEncrypter_TypedStorage(t *testing.T) {
+	memoryStorage, err := memory.New(memory.DefaultConfig())
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+
+	c := DefaultMicrostorageConfig()
+	c.Storage = testTypedStorage{Storage: memoryStorage}
+	newStorage, err := NewMicrostorage(c)
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+
+	keyEncrypter, err := NewAESKeyEncrypter(bytes.Repeat([]byte{1}, 32))
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+
+	// Storages persisting typed values must be refused, since they cannot hold
+	// encrypted values.
+	_, err = NewWithOptions(newStorage, WithKeyEncrypter(keyEncrypter))
+	if !IsInvalidConfig(err) {
+		t.Fatal("expected", true, "got", false)
+	}
+
+	_, err = NewWithOptions(newStorage)
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+}
+
+// testTypedStorage pretends to persist typed values, see TypedStorage.
+type testTypedStorage struct {
+	microstorage.Storage
+}
+
+func (s testTypedStorage) TypedValues() bool {
+	return true
+}
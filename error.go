@@ -1,25 +1,167 @@
 package rangepool
 
 import (
+	"errors"
+	"fmt"
+
 	"github.com/giantswarm/microerror"
 )
 
+// The following errors can be matched using errors.Is by callers not using
+// the microerror matchers of this package. They are the same errors asserted
+// by the respective Is* functions. Errors carrying details, like
+// CapacityReachedError, unwrap to them and can be obtained using errors.As.
+var (
+	ErrCapacityReached        = capacityReachedError
+	ErrCircuitOpen            = circuitOpenError
+	ErrClosed                 = closedError
+	ErrCorrupted              = corruptedError
+	ErrExecutionFailed        = executionFailedError
+	ErrIDClassViolation       = idClassViolationError
+	ErrInvalidArgument        = invalidArgumentError
+	ErrInvalidBitmap          = invalidBitmapError
+	ErrInvalidConfig          = invalidConfigError
+	ErrInvalidRange           = invalidRangeError
+	ErrInvalidSnapshot        = invalidSnapshotError
+	ErrItemsNotFound          = itemsNotFoundError
+	ErrLimitExceeded          = limitExceededError
+	ErrNamespaceNotEmpty      = namespaceNotEmptyError
+	ErrNamespaceQuotaExceeded = namespaceQuotaExceededError
+	ErrNotLeader              = notLeaderError
+	ErrPreconditionFailed     = preconditionFailedError
+	ErrQuotaExceeded          = quotaExceededError
+	ErrRangeConflict          = rangeConflictError
+	ErrRangeNotFound          = rangeNotFoundError
+	ErrReadOnly               = readOnlyError
+	ErrReservationExpired     = reservationExpiredError
+	ErrReservationNotFound    = reservationNotFoundError
+	ErrRevisionConflict       = revisionConflictError
+	ErrSchemaMismatch         = schemaMismatchError
+	ErrSubPoolNotFound        = subPoolNotFoundError
+)
+
 var capacityReachedError = &microerror.Error{
 	Kind: "capacityReachedError",
 }
 
-// IsCapacityReached asserts capacityReachedError.
+// IsCapacityReached asserts capacityReachedError and CapacityReachedError.
 func IsCapacityReached(err error) bool {
-	return microerror.Cause(err) == capacityReachedError
+	var e *CapacityReachedError
+	return errors.As(err, &e) || microerror.Cause(err) == capacityReachedError
+}
+
+// CapacityReachedError is returned by Service.Create in case the range of a
+// namespace does not have enough free items for the requested allocation. It
+// can be obtained using AsCapacityReached.
+type CapacityReachedError struct {
+	// Free is the number of free items within the range at the time of the
+	// request.
+	Free int
+	// Max is the upper boundary of the range.
+	Max int
+	// Min is the lower boundary of the range.
+	Min int
+	// Namespace is the namespace of the range.
+	Namespace string
+	// Num is the number of items requested.
+	Num int
+}
+
+func (e *CapacityReachedError) Error() string {
+	return fmt.Sprintf("capacityReachedError: requested %d items in namespace '%s' but only %d of range %d-%d are free", e.Num, e.Namespace, e.Free, e.Min, e.Max)
+}
+
+// Unwrap returns ErrCapacityReached.
+func (e *CapacityReachedError) Unwrap() error {
+	return capacityReachedError
+}
+
+// AsCapacityReached returns the details of the given error in case it is a
+// CapacityReachedError.
+func AsCapacityReached(err error) (*CapacityReachedError, bool) {
+	var e *CapacityReachedError
+	ok := errors.As(err, &e)
+	return e, ok
+}
+
+var circuitOpenError = &microerror.Error{
+	Kind: "circuitOpenError",
+}
+
+// IsCircuitOpen asserts circuitOpenError.
+func IsCircuitOpen(err error) bool {
+	return microerror.Cause(err) == circuitOpenError
+}
+
+var closedError = &microerror.Error{
+	Kind: "closedError",
+}
+
+// IsClosed asserts closedError.
+func IsClosed(err error) bool {
+	return microerror.Cause(err) == closedError
+}
+
+// ConflictError must be returned by Storage implementations in case a write
+// conflicts with a concurrent write, e.g. because the object holding the key
+// changed since it was read, see ReadVersions. Conflicts are not retried, so
+// that the operation fails instead of applying its change to data it has not
+// seen.
+var ConflictError = &microerror.Error{
+	Kind: "conflictError",
+}
+
+// IsConflict asserts ConflictError.
+func IsConflict(err error) bool {
+	return microerror.Cause(err) == ConflictError
+}
+
+var corruptedError = &microerror.Error{
+	Kind: "corruptedError",
+}
+
+// IsCorrupted asserts corruptedError.
+func IsCorrupted(err error) bool {
+	return microerror.Cause(err) == corruptedError
 }
 
 var executionFailedError = &microerror.Error{
 	Kind: "executionFailed",
 }
 
-// IsExecutionFailed asserts executionFailedError.
+// IsExecutionFailed asserts executionFailedError. InvalidRangeError is
+// asserted as well, since invalid ranges used to be reported as
+// executionFailedError.
 func IsExecutionFailed(err error) bool {
-	return microerror.Cause(err) == executionFailedError
+	var e *InvalidRangeError
+	return errors.As(err, &e) || microerror.Cause(err) == executionFailedError
+}
+
+var idClassViolationError = &microerror.Error{
+	Kind: "idClassViolationError",
+}
+
+// IsIDClassViolation asserts idClassViolationError.
+func IsIDClassViolation(err error) bool {
+	return microerror.Cause(err) == idClassViolationError
+}
+
+var invalidArgumentError = &microerror.Error{
+	Kind: "invalidArgumentError",
+}
+
+// IsInvalidArgument asserts invalidArgumentError.
+func IsInvalidArgument(err error) bool {
+	return microerror.Cause(err) == invalidArgumentError
+}
+
+var invalidBitmapError = &microerror.Error{
+	Kind: "invalidBitmapError",
+}
+
+// IsInvalidBitmap asserts invalidBitmapError.
+func IsInvalidBitmap(err error) bool {
+	return microerror.Cause(err) == invalidBitmapError
 }
 
 var invalidConfigError = &microerror.Error{
@@ -31,6 +173,62 @@ func IsInvalidConfig(err error) bool {
 	return microerror.Cause(err) == invalidConfigError
 }
 
+var invalidRangeError = &microerror.Error{
+	Kind: "invalidRangeError",
+}
+
+// InvalidRangeError is returned in case the boundaries of a range or the
+// latest item are invalid. It can be obtained using AsInvalidRange.
+type InvalidRangeError struct {
+	// Latest is the latest item used within the range, -1 in case there is
+	// none.
+	Latest int
+	// Max is the upper boundary of the range.
+	Max int
+	// Min is the lower boundary of the range.
+	Min int
+	// Reason describes why the range is invalid.
+	Reason string
+}
+
+func (e *InvalidRangeError) Error() string {
+	return fmt.Sprintf("invalidRangeError: %s (min %d, max %d, latest %d)", e.Reason, e.Min, e.Max, e.Latest)
+}
+
+// Is reports whether target is ErrExecutionFailed, since invalid ranges used to
+// be reported as executionFailedError.
+func (e *InvalidRangeError) Is(target error) bool {
+	return target == executionFailedError
+}
+
+// Unwrap returns ErrInvalidRange.
+func (e *InvalidRangeError) Unwrap() error {
+	return invalidRangeError
+}
+
+// AsInvalidRange returns the details of the given error in case it is an
+// InvalidRangeError.
+func AsInvalidRange(err error) (*InvalidRangeError, bool) {
+	var e *InvalidRangeError
+	ok := errors.As(err, &e)
+	return e, ok
+}
+
+// IsInvalidRange asserts invalidRangeError and InvalidRangeError.
+func IsInvalidRange(err error) bool {
+	var e *InvalidRangeError
+	return errors.As(err, &e) || microerror.Cause(err) == invalidRangeError
+}
+
+var invalidSnapshotError = &microerror.Error{
+	Kind: "invalidSnapshotError",
+}
+
+// IsInvalidSnapshot asserts invalidSnapshotError.
+func IsInvalidSnapshot(err error) bool {
+	return microerror.Cause(err) == invalidSnapshotError
+}
+
 var itemsNotFoundError = &microerror.Error{
 	Kind: "itemsNotFoundError",
 }
@@ -39,3 +237,282 @@ var itemsNotFoundError = &microerror.Error{
 func IsItemsNotFound(err error) bool {
 	return microerror.Cause(err) == itemsNotFoundError
 }
+
+var limitExceededError = &microerror.Error{
+	Kind: "limitExceededError",
+}
+
+// IsLimitExceeded asserts limitExceededError.
+func IsLimitExceeded(err error) bool {
+	return microerror.Cause(err) == limitExceededError
+}
+
+var namespaceNotEmptyError = &microerror.Error{
+	Kind: "namespaceNotEmptyError",
+}
+
+// IsNamespaceNotEmpty asserts namespaceNotEmptyError.
+func IsNamespaceNotEmpty(err error) bool {
+	return microerror.Cause(err) == namespaceNotEmptyError
+}
+
+var namespaceQuotaExceededError = &microerror.Error{
+	Kind: "namespaceQuotaExceededError",
+}
+
+// IsNamespaceQuotaExceeded asserts namespaceQuotaExceededError.
+func IsNamespaceQuotaExceeded(err error) bool {
+	return microerror.Cause(err) == namespaceQuotaExceededError
+}
+
+// NotFoundError must be returned by Storage implementations in case a key
+// cannot be found.
+var NotFoundError = &microerror.Error{
+	Kind: "notFoundError",
+}
+
+// IsNotFound asserts NotFoundError.
+func IsNotFound(err error) bool {
+	return microerror.Cause(err) == NotFoundError
+}
+
+var notLeaderError = &microerror.Error{
+	Kind: "notLeaderError",
+}
+
+// IsNotLeader asserts notLeaderError and NotLeaderError.
+func IsNotLeader(err error) bool {
+	var e *NotLeaderError
+	return errors.As(err, &e) || microerror.Cause(err) == notLeaderError
+}
+
+// NotLeaderError is returned by the allocating methods of a Service in case
+// its elector does not hold the lease, see Config.Elector. It can be obtained
+// using AsNotLeader.
+type NotLeaderError struct {
+	// Leader is the identity of the leader last observed by the elector. It is
+	// empty in case no leader is known.
+	Leader string
+}
+
+func (e *NotLeaderError) Error() string {
+	return fmt.Sprintf("notLeaderError: allocations must be executed by the leader '%s'", e.Leader)
+}
+
+// Unwrap returns ErrNotLeader.
+func (e *NotLeaderError) Unwrap() error {
+	return notLeaderError
+}
+
+// AsNotLeader returns the details of the given error in case it is a
+// NotLeaderError.
+func AsNotLeader(err error) (*NotLeaderError, bool) {
+	var e *NotLeaderError
+	ok := errors.As(err, &e)
+	return e, ok
+}
+
+var preconditionFailedError = &microerror.Error{
+	Kind: "preconditionFailedError",
+}
+
+// IsPreconditionFailed asserts preconditionFailedError.
+func IsPreconditionFailed(err error) bool {
+	return microerror.Cause(err) == preconditionFailedError
+}
+
+var quotaExceededError = &microerror.Error{
+	Kind: "quotaExceededError",
+}
+
+// IsQuotaExceeded asserts quotaExceededError and QuotaExceededError.
+func IsQuotaExceeded(err error) bool {
+	var e *QuotaExceededError
+	return errors.As(err, &e) || microerror.Cause(err) == quotaExceededError
+}
+
+// QuotaExceededError is returned by Service.Create in case the requested
+// allocation would exceed the quota of the ID, see Config.IDQuota. It can be
+// obtained using AsQuotaExceeded.
+type QuotaExceededError struct {
+	// ID is the ID the items were requested for.
+	ID string
+	// Namespace is the namespace of the range.
+	Namespace string
+	// Num is the number of items requested.
+	Num int
+	// Owned is the number of items the ID held or had reserved at the time of
+	// the request.
+	Owned int
+	// Quota is the maximum number of items the ID may hold.
+	Quota int
+}
+
+func (e *QuotaExceededError) Error() string {
+	return fmt.Sprintf("quotaExceededError: requested %d items for ID '%s' in namespace '%s' holding %d items but quota is %d", e.Num, e.ID, e.Namespace, e.Owned, e.Quota)
+}
+
+// Unwrap returns ErrQuotaExceeded.
+func (e *QuotaExceededError) Unwrap() error {
+	return quotaExceededError
+}
+
+// AsQuotaExceeded returns the details of the given error in case it is a
+// QuotaExceededError.
+func AsQuotaExceeded(err error) (*QuotaExceededError, bool) {
+	var e *QuotaExceededError
+	ok := errors.As(err, &e)
+	return e, ok
+}
+
+var rangeConflictError = &microerror.Error{
+	Kind: "rangeConflictError",
+}
+
+// IsRangeConflict asserts rangeConflictError.
+func IsRangeConflict(err error) bool {
+	return microerror.Cause(err) == rangeConflictError
+}
+
+var rangeNotFoundError = &microerror.Error{
+	Kind: "rangeNotFoundError",
+}
+
+// IsRangeNotFound asserts rangeNotFoundError.
+func IsRangeNotFound(err error) bool {
+	return microerror.Cause(err) == rangeNotFoundError
+}
+
+var readOnlyError = &microerror.Error{
+	Kind: "readOnlyError",
+}
+
+// IsReadOnly asserts readOnlyError.
+func IsReadOnly(err error) bool {
+	return microerror.Cause(err) == readOnlyError
+}
+
+var reservationExpiredError = &microerror.Error{
+	Kind: "reservationExpiredError",
+}
+
+// IsReservationExpired asserts reservationExpiredError.
+func IsReservationExpired(err error) bool {
+	return microerror.Cause(err) == reservationExpiredError
+}
+
+var reservationNotFoundError = &microerror.Error{
+	Kind: "reservationNotFoundError",
+}
+
+// IsReservationNotFound asserts reservationNotFoundError.
+func IsReservationNotFound(err error) bool {
+	return microerror.Cause(err) == reservationNotFoundError
+}
+
+var revisionConflictError = &microerror.Error{
+	Kind: "revisionConflictError",
+}
+
+// IsRevisionConflict asserts revisionConflictError and RevisionConflictError.
+func IsRevisionConflict(err error) bool {
+	var e *RevisionConflictError
+	return errors.As(err, &e) || microerror.Cause(err) == revisionConflictError
+}
+
+// RevisionConflictError is returned by mutating calls in case the revision
+// carried by their context is not the current revision of the ID anymore, see
+// NewRevisionContext. It can be obtained using AsRevisionConflict.
+type RevisionConflictError struct {
+	// Actual is the current revision of the ID.
+	Actual string
+	// Expected is the revision carried by the context.
+	Expected string
+	// ID is the ID which was modified concurrently.
+	ID string
+	// Namespace is the namespace of the ID.
+	Namespace string
+}
+
+func (e *RevisionConflictError) Error() string {
+	return fmt.Sprintf("revisionConflictError: expected revision '%s' of ID '%s' in namespace '%s' but current revision is '%s'", e.Expected, e.ID, e.Namespace, e.Actual)
+}
+
+// Unwrap returns ErrRevisionConflict.
+func (e *RevisionConflictError) Unwrap() error {
+	return revisionConflictError
+}
+
+// AsRevisionConflict returns the details of the given error in case it is a
+// RevisionConflictError.
+func AsRevisionConflict(err error) (*RevisionConflictError, bool) {
+	var e *RevisionConflictError
+	ok := errors.As(err, &e)
+	return e, ok
+}
+
+// IsRollbackFailed asserts RollbackFailedError.
+func IsRollbackFailed(err error) bool {
+	var e *RollbackFailedError
+	return errors.As(err, &e)
+}
+
+// RollbackFailedError is returned by Service.CreateTuple in case rolling back
+// a failed allocation failed as well. Unwrap returns the error which caused
+// the rollback, so that it can still be asserted, e.g. using
+// IsCapacityReached. It can be obtained using AsRollbackFailed.
+type RollbackFailedError struct {
+	// Cause is the error which caused the rollback.
+	Cause error
+	// Failures are the errors the rollback failed with.
+	Failures []error
+	// Leaked are the committed items which could not be released, keyed by
+	// namespace. They stay allocated to the ID until they are released, e.g.
+	// using Service.ForceRelease. Reserved items which could not be released
+	// are not listed, since they are released once their reservations expire.
+	Leaked map[string][]int
+}
+
+func (e *RollbackFailedError) Error() string {
+	return fmt.Sprintf("rollbackFailedError: rolling back after '%s' failed with %d errors, leaking items %v: %s", e.Cause, len(e.Failures), e.Leaked, e.Failures[0])
+}
+
+// Unwrap returns the error which caused the rollback.
+func (e *RollbackFailedError) Unwrap() error {
+	return e.Cause
+}
+
+// AsRollbackFailed returns the details of the given error in case it is a
+// RollbackFailedError.
+func AsRollbackFailed(err error) (*RollbackFailedError, bool) {
+	var e *RollbackFailedError
+	ok := errors.As(err, &e)
+	return e, ok
+}
+
+var schemaMismatchError = &microerror.Error{
+	Kind: "schemaMismatchError",
+}
+
+// IsSchemaMismatch asserts schemaMismatchError.
+func IsSchemaMismatch(err error) bool {
+	return microerror.Cause(err) == schemaMismatchError
+}
+
+var stopWalkError = &microerror.Error{
+	Kind: "stopWalkError",
+}
+
+// isStopWalk asserts stopWalkError.
+func isStopWalk(err error) bool {
+	return microerror.Cause(err) == stopWalkError
+}
+
+var subPoolNotFoundError = &microerror.Error{
+	Kind: "subPoolNotFoundError",
+}
+
+// IsSubPoolNotFound asserts subPoolNotFoundError.
+func IsSubPoolNotFound(err error) bool {
+	return microerror.Cause(err) == subPoolNotFoundError
+}
@@ -0,0 +1,61 @@
+package rangepool
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/giantswarm/microerror"
+)
+
+func Test_Errors_Stdlib(t *testing.T) {
+	testCases := []struct {
+		Err     error
+		Target  error
+		Matcher func(err error) bool
+	}{
+		// Case 1 ensures masked sentinel errors match their exported value.
+		{
+			Err:     microerror.Maskf(invalidConfigError, "logger must not be empty"),
+			Target:  ErrInvalidConfig,
+			Matcher: IsInvalidConfig,
+		},
+		// Case 2 ensures masked typed errors match their sentinel error.
+		{
+			Err:     microerror.Mask(&CapacityReachedError{Num: 3}),
+			Target:  ErrCapacityReached,
+			Matcher: IsCapacityReached,
+		},
+		// Case 3 ensures invalid ranges match their sentinel error.
+		{
+			Err:     validateBoundaries(7, 2, -1),
+			Target:  ErrInvalidRange,
+			Matcher: IsInvalidRange,
+		},
+		// Case 4 ensures invalid ranges still match executionFailedError.
+		{
+			Err:     validateBoundaries(7, 2, -1),
+			Target:  ErrExecutionFailed,
+			Matcher: IsExecutionFailed,
+		},
+	}
+
+	for i, tc := range testCases {
+		if !errors.Is(tc.Err, tc.Target) {
+			t.Fatal("case", i+1, "expected", true, "got", false)
+		}
+		if !tc.Matcher(tc.Err) {
+			t.Fatal("case", i+1, "expected", true, "got", false)
+		}
+	}
+
+	// Typed errors must be obtainable using errors.As.
+	{
+		var e *CapacityReachedError
+		if !errors.As(microerror.Mask(&CapacityReachedError{Num: 3}), &e) {
+			t.Fatal("expected", true, "got", false)
+		}
+		if e.Num != 3 {
+			t.Fatal("expected", 3, "got", e.Num)
+		}
+	}
+}
@@ -0,0 +1,248 @@
+package rangepool
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/giantswarm/microerror"
+)
+
+// flightStorage coalesces concurrent List calls for the same key of the given
+// Storage, so that many goroutines allocating within the same namespace cause
+// a single listing instead of one each. Writes drop the listings in flight
+// for all keys they affect, so that listings started after a write completed
+// always reflect it. Shared listings run on a context detached from the
+// cancellation of the caller starting them, so that cancelling one caller does
+// not fail all of the callers joining it. Every caller only waits as long as
+// its own context permits. Once all callers of a shared listing gave up, the
+// listing is cancelled and forgotten, so that a hanging listing neither blocks
+// later callers nor leaks. Shared listings record the versions they read on a
+// record of their own, which is merged into the record of every caller, see
+// ReadVersions.
+type flightStorage struct {
+	// Dependencies.
+	storage Storage
+
+	// Internals.
+	calls map[string]*flightCall
+	mutex sync.Mutex
+}
+
+// flightCall is a List call in flight. done is closed once kvs and err are
+// set. versions records the versions of the objects read by the call. waiters
+// is the number of callers still waiting for the call and is guarded by the
+// mutex of the flightStorage.
+type flightCall struct {
+	cancel   context.CancelFunc
+	done     chan struct{}
+	err      error
+	kvs      []KV
+	versions *ReadVersions
+	waiters  int
+}
+
+func (f *flightStorage) Apply(ctx context.Context, kvs []KV, keys []string) error {
+	err := apply(ctx, f.storage, kvs, keys)
+	for _, kv := range kvs {
+		f.forget(kv.Key)
+	}
+	for _, k := range keys {
+		f.forget(k)
+	}
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+func (f *flightStorage) Create(ctx context.Context, key, value string) error {
+	err := f.storage.Create(ctx, key, value)
+	f.forget(key)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+func (f *flightStorage) CreateBatch(ctx context.Context, kvs []KV) error {
+	err := createBatch(ctx, f.storage, kvs)
+	for _, kv := range kvs {
+		f.forget(kv.Key)
+	}
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+func (f *flightStorage) Delete(ctx context.Context, key string) error {
+	err := f.storage.Delete(ctx, key)
+	f.forget(key)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+func (f *flightStorage) DeleteBatch(ctx context.Context, keys []string) error {
+	err := deleteBatch(ctx, f.storage, keys)
+	for _, k := range keys {
+		f.forget(k)
+	}
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// List joins the call in flight for the given key, in case there is one and
+// the context does not request ConsistencyLinearizable. Every caller gets its
+// own copy of the key-value pairs.
+func (f *flightStorage) List(ctx context.Context, key string) ([]KV, error) {
+	if isLinearizable(ctx) {
+		kvs, err := f.storage.List(ctx, key)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		return kvs, nil
+	}
+
+	f.mutex.Lock()
+	c, ok := f.calls[key]
+	if !ok {
+		versions := NewReadVersions()
+		callCtx, cancel := context.WithCancel(NewReadVersionsContext(detachedContext{parent: ctx}, versions))
+		c = &flightCall{cancel: cancel, done: make(chan struct{}), versions: versions}
+		f.calls[key] = c
+
+		go func() {
+			c.kvs, c.err = f.storage.List(callCtx, key)
+			cancel()
+
+			f.mutex.Lock()
+			if f.calls[key] == c {
+				delete(f.calls, key)
+			}
+			f.mutex.Unlock()
+
+			close(c.done)
+		}()
+	}
+	c.waiters++
+	f.mutex.Unlock()
+
+	select {
+	case <-ctx.Done():
+		f.leave(key, c)
+		return nil, microerror.Mask(ctx.Err())
+	case <-c.done:
+	}
+
+	if c.err != nil {
+		return nil, microerror.Mask(c.err)
+	}
+
+	r, ok := ReadVersionsFromContext(ctx)
+	if ok {
+		r.merge(c.versions)
+	}
+
+	return append([]KV(nil), c.kvs...), nil
+}
+
+func (f *flightStorage) Search(ctx context.Context, key string) (string, error) {
+	v, err := f.storage.Search(ctx, key)
+	if err != nil {
+		return "", microerror.Mask(err)
+	}
+
+	return v, nil
+}
+
+// Walk is only coalesced in case the underlying storage does not implement
+// WalkStorage, since walks are meant to keep the memory bounded.
+func (f *flightStorage) Walk(ctx context.Context, key string, fn func(kv KV) error) error {
+	_, ok := f.storage.(WalkStorage)
+	if ok {
+		err := walk(ctx, f.storage, key, fn)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
+		return nil
+	}
+
+	kvs, err := f.List(ctx, key)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	for _, kv := range kvs {
+		err := fn(kv)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	return nil
+}
+
+// leave removes a caller giving up on the given call. The call is cancelled
+// and forgotten once no caller waits for it anymore.
+func (f *flightStorage) leave(key string, c *flightCall) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	c.waiters--
+	if c.waiters > 0 {
+		return
+	}
+
+	c.cancel()
+	if f.calls[key] == c {
+		delete(f.calls, key)
+	}
+}
+
+// forget drops the calls in flight listing the given key, so that later
+// callers start a new listing.
+func (f *flightStorage) forget(key string) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	for k := range f.calls {
+		if key == k || strings.HasPrefix(key, k+"/") {
+			delete(f.calls, k)
+		}
+	}
+}
+
+// detachedContext carries the values of its parent, e.g. the logging
+// annotations of the operation, but neither its deadline nor its cancellation.
+type detachedContext struct {
+	parent context.Context
+}
+
+func (c detachedContext) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (c detachedContext) Done() <-chan struct{} {
+	return nil
+}
+
+func (c detachedContext) Err() error {
+	return nil
+}
+
+func (c detachedContext) Value(key interface{}) interface{} {
+	return c.parent.Value(key)
+}
@@ -0,0 +1,218 @@
+package rangepool
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/giantswarm/microerror"
+)
+
+func Test_flightStorage_List(t *testing.T) {
+	s, err := newMemoryStorage()
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+	blocking := &testBlockingStorage{Storage: s, cancelled: make(chan struct{}, 10), entered: make(chan struct{}, 10), release: make(chan struct{})}
+
+	f := &flightStorage{
+		storage: blocking,
+		calls:   map[string]*flightCall{},
+	}
+
+	ctx := context.TODO()
+
+	err = f.Create(ctx, "range-pool/test-namespace/item/1", "1")
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+
+	var wg sync.WaitGroup
+	list := func() {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			kvs, err := f.List(ctx, "range-pool/test-namespace/item")
+			if err != nil {
+				t.Error("expected", nil, "got", err)
+			}
+			if len(kvs) == 0 {
+				t.Error("expected", "items", "got", kvs)
+			}
+		}()
+	}
+
+	// Concurrent listings of the same key must cause a single listing.
+	{
+		list()
+		<-blocking.entered
+		for i := 0; i < 4; i++ {
+			list()
+		}
+		time.Sleep(50 * time.Millisecond)
+
+		blocking.release <- struct{}{}
+		wg.Wait()
+
+		if blocking.Lists != 1 {
+			t.Fatal("expected", 1, "got", blocking.Lists)
+		}
+	}
+
+	// Listings started after a write must not join listings started before
+	// it.
+	{
+		list()
+		<-blocking.entered
+
+		err := f.Create(ctx, "range-pool/test-namespace/item/2", "2")
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		list()
+		<-blocking.entered
+
+		blocking.release <- struct{}{}
+		blocking.release <- struct{}{}
+		wg.Wait()
+
+		if blocking.Lists != 3 {
+			t.Fatal("expected", 3, "got", blocking.Lists)
+		}
+	}
+
+	// Cancelling the caller which started a listing must only fail this
+	// caller, but not the callers joining the listing.
+	{
+		cancelCtx, cancel := context.WithCancel(ctx)
+
+		errs := make(chan error, 1)
+		go func() {
+			_, err := f.List(cancelCtx, "range-pool/test-namespace/item")
+			errs <- err
+		}()
+		<-blocking.entered
+
+		list()
+		time.Sleep(50 * time.Millisecond)
+
+		cancel()
+		err := <-errs
+		if err == nil {
+			t.Fatal("expected", context.Canceled, "got", nil)
+		}
+
+		blocking.release <- struct{}{}
+		wg.Wait()
+
+		if blocking.Lists != 4 {
+			t.Fatal("expected", 4, "got", blocking.Lists)
+		}
+	}
+
+	// Once all callers of a listing gave up, the listing must be cancelled and
+	// later callers must start a new listing instead of joining it.
+	{
+		cancelCtx, cancel := context.WithCancel(ctx)
+
+		errs := make(chan error, 1)
+		go func() {
+			_, err := f.List(cancelCtx, "range-pool/test-namespace/item")
+			errs <- err
+		}()
+		<-blocking.entered
+
+		cancel()
+		err := <-errs
+		if err == nil {
+			t.Fatal("expected", context.Canceled, "got", nil)
+		}
+
+		select {
+		case <-blocking.cancelled:
+		case <-time.After(5 * time.Second):
+			t.Fatal("expected", "cancelled listing", "got", nil)
+		}
+
+		list()
+		<-blocking.entered
+
+		blocking.release <- struct{}{}
+		wg.Wait()
+
+		if blocking.Lists != 6 {
+			t.Fatal("expected", 6, "got", blocking.Lists)
+		}
+	}
+
+	// The versions read by a shared listing must be recorded for all of its
+	// callers.
+	{
+		records := []*ReadVersions{NewReadVersions(), NewReadVersions()}
+		for _, r := range records {
+			wg.Add(1)
+			go func(r *ReadVersions) {
+				defer wg.Done()
+
+				_, err := f.List(NewReadVersionsContext(ctx, r), "range-pool/test-namespace/item")
+				if err != nil {
+					t.Error("expected", nil, "got", err)
+				}
+			}(r)
+		}
+		<-blocking.entered
+		time.Sleep(50 * time.Millisecond)
+
+		blocking.release <- struct{}{}
+		wg.Wait()
+
+		if blocking.Lists != 7 {
+			t.Fatal("expected", 7, "got", blocking.Lists)
+		}
+		for _, r := range records {
+			v, ok := r.Version("range-pool/test-namespace/item")
+			if !ok || v != "7" {
+				t.Fatal("expected", "7", "got", v)
+			}
+		}
+	}
+}
+
+// testBlockingStorage blocks List calls of the given Storage until they are
+// released or cancelled and counts them. The number of the listing is recorded
+// as version of the key listed, see ReadVersions.
+type testBlockingStorage struct {
+	Storage
+
+	Lists     int
+	cancelled chan struct{}
+	entered   chan struct{}
+	mutex     sync.Mutex
+	release   chan struct{}
+}
+
+func (s *testBlockingStorage) List(ctx context.Context, key string) ([]KV, error) {
+	s.mutex.Lock()
+	s.Lists++
+	version := fmt.Sprintf("%d", s.Lists)
+	s.mutex.Unlock()
+
+	s.entered <- struct{}{}
+	select {
+	case <-s.release:
+	case <-ctx.Done():
+		s.cancelled <- struct{}{}
+		return nil, microerror.Mask(ctx.Err())
+	}
+
+	r, ok := ReadVersionsFromContext(ctx)
+	if ok {
+		r.Record(key, version)
+	}
+
+	return s.Storage.List(ctx, key)
+}
@@ -0,0 +1,96 @@
+package rangepool
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/giantswarm/microerror"
+)
+
+// ForecastWindow is the period of the audit trail Service.Forecast derives
+// the allocation rate of a namespace from, in case no rate is given.
+const ForecastWindow = 7 * 24 * time.Hour
+
+// Forecast estimates when the range of a namespace runs out of free items,
+// see Service.Forecast.
+type Forecast struct {
+	// Exhaustion is the estimated time the range runs out of free items. It is
+	// the zero time in case the range is not expected to run out of free
+	// items within the next centuries, e.g. because the number of used items
+	// does not grow.
+	Exhaustion time.Time
+	// Free is the number of items within the range which are not used.
+	Free int
+	// RatePerDay is the number of items used additionally per day the
+	// forecast is based on.
+	RatePerDay float64
+}
+
+// Forecast estimates when the range of the given namespace defined by min and
+// max, both inclusive, runs out of free items, so that the range can be
+// expanded ahead of time. The estimate assumes ratePerDay items being used
+// additionally per day. In case ratePerDay is 0, the rate is derived from the
+// items allocated and released within the given range during the last
+// ForecastWindow, according to the audit trail, see Config.Audit. Audit
+// entries older than the first one within the window are not taken into
+// account, so the rate of namespaces audited only recently is not
+// underestimated.
+func (s *Service) Forecast(ctx context.Context, namespace string, min, max int, ratePerDay float64) (Forecast, error) {
+	ctx = withOperation(ctx, "Forecast", namespace, "")
+
+	if ratePerDay < 0 || math.IsNaN(ratePerDay) || math.IsInf(ratePerDay, 0) {
+		return Forecast{}, microerror.Maskf(invalidArgumentError, "rate per day must be a finite number of at least 0")
+	}
+
+	st, err := s.Status(ctx, namespace, min, max)
+	if err != nil {
+		return Forecast{}, microerror.Mask(err)
+	}
+
+	now := s.clock.Now()
+
+	if ratePerDay == 0 {
+		entries, err := s.AuditLog(ctx, namespace, now.Add(-ForecastWindow))
+		if err != nil {
+			return Forecast{}, microerror.Mask(err)
+		}
+
+		if len(entries) != 0 {
+			var net int
+			for _, e := range entries {
+				switch e.Action {
+				case AuditActionAllocate:
+					net += countInRange(e.Items, min, max)
+				case AuditActionRelease:
+					net -= countInRange(e.Items, min, max)
+				}
+			}
+
+			days := now.Sub(entries[0].Time).Hours() / 24
+			if days > 0 {
+				ratePerDay = float64(net) / days
+			}
+		}
+	}
+
+	f := Forecast{
+		Free:       st.Free,
+		RatePerDay: ratePerDay,
+	}
+
+	if st.Free == 0 {
+		f.Exhaustion = now
+	} else if ratePerDay > 0 {
+		// Estimates beyond the range of time.Duration are as good as never.
+		d := float64(st.Free) / ratePerDay * float64(24*time.Hour)
+		if d < math.MaxInt64 {
+			f.Exhaustion = now.Add(time.Duration(d))
+		}
+	}
+
+	s.logger.LogCtx(ctx, "level", "debug", "message", fmt.Sprintf("forecasted namespace '%s' with %d free items at %.2f items per day", namespace, f.Free, f.RatePerDay))
+
+	return f, nil
+}
@@ -0,0 +1,88 @@
+package rangepool
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func Test_Service_Forecast(t *testing.T) {
+	newStorage, err := newMemoryStorage()
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+
+	clock := &testClock{now: time.Unix(0, 0)}
+
+	newService, err := NewWithOptions(newStorage, WithAudit(true), WithClock(clock))
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+
+	ctx := context.TODO()
+
+	// Namespaces without audit trail are not expected to run out of items.
+	{
+		f, err := newService.Forecast(ctx, namespace, 1, 100, 0)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		if !f.Exhaustion.IsZero() {
+			t.Fatal("expected", time.Time{}, "got", f.Exhaustion)
+		}
+		if f.Free != 100 {
+			t.Fatal("expected", 100, "got", f.Free)
+		}
+	}
+
+	// The given rate must be used.
+	{
+		f, err := newService.Forecast(ctx, namespace, 1, 100, 10)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		expected := clock.now.Add(10 * 24 * time.Hour)
+		if !f.Exhaustion.Equal(expected) {
+			t.Fatal("expected", expected, "got", f.Exhaustion)
+		}
+	}
+
+	// The rate must be derived from the audit trail. 12 items are allocated
+	// and 2 released within 2 days, so the remaining 90 items last 18 days.
+	{
+		_, err = newService.Create(ctx, namespace, "test-id-1", 2, 1, 100)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		clock.now = clock.now.Add(24 * time.Hour)
+		_, err = newService.Create(ctx, namespace, "test-id-2", 10, 1, 100)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		err = newService.Delete(ctx, namespace, "test-id-1")
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		clock.now = clock.now.Add(24 * time.Hour)
+
+		f, err := newService.Forecast(ctx, namespace, 1, 100, 0)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		if f.RatePerDay != 5 {
+			t.Fatal("expected", 5, "got", f.RatePerDay)
+		}
+		expected := clock.now.Add(18 * 24 * time.Hour)
+		if !f.Exhaustion.Equal(expected) {
+			t.Fatal("expected", expected, "got", f.Exhaustion)
+		}
+	}
+
+	// Negative rates must be refused.
+	{
+		_, err := newService.Forecast(ctx, namespace, 1, 100, -1)
+		if !IsInvalidArgument(err) {
+			t.Fatal("expected", true, "got", err)
+		}
+	}
+}
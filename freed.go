@@ -0,0 +1,118 @@
+package rangepool
+
+import (
+	"context"
+	"sort"
+	"strconv"
+
+	"github.com/giantswarm/microerror"
+)
+
+// recordFreed persists the time the given items were released at in case
+// Config.LatestMode is LatestModeLeastRecentlyFreed or the policy of the
+// namespace defines a cooldown.
+func (s *Service) recordFreed(ctx context.Context, namespace string, items []int) error {
+	if s.latestMode != LatestModeLeastRecentlyFreed && s.cooldown == 0 || len(items) == 0 {
+		return nil
+	}
+
+	now := strconv.FormatInt(s.clock.Now().UnixNano(), 10)
+
+	var kvs []KV
+	for _, item := range items {
+		kvs = append(kvs, KV{Key: s.key(FreedKeyFormat, namespace, s.encodeItem(item)), Value: now})
+	}
+
+	err := createBatch(ctx, s.storage, kvs)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// searchLeastRecentlyFreed returns up to num items within the range defined by
+// min and max which are not contained in the given used items, in case
+// Config.LatestMode is LatestModeLeastRecentlyFreed. Items which have never
+// been used are returned first, followed by the items which have been
+// released the longest ago. Release times of used items are ignored, so they
+// do not have to be removed on allocation.
+func (s *Service) searchLeastRecentlyFreed(ctx context.Context, namespace string, num, min, max int, used []int) ([]int, error) {
+	if s.latestMode != LatestModeLeastRecentlyFreed || num <= 0 {
+		return nil, nil
+	}
+
+	freed := map[int]int64{}
+	{
+		err := walk(ctx, s.storage, s.key(FreedListKeyFormat, namespace), func(kv KV) error {
+			item, err := strconv.Atoi(kv.Key)
+			if err != nil {
+				return microerror.Mask(err)
+			}
+			t, err := strconv.ParseInt(kv.Value, 10, 64)
+			if err != nil {
+				return microerror.Mask(err)
+			}
+			freed[item] = t
+
+			return nil
+		})
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
+	// Items which have been released before are blocked while looking for
+	// items which have never been used.
+	blocked := append([]int{}, used...)
+	for item := range freed {
+		blocked = append(blocked, item)
+	}
+	sort.Ints(blocked)
+
+	var items []int
+	for len(items) < num {
+		item, err := s.nextItem(blocked, min, max, latestItemException)
+		if IsCapacityReached(err) {
+			break
+		} else if err != nil {
+			return nil, microerror.Mask(err)
+		}
+		items = append(items, item)
+		blocked = insertSorted(blocked, item)
+	}
+
+	if len(items) == num {
+		return items, nil
+	}
+
+	sort.Ints(used)
+
+	var candidates []int
+	for item := range freed {
+		if item < min || item > max {
+			continue
+		}
+		i := sort.SearchInts(used, item)
+		if i < len(used) && used[i] == item {
+			continue
+		}
+
+		candidates = append(candidates, item)
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if freed[candidates[i]] == freed[candidates[j]] {
+			return candidates[i] < candidates[j]
+		}
+		return freed[candidates[i]] < freed[candidates[j]]
+	})
+
+	for _, item := range candidates {
+		if len(items) == num {
+			break
+		}
+		items = append(items, item)
+	}
+
+	return items, nil
+}
@@ -0,0 +1,73 @@
+package rangepool
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/giantswarm/micrologger/microloggertest"
+)
+
+func Test_Service_Create_LeastRecentlyFreed(t *testing.T) {
+	for _, b := range []bool{false, true} {
+		var err error
+		var newService *Service
+		{
+			newStorage, err := newMemoryStorage()
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+
+			config := DefaultConfig()
+			config.Logger = microloggertest.New()
+			config.Storage = newStorage
+			config.Bitmap = b
+			config.LatestMode = LatestModeLeastRecentlyFreed
+			newService, err = New(config)
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+		}
+
+		ctx := context.TODO()
+
+		create := func(ID string, num int, expected []int) {
+			items, err := newService.Create(ctx, namespace, ID, num, 2, 7)
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+			if !reflect.DeepEqual(items, expected) {
+				t.Fatal("expected", expected, "got", items)
+			}
+		}
+		release := func(ID string) {
+			err := newService.Delete(ctx, namespace, ID)
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+			// Release times must differ.
+			time.Sleep(time.Millisecond)
+		}
+
+		create("test-id-1", 1, []int{2})
+		create("test-id-2", 1, []int{3})
+		create("test-id-3", 1, []int{4})
+		release("test-id-2")
+		release("test-id-1")
+
+		// Items which have never been used must come first, even though
+		// released items are lower.
+		create("test-id-4", 3, []int{5, 6, 7})
+
+		// Released items must be reused in the order they were released.
+		create("test-id-5", 1, []int{3})
+		release("test-id-3")
+		create("test-id-6", 2, []int{2, 4})
+
+		_, err = newService.Create(ctx, namespace, "test-id-7", 1, 2, 7)
+		if !IsCapacityReached(err) {
+			t.Fatal("expected", true, "got", false)
+		}
+	}
+}
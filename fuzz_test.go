@@ -0,0 +1,244 @@
+//go:build go1.18
+// +build go1.18
+
+package rangepool
+
+import (
+	"reflect"
+	"sort"
+	"strconv"
+	"testing"
+)
+
+// The fuzz targets of this file require Go 1.18 or later. They can be run
+// using e.g. go test -fuzz FuzzNextItem. Without -fuzz only the seed corpus is
+// executed as part of the regular tests.
+
+// FuzzNextItem ensures nextItem either finds a free item within the range or
+// fails for a reason matching its arguments. Every byte of raw becomes a used
+// item, so that ranges are densely populated.
+func FuzzNextItem(f *testing.F) {
+	f.Add([]byte{}, 0, 10, -1)
+	f.Add([]byte{0, 1, 2}, 1, 3, 3)
+	f.Add([]byte{5, 6, 7}, 4, 8, 9)
+	f.Add([]byte{1}, 2, 1, -1)
+	f.Add([]byte{255, 0}, 0, 255, 254)
+
+	f.Fuzz(func(t *testing.T, raw []byte, min, max, latest int) {
+		// Keep the ranges small, so that exhausted ranges are found as well.
+		if min > 1<<16 || max > 1<<16 || max-min > 1<<10 {
+			t.Skip()
+		}
+
+		var used []int
+		for _, b := range raw {
+			used = append(used, min+int(b))
+		}
+
+		item, err := nextItem(append([]int{}, used...), min, max, latest)
+
+		if r, ok := AsInvalidRange(err); ok {
+			var expected string
+			switch {
+			case min < 0:
+				expected = "min must not be negative"
+			case max < 0:
+				expected = "max must not be negative"
+			case min >= max:
+				expected = "min must be lower than max"
+			case latest < min:
+				expected = "latest must not be lower than min"
+			case latest > max:
+				expected = "latest must not be greater than max"
+			}
+			if r.Reason != expected {
+				t.Fatal("expected", expected, "got", r.Reason)
+			}
+			return
+		}
+
+		if min < 0 || max < 0 || min >= max || latest != latestItemException && (latest < min || latest > max) {
+			t.Fatal("expected", "invalid range error", "got", err)
+		}
+
+		set := map[int]bool{}
+		for _, u := range used {
+			set[u] = true
+		}
+
+		if IsCapacityReached(err) {
+			for i := min; i <= max; i++ {
+				if !set[i] {
+					t.Fatal("expected", "free item", i, "got", err)
+				}
+			}
+			return
+		}
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		if item < min || item > max {
+			t.Fatal("expected", "item in between", min, "and", max, "got", item)
+		}
+		if set[item] {
+			t.Fatal("expected", "unused item", "got", item)
+		}
+
+		// Items after latest are preferred, so there must not be a free item in
+		// between latest and the returned item.
+		if latest != latestItemException && item > latest {
+			for i := latest + 1; i < item; i++ {
+				if !set[i] {
+					t.Fatal("expected", i, "got", item)
+				}
+			}
+		}
+	})
+}
+
+// FuzzNextItems ensures NextItems finds the same items as calling nextItem
+// num times with the same latest item, adding every item found to the used
+// ones, like Service.Create used to.
+func FuzzNextItems(f *testing.F) {
+	f.Add([]byte{}, 0, 10, -1, 3)
+	f.Add([]byte{0, 1, 2}, 0, 5, 1, 3)
+	f.Add([]byte{5, 6, 7}, 4, 12, 6, 6)
+	f.Add([]byte{3, 3, 9}, 0, 9, 9, 7)
+
+	f.Fuzz(func(t *testing.T, raw []byte, min, max, latest, num int) {
+		// Keep the ranges small, so that exhausted ranges are found as well.
+		if min < 0 || min > 1<<16 || max < min+1 || max-min > 1<<10 || latest != latestItemException && (latest < min || latest > max) || num <= 0 || num > 1<<10 {
+			t.Skip()
+		}
+
+		var used []int
+		for _, b := range raw {
+			used = append(used, min+int(b))
+		}
+
+		items, err := NextItems(used, min, max, latest, num)
+
+		var expected []int
+		var expectedErr error
+		for len(expected) < num {
+			item, err := nextItem(append(append([]int{}, used...), expected...), min, max, latest)
+			if err != nil {
+				expectedErr = err
+				break
+			}
+			expected = append(expected, item)
+		}
+
+		if expectedErr != nil {
+			c, ok := AsCapacityReached(err)
+			if !ok {
+				t.Fatal("expected", expectedErr, "got", err)
+			}
+			if c.Free != len(expected) {
+				t.Fatal("expected", len(expected), "got", c.Free)
+			}
+			return
+		}
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		if !reflect.DeepEqual(items, expected) {
+			t.Fatal("expected", expected, "got", items)
+		}
+	})
+}
+
+// FuzzBitmap ensures persisted bitmaps either fail to decode or survive
+// encoding, and that NextUnset and PrevUnset agree with scanning the same
+// items one by one.
+func FuzzBitmap(f *testing.F) {
+	f.Add("", 0, 10)
+	f.Add("AQAAAAAAAAA=", 0, 63)
+	f.Add("/////////////////////w==", 60, 130)
+	f.Add("not base64", 0, 1)
+
+	f.Fuzz(func(t *testing.T, s string, from, to int) {
+		m, err := newBitmap(s)
+		if IsInvalidBitmap(err) {
+			return
+		} else if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		for _, e := range []string{m.String(), m.Compress()} {
+			decoded, err := newBitmap(e)
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+			if !reflect.DeepEqual(decoded.Items(), m.Items()) {
+				t.Fatal("expected", m.Items(), "got", decoded.Items())
+			}
+		}
+		if m.Len() != len(m.Items()) {
+			t.Fatal("expected", len(m.Items()), "got", m.Len())
+		}
+
+		if from < 0 || to < from || to-from > 1<<12 {
+			return
+		}
+
+		expected := firstGap(m.Items(), from, to)
+		next := m.NextUnset(from, to)
+		if next != expected {
+			t.Fatal("expected", expected, "got", next)
+		}
+
+		expected = -1
+		for i := to; i >= from; i-- {
+			if !m.IsSet(i) {
+				expected = i
+				break
+			}
+		}
+		prev := m.PrevUnset(from, to)
+		if prev != expected {
+			t.Fatal("expected", expected, "got", prev)
+		}
+	})
+}
+
+// FuzzEncodeItem ensures items survive being encoded within storage keys and
+// that zero padded keys sort like the items they encode.
+func FuzzEncodeItem(f *testing.F) {
+	f.Add(uint32(0), uint32(1), false)
+	f.Add(uint32(9), uint32(10), true)
+	f.Add(uint32(4294967295), uint32(100), true)
+
+	f.Fuzz(func(t *testing.T, a, b uint32, zeroPaddedKeys bool) {
+		s := &Service{zeroPaddedKeys: zeroPaddedKeys}
+
+		items := []int{int(a), int(b)}
+		var keys []string
+		for _, item := range items {
+			k := s.encodeItem(item)
+
+			decoded, err := strconv.Atoi(k)
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+			if decoded != item {
+				t.Fatal("expected", item, "got", decoded)
+			}
+
+			keys = append(keys, k)
+		}
+
+		if !zeroPaddedKeys {
+			return
+		}
+
+		sort.Ints(items)
+		sort.Strings(keys)
+		for i := range items {
+			if keys[i] != s.encodeItem(items[i]) {
+				t.Fatal("expected", s.encodeItem(items[i]), "got", keys[i])
+			}
+		}
+	})
+}
@@ -0,0 +1,168 @@
+package rangepool
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/giantswarm/microerror"
+)
+
+// GCReport describes the keys of a namespace found by Service.GC.
+type GCReport struct {
+	// DanglingIDKeys are the keys relating IDs to items which are not used
+	// within the namespace, e.g. left behind by an interrupted release.
+	DanglingIDKeys []string
+	// DryRun is whether the keys have only been found, but not removed.
+	DryRun bool
+	// Namespace is the namespace the report belongs to.
+	Namespace string
+	// OrphanedItems are the items used within the namespace which are not
+	// owned by any ID, e.g. left behind by an interrupted allocation.
+	OrphanedItems []int
+}
+
+// GC finds the keys of the given namespace which are missing their
+// counterparts, because an allocation or release was interrupted, and removes
+// them. Orphaned items are freed and dangling ID keys are deleted, which
+// completes interrupted releases and rolls back interrupted allocations. In
+// case dryRun is true, the keys are only reported. GC must not be executed
+// concurrently with other operations on the same namespace.
+func (s *Service) GC(ctx context.Context, namespace string, dryRun bool) (GCReport, error) {
+	ctx = withOperation(ctx, "GC", namespace, "")
+
+	if !dryRun {
+		err := s.checkReadOnly()
+		if err != nil {
+			return GCReport{}, microerror.Mask(err)
+		}
+
+		err = s.checkLeader()
+		if err != nil {
+			return GCReport{}, microerror.Mask(err)
+		}
+	}
+
+	// Collect the ID keys of the namespace, ${id1}/item/${item1}. Keys are
+	// tracked as they are persisted, since their encoding may differ from the
+	// configured one, see Config.ZeroPaddedKeys.
+	IDKeys := map[string]int{}
+	owned := map[int]struct{}{}
+	{
+		prefix := s.key(IDPrefixKeyFormat, namespace)
+		err := walk(ctx, s.storage, prefix, func(kv KV) error {
+			if !strings.Contains(kv.Key, "/item/") {
+				return nil
+			}
+
+			i, err := strconv.Atoi(kv.Value)
+			if err != nil {
+				return microerror.Mask(err)
+			}
+			IDKeys[prefix+"/"+kv.Key] = i
+			owned[i] = struct{}{}
+
+			return nil
+		})
+		if err != nil {
+			return GCReport{}, microerror.Mask(err)
+		}
+	}
+
+	// Collect the used items of the namespace.
+	var used bitmap
+	itemKeys := map[int]string{}
+	if s.bitmap {
+		var err error
+		used, err = s.searchBitmap(ctx, namespace)
+		if err != nil {
+			return GCReport{}, microerror.Mask(err)
+		}
+	} else {
+		prefix := s.key(ItemListKeyFormat, namespace)
+		err := walk(ctx, s.storage, prefix, func(kv KV) error {
+			i, err := strconv.Atoi(kv.Value)
+			if err != nil {
+				return microerror.Mask(err)
+			}
+			itemKeys[i] = prefix + "/" + kv.Key
+
+			return nil
+		})
+		if err != nil {
+			return GCReport{}, microerror.Mask(err)
+		}
+	}
+	isUsed := func(item int) bool {
+		if s.bitmap {
+			return used.IsSet(item)
+		}
+		_, ok := itemKeys[item]
+		return ok
+	}
+
+	r := GCReport{
+		DryRun:    dryRun,
+		Namespace: namespace,
+	}
+	for k, item := range IDKeys {
+		if !isUsed(item) {
+			r.DanglingIDKeys = append(r.DanglingIDKeys, k)
+		}
+	}
+	if s.bitmap {
+		for _, item := range used.Items() {
+			_, ok := owned[item]
+			if !ok {
+				r.OrphanedItems = append(r.OrphanedItems, item)
+			}
+		}
+	} else {
+		for item := range itemKeys {
+			_, ok := owned[item]
+			if !ok {
+				r.OrphanedItems = append(r.OrphanedItems, item)
+			}
+		}
+	}
+	sort.Strings(r.DanglingIDKeys)
+	sort.Ints(r.OrphanedItems)
+
+	if dryRun || (len(r.DanglingIDKeys) == 0 && len(r.OrphanedItems) == 0) {
+		return r, nil
+	}
+
+	// The cached items of the namespace are going to be rewritten.
+	defer s.InvalidateCache(namespace)
+
+	keys := append([]string{}, r.DanglingIDKeys...)
+	if s.bitmap {
+		for _, item := range r.OrphanedItems {
+			used.Unset(item)
+		}
+
+		if used.Len() == 0 {
+			keys = append(keys, s.key(BitmapKeyFormat, namespace))
+		} else {
+			err := s.storage.Create(ctx, s.key(BitmapKeyFormat, namespace), s.encodeBitmap(used))
+			if err != nil {
+				return GCReport{}, microerror.Mask(err)
+			}
+		}
+	} else {
+		for _, item := range r.OrphanedItems {
+			keys = append(keys, itemKeys[item])
+		}
+	}
+
+	err := deleteBatch(ctx, s.storage, keys)
+	if err != nil {
+		return GCReport{}, microerror.Mask(err)
+	}
+
+	s.logger.LogCtx(ctx, "level", "debug", "message", fmt.Sprintf("removed %d dangling ID keys and %d orphaned items of namespace '%s'", len(r.DanglingIDKeys), len(r.OrphanedItems), namespace))
+
+	return r, nil
+}
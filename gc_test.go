@@ -0,0 +1,116 @@
+package rangepool
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/giantswarm/micrologger/microloggertest"
+)
+
+func Test_Service_GC(t *testing.T) {
+	for _, b := range []bool{false, true} {
+		var err error
+		var newService *Service
+		{
+			newStorage, err := newMemoryStorage()
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+
+			config := DefaultConfig()
+			config.Logger = microloggertest.New()
+			config.Storage = newStorage
+			config.Bitmap = b
+			newService, err = New(config)
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+		}
+
+		ctx := context.TODO()
+
+		// Allocate items and simulate an interrupted allocation of item 4,
+		// which left an orphaned item behind, and an interrupted release of
+		// item 9, which left a dangling ID key behind.
+		{
+			_, err = newService.Create(ctx, namespace, "test-id-1", 2, 2, 10)
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+			_, err = newService.Create(ctx, namespace, "test-id-2", 1, 2, 10)
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+			err = newService.storage.Delete(ctx, fmt.Sprintf(IDKeyFormat, namespace, "test-id-2", "4"))
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+			err = newService.storage.Create(ctx, fmt.Sprintf(IDKeyFormat, namespace, "test-id-3", "9"), "9")
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+		}
+
+		expected := GCReport{
+			DanglingIDKeys: []string{fmt.Sprintf(IDKeyFormat, namespace, "test-id-3", "9")},
+			DryRun:         true,
+			Namespace:      namespace,
+			OrphanedItems:  []int{4},
+		}
+
+		// A dry run must report the keys without removing them.
+		{
+			r, err := newService.GC(ctx, namespace, true)
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+			if !reflect.DeepEqual(r, expected) {
+				t.Fatal("expected", expected, "got", r)
+			}
+
+			r, err = newService.GC(ctx, namespace, true)
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+			if !reflect.DeepEqual(r, expected) {
+				t.Fatal("expected", expected, "got", r)
+			}
+		}
+
+		// Collecting garbage must remove the keys.
+		{
+			r, err := newService.GC(ctx, namespace, false)
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+			expected.DryRun = false
+			if !reflect.DeepEqual(r, expected) {
+				t.Fatal("expected", expected, "got", r)
+			}
+
+			d, err := newService.Dump(ctx, namespace)
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+			if !reflect.DeepEqual(d.Used, []int{2, 3}) {
+				t.Fatal("expected", []int{2, 3}, "got", d.Used)
+			}
+			if !reflect.DeepEqual(d.IDs, map[string][]int{"test-id-1": {2, 3}}) {
+				t.Fatal("expected", map[string][]int{"test-id-1": {2, 3}}, "got", d.IDs)
+			}
+		}
+
+		// A clean namespace must not report anything.
+		{
+			r, err := newService.GC(ctx, namespace, false)
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+			if len(r.DanglingIDKeys) != 0 || len(r.OrphanedItems) != 0 {
+				t.Fatal("expected", "empty report", "got", r)
+			}
+		}
+	}
+}
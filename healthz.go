@@ -0,0 +1,21 @@
+package rangepool
+
+import (
+	"context"
+
+	"github.com/giantswarm/microerror"
+)
+
+// Healthz checks whether the storage is reachable by reading a sentinel key,
+// see HealthzKeyFormat. The sentinel key not existing is considered healthy.
+// Healthz is cheap enough to be used for readiness probes.
+func (s *Service) Healthz(ctx context.Context) error {
+	_, err := s.storage.Search(ctx, s.key(HealthzKeyFormat))
+	if IsNotFound(err) {
+		// Fall through since the storage answered our request.
+	} else if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
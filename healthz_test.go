@@ -0,0 +1,56 @@
+package rangepool
+
+import (
+	"context"
+	"testing"
+
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger/microloggertest"
+)
+
+func Test_Service_Healthz(t *testing.T) {
+	s, err := newMemoryStorage()
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+	newStorage := &testUnavailableStorage{Storage: s}
+
+	config := DefaultConfig()
+	config.Logger = microloggertest.New()
+	config.Storage = newStorage
+	newService, err := New(config)
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+
+	ctx := context.TODO()
+
+	// A reachable storage must be healthy, even though the sentinel key does
+	// not exist.
+	err = newService.Healthz(ctx)
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+
+	// An unreachable storage must not be healthy.
+	newStorage.Unavailable = true
+	err = newService.Healthz(ctx)
+	if !IsExecutionFailed(err) {
+		t.Fatal("expected", true, "got", false)
+	}
+}
+
+// testUnavailableStorage fails all searches in case Unavailable is set.
+type testUnavailableStorage struct {
+	Storage
+
+	Unavailable bool
+}
+
+func (s *testUnavailableStorage) Search(ctx context.Context, key string) (string, error) {
+	if s.Unavailable {
+		return "", microerror.Maskf(executionFailedError, "storage unavailable")
+	}
+
+	return s.Storage.Search(ctx, key)
+}
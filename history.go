@@ -0,0 +1,90 @@
+package rangepool
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/giantswarm/microerror"
+)
+
+// HistoryEntry describes a previous owner of an item, see Service.History.
+type HistoryEntry struct {
+	// ID is the ID which owned the item.
+	ID string `json:"id"`
+	// ReleasedAt is the time the item was released by the ID at.
+	ReleasedAt time.Time `json:"releasedAt"`
+}
+
+// History returns the previous owners of the given item within the given
+// namespace, starting with the most recent one, e.g. to find out which
+// cluster used to hold an item once traffic shows up for it unexpectedly. At
+// most Config.HistorySize owners are remembered per item. Items which were
+// never released, or released while the history was disabled, have no
+// history.
+func (s *Service) History(ctx context.Context, namespace string, item int) ([]HistoryEntry, error) {
+	ctx = withOperation(ctx, "History", namespace, "")
+
+	entries, err := s.searchHistory(ctx, namespace, item)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return entries, nil
+}
+
+// recordHistory remembers the given ID as the most recent previous owner of
+// the given released items in case Config.HistorySize is greater than zero.
+func (s *Service) recordHistory(ctx context.Context, namespace, ID string, items []int) error {
+	if s.historySize == 0 || len(items) == 0 {
+		return nil
+	}
+
+	now := s.clock.Now().UTC()
+
+	var kvs []KV
+	for _, item := range items {
+		entries, err := s.searchHistory(ctx, namespace, item)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
+		entries = append([]HistoryEntry{{ID: ID, ReleasedAt: now}}, entries...)
+		if len(entries) > s.historySize {
+			entries = entries[:s.historySize]
+		}
+
+		b, err := json.Marshal(entries)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
+		kvs = append(kvs, KV{Key: s.key(HistoryKeyFormat, namespace, s.encodeItem(item)), Value: string(b)})
+	}
+
+	err := createBatch(ctx, s.storage, kvs)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// searchHistory fetches the previous owners of the given item, starting with
+// the most recent one.
+func (s *Service) searchHistory(ctx context.Context, namespace string, item int) ([]HistoryEntry, error) {
+	v, err := s.storage.Search(ctx, s.key(HistoryKeyFormat, namespace, s.encodeItem(item)))
+	if IsNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	var entries []HistoryEntry
+	err = json.Unmarshal([]byte(v), &entries)
+	if err != nil {
+		return nil, microerror.Maskf(executionFailedError, "decoding history of item %d: %s", item, err.Error())
+	}
+
+	return entries, nil
+}
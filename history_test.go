@@ -0,0 +1,88 @@
+package rangepool
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_Service_History(t *testing.T) {
+	newStorage, err := newMemoryStorage()
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+	clock := &testClock{now: time.Unix(0, 0).UTC()}
+	newService, err := NewWithOptions(newStorage, WithClock(clock), WithHistorySize(2), WithLatestMode(LatestModeLowestFree))
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+
+	ctx := context.TODO()
+
+	// Item 2 is held and released by three IDs in turn, so that the oldest
+	// owner is forgotten.
+	for _, ID := range []string{"test-id-1", "test-id-2", "test-id-3"} {
+		clock.now = clock.now.Add(time.Hour)
+
+		items, err := newService.Create(ctx, namespace, ID, 1, 2, 5)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		if !reflect.DeepEqual(items, []int{2}) {
+			t.Fatal("expected", []int{2}, "got", items)
+		}
+
+		err = newService.Delete(ctx, namespace, ID)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+	}
+
+	// Force released items are recorded as well.
+	_, err = newService.Create(ctx, namespace, "test-id-4", 2, 2, 5)
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+	err = newService.ForceRelease(ctx, namespace, 3)
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+
+	testCases := []struct {
+		Item            int
+		ExpectedEntries []HistoryEntry
+	}{
+		// Case 1 ensures the most recent owners are returned first and the
+		// history is bounded.
+		{
+			Item: 2,
+			ExpectedEntries: []HistoryEntry{
+				{ID: "test-id-3", ReleasedAt: time.Unix(0, 0).UTC().Add(3 * time.Hour)},
+				{ID: "test-id-2", ReleasedAt: time.Unix(0, 0).UTC().Add(2 * time.Hour)},
+			},
+		},
+		// Case 2 ensures force released items are recorded.
+		{
+			Item: 3,
+			ExpectedEntries: []HistoryEntry{
+				{ID: "test-id-4", ReleasedAt: time.Unix(0, 0).UTC().Add(3 * time.Hour)},
+			},
+		},
+		// Case 3 ensures items which were never released have no history.
+		{
+			Item:            4,
+			ExpectedEntries: nil,
+		},
+	}
+
+	for i, tc := range testCases {
+		entries, err := newService.History(ctx, namespace, tc.Item)
+		if err != nil {
+			t.Fatal("case", i+1, "expected", nil, "got", err)
+		}
+		if !reflect.DeepEqual(entries, tc.ExpectedEntries) {
+			t.Fatal("case", i+1, "expected", tc.ExpectedEntries, "got", entries)
+		}
+	}
+}
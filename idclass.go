@@ -0,0 +1,69 @@
+package rangepool
+
+import (
+	"strings"
+
+	"github.com/giantswarm/microerror"
+)
+
+// IDClass reserves a sub-range of a namespace for all IDs starting with a
+// prefix, see Policy.IDClasses.
+type IDClass struct {
+	// Max is the max boundary of the sub-range, inclusive.
+	Max int `json:"max"`
+	// Min is the min boundary of the sub-range, inclusive.
+	Min int `json:"min"`
+	// Prefix is the prefix of the IDs of the class, e.g. "tenant-".
+	Prefix string `json:"prefix"`
+}
+
+// checkIDClass ensures that the given ID only allocates within the sub-range
+// of its class, see Policy.IDClasses. In case the requested range is not
+// within the sub-range of the class, an error is returned which can be
+// asserted using IsIDClassViolation.
+func (s *Service) checkIDClass(namespace, ID string, min, max int) error {
+	c, ok := matchIDClass(s.idClasses, ID)
+	if !ok {
+		return nil
+	}
+
+	if min < c.Min || max > c.Max {
+		return microerror.Maskf(idClassViolationError, "ID '%s' of namespace '%s' must allocate within %d-%d of class '%s', got %d-%d", ID, namespace, c.Min, c.Max, c.Prefix, min, max)
+	}
+
+	return nil
+}
+
+// matchIDClass returns the class of the given ID. The class of the longest
+// matching prefix wins.
+func matchIDClass(classes []IDClass, ID string) (IDClass, bool) {
+	var match IDClass
+	var ok bool
+	for _, c := range classes {
+		if strings.HasPrefix(ID, c.Prefix) && (!ok || len(c.Prefix) > len(match.Prefix)) {
+			match = c
+			ok = true
+		}
+	}
+
+	return match, ok
+}
+
+func validateIDClasses(classes []IDClass) error {
+	prefixes := map[string]bool{}
+	for _, c := range classes {
+		if c.Prefix == "" {
+			return microerror.Maskf(invalidArgumentError, "ID class prefix must not be empty")
+		}
+		if prefixes[c.Prefix] {
+			return microerror.Maskf(invalidArgumentError, "ID class prefix '%s' must be unique", c.Prefix)
+		}
+		if c.Min < 0 || c.Max < c.Min {
+			return microerror.Maskf(invalidArgumentError, "ID class '%s' must define 0 <= min <= max", c.Prefix)
+		}
+
+		prefixes[c.Prefix] = true
+	}
+
+	return nil
+}
@@ -0,0 +1,93 @@
+package rangepool
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/giantswarm/micrologger/microloggertest"
+)
+
+func Test_Service_Create_IDClasses(t *testing.T) {
+	var err error
+	var newService *Service
+	{
+		newStorage, err := newMemoryStorage()
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		config := DefaultConfig()
+		config.Logger = microloggertest.New()
+		config.Storage = newStorage
+		newService, err = New(config)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+	}
+
+	ctx := context.TODO()
+
+	// Duplicated prefixes must be rejected.
+	{
+		p := Policy{
+			IDClasses: []IDClass{
+				{Prefix: "tenant-", Min: 5, Max: 9},
+				{Prefix: "tenant-", Min: 0, Max: 4},
+			},
+		}
+		err := newService.SetPolicy(ctx, namespace, p)
+		if !IsInvalidArgument(err) {
+			t.Fatal("expected", true, "got", false)
+		}
+	}
+
+	{
+		p := Policy{
+			IDClasses: []IDClass{
+				{Prefix: "tenant-", Min: 5, Max: 9},
+				{Prefix: "tenant-system-", Min: 10, Max: 12},
+			},
+		}
+		err = newService.SetPolicy(ctx, namespace, p)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+	}
+
+	// IDs of a class must not allocate outside of their sub-range.
+	{
+		_, err := newService.Create(ctx, namespace, "tenant-a", 1, 0, 9)
+		if !IsIDClassViolation(err) {
+			t.Fatal("expected", true, "got", false)
+		}
+	}
+
+	// IDs of a class must allocate within their sub-range.
+	{
+		items, err := newService.Create(ctx, namespace, "tenant-a", 2, 5, 9)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		expected := []int{5, 6}
+		if !reflect.DeepEqual(items, expected) {
+			t.Fatal("expected", expected, "got", items)
+		}
+	}
+
+	// The class of the longest matching prefix must win.
+	{
+		_, err := newService.Create(ctx, namespace, "tenant-system-a", 1, 5, 9)
+		if !IsIDClassViolation(err) {
+			t.Fatal("expected", true, "got", false)
+		}
+	}
+
+	// IDs without class must not be restricted.
+	{
+		_, err := newService.Create(ctx, namespace, "other", 1, 0, 20)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package rangepool
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/giantswarm/microerror"
+)
+
+// MigrateKeys rewrites the item keys of the given namespace to the encoding
+// configured using Config.ZeroPaddedKeys. It must be executed for namespaces
+// which already hold items after changing the setting. Keys which are already
+// encoded accordingly are left untouched. The schema marker of the namespace is
+// updated accordingly, see SchemaKeyFormat. MigrateKeys must not be executed
+// concurrently with other operations on the same namespace.
+func (s *Service) MigrateKeys(ctx context.Context, namespace string) error {
+	ctx = withOperation(ctx, "MigrateKeys", namespace, "")
+
+	err := s.checkReadOnly()
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = s.checkLeader()
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	var kvs []KV
+	var keys []string
+
+	// Collect the item keys of the namespace, ${item1}.
+	{
+		prefix := s.key(ItemListKeyFormat, namespace)
+		err := walk(ctx, s.storage, prefix, func(kv KV) error {
+			item, err := strconv.Atoi(kv.Value)
+			if err != nil {
+				return microerror.Mask(err)
+			}
+
+			k := s.encodeItem(item)
+			if kv.Key != k {
+				kvs = append(kvs, KV{Key: prefix + "/" + k, Value: kv.Value})
+				keys = append(keys, prefix+"/"+kv.Key)
+			}
+
+			return nil
+		})
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	// Collect the ID keys of the namespace, ${id1}/item/${item1}.
+	{
+		prefix := s.key(IDPrefixKeyFormat, namespace)
+		err := walk(ctx, s.storage, prefix, func(kv KV) error {
+			i := strings.LastIndex(kv.Key, "/item/")
+			if i == -1 {
+				return nil
+			}
+
+			item, err := strconv.Atoi(kv.Value)
+			if err != nil {
+				return microerror.Mask(err)
+			}
+
+			k := kv.Key[:i] + "/item/" + s.encodeItem(item)
+			if kv.Key != k {
+				kvs = append(kvs, KV{Key: prefix + "/" + k, Value: kv.Value})
+				keys = append(keys, prefix+"/"+kv.Key)
+			}
+
+			return nil
+		})
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	err = s.updateSchema(ctx, namespace, func(l *schema) {
+		l.ZeroPaddedKeys = s.zeroPaddedKeys
+	})
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	if len(kvs) == 0 {
+		return nil
+	}
+
+	// The new keys are written before the old ones are removed, so that the
+	// items stay allocated even if the migration is interrupted.
+	err = createBatch(ctx, s.storage, kvs)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	err = deleteBatch(ctx, s.storage, keys)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	s.logger.LogCtx(ctx, "level", "debug", "message", fmt.Sprintf("migrated %d keys of namespace '%s'", len(keys), namespace))
+
+	return nil
+}
+
+// encodeItem returns the representation of the given item used within storage
+// keys. See Config.ZeroPaddedKeys.
+func (s *Service) encodeItem(item int) string {
+	if s.zeroPaddedKeys {
+		return fmt.Sprintf("%0*d", zeroPaddedKeyWidth, item)
+	}
+
+	return strconv.Itoa(item)
+}
+
+// key creates a storage key using the given format, which is one of the key
+// formats like ItemKeyFormat. The default prefix of the format is replaced
+// with the prefix configured using Config.KeyPrefix.
+func (s *Service) key(format string, a ...interface{}) string {
+	return s.keyPrefix + fmt.Sprintf(strings.TrimPrefix(format, DefaultKeyPrefix), a...)
+}
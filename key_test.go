@@ -0,0 +1,159 @@
+package rangepool
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/giantswarm/micrologger/microloggertest"
+)
+
+func Test_Service_MigrateKeys(t *testing.T) {
+	var err error
+	var newStorage Storage
+	{
+		newStorage, err = newMemoryStorage()
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+	}
+
+	newService := func(zeroPaddedKeys bool) *Service {
+		config := DefaultConfig()
+		config.Logger = microloggertest.New()
+		config.Storage = newStorage
+		config.ZeroPaddedKeys = zeroPaddedKeys
+		s, err := New(config)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		return s
+	}
+
+	ctx := context.TODO()
+
+	// Allocate items using the plain encoding.
+	{
+		_, err := newService(false).Create(ctx, namespace, "test-id", 3, 8, 20)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+	}
+
+	// Migrate the keys to the zero padded encoding. The lexicographic ordering
+	// of the keys must match the numeric ordering of the items afterwards.
+	{
+		err := newService(true).MigrateKeys(ctx, namespace)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		kvs, err := newStorage.List(ctx, fmt.Sprintf(ItemListKeyFormat, namespace))
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		var keys []string
+		for _, kv := range kvs {
+			keys = append(keys, kv.Key)
+		}
+		sort.Strings(keys)
+
+		expected := []string{"0000000008", "0000000009", "0000000010"}
+		if len(keys) != len(expected) {
+			t.Fatal("expected", expected, "got", keys)
+		}
+		for i := range keys {
+			if keys[i] != expected[i] {
+				t.Fatal("expected", expected, "got", keys)
+			}
+		}
+	}
+
+	// Searching and deleting must work with the migrated keys.
+	{
+		items, err := newService(true).Search(ctx, namespace, "test-id")
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		if len(items) != 3 || items[0] != 8 || items[2] != 10 {
+			t.Fatal("expected", []int{8, 9, 10}, "got", items)
+		}
+
+		err = newService(true).Delete(ctx, namespace, "test-id")
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		kvs, err := newStorage.List(ctx, fmt.Sprintf(IDPrefixKeyFormat, namespace))
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		if len(kvs) != 0 {
+			t.Fatal("expected", 0, "got", len(kvs))
+		}
+	}
+}
+
+func Test_Service_KeyPrefix(t *testing.T) {
+	var err error
+	var newStorage Storage
+	{
+		newStorage, err = newMemoryStorage()
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+	}
+
+	newService := func(keyPrefix string) *Service {
+		config := DefaultConfig()
+		config.Logger = microloggertest.New()
+		config.Storage = newStorage
+		config.KeyPrefix = keyPrefix
+		s, err := New(config)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		return s
+	}
+
+	ctx := context.TODO()
+
+	// Both pools share the same storage and namespace, but must not see each
+	// others items.
+	for _, keyPrefix := range []string{DefaultKeyPrefix, "custom-pool"} {
+		items, err := newService(keyPrefix).Create(ctx, namespace, "test-id", 2, 2, 3)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		if len(items) != 2 || items[0] != 2 || items[1] != 3 {
+			t.Fatal("expected", []int{2, 3}, "got", items)
+		}
+	}
+
+	// The items of the custom pool must be persisted using its prefix.
+	{
+		kvs, err := newStorage.List(ctx, "custom-pool/"+namespace+"/item")
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		if len(kvs) != 2 {
+			t.Fatal("expected", 2, "got", len(kvs))
+		}
+	}
+
+	// Invalid prefixes must be rejected.
+	for _, keyPrefix := range []string{"", "custom/pool"} {
+		config := DefaultConfig()
+		config.Logger = microloggertest.New()
+		config.Storage = newStorage
+		config.KeyPrefix = keyPrefix
+		_, err := New(config)
+		if !IsInvalidConfig(err) {
+			t.Fatal("expected", true, "got", false)
+		}
+	}
+}
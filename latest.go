@@ -0,0 +1,127 @@
+package rangepool
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/giantswarm/microerror"
+)
+
+// Latest returns the latest item allocated within the given namespace, which
+// the next allocation continues after, see LatestKeyFormat. The returned bool
+// is false in case no latest item is persisted, e.g. because no item has ever
+// been allocated, so that the next allocation starts at min. The latest items
+// of sub-pools are not taken into account, see Service.CreateInSubPool.
+func (s *Service) Latest(ctx context.Context, namespace string) (int, bool, error) {
+	ctx = withOperation(ctx, "Latest", namespace, "")
+
+	latest, err := s.searchLatest(ctx, namespace, callOptions{})
+	if err != nil {
+		return 0, false, microerror.Mask(err)
+	}
+
+	if latest == latestItemException {
+		return 0, false, nil
+	}
+
+	return latest, true, nil
+}
+
+// ResetLatest removes the latest item of the given namespace and the ones of
+// its sub-pools, so that the next allocation starts at min again, looking for
+// the lowest free item. In case Config.LatestMode is LatestModePerID, the
+// latest items of all IDs are removed as well. This is useful after cleaning up a namespace, e.g. to
+// fill the gaps left behind first.
+func (s *Service) ResetLatest(ctx context.Context, namespace string) error {
+	ctx = withOperation(ctx, "ResetLatest", namespace, "")
+
+	err := s.checkReadOnly()
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = s.checkLeader()
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	s, err = s.withPolicy(ctx, namespace)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	keys := s.latestKeys(namespace)
+	if s.latestMode == LatestModePerID {
+		prefix := s.key(IDPrefixKeyFormat, namespace)
+		err := walk(ctx, s.storage, prefix, func(kv KV) error {
+			// The keys are relative to the ID prefix, e.g. ${id1}/latest.
+			if strings.HasSuffix(kv.Key, "/latest") {
+				keys = append(keys, prefix+"/"+kv.Key)
+			}
+			return nil
+		})
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	err = deleteBatch(ctx, s.storage, keys)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	s.logger.LogCtx(ctx, "level", "debug", "message", fmt.Sprintf("reset latest item of namespace '%s'", namespace))
+
+	return nil
+}
+
+// Seek moves the latest item of the given namespace to the given item, so
+// that the next allocation continues after it, e.g. to skip a problematic
+// part of the range or to continue where another system stopped after
+// importing its items. In case a range is configured for the namespace, see
+// Config.Ranges, the item must be within it, otherwise it must not be
+// negative. Seek fails in case the latest mode of the namespace does not use
+// the latest item, see Config.LatestMode. The latest items of sub-pools are
+// not moved, see Service.CreateInSubPool.
+func (s *Service) Seek(ctx context.Context, namespace string, item int) error {
+	ctx = withOperation(ctx, "Seek", namespace, "")
+
+	err := s.checkReadOnly()
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = s.checkLeader()
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	s, err = s.withPolicy(ctx, namespace)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	switch s.latestMode {
+	case LatestModeContinue, LatestModeResetOnEmpty:
+	default:
+		return microerror.Maskf(invalidArgumentError, "latest mode '%s' of namespace '%s' does not use the latest item", s.latestMode, namespace)
+	}
+
+	r, ok := s.ranges[namespace]
+	if ok && (item < r.Min || item > r.Max) {
+		return microerror.Maskf(rangeConflictError, "item %d must be within %d-%d of namespace '%s'", item, r.Min, r.Max, namespace)
+	}
+	if item < 0 {
+		return microerror.Maskf(invalidArgumentError, "item %d must not be negative", item)
+	}
+
+	err = s.storage.Create(ctx, s.key(LatestKeyFormat, namespace), s.encodeLatest(item))
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	s.logger.LogCtx(ctx, "level", "debug", "message", fmt.Sprintf("moved latest item of namespace '%s' to %d", namespace, item))
+
+	return nil
+}
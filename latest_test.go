@@ -0,0 +1,196 @@
+package rangepool
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func Test_Service_Latest(t *testing.T) {
+	newStorage, err := newMemoryStorage()
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+
+	newService, err := NewWithOptions(newStorage)
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+
+	ctx := context.TODO()
+
+	// Namespaces without allocations must not have a latest item.
+	{
+		_, ok, err := newService.Latest(ctx, namespace)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		if ok {
+			t.Fatal("expected", false, "got", true)
+		}
+	}
+
+	// The latest item must follow allocations.
+	{
+		_, err := newService.Create(ctx, namespace, "test-id", 3, 5, 10)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		latest, ok, err := newService.Latest(ctx, namespace)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		if !ok {
+			t.Fatal("expected", true, "got", false)
+		}
+		if latest != 7 {
+			t.Fatal("expected", 7, "got", latest)
+		}
+	}
+
+	// Allocations must start at min again once the latest item is reset.
+	{
+		err := newService.Delete(ctx, namespace, "test-id")
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		_, err = newService.Create(ctx, namespace, "test-id", 1, 5, 10)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		err = newService.ResetLatest(ctx, namespace)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		_, ok, err := newService.Latest(ctx, namespace)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		if ok {
+			t.Fatal("expected", false, "got", true)
+		}
+
+		items, err := newService.Create(ctx, namespace, "test-id-2", 1, 5, 10)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		if !reflect.DeepEqual(items, []int{5}) {
+			t.Fatal("expected", []int{5}, "got", items)
+		}
+	}
+}
+
+func Test_Service_Seek(t *testing.T) {
+	newStorage, err := newMemoryStorage()
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+
+	newService, err := NewWithOptions(newStorage, WithRanges(map[string]Range{namespace: {Min: 1, Max: 100}}))
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+
+	ctx := context.TODO()
+
+	// Allocations must continue after the item sought.
+	{
+		err := newService.Seek(ctx, namespace, 50)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		items, err := newService.CreateDefault(ctx, namespace, "test-id", 2)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		expected := []int{51, 52}
+		if !reflect.DeepEqual(items, expected) {
+			t.Fatal("expected", expected, "got", items)
+		}
+	}
+
+	// Items outside of the range of the namespace must be refused.
+	{
+		err := newService.Seek(ctx, namespace, 101)
+		if !IsRangeConflict(err) {
+			t.Fatal("expected", true, "got", err)
+		}
+	}
+
+	// Namespaces not using the latest item must be refused.
+	{
+		err := newService.SetPolicy(ctx, namespace, Policy{LatestMode: LatestModeLowestFree})
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		err = newService.Seek(ctx, namespace, 10)
+		if !IsInvalidArgument(err) {
+			t.Fatal("expected", true, "got", err)
+		}
+	}
+}
+
+func Test_Service_LatestModePerID(t *testing.T) {
+	for _, bitmap := range []bool{false, true} {
+		newStorage, err := newMemoryStorage()
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		newService, err := NewWithOptions(newStorage, WithBitmap(bitmap), WithLatestMode(LatestModePerID))
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		ctx := context.TODO()
+
+		// Allocations of IDs in turns must stay sequential per ID.
+		{
+			steps := []struct {
+				ID       string
+				Expected []int
+			}{
+				{ID: "test-id-1", Expected: []int{1, 2}},
+				{ID: "test-id-2", Expected: []int{3, 4}},
+				{ID: "test-id-1", Expected: []int{5, 6}},
+				{ID: "test-id-2", Expected: []int{7, 8}},
+			}
+			for i, s := range steps {
+				items, err := newService.Create(ctx, namespace, s.ID, 2, 1, 100)
+				if err != nil {
+					t.Fatal("step", i+1, "expected", nil, "got", err)
+				}
+				if !reflect.DeepEqual(items, s.Expected) {
+					t.Fatal("step", i+1, "expected", s.Expected, "got", items)
+				}
+			}
+		}
+
+		// The latest item of an ID must be removed once it releases its items.
+		{
+			err := newService.Delete(ctx, namespace, "test-id-1")
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+
+			_, err = newStorage.Search(ctx, fmt.Sprintf(IDLatestKeyFormat, namespace, "test-id-1"))
+			if !IsNotFound(err) {
+				t.Fatal("expected", true, "got", err)
+			}
+
+			items, err := newService.Create(ctx, namespace, "test-id-2", 1, 1, 100)
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+			if !reflect.DeepEqual(items, []int{9}) {
+				t.Fatal("expected", []int{9}, "got", items)
+			}
+		}
+	}
+}
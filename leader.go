@@ -0,0 +1,354 @@
+package rangepool
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+)
+
+// lease is the value persisted at LeaseKeyFormat.
+type lease struct {
+	ExpiresAt time.Time `json:"expiresAt"`
+	Holder    string    `json:"holder"`
+}
+
+// ElectorConfig represents the configuration used to create a new elector.
+type ElectorConfig struct {
+	// Dependencies.
+
+	// Clock provides the current time used to expire leases. It defaults to
+	// the wall clock.
+	Clock  Clock
+	Logger micrologger.Logger
+	// Storage persists the lease. It must implement SwapStorage, see CanSwap.
+	Storage Storage
+
+	// Settings.
+
+	// Identity identifies the candidate, e.g. the name of the pod. Followers
+	// report it as NotLeaderError.Leader, so using an address allows followers
+	// to proxy requests to the leader.
+	Identity string
+	// KeyPrefix must match Config.KeyPrefix of the Services using the elector.
+	KeyPrefix string
+	// LeaseDuration is the duration a lease is valid for after it has been
+	// acquired or renewed. Other candidates take over once it expired.
+	LeaseDuration time.Duration
+	// LeaseName identifies the lease, so that multiple groups of candidates
+	// can share one storage.
+	LeaseName string
+	// RenewInterval is the interval in which the lease is acquired or renewed.
+	// It must be shorter than LeaseDuration.
+	RenewInterval time.Duration
+}
+
+// DefaultElectorConfig provides a default configuration to create a new
+// elector by best effort.
+func DefaultElectorConfig() ElectorConfig {
+	return ElectorConfig{
+		// Dependencies.
+		Clock:   realClock{},
+		Logger:  nopLogger{},
+		Storage: nil,
+
+		// Settings.
+		Identity:      "",
+		KeyPrefix:     DefaultKeyPrefix,
+		LeaseDuration: 15 * time.Second,
+		LeaseName:     "default",
+		RenewInterval: 5 * time.Second,
+	}
+}
+
+// NewElector creates a new configured elector. Elector.Run must be called to
+// take part in the election.
+func NewElector(config ElectorConfig) (*Elector, error) {
+	// Dependencies.
+	if config.Clock == nil {
+		return nil, microerror.Maskf(invalidConfigError, "clock must not be empty")
+	}
+	if config.Logger == nil {
+		return nil, microerror.Maskf(invalidConfigError, "logger must not be empty")
+	}
+	if config.Storage == nil {
+		return nil, microerror.Maskf(invalidConfigError, "storage must not be empty")
+	}
+	if !CanSwap(config.Storage) {
+		return nil, microerror.Maskf(invalidConfigError, "storage must implement SwapStorage")
+	}
+
+	// Settings.
+	if config.Identity == "" {
+		return nil, microerror.Maskf(invalidConfigError, "identity must not be empty")
+	}
+	if config.KeyPrefix == "" {
+		return nil, microerror.Maskf(invalidConfigError, "key prefix must not be empty")
+	}
+	if config.LeaseName == "" || strings.Contains(config.LeaseName, "/") {
+		return nil, microerror.Maskf(invalidConfigError, "lease name must not be empty and must not contain slashes")
+	}
+	if config.RenewInterval <= 0 {
+		return nil, microerror.Maskf(invalidConfigError, "renew interval must be greater than zero")
+	}
+	if config.LeaseDuration <= config.RenewInterval {
+		return nil, microerror.Maskf(invalidConfigError, "lease duration must be greater than renew interval")
+	}
+
+	e := &Elector{
+		// Dependencies.
+		clock:   config.Clock,
+		logger:  config.Logger,
+		storage: config.Storage.(SwapStorage),
+
+		// Internals.
+		done:       make(chan struct{}),
+		electMutex: sync.Mutex{},
+		mutex:      sync.RWMutex{},
+
+		// Settings.
+		identity:      config.Identity,
+		key:           config.KeyPrefix + fmt.Sprintf(strings.TrimPrefix(LeaseKeyFormat, DefaultKeyPrefix), config.LeaseName),
+		leaseDuration: config.LeaseDuration,
+		renewInterval: config.RenewInterval,
+	}
+
+	return e, nil
+}
+
+// Elector elects a single leader among the candidates sharing a storage using
+// a lease persisted at LeaseKeyFormat, see Config.Elector. The storage must
+// implement SwapStorage, see CanSwap. The lease is acquired, renewed and
+// released using compare-and-swap, so that at most one candidate holds it at
+// any time, given the clocks of the candidates are roughly in sync.
+type Elector struct {
+	// Dependencies.
+	clock   Clock
+	logger  micrologger.Logger
+	storage SwapStorage
+
+	// Internals.
+	closeOnce  sync.Once
+	done       chan struct{}
+	electMutex sync.Mutex
+	leader     string
+	mutex      sync.RWMutex
+	renewedAt  time.Time
+
+	// Settings.
+	identity      string
+	key           string
+	leaseDuration time.Duration
+	renewInterval time.Duration
+}
+
+// IsLeader returns whether the elector holds the lease. Leadership is given
+// up locally once the lease expires without being renewed, even in case the
+// storage cannot be reached anymore.
+func (e *Elector) IsLeader() bool {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	return e.leader == e.identity && e.clock.Now().Sub(e.renewedAt) < e.leaseDuration
+}
+
+// Leader returns the identity of the leader last observed. It is empty in
+// case no leader has been observed yet.
+func (e *Elector) Leader() string {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	return e.leader
+}
+
+// Run acquires and renews the lease in the configured interval until the
+// given context is done or the elector is closed. The lease is released on
+// return in case it is held, so that another candidate can take over right
+// away.
+func (e *Elector) Run(ctx context.Context) error {
+	ticker := time.NewTicker(e.renewInterval)
+	defer ticker.Stop()
+
+	for {
+		closed, err := e.electUnlessClosed(ctx)
+		if closed {
+			return nil
+		}
+		if err != nil {
+			e.logger.LogCtx(ctx, "level", "warning", "message", "failed electing leader", "stack", fmt.Sprintf("%#v", err))
+		}
+
+		select {
+		case <-ctx.Done():
+			err := e.release(context.Background())
+			if err != nil {
+				return microerror.Mask(err)
+			}
+
+			return nil
+		case <-e.done:
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Close stops Elector.Run and releases the lease in case it is held, so that
+// another candidate can take over right away. Closing the elector cannot be
+// undone. Services using the elector reject allocations afterwards.
+func (e *Elector) Close(ctx context.Context) error {
+	e.closeOnce.Do(func() {
+		close(e.done)
+	})
+
+	// Running elections are awaited, so that the lease is not acquired again
+	// right after it has been released.
+	e.electMutex.Lock()
+	defer e.electMutex.Unlock()
+
+	err := e.release(ctx)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// electUnlessClosed runs the election in case the elector is not closed. The
+// first return value is true in case it is.
+func (e *Elector) electUnlessClosed(ctx context.Context) (bool, error) {
+	e.electMutex.Lock()
+	defer e.electMutex.Unlock()
+
+	select {
+	case <-e.done:
+		return true, nil
+	default:
+	}
+
+	err := e.elect(ctx)
+	if err != nil {
+		return false, microerror.Mask(err)
+	}
+
+	return false, nil
+}
+
+// elect acquires or renews the lease in case it is free, expired or held by
+// the elector already. Otherwise the current holder is recorded as leader.
+func (e *Elector) elect(ctx context.Context) error {
+	l, v, err := e.searchLease(ctx)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	now := e.clock.Now()
+
+	if l.Holder != "" && l.Holder != e.identity && now.Before(l.ExpiresAt) {
+		e.setLeader(ctx, l.Holder, time.Time{})
+		return nil
+	}
+
+	b, err := json.Marshal(lease{ExpiresAt: now.Add(e.leaseDuration).UTC(), Holder: e.identity})
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	swapped, err := e.storage.Swap(ctx, e.key, v, string(b))
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	// Another candidate changed the lease since it was read. The lease is read
+	// again, so that its holder is recorded as leader.
+	if !swapped {
+		l, _, err = e.searchLease(ctx)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+		e.setLeader(ctx, l.Holder, time.Time{})
+		return nil
+	}
+
+	e.setLeader(ctx, e.identity, now)
+
+	return nil
+}
+
+// release gives up the lease in case the elector holds it. The lease is only
+// released in case it is still held by the elector.
+func (e *Elector) release(ctx context.Context) error {
+	if !e.IsLeader() {
+		return nil
+	}
+
+	l, v, err := e.searchLease(ctx)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	if l.Holder == e.identity {
+		// The lease is replaced by an expired one without holder, since
+		// deleting it cannot be conditional.
+		b, err := json.Marshal(lease{ExpiresAt: e.clock.Now().UTC()})
+		if err != nil {
+			return microerror.Mask(err)
+		}
+		_, err = e.storage.Swap(ctx, e.key, v, string(b))
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+	e.setLeader(ctx, "", time.Time{})
+
+	return nil
+}
+
+// searchLease fetches the lease and its raw value. The raw value is empty in
+// case there is no lease.
+func (e *Elector) searchLease(ctx context.Context) (lease, string, error) {
+	var l lease
+
+	v, err := e.storage.Search(ctx, e.key)
+	if IsNotFound(err) {
+		return lease{}, "", nil
+	} else if err != nil {
+		return lease{}, "", microerror.Mask(err)
+	}
+
+	err = json.Unmarshal([]byte(v), &l)
+	if err != nil {
+		return lease{}, "", microerror.Maskf(executionFailedError, "decoding lease '%s': %s", e.key, err.Error())
+	}
+
+	return l, v, nil
+}
+
+func (e *Elector) setLeader(ctx context.Context, leader string, renewedAt time.Time) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if leader != e.leader {
+		e.logger.LogCtx(ctx, "level", "info", "message", fmt.Sprintf("leader changed from '%s' to '%s'", e.leader, leader))
+	}
+
+	e.leader = leader
+	if leader == e.identity {
+		e.renewedAt = renewedAt
+	}
+}
+
+// checkLeader fails with NotLeaderError in case an elector is configured and
+// does not hold the lease, see Config.Elector.
+func (s *Service) checkLeader() error {
+	if s.elector == nil || s.elector.IsLeader() {
+		return nil
+	}
+
+	return microerror.Mask(&NotLeaderError{Leader: s.elector.Leader()})
+}
@@ -0,0 +1,203 @@
+package rangepool
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/giantswarm/micrologger/microloggertest"
+)
+
+func Test_Elector(t *testing.T) {
+	s, err := newMemoryStorage()
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+	newStorage := &testSwapStorage{Storage: s}
+
+	var electors []*Elector
+	for _, identity := range []string{"replica-1", "replica-2"} {
+		c := DefaultElectorConfig()
+		c.Identity = identity
+		c.Logger = microloggertest.New()
+		c.Storage = newStorage
+		e, err := NewElector(c)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		electors = append(electors, e)
+	}
+
+	var follower *Service
+	{
+		follower, err = NewWithOptions(newStorage, WithElector(electors[1]), WithLogger(microloggertest.New()))
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+	}
+
+	ctx := context.TODO()
+
+	// The first candidate must acquire the free lease and the second one must
+	// follow it.
+	{
+		for _, e := range electors {
+			err := e.elect(ctx)
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+		}
+
+		if !electors[0].IsLeader() {
+			t.Fatal("expected", true, "got", false)
+		}
+		if electors[1].IsLeader() {
+			t.Fatal("expected", false, "got", true)
+		}
+		if electors[1].Leader() != "replica-1" {
+			t.Fatal("expected", "replica-1", "got", electors[1].Leader())
+		}
+	}
+
+	// Followers must reject allocations and name the leader.
+	{
+		_, err := follower.Create(ctx, namespace, "test-id", 1, 1, 5)
+		if !IsNotLeader(err) {
+			t.Fatal("expected", true, "got", false)
+		}
+		e, ok := AsNotLeader(err)
+		if !ok {
+			t.Fatal("expected", true, "got", false)
+		}
+		if e.Leader != "replica-1" {
+			t.Fatal("expected", "replica-1", "got", e.Leader)
+		}
+		err = follower.Burn(ctx, namespace, []int{5})
+		if !IsNotLeader(err) {
+			t.Fatal("expected", true, "got", false)
+		}
+		err = follower.SetPolicy(ctx, namespace, Policy{})
+		if !IsNotLeader(err) {
+			t.Fatal("expected", true, "got", false)
+		}
+	}
+
+	// Expired leases must be taken over.
+	{
+		b, err := json.Marshal(lease{ExpiresAt: time.Now().Add(-time.Second), Holder: "replica-1"})
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		err = newStorage.Create(ctx, electors[1].key, string(b))
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		err = electors[1].elect(ctx)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		if !electors[1].IsLeader() {
+			t.Fatal("expected", true, "got", false)
+		}
+
+		_, err = follower.Create(ctx, namespace, "test-id", 1, 1, 5)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+	}
+
+	// Releasing the lease must give up leadership.
+	{
+		err := electors[1].release(ctx)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		if electors[1].IsLeader() {
+			t.Fatal("expected", false, "got", true)
+		}
+
+		err = electors[0].elect(ctx)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		if !electors[0].IsLeader() {
+			t.Fatal("expected", true, "got", false)
+		}
+	}
+}
+
+func Test_Elector_Swap(t *testing.T) {
+	s, err := newMemoryStorage()
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+	newStorage := &testSwapStorage{Storage: s}
+
+	var electors []*Elector
+	for _, identity := range []string{"replica-1", "replica-2", "replica-3"} {
+		c := DefaultElectorConfig()
+		c.Identity = identity
+		c.Storage = newStorage
+		e, err := NewElector(c)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		electors = append(electors, e)
+	}
+
+	ctx := context.TODO()
+
+	// Candidates racing for a free lease must never both become leader, since
+	// the lease is acquired using compare-and-swap.
+	for i := 0; i < 50; i++ {
+		var wg sync.WaitGroup
+		for _, e := range electors {
+			wg.Add(1)
+			go func(e *Elector) {
+				defer wg.Done()
+				err := e.elect(ctx)
+				if err != nil {
+					t.Error("expected", nil, "got", err)
+				}
+			}(e)
+		}
+		wg.Wait()
+
+		var leaders int
+		for _, e := range electors {
+			if e.IsLeader() {
+				leaders++
+			}
+		}
+		if leaders != 1 {
+			t.Fatal("expected", 1, "got", leaders)
+		}
+
+		for _, e := range electors {
+			err := e.release(ctx)
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+		}
+	}
+}
+
+func Test_NewElector_Swap(t *testing.T) {
+	newStorage, err := newMemoryStorage()
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+
+	// Electors must be rejected for storages not able to swap values, since
+	// they could not provide mutual exclusion.
+	c := DefaultElectorConfig()
+	c.Identity = "replica-1"
+	c.Storage = newStorage
+	_, err = NewElector(c)
+	if !IsInvalidConfig(err) {
+		t.Fatal("expected", true, "got", false)
+	}
+}
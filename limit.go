@@ -0,0 +1,20 @@
+package rangepool
+
+import (
+	"github.com/giantswarm/microerror"
+)
+
+// checkLimits ensures that an allocation of num items within the range
+// defined by min and max, both inclusive, does not exceed Config.MaxNum and
+// Config.MaxRangeSize. Otherwise an error is returned which can be asserted
+// using IsLimitExceeded.
+func (s *Service) checkLimits(num, min, max int) error {
+	if s.maxNum > 0 && num > s.maxNum {
+		return microerror.Maskf(limitExceededError, "num %d must not exceed %d", num, s.maxNum)
+	}
+	if s.maxRangeSize > 0 && max-min+1 > s.maxRangeSize {
+		return microerror.Maskf(limitExceededError, "size %d of range %d-%d must not exceed %d", max-min+1, min, max, s.maxRangeSize)
+	}
+
+	return nil
+}
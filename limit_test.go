@@ -0,0 +1,60 @@
+package rangepool
+
+import (
+	"context"
+	"testing"
+)
+
+func Test_Service_Limits(t *testing.T) {
+	newStorage, err := newMemoryStorage()
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+
+	newService, err := NewWithOptions(newStorage, WithMaxNum(2), WithMaxRangeSize(100))
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+
+	ctx := context.TODO()
+
+	testCases := []struct {
+		Num          int
+		Min          int
+		Max          int
+		ErrorMatcher func(error) bool
+	}{
+		// Case 1 ensures allocations within the limits succeed.
+		{
+			Num:          2,
+			Min:          1,
+			Max:          100,
+			ErrorMatcher: nil,
+		},
+		// Case 2 ensures allocations of too many items are refused.
+		{
+			Num:          3,
+			Min:          1,
+			Max:          100,
+			ErrorMatcher: IsLimitExceeded,
+		},
+		// Case 3 ensures allocations within too large ranges are refused.
+		{
+			Num:          1,
+			Min:          1,
+			Max:          999999999,
+			ErrorMatcher: IsLimitExceeded,
+		},
+	}
+
+	for i, tc := range testCases {
+		_, err := newService.Create(ctx, namespace, "test-id", tc.Num, tc.Min, tc.Max)
+		if tc.ErrorMatcher != nil {
+			if !tc.ErrorMatcher(err) {
+				t.Fatal("case", i+1, "expected", true, "got", err)
+			}
+		} else if err != nil {
+			t.Fatal("case", i+1, "expected", nil, "got", err)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package rangepool
+
+import (
+	"context"
+
+	"github.com/giantswarm/micrologger"
+)
+
+// nopLogger is the logger used by DefaultConfig. It discards all log lines, so
+// that the default configuration does not need to construct any dependency.
+type nopLogger struct{}
+
+func (l nopLogger) Log(keyVals ...interface{}) {}
+
+func (l nopLogger) LogCtx(ctx context.Context, keyVals ...interface{}) {}
+
+func (l nopLogger) With(keyVals ...interface{}) micrologger.Logger {
+	return l
+}
+
+type loggerContextKey struct{}
+
+// loggerFields are the key value pairs carried by a context, see
+// NewLoggerContext.
+type loggerFields struct {
+	// Caller are the key value pairs passed to NewLoggerContext.
+	Caller []interface{}
+	// Operation are the key value pairs describing the operation of the
+	// Service being executed, see withOperation.
+	Operation []interface{}
+}
+
+// NewLoggerContext returns a new context carrying the given key value pairs,
+// e.g. the ID of a request. All log lines the Service writes while executing
+// operations using the returned context are annotated with them, in addition
+// to the operation, namespace and ID the Service annotates log lines with
+// anyway. Key value pairs already carried by the given context are kept.
+func NewLoggerContext(ctx context.Context, keyVals ...interface{}) context.Context {
+	f, _ := ctx.Value(loggerContextKey{}).(loggerFields)
+	f.Caller = append(append([]interface{}{}, f.Caller...), keyVals...)
+
+	return context.WithValue(ctx, loggerContextKey{}, f)
+}
+
+// withOperation returns a new context annotating log lines with the given
+// operation of the Service and the namespace and ID it is executed for. Empty
+// values are omitted. Operations executed by other operations replace the
+// annotations of the outer operation for their duration.
+func withOperation(ctx context.Context, operation, namespace, ID string) context.Context {
+	f, _ := ctx.Value(loggerContextKey{}).(loggerFields)
+	f.Operation = []interface{}{"operation", operation}
+	if namespace != "" {
+		f.Operation = append(f.Operation, "namespace", namespace)
+	}
+	if ID != "" {
+		f.Operation = append(f.Operation, "id", ID)
+	}
+
+	return context.WithValue(ctx, loggerContextKey{}, f)
+}
+
+// contextLogger annotates the log lines written using LogCtx with the key
+// value pairs carried by the given context, see NewLoggerContext.
+type contextLogger struct {
+	logger micrologger.Logger
+}
+
+func (l contextLogger) Log(keyVals ...interface{}) {
+	l.logger.Log(keyVals...)
+}
+
+func (l contextLogger) LogCtx(ctx context.Context, keyVals ...interface{}) {
+	f, ok := ctx.Value(loggerContextKey{}).(loggerFields)
+	if !ok {
+		l.logger.LogCtx(ctx, keyVals...)
+		return
+	}
+
+	fields := append(append([]interface{}{}, f.Operation...), f.Caller...)
+
+	l.logger.With(fields...).LogCtx(ctx, keyVals...)
+}
+
+func (l contextLogger) With(keyVals ...interface{}) micrologger.Logger {
+	return contextLogger{logger: l.logger.With(keyVals...)}
+}
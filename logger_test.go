@@ -0,0 +1,90 @@
+package rangepool
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/giantswarm/micrologger"
+)
+
+// testLogger records the key value pairs of all log lines, including the ones
+// added using With.
+type testLogger struct {
+	fields []interface{}
+	lines  *[][]interface{}
+	mutex  *sync.Mutex
+}
+
+func newTestLogger() testLogger {
+	return testLogger{lines: &[][]interface{}{}, mutex: &sync.Mutex{}}
+}
+
+func (l testLogger) Log(keyVals ...interface{}) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	*l.lines = append(*l.lines, append(append([]interface{}{}, l.fields...), keyVals...))
+}
+
+func (l testLogger) LogCtx(ctx context.Context, keyVals ...interface{}) {
+	l.Log(keyVals...)
+}
+
+func (l testLogger) With(keyVals ...interface{}) micrologger.Logger {
+	l.fields = append(append([]interface{}{}, l.fields...), keyVals...)
+	return l
+}
+
+func Test_Service_Logger_Context(t *testing.T) {
+	logger := newTestLogger()
+
+	var err error
+	var newService *Service
+	{
+		newStorage, err := newMemoryStorage()
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		config := DefaultConfig()
+		config.Logger = logger
+		config.Storage = newStorage
+		newService, err = New(config)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+	}
+
+	ctx := NewLoggerContext(context.TODO(), "request", "r-1")
+
+	_, err = newService.Adopt(ctx, namespace, "test-id", []int{3}, 1, 5)
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+
+	// Log lines must be annotated with the operation and the key value pairs
+	// of the caller.
+	{
+		if len(*logger.lines) != 1 {
+			t.Fatal("expected", 1, "got", len(*logger.lines))
+		}
+		expected := []interface{}{"operation", "Adopt", "namespace", namespace, "id", "test-id", "request", "r-1"}
+		fields := (*logger.lines)[0][:len(expected)]
+		if !reflect.DeepEqual(fields, expected) {
+			t.Fatal("expected", expected, "got", fields)
+		}
+	}
+
+	// Key value pairs must accumulate.
+	{
+		ctx := NewLoggerContext(ctx, "user", "u-1")
+
+		f, _ := ctx.Value(loggerContextKey{}).(loggerFields)
+		expected := []interface{}{"request", "r-1", "user", "u-1"}
+		if !reflect.DeepEqual(f.Caller, expected) {
+			t.Fatal("expected", expected, "got", f.Caller)
+		}
+	}
+}
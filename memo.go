@@ -0,0 +1,93 @@
+package rangepool
+
+import (
+	"sync"
+	"time"
+)
+
+// readCache memoizes the results of reading operations like Service.Search
+// and Service.Status per namespace for a limited amount of time. All methods
+// are safe to be called on a nil cache, which disables memoization.
+type readCache struct {
+	clock   Clock
+	entries map[string]map[string]readCacheEntry
+	mutex   sync.Mutex
+	ttl     time.Duration
+}
+
+type readCacheEntry struct {
+	created time.Time
+	value   interface{}
+}
+
+func newReadCache(clock Clock, ttl time.Duration) *readCache {
+	if ttl <= 0 {
+		return nil
+	}
+
+	c := &readCache{
+		clock:   clock,
+		entries: map[string]map[string]readCacheEntry{},
+		mutex:   sync.Mutex{},
+		ttl:     ttl,
+	}
+
+	return c
+}
+
+// Get returns the memoized value of the given key within the namespace. The
+// second return value is false in case the key is not memoized or its entry
+// expired. Callers must not modify the returned value.
+func (c *readCache) Get(namespace, key string) (interface{}, bool) {
+	if c == nil {
+		return nil, false
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	e, ok := c.entries[namespace][key]
+	if !ok {
+		return nil, false
+	}
+	if c.clock.Now().Sub(e.created) > c.ttl {
+		delete(c.entries[namespace], key)
+		return nil, false
+	}
+
+	return e.value, true
+}
+
+// Invalidate drops the memoized values of the namespace.
+func (c *readCache) Invalidate(namespace string) {
+	if c == nil {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	delete(c.entries, namespace)
+}
+
+// Set memoizes the given value of the given key within the namespace. Callers
+// must not modify the value afterwards.
+func (c *readCache) Set(namespace, key string, value interface{}) {
+	if c == nil {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	m, ok := c.entries[namespace]
+	if !ok {
+		m = map[string]readCacheEntry{}
+		c.entries[namespace] = m
+	}
+
+	m[key] = readCacheEntry{
+		created: c.clock.Now(),
+		value:   value,
+	}
+}
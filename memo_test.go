@@ -0,0 +1,96 @@
+package rangepool
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/giantswarm/micrologger/microloggertest"
+)
+
+func Test_Service_ReadCacheTTL(t *testing.T) {
+	newStorage, err := newMemoryStorage()
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+
+	clock := &testClock{now: time.Unix(0, 0)}
+
+	newTestService := func() *Service {
+		config := DefaultConfig()
+		config.Clock = clock
+		config.Logger = microloggertest.New()
+		config.Storage = newStorage
+		config.ReadCacheTTL = time.Minute
+		newService, err := New(config)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		return newService
+	}
+
+	ctx := context.TODO()
+
+	reader := newTestService()
+	writer := newTestService()
+
+	search := func(expected []int) {
+		items, err := reader.Search(ctx, namespace, "test-id-1")
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		if !reflect.DeepEqual(items, expected) {
+			t.Fatal("expected", expected, "got", items)
+		}
+	}
+	status := func(expected int) {
+		st, err := reader.Status(ctx, namespace, 2, 10)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		if st.Used != expected {
+			t.Fatal("expected", expected, "got", st.Used)
+		}
+	}
+
+	_, err = writer.Create(ctx, namespace, "test-id-1", 1, 2, 10)
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+
+	search([]int{2})
+	status(1)
+
+	// Changes of other processes must not be visible until the memoized reads
+	// expire.
+	{
+		_, err = writer.Create(ctx, namespace, "test-id-1", 1, 2, 10)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		search([]int{2})
+		status(1)
+
+		clock.now = clock.now.Add(2 * time.Minute)
+
+		search([]int{2, 3})
+		status(2)
+	}
+
+	// Changes of the Service itself must be visible right away.
+	{
+		err := reader.Delete(ctx, namespace, "test-id-1")
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		_, err = reader.Search(ctx, namespace, "test-id-1")
+		if !IsItemsNotFound(err) {
+			t.Fatal("expected", true, "got", false)
+		}
+		status(0)
+	}
+}
@@ -0,0 +1,268 @@
+package rangepool
+
+import (
+	"context"
+
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/microstorage"
+)
+
+// MicrostorageBatch can optionally be implemented by microstorage.Storage
+// implementations which are able to write multiple keys in a single round
+// trip, e.g. the ones of the storage/crd and storage/configmap packages. The
+// microstorage adapter makes use of it to implement BatchStorage.
+type MicrostorageBatch interface {
+	DeleteBatch(ctx context.Context, keys []microstorage.K) error
+	PutBatch(ctx context.Context, kvs []microstorage.KV) error
+}
+
+// MicrostorageAtomic can optionally be implemented by microstorage.Storage
+// implementations which are able to write and remove multiple keys atomically,
+// e.g. the ones of the storage/crd and storage/configmap packages. The
+// microstorage adapter makes use of it to implement AtomicStorage.
+type MicrostorageAtomic interface {
+	Apply(ctx context.Context, kvs []microstorage.KV, keys []microstorage.K) error
+}
+
+// MicrostorageSwap can optionally be implemented by microstorage.Storage
+// implementations which are able to replace the value of a key atomically,
+// e.g. the ones of the storage/crd, storage/configmap and storage/postgres
+// packages. The microstorage adapter makes use of it to implement SwapStorage,
+// see CanSwap.
+type MicrostorageSwap interface {
+	Swap(ctx context.Context, key microstorage.K, old, value string) (bool, error)
+}
+
+// MicrostorageConfig represents the configuration used to create a new
+// microstorage adapter.
+type MicrostorageConfig struct {
+	// Dependencies.
+	Storage microstorage.Storage
+}
+
+// DefaultMicrostorageConfig provides a default configuration to create a new
+// microstorage adapter by best effort.
+func DefaultMicrostorageConfig() MicrostorageConfig {
+	return MicrostorageConfig{
+		// Dependencies.
+		Storage: nil,
+	}
+}
+
+// NewMicrostorage creates a new configured microstorage adapter. It makes any
+// microstorage.Storage implementation, e.g. the ones of the storage/crd,
+// storage/configmap and storage/postgres packages, usable as Storage.
+func NewMicrostorage(config MicrostorageConfig) (*Microstorage, error) {
+	// Dependencies.
+	if config.Storage == nil {
+		return nil, microerror.Maskf(invalidConfigError, "storage must not be empty")
+	}
+
+	newMicrostorage := &Microstorage{
+		// Dependencies.
+		storage: config.Storage,
+	}
+
+	return newMicrostorage, nil
+}
+
+// Microstorage implements Storage on top of microstorage.Storage.
+type Microstorage struct {
+	// Dependencies.
+	storage microstorage.Storage
+}
+
+// Apply is only atomic in case the microstorage.Storage implements
+// MicrostorageAtomic. Otherwise the key-value pairs are persisted before the
+// keys are removed.
+func (m *Microstorage) Apply(ctx context.Context, kvs []KV, keys []string) error {
+	a, ok := m.storage.(MicrostorageAtomic)
+	if !ok {
+		err := m.CreateBatch(ctx, kvs)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+		err = m.DeleteBatch(ctx, keys)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
+		return nil
+	}
+
+	var kvList []microstorage.KV
+	for _, kv := range kvs {
+		l, err := microstorage.NewKV(kv.Key, kv.Value)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+		kvList = append(kvList, l)
+	}
+
+	var keyList []microstorage.K
+	for _, k := range keys {
+		l, err := microstorage.NewK(k)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+		keyList = append(keyList, l)
+	}
+
+	err := a.Apply(ctx, kvList, keyList)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+func (m *Microstorage) Create(ctx context.Context, key, value string) error {
+	kv, err := microstorage.NewKV(key, value)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = m.storage.Put(ctx, kv)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+func (m *Microstorage) CreateBatch(ctx context.Context, kvs []KV) error {
+	b, ok := m.storage.(MicrostorageBatch)
+	if !ok {
+		for _, kv := range kvs {
+			err := m.Create(ctx, kv.Key, kv.Value)
+			if err != nil {
+				return microerror.Mask(err)
+			}
+		}
+
+		return nil
+	}
+
+	var list []microstorage.KV
+	for _, kv := range kvs {
+		l, err := microstorage.NewKV(kv.Key, kv.Value)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+		list = append(list, l)
+	}
+
+	err := b.PutBatch(ctx, list)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+func (m *Microstorage) Delete(ctx context.Context, key string) error {
+	k, err := microstorage.NewK(key)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = m.storage.Delete(ctx, k)
+	if microstorage.IsNotFound(err) {
+		// Fall through in case what we want to remove is already gone.
+	} else if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+func (m *Microstorage) DeleteBatch(ctx context.Context, keys []string) error {
+	b, ok := m.storage.(MicrostorageBatch)
+	if !ok {
+		for _, k := range keys {
+			err := m.Delete(ctx, k)
+			if err != nil {
+				return microerror.Mask(err)
+			}
+		}
+
+		return nil
+	}
+
+	var list []microstorage.K
+	for _, k := range keys {
+		l, err := microstorage.NewK(k)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+		list = append(list, l)
+	}
+
+	err := b.DeleteBatch(ctx, list)
+	if microstorage.IsNotFound(err) {
+		// Fall through in case what we want to remove is already gone.
+	} else if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+func (m *Microstorage) List(ctx context.Context, key string) ([]KV, error) {
+	k, err := microstorage.NewK(key)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	kvs, err := m.storage.List(ctx, k)
+	if microstorage.IsNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	var list []KV
+	for _, kv := range kvs {
+		list = append(list, KV{Key: kv.KeyNoLeadingSlash(), Value: kv.Val()})
+	}
+
+	return list, nil
+}
+
+// Swap is only supported in case the microstorage.Storage implements
+// MicrostorageSwap. Otherwise an error is returned which can be asserted using
+// IsInvalidConfig, see CanSwap.
+func (m *Microstorage) Swap(ctx context.Context, key, old, value string) (bool, error) {
+	w, ok := m.storage.(MicrostorageSwap)
+	if !ok {
+		return false, microerror.Maskf(invalidConfigError, "microstorage must implement MicrostorageSwap")
+	}
+
+	k, err := microstorage.NewK(key)
+	if err != nil {
+		return false, microerror.Mask(err)
+	}
+
+	swapped, err := w.Swap(ctx, k, old, value)
+	if err != nil {
+		return false, microerror.Mask(err)
+	}
+
+	return swapped, nil
+}
+
+func (m *Microstorage) Search(ctx context.Context, key string) (string, error) {
+	k, err := microstorage.NewK(key)
+	if err != nil {
+		return "", microerror.Mask(err)
+	}
+
+	kv, err := m.storage.Search(ctx, k)
+	if microstorage.IsNotFound(err) {
+		return "", microerror.Maskf(NotFoundError, "%s", key)
+	} else if err != nil {
+		return "", microerror.Mask(err)
+	}
+
+	return kv.Val(), nil
+}
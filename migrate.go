@@ -0,0 +1,126 @@
+package rangepool
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/giantswarm/microerror"
+)
+
+// LegacyStorage is the subset of the storage interface of microkit/storage
+// used by older versions of the range pool. Legacy storages list the keys
+// below a given key, relative to it, instead of key-value pairs.
+type LegacyStorage interface {
+	List(ctx context.Context, key string) ([]string, error)
+	Search(ctx context.Context, key string) (string, error)
+}
+
+// Migrate copies all namespaces persisted by older versions of the range pool
+// in the given legacy storage to the given storage. The namespaces are
+// persisted using the storage representation configured by the given options,
+// e.g. WithBitmap or WithZeroPaddedKeys, so keys are re-encoded on the way.
+// The relationships between IDs and items are the source of truth, so items
+// not owned by any ID are not migrated. The namespaces must not hold any items
+// in the given storage yet, see Service.Import. The legacy storage is not
+// modified.
+func Migrate(ctx context.Context, oldStorage LegacyStorage, newStorage Storage, opts ...Option) error {
+	s, err := NewWithOptions(newStorage, opts...)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	snapshots, err := searchLegacySnapshots(ctx, oldStorage)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	for _, snapshot := range snapshots {
+		err := s.Import(ctx, snapshot)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
+		s.logger.LogCtx(ctx, "level", "debug", "message", fmt.Sprintf("migrated %d IDs of namespace '%s'", len(snapshot.IDs), snapshot.Namespace))
+	}
+
+	return nil
+}
+
+// searchLegacySnapshots fetches the state of all namespaces of the given
+// legacy storage, ordered by namespace. The legacy schema uses the same key
+// formats, but always below DefaultKeyPrefix. Items are taken from the keys,
+// which legacy versions used to encode the items in.
+func searchLegacySnapshots(ctx context.Context, storage LegacyStorage) ([]Snapshot, error) {
+	keys, err := storage.List(ctx, DefaultKeyPrefix)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	snapshots := map[string]*Snapshot{}
+	get := func(namespace string) *Snapshot {
+		snapshot, ok := snapshots[namespace]
+		if !ok {
+			snapshot = &Snapshot{
+				IDs:       map[string][]int{},
+				Latest:    latestItemException,
+				Namespace: namespace,
+				Version:   SnapshotVersion,
+			}
+			snapshots[namespace] = snapshot
+		}
+
+		return snapshot
+	}
+
+	for _, k := range keys {
+		// The keys are relative to the prefix, e.g. ${namespace}/latest or
+		// ${namespace}/id/${id}/item/${item}.
+		parts := strings.Split(strings.TrimPrefix(k, "/"), "/")
+
+		switch {
+		case len(parts) == 2 && parts[1] == "latest":
+			if DefaultKeyPrefix+"/"+strings.Join(parts, "/") == HealthzKeyFormat {
+				continue
+			}
+
+			v, err := storage.Search(ctx, fmt.Sprintf(LatestKeyFormat, parts[0]))
+			if err != nil {
+				return nil, microerror.Mask(err)
+			}
+			latest, err := strconv.Atoi(v)
+			if err != nil {
+				return nil, microerror.Maskf(executionFailedError, "decoding latest item of namespace '%s': %s", parts[0], err.Error())
+			}
+
+			get(parts[0]).Latest = latest
+		case len(parts) == 5 && parts[1] == "id" && parts[3] == "item":
+			item, err := strconv.Atoi(parts[4])
+			if err != nil {
+				return nil, microerror.Maskf(executionFailedError, "decoding item of key '%s': %s", k, err.Error())
+			}
+
+			snapshot := get(parts[0])
+			snapshot.IDs[parts[2]] = append(snapshot.IDs[parts[2]], item)
+		}
+	}
+
+	var namespaces []string
+	for namespace := range snapshots {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Strings(namespaces)
+
+	var list []Snapshot
+	for _, namespace := range namespaces {
+		snapshot := snapshots[namespace]
+		for _, items := range snapshot.IDs {
+			sort.Ints(items)
+		}
+		list = append(list, *snapshot)
+	}
+
+	return list, nil
+}
@@ -0,0 +1,106 @@
+package rangepool
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/giantswarm/microerror"
+)
+
+func Test_Migrate(t *testing.T) {
+	ctx := context.TODO()
+
+	oldStorage := testLegacyStorage{
+		"range-pool/healthz/latest":                        "0",
+		"range-pool/test-namespace-1/item/2":               "2",
+		"range-pool/test-namespace-1/item/3":               "3",
+		"range-pool/test-namespace-1/item/7":               "7",
+		"range-pool/test-namespace-1/id/test-id-1/item/2":  "2",
+		"range-pool/test-namespace-1/id/test-id-1/item/3":  "3",
+		"range-pool/test-namespace-1/latest":               "7",
+		"range-pool/test-namespace-2/item/10":              "10",
+		"range-pool/test-namespace-2/id/test-id-2/item/10": "10",
+		"range-pool/test-namespace-2/latest":               "10",
+	}
+
+	newStorage, err := newMemoryStorage()
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+
+	err = Migrate(ctx, oldStorage, newStorage, WithZeroPaddedKeys(true))
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+
+	newService, err := NewWithOptions(newStorage, WithZeroPaddedKeys(true))
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+
+	// Orphaned items must be dropped while the latest item is kept.
+	{
+		d, err := newService.Dump(ctx, "test-namespace-1")
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		expected := Dump{
+			IDs:       map[string][]int{"test-id-1": {2, 3}},
+			Latest:    7,
+			Namespace: "test-namespace-1",
+			Used:      []int{2, 3},
+		}
+		if !reflect.DeepEqual(d, expected) {
+			t.Fatal("expected", expected, "got", d)
+		}
+	}
+
+	// Keys must be re-encoded using the configured encoding.
+	{
+		_, err := newStorage.Search(ctx, "range-pool/test-namespace-2/id/test-id-2/item/"+newService.encodeItem(10))
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+	}
+
+	// The healthz sentinel key must not be migrated as namespace.
+	{
+		d, err := newService.Dump(ctx, "healthz")
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		if d.Latest != -1 {
+			t.Fatal("expected", -1, "got", d.Latest)
+		}
+	}
+}
+
+// testLegacyStorage implements LegacyStorage like microkit/storage, listing
+// the keys below a given key relative to it.
+type testLegacyStorage map[string]string
+
+func (s testLegacyStorage) List(ctx context.Context, key string) ([]string, error) {
+	prefix := strings.TrimSuffix(key, "/") + "/"
+
+	var keys []string
+	for k := range s {
+		if strings.HasPrefix(k, prefix) {
+			keys = append(keys, k[len(prefix):])
+		}
+	}
+	sort.Strings(keys)
+
+	return keys, nil
+}
+
+func (s testLegacyStorage) Search(ctx context.Context, key string) (string, error) {
+	v, ok := s[key]
+	if !ok {
+		return "", microerror.Maskf(NotFoundError, "%s", key)
+	}
+
+	return v, nil
+}
@@ -0,0 +1,44 @@
+package rangepool
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// Notifier is informed about items being allocated and released by the
+// Service, see Config.Notifier. Events are passed synchronously right after
+// the change has been persisted, so implementations should not block for
+// long.
+type Notifier interface {
+	Notify(ctx context.Context, e Event) error
+}
+
+// notify passes the event of the given type to the configured Notifier.
+// Failures are only logged, since the change has been persisted already.
+func (s *Service) notify(ctx context.Context, eventType, namespace, ID string, items []int) {
+	// Every allocation and release outdates the memoized reads of the
+	// namespace, see Config.ReadCacheTTL.
+	s.reads.Invalidate(namespace)
+
+	if s.notifier == nil || len(items) == 0 {
+		return
+	}
+
+	// Items are passed in ascending order, no matter the order in which the
+	// storage listed them.
+	l := append([]int{}, items...)
+	sort.Ints(l)
+
+	e := Event{
+		ID:        ID,
+		Items:     l,
+		Namespace: namespace,
+		Type:      eventType,
+	}
+
+	err := s.notifier.Notify(ctx, e)
+	if err != nil {
+		s.logger.LogCtx(ctx, "level", "warning", "message", fmt.Sprintf("failed notifying %s event of ID '%s' in namespace '%s'", eventType, ID, namespace), "stack", fmt.Sprintf("%#v", err))
+	}
+}
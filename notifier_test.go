@@ -0,0 +1,67 @@
+package rangepool
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/giantswarm/micrologger/microloggertest"
+)
+
+type testNotifier struct {
+	events []Event
+}
+
+func (n *testNotifier) Notify(ctx context.Context, e Event) error {
+	n.events = append(n.events, e)
+	return nil
+}
+
+func Test_Service_Notifier(t *testing.T) {
+	for _, b := range []bool{false, true} {
+		n := &testNotifier{}
+
+		var err error
+		var newService *Service
+		{
+			newStorage, err := newMemoryStorage()
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+
+			newService, err = NewWithOptions(newStorage, WithBitmap(b), WithLogger(microloggertest.New()), WithNotifier(n))
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+		}
+
+		ctx := context.TODO()
+
+		_, err = newService.Create(ctx, namespace, "test-id-1", 2, 1, 5)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		_, err = newService.Create(ctx, namespace, "test-id-2", 1, 1, 5)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		err = newService.Delete(ctx, namespace, "test-id-1")
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		err = newService.ForceRelease(ctx, namespace, 3)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		expected := []Event{
+			{ID: "test-id-1", Items: []int{1, 2}, Namespace: namespace, Type: EventTypeAllocated},
+			{ID: "test-id-2", Items: []int{3}, Namespace: namespace, Type: EventTypeAllocated},
+			{ID: "test-id-1", Items: []int{1, 2}, Namespace: namespace, Type: EventTypeReleased},
+			{ID: "test-id-2", Items: []int{3}, Namespace: namespace, Type: EventTypeReleased},
+		}
+		if !reflect.DeepEqual(n.events, expected) {
+			t.Fatal("expected", expected, "got", n.events)
+		}
+	}
+}
@@ -0,0 +1,274 @@
+package rangepool
+
+import (
+	"time"
+
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+)
+
+// Option configures a Service created using NewWithOptions. Each option sets
+// the Config field of the same name.
+type Option func(config *Config)
+
+// NewWithOptions creates a new range pool using the given storage and options.
+// All settings not configured by options default to DefaultConfig. New knobs
+// are added as options, so that callers do not break when the Service grows.
+// New remains available for callers configuring the Service using Config.
+func NewWithOptions(storage Storage, opts ...Option) (*Service, error) {
+	config := DefaultConfig()
+	config.Storage = storage
+
+	for _, o := range opts {
+		o(&config)
+	}
+
+	s, err := New(config)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return s, nil
+}
+
+// WithAlmostFullThreshold sets Config.AlmostFullThreshold.
+func WithAlmostFullThreshold(threshold float64) Option {
+	return func(config *Config) {
+		config.AlmostFullThreshold = threshold
+	}
+}
+
+// WithAudit sets Config.Audit.
+func WithAudit(audit bool) Option {
+	return func(config *Config) {
+		config.Audit = audit
+	}
+}
+
+// WithBitmap sets Config.Bitmap.
+func WithBitmap(bitmap bool) Option {
+	return func(config *Config) {
+		config.Bitmap = bitmap
+	}
+}
+
+// WithBreaker sets Config.BreakerThreshold and Config.BreakerTimeout.
+func WithBreaker(threshold int, timeout time.Duration) Option {
+	return func(config *Config) {
+		config.BreakerThreshold = threshold
+		config.BreakerTimeout = timeout
+	}
+}
+
+// WithBurnedCacheTTL sets Config.BurnedCacheTTL.
+func WithBurnedCacheTTL(ttl time.Duration) Option {
+	return func(config *Config) {
+		config.BurnedCacheTTL = ttl
+	}
+}
+
+// WithCacheTTL sets Config.CacheTTL.
+func WithCacheTTL(ttl time.Duration) Option {
+	return func(config *Config) {
+		config.CacheTTL = ttl
+	}
+}
+
+// WithChecksums sets Config.Checksums.
+func WithChecksums(checksums bool) Option {
+	return func(config *Config) {
+		config.Checksums = checksums
+	}
+}
+
+// WithClock sets Config.Clock.
+func WithClock(clock Clock) Option {
+	return func(config *Config) {
+		config.Clock = clock
+	}
+}
+
+// WithCoalesceLists sets Config.CoalesceLists.
+func WithCoalesceLists(coalesce bool) Option {
+	return func(config *Config) {
+		config.CoalesceLists = coalesce
+	}
+}
+
+// WithCompressBitmaps sets Config.CompressBitmaps.
+func WithCompressBitmaps(compress bool) Option {
+	return func(config *Config) {
+		config.CompressBitmaps = compress
+	}
+}
+
+// WithDescending sets Config.Descending.
+func WithDescending(descending bool) Option {
+	return func(config *Config) {
+		config.Descending = descending
+	}
+}
+
+// WithElector sets Config.Elector.
+func WithElector(elector *Elector) Option {
+	return func(config *Config) {
+		config.Elector = elector
+	}
+}
+
+// WithHistorySize sets Config.HistorySize.
+func WithHistorySize(size int) Option {
+	return func(config *Config) {
+		config.HistorySize = size
+	}
+}
+
+// WithIDQuota sets Config.IDQuota.
+func WithIDQuota(quota int) Option {
+	return func(config *Config) {
+		config.IDQuota = quota
+	}
+}
+
+// WithIDQuotas sets Config.IDQuotas.
+func WithIDQuotas(quotas map[string]int) Option {
+	return func(config *Config) {
+		config.IDQuotas = quotas
+	}
+}
+
+// WithKeyEncrypter sets Config.KeyEncrypter.
+func WithKeyEncrypter(keyEncrypter KeyEncrypter) Option {
+	return func(config *Config) {
+		config.KeyEncrypter = keyEncrypter
+	}
+}
+
+// WithKeyPrefix sets Config.KeyPrefix.
+func WithKeyPrefix(prefix string) Option {
+	return func(config *Config) {
+		config.KeyPrefix = prefix
+	}
+}
+
+// WithLatestMode sets Config.LatestMode.
+func WithLatestMode(mode string) Option {
+	return func(config *Config) {
+		config.LatestMode = mode
+	}
+}
+
+// WithLogger sets Config.Logger.
+func WithLogger(logger micrologger.Logger) Option {
+	return func(config *Config) {
+		config.Logger = logger
+	}
+}
+
+// WithMaxNum sets Config.MaxNum.
+func WithMaxNum(maxNum int) Option {
+	return func(config *Config) {
+		config.MaxNum = maxNum
+	}
+}
+
+// WithMaxRangeSize sets Config.MaxRangeSize.
+func WithMaxRangeSize(maxRangeSize int) Option {
+	return func(config *Config) {
+		config.MaxRangeSize = maxRangeSize
+	}
+}
+
+// WithNamespaceQuota sets Config.NamespaceQuota.
+func WithNamespaceQuota(quota int) Option {
+	return func(config *Config) {
+		config.NamespaceQuota = quota
+	}
+}
+
+// WithNamespaceQuotas sets Config.NamespaceQuotas.
+func WithNamespaceQuotas(quotas map[string]int) Option {
+	return func(config *Config) {
+		config.NamespaceQuotas = quotas
+	}
+}
+
+// WithNotifier sets Config.Notifier.
+func WithNotifier(notifier Notifier) Option {
+	return func(config *Config) {
+		config.Notifier = notifier
+	}
+}
+
+// WithPolicyCacheTTL sets Config.PolicyCacheTTL.
+func WithPolicyCacheTTL(ttl time.Duration) Option {
+	return func(config *Config) {
+		config.PolicyCacheTTL = ttl
+	}
+}
+
+// WithRanges sets Config.Ranges.
+func WithRanges(ranges map[string]Range) Option {
+	return func(config *Config) {
+		config.Ranges = ranges
+	}
+}
+
+// WithReadCacheTTL sets Config.ReadCacheTTL.
+func WithReadCacheTTL(ttl time.Duration) Option {
+	return func(config *Config) {
+		config.ReadCacheTTL = ttl
+	}
+}
+
+// WithReadOnly sets Config.ReadOnly.
+func WithReadOnly(readOnly bool) Option {
+	return func(config *Config) {
+		config.ReadOnly = readOnly
+	}
+}
+
+// WithReservationTimeout sets Config.ReservationTimeout.
+func WithReservationTimeout(timeout time.Duration) Option {
+	return func(config *Config) {
+		config.ReservationTimeout = timeout
+	}
+}
+
+// WithRetry sets Config.RetryAttempts, Config.RetryBackoff and
+// Config.RetryJitter.
+func WithRetry(attempts int, backoff time.Duration, jitter float64) Option {
+	return func(config *Config) {
+		config.RetryAttempts = attempts
+		config.RetryBackoff = backoff
+		config.RetryJitter = jitter
+	}
+}
+
+// WithSchemaMigration sets Config.SchemaMigration.
+func WithSchemaMigration(migration bool) Option {
+	return func(config *Config) {
+		config.SchemaMigration = migration
+	}
+}
+
+// WithSticky sets Config.Sticky.
+func WithSticky(sticky bool) Option {
+	return func(config *Config) {
+		config.Sticky = sticky
+	}
+}
+
+// WithWatchInterval sets Config.WatchInterval.
+func WithWatchInterval(interval time.Duration) Option {
+	return func(config *Config) {
+		config.WatchInterval = interval
+	}
+}
+
+// WithZeroPaddedKeys sets Config.ZeroPaddedKeys.
+func WithZeroPaddedKeys(zeroPaddedKeys bool) Option {
+	return func(config *Config) {
+		config.ZeroPaddedKeys = zeroPaddedKeys
+	}
+}
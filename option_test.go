@@ -0,0 +1,53 @@
+package rangepool
+
+import (
+	"context"
+	"testing"
+
+	"github.com/giantswarm/micrologger/microloggertest"
+)
+
+func Test_NewWithOptions(t *testing.T) {
+	newStorage, err := newMemoryStorage()
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+
+	// Options must be applied on top of the default configuration.
+	{
+		s, err := NewWithOptions(newStorage, WithLogger(microloggertest.New()), WithBitmap(true), WithKeyPrefix("custom-pool"))
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		if !s.bitmap {
+			t.Fatal("expected", true, "got", false)
+		}
+		if s.keyPrefix != "custom-pool" {
+			t.Fatal("expected", "custom-pool", "got", s.keyPrefix)
+		}
+		if s.watchInterval != DefaultConfig().WatchInterval {
+			t.Fatal("expected", DefaultConfig().WatchInterval, "got", s.watchInterval)
+		}
+
+		_, err = s.Create(context.TODO(), namespace, "test-id", 1, 2, 3)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+	}
+
+	// Options must be validated like Config.
+	{
+		_, err := NewWithOptions(newStorage, WithLogger(microloggertest.New()), WithKeyPrefix(""))
+		if !IsInvalidConfig(err) {
+			t.Fatal("expected", true, "got", false)
+		}
+	}
+
+	// The storage must not be empty.
+	{
+		_, err := NewWithOptions(nil, WithLogger(microloggertest.New()))
+		if !IsInvalidConfig(err) {
+			t.Fatal("expected", true, "got", false)
+		}
+	}
+}
@@ -0,0 +1,327 @@
+package rangepool
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/giantswarm/microerror"
+)
+
+// Policy is the allocation policy of a namespace persisted within the storage,
+// see Service.SetPolicy. Zero values fall back to the configuration of the
+// Service, so a policy only has to define the settings it overrides.
+type Policy struct {
+	// Cooldown is the duration released items rest before they are handed out
+	// again. A cooldown of 0 disables it.
+	Cooldown time.Duration `json:"cooldown,omitempty"`
+	// Exclusions are items which are never handed out while the policy is in
+	// place. Unlike burned items they become available again once they are
+	// removed from the policy, see Service.Burn.
+	Exclusions []int `json:"exclusions,omitempty"`
+	// IDClasses reserve sub-ranges for IDs starting with a prefix, e.g.
+	// "tenant-" 5000-9000. IDs of a class must only request ranges within the
+	// sub-range of their class, which Service.Create enforces. The class of the
+	// longest matching prefix wins. IDs without class are not restricted.
+	IDClasses []IDClass `json:"idClasses,omitempty"`
+	// IDQuota overrides Config.IDQuota and Config.IDQuotas.
+	IDQuota int `json:"idQuota,omitempty"`
+	// LatestMode overrides Config.LatestMode.
+	LatestMode string `json:"latestMode,omitempty"`
+	// NamespaceQuota overrides Config.NamespaceQuota and
+	// Config.NamespaceQuotas.
+	NamespaceQuota int `json:"namespaceQuota,omitempty"`
+	// SubPools partitions the range of the namespace into named sub-pools,
+	// e.g. "system" 2-100 and "user" 101-9999, keyed by name. Sub-pools must
+	// not overlap. See Service.CreateInSubPool.
+	SubPools map[string]SubPool `json:"subPools,omitempty"`
+	// Windows are the parts of the range allocations prefer, e.g. favor
+	// 1000-2000 and spill into 2001-4000 only when needed. Windows of higher
+	// weight are used first. Items outside of all windows are only handed out
+	// once the windows within the requested range are exhausted.
+	Windows []Window `json:"windows,omitempty"`
+}
+
+// Policy returns the allocation policy persisted for the given namespace. In
+// case no policy has been set, the zero Policy is returned. The policy is
+// always read from the storage, see also Config.PolicyCacheTTL.
+func (s *Service) Policy(ctx context.Context, namespace string) (Policy, error) {
+	var p Policy
+
+	v, err := s.storage.Search(ctx, s.key(PolicyKeyFormat, namespace))
+	if IsNotFound(err) {
+		return Policy{}, nil
+	} else if err != nil {
+		return Policy{}, microerror.Mask(err)
+	}
+
+	err = json.Unmarshal([]byte(v), &p)
+	if err != nil {
+		return Policy{}, microerror.Maskf(executionFailedError, "decoding policy of namespace '%s': %s", namespace, err.Error())
+	}
+
+	return p, nil
+}
+
+// SetPolicy persists the given allocation policy for the given namespace, so
+// that every Service sharing the storage allocates items of the namespace the
+// same way, no matter how it is configured. Setting the zero Policy removes
+// the policy of the namespace.
+func (s *Service) SetPolicy(ctx context.Context, namespace string, policy Policy) error {
+	ctx = withOperation(ctx, "SetPolicy", namespace, "")
+
+	err := s.checkReadOnly()
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = s.checkLeader()
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = validatePolicy(policy)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	k := s.key(PolicyKeyFormat, namespace)
+
+	// The cached policy is dropped before and after the write, so that it is
+	// not cached while the write is in flight.
+	s.policies.Invalidate(namespace)
+	defer s.policies.Invalidate(namespace)
+
+	if isEmptyPolicy(policy) {
+		err := s.storage.Delete(ctx, k)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
+		return nil
+	}
+
+	b, err := json.Marshal(policy)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = s.storage.Create(ctx, k, string(b))
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	s.logger.LogCtx(ctx, "level", "info", "message", fmt.Sprintf("set policy of namespace '%s'", namespace))
+
+	return nil
+}
+
+// searchBlocked fetches the items of the given namespace which must not be
+// handed out even though they are free. These are the burned items, the
+// exclusions of the policy and the items which are still cooling down.
+func (s *Service) searchBlocked(ctx context.Context, namespace string) ([]int, error) {
+	blocked, err := s.searchBurned(ctx, namespace)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	blocked = append(blocked, s.exclusions...)
+
+	if s.cooldown > 0 {
+		since := s.clock.Now().Add(-s.cooldown).UnixNano()
+
+		err := walk(ctx, s.storage, s.key(FreedListKeyFormat, namespace), func(kv KV) error {
+			item, err := strconv.Atoi(kv.Key)
+			if err != nil {
+				return microerror.Mask(err)
+			}
+			t, err := strconv.ParseInt(kv.Value, 10, 64)
+			if err != nil {
+				return microerror.Mask(err)
+			}
+			if t > since {
+				blocked = append(blocked, item)
+			}
+
+			return nil
+		})
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
+	return blocked, nil
+}
+
+// searchPolicy works like Service.Policy, but caches the policy in case
+// Config.PolicyCacheTTL is greater than zero.
+func (s *Service) searchPolicy(ctx context.Context, namespace string) (Policy, error) {
+	p, ok := s.policies.Get(namespace)
+	if ok {
+		return p, nil
+	}
+
+	p, err := s.Policy(ctx, namespace)
+	if err != nil {
+		return Policy{}, microerror.Mask(err)
+	}
+	s.policies.Set(namespace, p)
+
+	return p, nil
+}
+
+// withPolicy returns a copy of the Service reflecting the policy persisted for
+// the given namespace. The copy shares the dependencies and internals of the
+// Service. In case no policy has been set, the Service itself is returned.
+func (s *Service) withPolicy(ctx context.Context, namespace string) (*Service, error) {
+	p, err := s.searchPolicy(ctx, namespace)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+	if isEmptyPolicy(p) {
+		return s, nil
+	}
+
+	n := *s
+
+	n.cooldown = p.Cooldown
+	n.exclusions = p.Exclusions
+	if p.IDQuota != 0 {
+		n.idQuota = p.IDQuota
+		n.idQuotas = nil
+	}
+	if p.LatestMode != "" {
+		n.latestMode = p.LatestMode
+	}
+	if p.NamespaceQuota != 0 {
+		n.namespaceQuota = p.NamespaceQuota
+		n.namespaceQuotas = nil
+	}
+	n.idClasses = p.IDClasses
+	n.subPools = p.SubPools
+	n.windows = sortWindows(p.Windows)
+
+	return &n, nil
+}
+
+func isEmptyPolicy(policy Policy) bool {
+	return policy.Cooldown == 0 && len(policy.Exclusions) == 0 && len(policy.IDClasses) == 0 && policy.IDQuota == 0 && policy.LatestMode == "" && policy.NamespaceQuota == 0 && len(policy.SubPools) == 0 && len(policy.Windows) == 0
+}
+
+func validatePolicy(policy Policy) error {
+	if policy.Cooldown < 0 {
+		return microerror.Maskf(invalidArgumentError, "cooldown must not be negative")
+	}
+	for _, item := range policy.Exclusions {
+		if item < 0 {
+			return microerror.Maskf(invalidArgumentError, "excluded item %d must not be negative", item)
+		}
+	}
+	if policy.IDQuota < 0 {
+		return microerror.Maskf(invalidArgumentError, "ID quota must not be negative")
+	}
+	switch policy.LatestMode {
+	case "", LatestModeContinue, LatestModeHash, LatestModeLeastRecentlyFreed, LatestModeLowestFree, LatestModePerID, LatestModeRandom, LatestModeResetOnEmpty:
+	default:
+		return microerror.Maskf(invalidArgumentError, "latest mode must be one of '%s', '%s', '%s', '%s', '%s', '%s' or '%s'", LatestModeContinue, LatestModeHash, LatestModeLeastRecentlyFreed, LatestModeLowestFree, LatestModePerID, LatestModeRandom, LatestModeResetOnEmpty)
+	}
+	if policy.NamespaceQuota < 0 {
+		return microerror.Maskf(invalidArgumentError, "namespace quota must not be negative")
+	}
+	err := validateIDClasses(policy.IDClasses)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	err = validateSubPools(policy.SubPools)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	err = validateWindows(policy.Windows)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// policyCache caches the policies of namespaces for a limited amount of time,
+// see Config.PolicyCacheTTL. All methods are safe to be called on a nil cache,
+// which disables caching.
+type policyCache struct {
+	clock   Clock
+	entries map[string]policyCacheEntry
+	mutex   sync.Mutex
+	ttl     time.Duration
+}
+
+type policyCacheEntry struct {
+	created time.Time
+	policy  Policy
+}
+
+func newPolicyCache(clock Clock, ttl time.Duration) *policyCache {
+	if ttl <= 0 {
+		return nil
+	}
+
+	c := &policyCache{
+		clock:   clock,
+		entries: map[string]policyCacheEntry{},
+		mutex:   sync.Mutex{},
+		ttl:     ttl,
+	}
+
+	return c
+}
+
+// Get returns the cached policy of the namespace. The second return value is
+// false in case the namespace is not cached or its entry expired.
+func (c *policyCache) Get(namespace string) (Policy, bool) {
+	if c == nil {
+		return Policy{}, false
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	e, ok := c.entries[namespace]
+	if !ok {
+		return Policy{}, false
+	}
+	if c.clock.Now().Sub(e.created) > c.ttl {
+		delete(c.entries, namespace)
+		return Policy{}, false
+	}
+
+	return e.policy, true
+}
+
+// Invalidate drops the cached policy of the namespace.
+func (c *policyCache) Invalidate(namespace string) {
+	if c == nil {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	delete(c.entries, namespace)
+}
+
+// Set caches the given policy of the namespace.
+func (c *policyCache) Set(namespace string, policy Policy) {
+	if c == nil {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.entries[namespace] = policyCacheEntry{
+		created: c.clock.Now(),
+		policy:  policy,
+	}
+}
@@ -0,0 +1,187 @@
+package rangepool
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/giantswarm/micrologger/microloggertest"
+)
+
+func Test_Service_Policy(t *testing.T) {
+	newStorage, err := newMemoryStorage()
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+
+	// Both services use the default configuration, so all deviating behaviour
+	// must come from the persisted policy.
+	var services []*Service
+	for i := 0; i < 2; i++ {
+		config := DefaultConfig()
+		config.Logger = microloggertest.New()
+		config.Storage = newStorage
+		newService, err := New(config)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		services = append(services, newService)
+	}
+
+	ctx := context.TODO()
+
+	policy := Policy{
+		Cooldown:   time.Hour,
+		Exclusions: []int{3},
+		IDQuota:    2,
+		LatestMode: LatestModeLowestFree,
+	}
+	err = services[0].SetPolicy(ctx, namespace, policy)
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+
+	{
+		p, err := services[1].Policy(ctx, namespace)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		if !reflect.DeepEqual(p, policy) {
+			t.Fatal("expected", policy, "got", p)
+		}
+	}
+
+	// The ID quota of the policy must be enforced.
+	{
+		items, err := services[0].Create(ctx, namespace, "test-id-1", 2, 1, 6)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		expected := []int{1, 2}
+		if !reflect.DeepEqual(items, expected) {
+			t.Fatal("expected", expected, "got", items)
+		}
+
+		_, err = services[1].Create(ctx, namespace, "test-id-1", 1, 1, 6)
+		if !IsQuotaExceeded(err) {
+			t.Fatal("expected", true, "got", false)
+		}
+	}
+
+	// Released items must cool down and excluded items must be skipped, while
+	// the lowest free items are used.
+	{
+		err := services[1].Delete(ctx, namespace, "test-id-1")
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		items, err := services[1].Create(ctx, namespace, "test-id-2", 2, 1, 6)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		expected := []int{4, 5}
+		if !reflect.DeepEqual(items, expected) {
+			t.Fatal("expected", expected, "got", items)
+		}
+	}
+
+	// Setting the zero policy must remove it, so that the configuration of the
+	// services applies again.
+	{
+		err := services[0].SetPolicy(ctx, namespace, Policy{})
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		p, err := services[1].Policy(ctx, namespace)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		if !reflect.DeepEqual(p, Policy{}) {
+			t.Fatal("expected", Policy{}, "got", p)
+		}
+
+		items, err := services[1].Create(ctx, namespace, "test-id-3", 3, 1, 6)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		expected := []int{6, 1, 2}
+		if !reflect.DeepEqual(items, expected) {
+			t.Fatal("expected", expected, "got", items)
+		}
+	}
+
+	// Invalid policies must be rejected.
+	{
+		err := services[0].SetPolicy(ctx, namespace, Policy{LatestMode: "unknown"})
+		if !IsInvalidArgument(err) {
+			t.Fatal("expected", true, "got", false)
+		}
+	}
+}
+
+func Test_Service_Policy_Cache(t *testing.T) {
+	newStorage, err := newMemoryStorage()
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+
+	clock := &testClock{now: time.Unix(0, 0)}
+
+	var services []*Service
+	for i := 0; i < 2; i++ {
+		config := DefaultConfig()
+		config.Clock = clock
+		config.Logger = microloggertest.New()
+		config.PolicyCacheTTL = time.Minute
+		config.Storage = newStorage
+		newService, err := New(config)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		services = append(services, newService)
+	}
+
+	ctx := context.TODO()
+
+	_, err = services[0].Create(ctx, namespace, "test-id-1", 1, 1, 6)
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+
+	// Policies set by the service itself must take effect right away.
+	{
+		err = services[0].SetPolicy(ctx, namespace, Policy{IDQuota: 1})
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		_, err = services[0].Create(ctx, namespace, "test-id-1", 1, 1, 6)
+		if !IsQuotaExceeded(err) {
+			t.Fatal("expected", true, "got", false)
+		}
+	}
+
+	// Policies set by other services must take effect once the cached policy
+	// expired.
+	{
+		err = services[1].SetPolicy(ctx, namespace, Policy{})
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		_, err = services[0].Create(ctx, namespace, "test-id-1", 1, 1, 6)
+		if !IsQuotaExceeded(err) {
+			t.Fatal("expected", true, "got", false)
+		}
+
+		clock.now = clock.now.Add(2 * time.Minute)
+
+		_, err = services[0].Create(ctx, namespace, "test-id-1", 1, 1, 6)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package rangepool
+
+import (
+	"context"
+	"reflect"
+	"sort"
+
+	"github.com/giantswarm/microerror"
+)
+
+// Precondition guards an allocation, see Service.CreateIf. Zero values
+// disable the respective condition.
+type Precondition struct {
+	// IDEmpty requires the ID to not hold any items within the namespace yet.
+	IDEmpty bool
+	// MaxUtilization requires the utilization of the range, from 0 to 1, to
+	// be below the given ratio before the allocation.
+	MaxUtilization float64
+}
+
+// CreateIf works like Create, but only allocates the items in case the given
+// precondition is met. Otherwise an error is returned which can be asserted
+// using IsPreconditionFailed. This keeps guard logic within the Service
+// instead of racy checks of callers in between separate calls.
+func (s *Service) CreateIf(ctx context.Context, namespace, ID string, num, min, max int, precondition Precondition) ([]int, error) {
+	if precondition.MaxUtilization < 0 || precondition.MaxUtilization > 1 {
+		return nil, microerror.Maskf(invalidArgumentError, "max utilization must be in between 0 and 1")
+	}
+
+	items, err := s.createWithOptions(ctx, namespace, ID, num, min, max, callOptions{precondition: precondition})
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return items, nil
+}
+
+// checkPrecondition returns an error in case the given precondition is not
+// met, see Service.CreateIf. The storage is read bypassing all caches.
+func (s *Service) checkPrecondition(ctx context.Context, namespace, ID string, min, max int, p Precondition) error {
+	if p.IDEmpty {
+		items, err := s.searchItems(ctx, s.key(IDListKeyFormat, namespace, ID))
+		if err != nil {
+			return microerror.Mask(err)
+		}
+		if len(items) != 0 {
+			return microerror.Maskf(preconditionFailedError, "ID '%s' holds %d items in namespace '%s'", ID, len(items), namespace)
+		}
+	}
+
+	if p.MaxUtilization > 0 {
+		st, err := s.Status(NewConsistencyContext(ctx, ConsistencyLinearizable), namespace, min, max)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+		if st.Utilization >= p.MaxUtilization {
+			return microerror.Maskf(preconditionFailedError, "utilization %.2f of namespace '%s' is not below %.2f", st.Utilization, namespace, p.MaxUtilization)
+		}
+	}
+
+	return nil
+}
+
+// DeleteIf works like Delete, but only releases the items of the given ID in
+// case the ID holds exactly the expected items, in any order. Otherwise an
+// error is returned which can be asserted using IsPreconditionFailed. This
+// prevents stale controllers from releasing items which were released and
+// allocated to the ID again concurrently.
+func (s *Service) DeleteIf(ctx context.Context, namespace, ID string, expected []int) error {
+	err := s.deleteWithOptions(ctx, namespace, ID, callOptions{expectItems: true, expectedItems: expected})
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// checkExpectedItems returns an error in case the given items held by the
+// given ID differ from the items expected by the given options, see
+// Service.DeleteIf.
+func (s *Service) checkExpectedItems(namespace, ID string, items []int, opts callOptions) error {
+	if !opts.expectItems {
+		return nil
+	}
+
+	a := append([]int{}, items...)
+	sort.Ints(a)
+	b := append([]int{}, opts.expectedItems...)
+	sort.Ints(b)
+
+	if len(a) != len(b) || (len(a) != 0 && !reflect.DeepEqual(a, b)) {
+		return microerror.Maskf(preconditionFailedError, "ID '%s' holds items %v in namespace '%s' instead of %v", ID, a, namespace, b)
+	}
+
+	return nil
+}
@@ -0,0 +1,186 @@
+package rangepool
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/giantswarm/micrologger/microloggertest"
+)
+
+func Test_Service_CreateIf(t *testing.T) {
+	var newService *Service
+	{
+		newStorage, err := newMemoryStorage()
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		config := DefaultConfig()
+		config.Logger = microloggertest.New()
+		config.Storage = newStorage
+		newService, err = New(config)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+	}
+
+	ctx := context.TODO()
+
+	testCases := []struct {
+		ID           string
+		Num          int
+		Precondition Precondition
+		ErrorMatcher func(error) bool
+	}{
+		// Case 1 ensures IDs without items meet IDEmpty.
+		{
+			ID:           "test-id-1",
+			Num:          2,
+			Precondition: Precondition{IDEmpty: true},
+			ErrorMatcher: nil,
+		},
+		// Case 2 ensures IDs holding items fail IDEmpty.
+		{
+			ID:           "test-id-1",
+			Num:          1,
+			Precondition: Precondition{IDEmpty: true},
+			ErrorMatcher: IsPreconditionFailed,
+		},
+		// Case 3 ensures allocations below the max utilization succeed. The
+		// range of 4 items is half used.
+		{
+			ID:           "test-id-2",
+			Num:          1,
+			Precondition: Precondition{MaxUtilization: 0.6},
+			ErrorMatcher: nil,
+		},
+		// Case 4 ensures allocations at the max utilization fail.
+		{
+			ID:           "test-id-3",
+			Num:          1,
+			Precondition: Precondition{MaxUtilization: 0.75},
+			ErrorMatcher: IsPreconditionFailed,
+		},
+		// Case 5 ensures invalid max utilizations are rejected.
+		{
+			ID:           "test-id-3",
+			Num:          1,
+			Precondition: Precondition{MaxUtilization: 2},
+			ErrorMatcher: IsInvalidArgument,
+		},
+		// Case 6 ensures allocations without precondition succeed.
+		{
+			ID:           "test-id-3",
+			Num:          1,
+			Precondition: Precondition{},
+			ErrorMatcher: nil,
+		},
+	}
+
+	for i, tc := range testCases {
+		items, err := newService.CreateIf(ctx, namespace, tc.ID, tc.Num, 2, 5, tc.Precondition)
+		if tc.ErrorMatcher != nil {
+			if !tc.ErrorMatcher(err) {
+				t.Fatal("case", i+1, "expected", true, "got", false)
+			}
+			continue
+		} else if err != nil {
+			t.Fatal("case", i+1, "expected", nil, "got", err)
+		}
+
+		if len(items) != tc.Num {
+			t.Fatal("case", i+1, "expected", tc.Num, "got", len(items))
+		}
+	}
+}
+
+func Test_Service_DeleteIf(t *testing.T) {
+	var newService *Service
+	{
+		newStorage, err := newMemoryStorage()
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		config := DefaultConfig()
+		config.Logger = microloggertest.New()
+		config.Storage = newStorage
+		newService, err = New(config)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+	}
+
+	ctx := context.TODO()
+
+	_, err := newService.Create(ctx, namespace, "test-id-1", 2, 2, 5)
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+
+	testCases := []struct {
+		ID            string
+		Expected      []int
+		ErrorMatcher  func(error) bool
+		ExpectedItems []int
+	}{
+		// Case 1 ensures items are kept in case the ID holds other items.
+		{
+			ID:            "test-id-1",
+			Expected:      []int{2, 4},
+			ErrorMatcher:  IsPreconditionFailed,
+			ExpectedItems: []int{2, 3},
+		},
+		// Case 2 ensures items are kept in case the ID holds more items.
+		{
+			ID:            "test-id-1",
+			Expected:      []int{2},
+			ErrorMatcher:  IsPreconditionFailed,
+			ExpectedItems: []int{2, 3},
+		},
+		// Case 3 ensures items are released in case the ID holds the expected
+		// items, no matter their order.
+		{
+			ID:            "test-id-1",
+			Expected:      []int{3, 2},
+			ErrorMatcher:  nil,
+			ExpectedItems: nil,
+		},
+		// Case 4 ensures IDs without items fail in case items are expected.
+		{
+			ID:            "test-id-1",
+			Expected:      []int{2, 3},
+			ErrorMatcher:  IsPreconditionFailed,
+			ExpectedItems: nil,
+		},
+		// Case 5 ensures IDs without items meet an empty expectation.
+		{
+			ID:            "test-id-1",
+			Expected:      nil,
+			ErrorMatcher:  nil,
+			ExpectedItems: nil,
+		},
+	}
+
+	for i, tc := range testCases {
+		err := newService.DeleteIf(ctx, namespace, tc.ID, tc.Expected)
+		if tc.ErrorMatcher != nil {
+			if !tc.ErrorMatcher(err) {
+				t.Fatal("case", i+1, "expected", true, "got", false)
+			}
+		} else if err != nil {
+			t.Fatal("case", i+1, "expected", nil, "got", err)
+		}
+
+		items, err := newService.Search(ctx, namespace, tc.ID)
+		if IsItemsNotFound(err) {
+			items = nil
+		} else if err != nil {
+			t.Fatal("case", i+1, "expected", nil, "got", err)
+		}
+		if !reflect.DeepEqual(items, tc.ExpectedItems) {
+			t.Fatal("case", i+1, "expected", tc.ExpectedItems, "got", items)
+		}
+	}
+}
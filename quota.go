@@ -0,0 +1,61 @@
+package rangepool
+
+import (
+	"context"
+
+	"github.com/giantswarm/microerror"
+)
+
+// checkIDQuota returns a QuotaExceededError in case allocating num more items
+// for the given ID would exceed its quota within the given namespace, see
+// Config.IDQuota. In case reserved is true, the items of pending reservations
+// for the ID count towards its quota as well, see Service.Reserve. The items
+// of the ID are only fetched in case a quota is configured for the namespace.
+func (s *Service) checkIDQuota(ctx context.Context, namespace, ID string, num int, reserved bool) error {
+	quota, ok := s.idQuotas[namespace]
+	if !ok {
+		quota = s.idQuota
+	}
+	if quota == 0 {
+		return nil
+	}
+
+	items, err := s.searchItems(ctx, s.key(IDListKeyFormat, namespace, ID))
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	owned := len(items)
+	if reserved {
+		n, err := s.reservedItems(ctx, namespace, ID)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+		owned += n
+	}
+
+	if owned+num > quota {
+		return microerror.Mask(&QuotaExceededError{ID: ID, Namespace: namespace, Num: num, Owned: owned, Quota: quota})
+	}
+
+	return nil
+}
+
+// checkNamespaceQuota returns an error in case allocating num more items
+// within the given namespace, which already uses the given number of items,
+// would exceed its quota, see Config.NamespaceQuota.
+func (s *Service) checkNamespaceQuota(namespace string, used, num int) error {
+	quota, ok := s.namespaceQuotas[namespace]
+	if !ok {
+		quota = s.namespaceQuota
+	}
+	if quota == 0 {
+		return nil
+	}
+
+	if used+num > quota {
+		return microerror.Maskf(namespaceQuotaExceededError, "requested %d items in namespace '%s' using %d items but quota is %d", num, namespace, used, quota)
+	}
+
+	return nil
+}
@@ -0,0 +1,158 @@
+package rangepool
+
+import (
+	"context"
+	"testing"
+
+	"github.com/giantswarm/micrologger/microloggertest"
+)
+
+func Test_Service_Create_IDQuota(t *testing.T) {
+	for _, b := range []bool{false, true} {
+		var err error
+		var newService *Service
+		{
+			newStorage, err := newMemoryStorage()
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+
+			config := DefaultConfig()
+			config.Logger = microloggertest.New()
+			config.Storage = newStorage
+			config.Bitmap = b
+			config.IDQuota = 3
+			config.IDQuotas = map[string]int{"test-namespace-unlimited": 0}
+			newService, err = New(config)
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+		}
+
+		ctx := context.TODO()
+
+		// Allocations up to the quota must succeed.
+		{
+			_, err = newService.Create(ctx, namespace, "test-id-1", 2, 2, 10)
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+			_, err = newService.Create(ctx, namespace, "test-id-1", 1, 2, 10)
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+		}
+
+		// Allocations exceeding the quota must fail with details.
+		{
+			_, err = newService.Create(ctx, namespace, "test-id-1", 1, 2, 10)
+			if !IsQuotaExceeded(err) {
+				t.Fatal("expected", true, "got", false)
+			}
+
+			e, ok := AsQuotaExceeded(err)
+			if !ok {
+				t.Fatal("expected", true, "got", false)
+			}
+			expected := QuotaExceededError{ID: "test-id-1", Namespace: namespace, Num: 1, Owned: 3, Quota: 3}
+			if *e != expected {
+				t.Fatal("expected", expected, "got", *e)
+			}
+		}
+
+		// The quota applies per ID.
+		{
+			_, err = newService.Create(ctx, namespace, "test-id-2", 3, 2, 10)
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+		}
+
+		// Namespaces can override the quota.
+		{
+			_, err = newService.Create(ctx, "test-namespace-unlimited", "test-id-1", 5, 2, 10)
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+		}
+
+		// Releasing the items of an ID must free its quota.
+		{
+			err = newService.Delete(ctx, namespace, "test-id-1")
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+			_, err = newService.Create(ctx, namespace, "test-id-1", 3, 2, 10)
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+		}
+	}
+}
+
+func Test_Service_Create_NamespaceQuota(t *testing.T) {
+	for _, b := range []bool{false, true} {
+		var err error
+		var newService *Service
+		{
+			newStorage, err := newMemoryStorage()
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+
+			config := DefaultConfig()
+			config.Logger = microloggertest.New()
+			config.Storage = newStorage
+			config.Bitmap = b
+			config.NamespaceQuota = 3
+			config.NamespaceQuotas = map[string]int{"test-namespace-unlimited": 0}
+			newService, err = New(config)
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+		}
+
+		ctx := context.TODO()
+
+		// Allocations up to the quota must succeed, no matter the ID.
+		{
+			_, err = newService.Create(ctx, namespace, "test-id-1", 2, 2, 10)
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+			_, err = newService.Create(ctx, namespace, "test-id-2", 1, 2, 10)
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+		}
+
+		// Allocations exceeding the quota must fail, even though the range
+		// has free items.
+		{
+			_, err = newService.Create(ctx, namespace, "test-id-3", 1, 2, 10)
+			if !IsNamespaceQuotaExceeded(err) {
+				t.Fatal("expected", true, "got", false)
+			}
+		}
+
+		// Namespaces can override the quota.
+		{
+			_, err = newService.Create(ctx, "test-namespace-unlimited", "test-id-1", 5, 2, 10)
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+		}
+
+		// Releasing items must free the quota.
+		{
+			err = newService.Delete(ctx, namespace, "test-id-2")
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+			_, err = newService.Create(ctx, namespace, "test-id-3", 1, 2, 10)
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+		}
+	}
+}
@@ -2,16 +2,72 @@ package rangepool
 
 import (
 	"context"
-	"fmt"
+	"hash/fnv"
+	"math/rand"
 	"sort"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/giantswarm/microerror"
 	"github.com/giantswarm/micrologger"
-	"github.com/giantswarm/microstorage"
 )
 
 const (
+	// AuditKeyFormat is the format string used to create a storage key to
+	// persist an entry of the audit trail of a namespace in case Config.Audit
+	// is enabled. Entry keys start with the zero padded timestamp in
+	// nanoseconds, followed by the action and the ID.
+	//
+	//     range-pool/${namespace1}/audit/${entry1}    ${json}
+	//
+	AuditKeyFormat = "range-pool/%s/audit/%s"
+	// AuditListKeyFormat is the format string used to create a storage key to
+	// lookup the audit trail of a namespace. See also AuditKeyFormat.
+	AuditListKeyFormat = "range-pool/%s/audit"
+	// BitmapKeyFormat is the format string used to create a storage key to
+	// persist the items of a namespace as a single bitmap in case Config.Bitmap
+	// is enabled. The bitmap then replaces the keys described by ItemKeyFormat.
+	//
+	//     range-pool/${namespace1}/bitmap    ${bitmap}
+	//
+	BitmapKeyFormat = "range-pool/%s/bitmap"
+	// HealthzKeyFormat is the format string used to create the sentinel key
+	// read by Service.Healthz. The key is never written. It is shaped like the
+	// latest key of a namespace, so that storage implementations mapping keys
+	// onto tables, like storage/postgres, execute an actual query.
+	//
+	//     range-pool/healthz/latest
+	//
+	HealthzKeyFormat = "range-pool/healthz/latest"
+	// BurnedKeyFormat is the format string used to create a storage key to
+	// persist an item which is permanently retired, see Service.Burn.
+	//
+	//     range-pool/${namespace1}/burned/${item1}    ${item1}
+	//
+	BurnedKeyFormat = "range-pool/%s/burned/%s"
+	// BurnedListKeyFormat is the format string used to create a storage key to
+	// lookup the burned items of a namespace. See also BurnedKeyFormat.
+	BurnedListKeyFormat = "range-pool/%s/burned"
+	// FreedKeyFormat is the format string used to create a storage key to
+	// persist the time an item was released at, in nanoseconds, in case
+	// Config.LatestMode is LatestModeLeastRecentlyFreed or the policy of the
+	// namespace defines a cooldown, see Policy.
+	//
+	//     range-pool/${namespace1}/freed/${item1}    ${time}
+	//
+	FreedKeyFormat = "range-pool/%s/freed/%s"
+	// FreedListKeyFormat is the format string used to create a storage key to
+	// lookup the release times of the items of a namespace. See also
+	// FreedKeyFormat.
+	FreedListKeyFormat = "range-pool/%s/freed"
+	// HistoryKeyFormat is the format string used to create a storage key to
+	// persist the previous owners of an item in case Config.HistorySize is
+	// greater than zero, see Service.History.
+	//
+	//     range-pool/${namespace1}/history/${item1}    ${json}
+	//
+	HistoryKeyFormat = "range-pool/%s/history/%s"
 	// IDKeyFormat is the format string used to create a storage key to persist
 	// the relationship between IDs and items.
 	//
@@ -21,9 +77,22 @@ const (
 	//     range-pool/${namespace1}/id/${id2}/item/${item4}    ${item4}
 	//
 	IDKeyFormat = "range-pool/%s/id/%s/item/%s"
+	// IDLatestKeyFormat is the format string used to create a storage key to
+	// persist the latest item of an ID in case Config.LatestMode is
+	// LatestModePerID. It replaces LatestKeyFormat for allocations of the ID.
+	//
+	//     range-pool/${namespace1}/id/${id1}/latest    ${item1}
+	//
+	IDLatestKeyFormat = "range-pool/%s/id/%s/latest"
 	// IDListKeyFormat is the format string used to create a storage key to lookup
 	// the list of items of an ID. See also IDKeyFormat.
 	IDListKeyFormat = "range-pool/%s/id/%s/item"
+	// IDPrefixKeyFormat is the format string used to create a storage key to
+	// lookup the items of all IDs of a namespace. See also IDKeyFormat.
+	//
+	//     range-pool/${namespace1}/id
+	//
+	IDPrefixKeyFormat = "range-pool/%s/id"
 	// ItemKeyFormat is the format string used to create a storage key to persist
 	// the relation between a namespace and its associated items.
 	//
@@ -48,6 +117,133 @@ const (
 	//     range-pool/${namespace1}/latest    ${item4}
 	//
 	LatestKeyFormat = "range-pool/%s/latest"
+	// LeaseKeyFormat is the format string used to create the storage key to
+	// persist the lease of an Elector, keyed by lease name.
+	//
+	//     range-pool/leader/${lease1}    ${json}
+	//
+	LeaseKeyFormat = "range-pool/leader/%s"
+	// NamespaceKeyFormat is the format string used to create a storage key to
+	// lookup all keys of a namespace.
+	//
+	//     range-pool/${namespace1}
+	//
+	NamespaceKeyFormat = "range-pool/%s"
+	// PolicyKeyFormat is the format string used to create a storage key to
+	// persist the allocation policy of a namespace, see Service.SetPolicy.
+	//
+	//     range-pool/${namespace1}/policy    ${json}
+	//
+	PolicyKeyFormat = "range-pool/%s/policy"
+	// PreviousKeyFormat is the format string used to create a storage key to
+	// persist the items an ID held before its last release in case
+	// Config.Sticky is enabled.
+	//
+	//     range-pool/${namespace1}/previous/${id1}    ${json}
+	//
+	PreviousKeyFormat = "range-pool/%s/previous/%s"
+	// ReservationKeyFormat is the format string used to create a storage key to
+	// persist a reservation of a namespace, see Service.Reserve. The reserved
+	// items are owned by the reservation ID of the reservation until they are
+	// committed.
+	//
+	//     range-pool/${namespace1}/reservation/${token1}    ${json}
+	//
+	ReservationKeyFormat = "range-pool/%s/reservation/%s"
+	// ReservationListKeyFormat is the format string used to create a storage
+	// key to lookup the reservations of a namespace. See also
+	// ReservationKeyFormat.
+	ReservationListKeyFormat = "range-pool/%s/reservation"
+	// SchemaKeyFormat is the format string used to create a storage key to
+	// persist the storage layout of a namespace, i.e. whether its items are
+	// persisted as bitmap or zero padded keys and the SchemaVersion it was
+	// written with. See Config.SchemaMigration.
+	//
+	//     range-pool/${namespace1}/schema    ${json}
+	//
+	SchemaKeyFormat = "range-pool/%s/schema"
+	// SnapshotKeyFormat is the format string used to create a storage key to
+	// persist a versioned snapshot of a namespace, see Service.Snapshot and
+	// Service.Rollback.
+	//
+	//     range-pool/${namespace1}/snapshot/${version1}    ${json}
+	//
+	SnapshotKeyFormat = "range-pool/%s/snapshot/%d"
+	// SnapshotListKeyFormat is the format string used to create a storage key
+	// to lookup the versioned snapshots of a namespace. See also
+	// SnapshotKeyFormat.
+	SnapshotListKeyFormat = "range-pool/%s/snapshot"
+	// SubPoolLatestKeyFormat is the format string used to create a storage key
+	// to persist the latest item of a sub-pool of a namespace, see
+	// Service.CreateInSubPool. It replaces LatestKeyFormat for allocations
+	// within the sub-pool.
+	//
+	//     range-pool/${namespace1}/subpool/${subpool1}/latest    ${item1}
+	//
+	SubPoolLatestKeyFormat = "range-pool/%s/subpool/%s/latest"
+	// WaiterKeyFormat is the format string used to create a storage key to
+	// persist a caller waiting for capacity within a namespace, see
+	// Service.CreateWait. The keys start with the zero padded time the caller
+	// started waiting at, so that waiters are ordered by their keys.
+	//
+	//     range-pool/${namespace1}/waiter/${waiter1}    ${json}
+	//
+	WaiterKeyFormat = "range-pool/%s/waiter/%s"
+	// WaiterListKeyFormat is the format string used to create a storage key to
+	// lookup the waiters of a namespace. See also WaiterKeyFormat.
+	WaiterListKeyFormat = "range-pool/%s/waiter"
+)
+
+const (
+	// DefaultKeyPrefix is the prefix of all storage keys described by the key
+	// formats above. It is replaced in case Config.KeyPrefix is configured.
+	DefaultKeyPrefix = "range-pool"
+)
+
+const (
+	// zeroPaddedKeyWidth is the number of digits items are padded to within
+	// storage keys in case Config.ZeroPaddedKeys is enabled.
+	zeroPaddedKeyWidth = 10
+)
+
+const (
+	// LatestModeContinue makes allocations continue after the latest item
+	// allocated, even after items have been released. Released items are only
+	// reused once the end of the range has been reached. This is the default.
+	LatestModeContinue = "continue"
+	// LatestModeHash disables the latest item and makes allocations start
+	// looking for free items at the item derived from the FNV-1a hash of the
+	// ID. In case it is used, the following items are probed. That way the
+	// same ID tends to get the same items in every environment, without any
+	// shared state besides the used items. The latest item is neither read nor
+	// written.
+	LatestModeHash = "hash"
+	// LatestModeLeastRecentlyFreed disables the latest item and makes
+	// allocations prefer the items which have been free the longest. Items
+	// which have never been used come first, in ascending order, followed by
+	// released items ordered by the time they were released, see
+	// FreedKeyFormat. That way released items get the maximum cooldown before
+	// they are reused. The storage/postgres package does not support it.
+	LatestModeLeastRecentlyFreed = "least-recently-freed"
+	// LatestModeLowestFree disables the latest item, so that allocations always
+	// use the lowest free items of the range.
+	LatestModeLowestFree = "lowest-free"
+	// LatestModePerID works like LatestModeContinue, but every ID keeps a
+	// latest item of its own, see IDLatestKeyFormat, so that the items of
+	// every ID stay sequential even though many IDs allocate in turns. This is
+	// useful in case the order of items maps to physical resources. The first
+	// allocation of an ID starts at min. The latest item of an ID is removed
+	// once it releases its items using Service.Delete.
+	LatestModePerID = "per-id"
+	// LatestModeRandom disables the latest item and makes every allocation
+	// start looking for free items at a random item of the range. Many
+	// uncoordinated clients allocating concurrently then rarely compete for
+	// the same items, and the latest item is neither read nor written.
+	LatestModeRandom = "random"
+	// LatestModeResetOnEmpty works like LatestModeContinue, but forgets the
+	// latest item once all items of a namespace have been released, so that
+	// allocations start at the beginning of the range again.
+	LatestModeResetOnEmpty = "reset-on-empty"
 )
 
 const (
@@ -60,23 +256,284 @@ const (
 // Config represents the configuration used to create a new range pool.
 type Config struct {
 	// Dependencies.
-	Logger  micrologger.Logger
-	Storage microstorage.Storage
+
+	// Clock provides the current time to all time based features. It
+	// defaults to the wall clock.
+	Clock Clock
+	// Elector restricts allocations to the leader in case multiple replicas
+	// share a storage. All writes, e.g. Create, Delete, ForceRelease, Commit,
+	// Abort, Burn, Compact, GC, SetPolicy, Import and MigrateKeys, fail with
+	// NotLeaderError on followers, which carries the identity of the leader so
+	// that requests can be proxied. It is optional.
+	Elector *Elector
+	// KeyEncrypter enables encrypting all values written to the storage using
+	// envelope encryption with AES-GCM, e.g. policies, reservations and audit
+	// entries. The data keys are encrypted using KeyEncrypter, see
+	// NewAESKeyEncrypter. Keys are not encrypted, so that they can still be
+	// listed. Since keys carry the namespaces, IDs and items, the allocations
+	// themselves stay visible to anybody able to read the storage, so
+	// encryption does not hide them. Values persisted before encryption was
+	// enabled stay readable. Storages persisting values in typed columns, like
+	// the storage/postgres package, cannot hold encrypted values and are
+	// refused, see TypedStorage. It is optional.
+	KeyEncrypter KeyEncrypter
+	Logger       micrologger.Logger
+	// Notifier is informed about every allocation and release, e.g. to keep
+	// inventory systems in sync, see the webhook package. It is optional.
+	Notifier Notifier
+	Storage  Storage
+
+	// Settings.
+
+	// AlmostFullThreshold is the utilization of the range of a namespace, from
+	// 0 to 1, above which the namespace is considered almost full. Allocations
+	// pushing a namespace above the threshold log a warning, and Service.Status
+	// reports it. A threshold of 0 disables the warnings.
+	AlmostFullThreshold float64
+	// Audit enables recording every allocation and release as an append-only
+	// entry of the audit trail of the namespace, see AuditKeyFormat. Entries
+	// carry the caller identity of the context, see NewCallerContext. They can
+	// be queried using Service.AuditLog. The storage/postgres package does not
+	// support audit entries.
+	Audit bool
+	// Bitmap enables persisting the items of a namespace as a single bitmap
+	// instead of one key per item. This collapses the item keys of a namespace
+	// into a single key and makes finding the next item on big ranges a lot
+	// faster. The setting must not be changed for namespaces which already hold
+	// items.
+	Bitmap bool
+	// Checksums enables persisting a checksum alongside the aggregate state
+	// of namespaces, which is the bitmap and the latest item, see Bitmap.
+	// Values whose checksum does not match, e.g. because they were edited
+	// manually or truncated, are refused with an error which can be asserted
+	// using IsCorrupted, instead of allocating items based on them. Values
+	// without checksum are refused as well, so namespaces which already hold
+	// items must be migrated once after enabling checksums, see
+	// Service.MigrateChecksums. Checksummed values are read when the setting
+	// is disabled again, but Services of earlier versions cannot read them.
+	// Storages persisting values in typed columns, like the storage/postgres
+	// package, cannot hold checksummed values and are refused, see
+	// TypedStorage.
+	Checksums bool
+	// CompressBitmaps enables compressing the bitmaps of namespaces using gzip
+	// before they are persisted, so that bitmaps of huge ranges stay well
+	// below the value size limits of storages, see Bitmap. Compressed and
+	// uncompressed bitmaps are both read, so the setting can be changed at any
+	// time. Services of earlier versions cannot read compressed bitmaps though.
+	CompressBitmaps bool
+	// BreakerThreshold enables a circuit breaker around the storage in case it
+	// is greater than zero. Once the given number of storage operations failed
+	// in a row, all operations fail fast with an error which can be asserted
+	// using IsCircuitOpen, so that callers shed load instead of piling up
+	// timeouts during storage outages. After BreakerTimeout a single probe
+	// operation is let through, which closes the breaker again in case it
+	// succeeds. Retries are executed before the breaker counts a failure, see
+	// RetryAttempts.
+	BreakerThreshold int
+	// BreakerTimeout is the duration the circuit breaker stays open before it
+	// lets a probe operation through, see BreakerThreshold.
+	BreakerTimeout time.Duration
+	// CoalesceLists enables coalescing concurrent listings of the same storage
+	// key, so that many goroutines allocating within the same namespace cause
+	// a single listing instead of one each. Listings started after a write of
+	// the Service completed always reflect the write, but a caller joining a
+	// listing in flight may miss writes of other processes which completed
+	// after the listing started. It is disabled by default.
+	CoalesceLists bool
+	// Descending enables handing out items from max downwards instead of from
+	// min upwards, e.g. in case the low end of the range is informally
+	// reserved for static assignments. The latest item then moves downwards as
+	// well, and LatestModeLowestFree hands out the highest free item.
+	Descending bool
+	// HistorySize is the number of previous owners remembered per item, so
+	// that it can be traced which IDs held an item before, see
+	// Service.History. Once the history of an item is full, the oldest owner
+	// is forgotten. Every release reads and writes the history of the released
+	// items. A size of 0 disables the history.
+	HistorySize int
+	// IDQuota is the maximum number of items a single ID may hold within a
+	// namespace. Allocations exceeding it fail with QuotaExceededError, so that
+	// a single misbehaving tenant cannot exhaust the range. Items of pending
+	// reservations for an ID count towards its quota, see Service.Reserve. A
+	// quota of 0 disables the limit.
+	IDQuota int
+	// IDQuotas overrides IDQuota for the namespaces it contains, keyed by
+	// namespace. A quota of 0 disables the limit for the namespace.
+	IDQuotas map[string]int
+	// KeyPrefix is the first segment of all storage keys, which defaults to
+	// DefaultKeyPrefix. Configuring different prefixes allows multiple pools or
+	// applications to share one storage without key collisions. The prefix must
+	// not contain slashes, since storage implementations may group keys by
+	// their first two segments.
+	KeyPrefix string
+	// LatestMode defines how the latest item allocated within a namespace
+	// affects the next allocations. See LatestModeContinue, LatestModeHash,
+	// LatestModeLeastRecentlyFreed, LatestModeLowestFree, LatestModePerID,
+	// LatestModeRandom and LatestModeResetOnEmpty.
+	LatestMode string
+	// MaxNum limits the number of items a single allocation may request, so
+	// that a typo does not make the Service write millions of keys. Exceeding
+	// it fails with an error which can be asserted using IsLimitExceeded. A
+	// limit of 0 disables it.
+	MaxNum int
+	// MaxRangeSize limits the number of items of the ranges allocations may
+	// request, i.e. max-min+1, so that a typo like max=999999999 does not make
+	// allocating and scanning the range prohibitively expensive. Exceeding it
+	// fails with an error which can be asserted using IsLimitExceeded. A
+	// limit of 0 disables it.
+	MaxRangeSize int
+	// NamespaceQuota is the maximum number of items used within a namespace,
+	// e.g. to keep some headroom of the range reserved for emergencies.
+	// Allocations exceeding it fail with an error asserted by
+	// IsNamespaceQuotaExceeded. A quota of 0 disables the limit.
+	NamespaceQuota int
+	// NamespaceQuotas overrides NamespaceQuota for the namespaces it contains,
+	// keyed by namespace. A quota of 0 disables the limit for the namespace.
+	NamespaceQuotas map[string]int
+	// Ranges maps namespaces to their canonical range, so that allocations
+	// within them can use Service.CreateDefault instead of repeating the
+	// boundaries at every call site. Allocations using explicit boundaries
+	// reaching beyond the range of their namespace fail with an error which
+	// can be asserted using IsRangeConflict. Ranges must be valid boundaries
+	// of allocations, so min must be lower than max, see InvalidRangeError.
+	Ranges map[string]Range
+	// ReadOnly turns the Service read-only, e.g. for safely pointing debugging
+	// tools and dashboards at production storage. Reading operations like
+	// Search, Dump and Status work as usual, while operations modifying the
+	// storage, e.g. Create, Delete or SetPolicy, fail with an error which can
+	// be asserted using IsReadOnly.
+	ReadOnly bool
+	// ReservationTimeout is the duration reservations created using
+	// Service.Reserve are valid for. Reservations which are not committed in
+	// time cannot be committed anymore. Their items are released by the next
+	// allocation within the namespace once they expired, so that abandoned
+	// reservations never permanently shrink the range. Reservations created by
+	// other Services sharing the storage are noticed at most ReservationTimeout
+	// after the last time expired reservations were released.
+	ReservationTimeout time.Duration
+	// RetryAttempts is the number of attempts made for every storage operation
+	// before its error is returned. Errors asserted by IsNotFound are not
+	// retried. The default of 1 disables retries.
+	RetryAttempts int
+	// RetryBackoff is the delay before the first retry of a failed storage
+	// operation. It doubles with every further retry.
+	RetryBackoff time.Duration
+	// RetryJitter varies the delay in between retries randomly by the given
+	// ratio, from 0 to 1, so that concurrent clients do not retry in lockstep.
+	RetryJitter float64
+	// SchemaMigration enables transparently migrating namespaces persisted
+	// with a storage layout other than the configured one, e.g. after changing
+	// Bitmap or ZeroPaddedKeys, using Service.Compact and Service.MigrateKeys.
+	// The layout is verified once per namespace by the first operation
+	// modifying its items, or by Service.Warm. This operation writes the
+	// schema marker of the namespace in case it is missing, and with the
+	// setting enabled it rewrites all items of namespaces persisted with
+	// another layout before proceeding. In case the setting is disabled, which
+	// is the default, operations on such namespaces fail with an error which
+	// can be asserted using IsSchemaMismatch. Namespaces persisted by newer
+	// versions of the range pool are always refused. See SchemaKeyFormat.
+	SchemaMigration bool
+	// Sticky enables remembering the items an ID held when it is deleted, see
+	// PreviousKeyFormat. When the same ID allocates items again, its previous
+	// items are handed back first in case they are still free and within the
+	// range. This keeps items stable across re-creations of the same
+	// workload. The storage/postgres package does not support sticky items.
+	Sticky bool
+	// ZeroPaddedKeys enables encoding the items within storage keys as fixed
+	// width, zero padded numbers, e.g. 0000000005 instead of 5. That way the
+	// lexicographic ordering of keys matches the numeric ordering of items
+	// below 10^10. After changing the setting for namespaces which already hold
+	// items, Service.MigrateKeys must be executed for them.
+	ZeroPaddedKeys bool
+	// CacheTTL enables caching the used items of namespaces within the Service
+	// in case it is greater than zero. Cached items are reused for allocations
+	// until they are older than CacheTTL, which bounds the staleness of the
+	// cache in case other processes allocate items in the same namespaces. Only
+	// namespaces persisted with one key per item are cached, see Bitmap.
+	CacheTTL time.Duration
+	// BurnedCacheTTL enables caching the burned items of namespaces within the
+	// Service in case it is greater than zero, so that allocations do not list
+	// them every time, see Service.Burn. Items burned by the Service itself are
+	// added to the cache right away. Items burned by other processes sharing
+	// the storage may still be handed out by the Service until its cached
+	// items are older than BurnedCacheTTL.
+	BurnedCacheTTL time.Duration
+	// PolicyCacheTTL enables caching the policies of namespaces within the
+	// Service in case it is greater than zero, so that allocations and
+	// releases do not read them every time, see Service.SetPolicy. Policies
+	// set by the Service itself take effect right away. Policies set by other
+	// processes sharing the storage take effect once the cached policy is
+	// older than PolicyCacheTTL.
+	PolicyCacheTTL time.Duration
+	// ReadCacheTTL enables memoizing the results of Service.Search and
+	// Service.Status in case it is greater than zero, so that UIs polling the
+	// status of namespaces do not translate into continuous storage scans.
+	// Results are reused until they are older than ReadCacheTTL, which bounds
+	// their staleness in case other processes modify the same namespaces.
+	// Allocations and releases of the Service itself drop the memoized results
+	// of their namespace right away. See also Service.InvalidateCache.
+	ReadCacheTTL time.Duration
+	// WatchInterval is the interval in which Service.Watch polls namespaces in
+	// case the storage does not implement WatchStorage.
+	WatchInterval time.Duration
 }
 
 // DefaultConfig provides a default configuration to create a new range pool by
-// best effort.
+// best effort. It never constructs any dependency and thus never fails. Logs
+// are discarded unless Logger is configured. Storage must always be
+// configured, which is validated by New.
 func DefaultConfig() Config {
 	return Config{
 		// Dependencies.
-		Logger:  nil,
-		Storage: nil,
+		Clock:        realClock{},
+		Elector:      nil,
+		KeyEncrypter: nil,
+		Logger:       nopLogger{},
+		Notifier:     nil,
+		Storage:      nil,
+
+		// Settings.
+		AlmostFullThreshold: 0,
+		Audit:               false,
+		Bitmap:              false,
+		BreakerThreshold:    0,
+		BreakerTimeout:      30 * time.Second,
+		BurnedCacheTTL:      0,
+		CacheTTL:            0,
+		Checksums:           false,
+		CoalesceLists:       false,
+		CompressBitmaps:     false,
+		Descending:          false,
+		HistorySize:         0,
+		IDQuota:             0,
+		IDQuotas:            nil,
+		KeyPrefix:           DefaultKeyPrefix,
+		LatestMode:          LatestModeContinue,
+		MaxNum:              0,
+		MaxRangeSize:        0,
+		NamespaceQuota:      0,
+		NamespaceQuotas:     nil,
+		PolicyCacheTTL:      0,
+		Ranges:              nil,
+		ReadCacheTTL:        0,
+		ReadOnly:            false,
+		ReservationTimeout:  10 * time.Minute,
+		RetryAttempts:       1,
+		RetryBackoff:        100 * time.Millisecond,
+		RetryJitter:         0.2,
+		SchemaMigration:     false,
+		Sticky:              false,
+		WatchInterval:       5 * time.Second,
+		ZeroPaddedKeys:      false,
 	}
 }
 
 // New creates a new configured range pool.
 func New(config Config) (*Service, error) {
 	// Dependencies.
+	if config.Clock == nil {
+		return nil, microerror.Maskf(invalidConfigError, "clock must not be empty")
+	}
 	if config.Logger == nil {
 		return nil, microerror.Maskf(invalidConfigError, "logger must not be empty")
 	}
@@ -84,10 +541,182 @@ func New(config Config) (*Service, error) {
 		return nil, microerror.Maskf(invalidConfigError, "storage must not be empty")
 	}
 
+	// Settings.
+	if config.KeyPrefix == "" {
+		return nil, microerror.Maskf(invalidConfigError, "key prefix must not be empty")
+	}
+	if strings.Contains(config.KeyPrefix, "/") {
+		return nil, microerror.Maskf(invalidConfigError, "key prefix must not contain slashes")
+	}
+	if config.AlmostFullThreshold < 0 || config.AlmostFullThreshold > 1 {
+		return nil, microerror.Maskf(invalidConfigError, "almost full threshold must be in between 0 and 1")
+	}
+	switch config.LatestMode {
+	case LatestModeContinue, LatestModeHash, LatestModeLeastRecentlyFreed, LatestModeLowestFree, LatestModePerID, LatestModeRandom, LatestModeResetOnEmpty:
+	default:
+		return nil, microerror.Maskf(invalidConfigError, "latest mode must be one of '%s', '%s', '%s', '%s', '%s', '%s' or '%s'", LatestModeContinue, LatestModeHash, LatestModeLeastRecentlyFreed, LatestModeLowestFree, LatestModePerID, LatestModeRandom, LatestModeResetOnEmpty)
+	}
+	if config.BreakerThreshold < 0 {
+		return nil, microerror.Maskf(invalidConfigError, "breaker threshold must not be negative")
+	}
+	if config.BreakerTimeout <= 0 {
+		return nil, microerror.Maskf(invalidConfigError, "breaker timeout must be greater than zero")
+	}
+	if config.ReservationTimeout <= 0 {
+		return nil, microerror.Maskf(invalidConfigError, "reservation timeout must be greater than zero")
+	}
+	if config.RetryAttempts < 1 {
+		return nil, microerror.Maskf(invalidConfigError, "retry attempts must be at least 1")
+	}
+	if config.RetryBackoff < 0 {
+		return nil, microerror.Maskf(invalidConfigError, "retry backoff must not be negative")
+	}
+	if config.RetryJitter < 0 || config.RetryJitter > 1 {
+		return nil, microerror.Maskf(invalidConfigError, "retry jitter must be in between 0 and 1")
+	}
+	if config.HistorySize < 0 {
+		return nil, microerror.Maskf(invalidConfigError, "history size must not be negative")
+	}
+	if config.IDQuota < 0 {
+		return nil, microerror.Maskf(invalidConfigError, "ID quota must not be negative")
+	}
+	for namespace, quota := range config.IDQuotas {
+		if quota < 0 {
+			return nil, microerror.Maskf(invalidConfigError, "ID quota of namespace '%s' must not be negative", namespace)
+		}
+	}
+	if config.MaxNum < 0 {
+		return nil, microerror.Maskf(invalidConfigError, "max num must not be negative")
+	}
+	if config.MaxRangeSize < 0 {
+		return nil, microerror.Maskf(invalidConfigError, "max range size must not be negative")
+	}
+	if config.NamespaceQuota < 0 {
+		return nil, microerror.Maskf(invalidConfigError, "namespace quota must not be negative")
+	}
+	for namespace, quota := range config.NamespaceQuotas {
+		if quota < 0 {
+			return nil, microerror.Maskf(invalidConfigError, "quota of namespace '%s' must not be negative", namespace)
+		}
+	}
+	for namespace, r := range config.Ranges {
+		// Ranges are validated like the boundaries of allocations, since
+		// Service.CreateDefault could never allocate within them otherwise.
+		e, ok := AsInvalidRange(validateBoundaries(r.Min, r.Max, latestItemException))
+		if ok {
+			return nil, microerror.Maskf(invalidConfigError, "range of namespace '%s' is invalid: %s", namespace, e.Reason)
+		}
+	}
+	if config.WatchInterval <= 0 {
+		return nil, microerror.Maskf(invalidConfigError, "watch interval must be greater than zero")
+	}
+	if config.KeyEncrypter != nil && hasTypedValues(config.Storage) {
+		return nil, microerror.Maskf(invalidConfigError, "key encrypter must not be set for storages persisting typed values")
+	}
+	if config.Checksums && hasTypedValues(config.Storage) {
+		return nil, microerror.Maskf(invalidConfigError, "checksums must not be enabled for storages persisting typed values")
+	}
+
+	// All log lines are annotated with the operation being executed and the
+	// key value pairs of the caller, see NewLoggerContext.
+	logger := contextLogger{logger: config.Logger}
+
+	var storage Storage
+	{
+		storage = config.Storage
+		if config.RetryAttempts > 1 {
+			storage = &retryStorage{
+				logger:  logger,
+				storage: config.Storage,
+
+				attempts: config.RetryAttempts,
+				backoff:  config.RetryBackoff,
+				jitter:   config.RetryJitter,
+			}
+		}
+		if config.BreakerThreshold > 0 {
+			storage = &breakerStorage{
+				clock:   config.Clock,
+				logger:  logger,
+				storage: storage,
+
+				threshold: config.BreakerThreshold,
+				timeout:   config.BreakerTimeout,
+			}
+		}
+		if config.CoalesceLists {
+			storage = &flightStorage{
+				storage: storage,
+
+				calls: map[string]*flightCall{},
+			}
+		}
+		// The codec wraps all other layers, so that values are encrypted once
+		// per write no matter how often it is retried, and listings are still
+		// coalesced.
+		if config.KeyEncrypter != nil {
+			storage = &codecStorage{
+				codec:   newEncrypter(config.KeyEncrypter),
+				storage: storage,
+			}
+		}
+	}
+
+	idQuotas := map[string]int{}
+	for namespace, quota := range config.IDQuotas {
+		idQuotas[namespace] = quota
+	}
+
+	namespaceQuotas := map[string]int{}
+	for namespace, quota := range config.NamespaceQuotas {
+		namespaceQuotas[namespace] = quota
+	}
+
+	ranges := map[string]Range{}
+	for namespace, r := range config.Ranges {
+		ranges[namespace] = r
+	}
+
 	newService := &Service{
 		// Dependencies.
-		logger:  config.Logger,
-		storage: config.Storage,
+		clock:    config.Clock,
+		elector:  config.Elector,
+		logger:   logger,
+		notifier: config.Notifier,
+		storage:  storage,
+
+		// Internals.
+		burned:   newUsedCache(config.Clock, config.BurnedCacheTTL),
+		cache:    newUsedCache(config.Clock, config.CacheTTL),
+		closer:   newCloser(),
+		policies: newPolicyCache(config.Clock, config.PolicyCacheTTL),
+		reads:    newReadCache(config.Clock, config.ReadCacheTTL),
+		schemas:  newSchemaCache(),
+		sweeps:   newSweepCache(),
+
+		// Settings.
+		almostFullThreshold: config.AlmostFullThreshold,
+		audit:               config.Audit,
+		bitmap:              config.Bitmap,
+		checksums:           config.Checksums,
+		compressBitmaps:     config.CompressBitmaps,
+		descending:          config.Descending,
+		historySize:         config.HistorySize,
+		idQuota:             config.IDQuota,
+		idQuotas:            idQuotas,
+		keyPrefix:           config.KeyPrefix,
+		latestMode:          config.LatestMode,
+		maxNum:              config.MaxNum,
+		maxRangeSize:        config.MaxRangeSize,
+		namespaceQuota:      config.NamespaceQuota,
+		namespaceQuotas:     namespaceQuotas,
+		ranges:              ranges,
+		readOnly:            config.ReadOnly,
+		reservationTimeout:  config.ReservationTimeout,
+		schemaMigration:     config.SchemaMigration,
+		sticky:              config.Sticky,
+		watchInterval:       config.WatchInterval,
+		zeroPaddedKeys:      config.ZeroPaddedKeys,
 	}
 
 	return newService, nil
@@ -95,168 +724,456 @@ func New(config Config) (*Service, error) {
 
 type Service struct {
 	// Dependencies.
-	logger  micrologger.Logger
-	storage microstorage.Storage
+	clock    Clock
+	elector  *Elector
+	logger   micrologger.Logger
+	notifier Notifier
+	storage  Storage
+
+	// Internals.
+	burned   *usedCache
+	cache    *usedCache
+	closer   *closer
+	policies *policyCache
+	reads    *readCache
+	schemas  *schemaCache
+	sweeps   *sweepCache
+
+	// Settings.
+	almostFullThreshold float64
+	audit               bool
+	bitmap              bool
+	checksums           bool
+	compressBitmaps     bool
+	cooldown            time.Duration
+	descending          bool
+	exclusions          []int
+	historySize         int
+	idClasses           []IDClass
+	idQuota             int
+	idQuotas            map[string]int
+	keyPrefix           string
+	latestMode          string
+	maxNum              int
+	maxRangeSize        int
+	namespaceQuota      int
+	namespaceQuotas     map[string]int
+	ranges              map[string]Range
+	readOnly            bool
+	reservationTimeout  time.Duration
+	schemaMigration     bool
+	sticky              bool
+	subPools            map[string]SubPool
+	watchInterval       time.Duration
+	windows             []Window
+	zeroPaddedKeys      bool
+}
+
+// callOptions are the options of a single call of Service.Create or
+// Service.Delete. They are set by the variants of these methods, e.g.
+// Service.CreateIf, Service.CreateInSubPool and Service.DeleteIf.
+type callOptions struct {
+	// expectItems enables checking the items of the ID against expectedItems
+	// before they are released, see Service.DeleteIf.
+	expectItems   bool
+	expectedItems []int
+	// latestID is the ID whose latest item allocations continue from, see
+	// LatestModePerID.
+	latestID string
+	// precondition guards the allocation, see Service.CreateIf.
+	precondition Precondition
+	// subPool is the sub-pool whose latest item allocations continue from, see
+	// Service.CreateInSubPool.
+	subPool string
 }
 
 func (s *Service) Create(ctx context.Context, namespace, ID string, num, min, max int) ([]int, error) {
-	var err error
+	items, err := s.createWithOptions(ctx, namespace, ID, num, min, max, callOptions{})
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return items, nil
+}
+
+// createWithOptions implements Service.Create using the given options.
+func (s *Service) createWithOptions(ctx context.Context, namespace, ID string, num, min, max int, opts callOptions) ([]int, error) {
+	ctx = withOperation(ctx, "Create", namespace, ID)
+	ctx = NewReadVersionsContext(ctx, NewReadVersions())
+
+	if num <= 0 {
+		return nil, microerror.Maskf(invalidArgumentError, "num must be greater than zero")
+	}
+
+	err := s.checkReadOnly()
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	err = s.checkLeader()
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	s, err = s.withPolicy(ctx, namespace)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	if s.latestMode == LatestModePerID {
+		opts.latestID = ID
+	}
+
+	err = s.ensureSchema(ctx, namespace)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	err = s.checkRange(namespace, min, max)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	err = s.checkLimits(num, min, max)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	err = s.checkIDClass(namespace, ID, min, max)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	err = s.checkIDQuota(ctx, namespace, ID, num, true)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	err = s.checkPrecondition(ctx, namespace, ID, min, max, opts.precondition)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	err = s.checkRevision(ctx, namespace, ID)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	err = s.releaseExpired(ctx, namespace)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	if s.bitmap {
+		items, err := s.createBitmap(ctx, namespace, ID, num, min, max, opts)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		s.notify(ctx, EventTypeAllocated, namespace, ID, items)
+
+		return items, nil
+	}
 
 	// Fetch a list of items we already created. Here we receive a list of items
 	// that may or may not have gaps in it. In case some items have been deleted
 	// there might be gaps, because items are freed and removed from the list.
+	// In case there is no item yet, we create and persist the first ones using
+	// the algorithm invoked below.
 	var used []int
 	{
-		k, err := microstorage.NewK(fmt.Sprintf(ItemListKeyFormat, namespace))
+		used, err = s.searchUsed(ctx, namespace)
 		if err != nil {
 			return nil, microerror.Mask(err)
 		}
-		kv, err := s.storage.List(ctx, k)
-		if microstorage.IsNotFound(err) {
-			// In case there is no item yet, we create and persist the first ones
-			// using the algorithm invoked below.
-		} else if err != nil {
-			return nil, microerror.Mask(err)
-		}
-		used, err = valuesToInts(kv)
+	}
+
+	err = s.checkNamespaceQuota(namespace, len(used), num)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	// Blocked items are never handed out, so we treat them as used. They are
+	// not cached, since they are fetched separately, see Service.Burn and
+	// Policy.
+	{
+		blocked, err := s.searchBlocked(ctx, namespace)
 		if err != nil {
 			return nil, microerror.Mask(err)
 		}
+		used = append(used, blocked...)
 	}
 
 	// Fetch the latest item used.
 	var latest int
 	{
-		k, err := microstorage.NewK(fmt.Sprintf(LatestKeyFormat, namespace))
+		latest, err = s.searchStartLatest(ctx, namespace, ID, min, max, opts)
 		if err != nil {
 			return nil, microerror.Mask(err)
 		}
-		kv, err := s.storage.Search(ctx, k)
-		if microstorage.IsNotFound(err) {
-			// In case there is no latest item yet, we set it to the special case -1.
-			// This indicates the first item for the algorithm being invoked below.
-			latest = latestItemException
-		} else if err != nil {
+	}
+
+	// Hand back the previous items of the ID first, in case they are still
+	// free, see Config.Sticky.
+	var items []int
+	{
+		// The used items are sorted once here and kept sorted from now on, so
+		// that looking up the next items does not sort them over and over
+		// again.
+		sort.Ints(used)
+		items, err = s.searchSticky(ctx, namespace, ID, num, min, max, func(item int) bool {
+			i := sort.SearchInts(used, item)
+			return i < len(used) && used[i] == item
+		})
+		if err != nil {
 			return nil, microerror.Mask(err)
-		} else {
-			latest, err = strconv.Atoi(kv.Val())
-			if err != nil {
-				return nil, microerror.Mask(err)
-			}
+		}
+		for _, item := range items {
+			used = insertSorted(used, item)
 		}
 
+		freed, err := s.searchLeastRecentlyFreed(ctx, namespace, num-len(items), min, max, used)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+		items = append(items, freed...)
+		for _, item := range freed {
+			used = insertSorted(used, item)
+		}
 	}
 
-	// Find and persist the next items.
-	var items []int
+	// Find and persist the next items. Only items found this way move the
+	// latest item, since sticky items are not taken in order.
+	newLatest := latestItemException
+	if len(s.windows) == 0 && len(items) < num {
+		found, err := s.nextItems(used, min, max, latest, num-len(items))
+		if c, ok := AsCapacityReached(err); ok {
+			return nil, microerror.Mask(&CapacityReachedError{Free: len(items) + c.Free, Max: max, Min: min, Namespace: namespace, Num: num})
+		} else if err != nil {
+			return nil, microerror.Mask(err)
+		}
+		items = append(items, found...)
+		// The used items are only counted from now on, so they do not need
+		// to stay sorted.
+		used = append(used, found...)
+		if s.persistsLatest() {
+			newLatest = found[len(found)-1]
+		}
+	}
 	{
-		for i := 0; i < num; i++ {
-			item, err := nextItem(used, min, max, latest)
-			if err != nil {
+		for i := len(items); i < num; i++ {
+			item, err := s.nextWindowItem(min, max, latest, func(min, max, latest int) (int, error) {
+				return s.nextItem(used, min, max, latest)
+			})
+			if IsCapacityReached(err) {
+				// nextItem only fails once all free items of the range have been
+				// taken, so the items found so far are the ones which were free.
+				return nil, microerror.Mask(&CapacityReachedError{Free: len(items), Max: max, Min: min, Namespace: namespace, Num: num})
+			} else if err != nil {
 				return nil, microerror.Mask(err)
 			}
 			items = append(items, item)
-			used = append(used, item)
+			used = insertSorted(used, item)
+			if s.persistsLatest() {
+				newLatest = item
+			}
 		}
 
-		err = s.create(ctx, namespace, ID, items)
+		err = s.create(ctx, namespace, ID, items, newLatest, opts)
 		if err != nil {
+			// Some of the items might have been persisted. We cannot know which
+			// ones, so the cached items of the namespace cannot be trusted anymore.
+			s.cache.Invalidate(namespace)
 			return nil, microerror.Mask(err)
 		}
+
+		s.cache.Add(namespace, items)
 	}
 
+	s.warnAlmostFull(ctx, namespace, num, countInRange(used, min, max), min, max)
+	s.notify(ctx, EventTypeAllocated, namespace, ID, items)
+
 	return items, nil
 }
 
 func (s *Service) Delete(ctx context.Context, namespace, ID string) error {
+	err := s.deleteWithOptions(ctx, namespace, ID, callOptions{})
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// deleteWithOptions implements Service.Delete using the given options.
+func (s *Service) deleteWithOptions(ctx context.Context, namespace, ID string, opts callOptions) error {
+	ctx = withOperation(ctx, "Delete", namespace, ID)
+
+	err := s.checkReadOnly()
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = s.checkLeader()
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	s, err = s.withPolicy(ctx, namespace)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = s.ensureSchema(ctx, namespace)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
 	var items []int
 	{
-		k, err := microstorage.NewK(fmt.Sprintf(IDListKeyFormat, namespace, ID))
+		items, err = s.searchItems(ctx, s.key(IDListKeyFormat, namespace, ID))
 		if err != nil {
 			return microerror.Mask(err)
 		}
-		kv, err := s.storage.List(ctx, k)
-		if microstorage.IsNotFound(err) {
-			// In case there is no item yet, we create and persist the first ones
-			// using the algorithm invoked below.
-		} else if err != nil {
+
+		err = s.checkExpectedItems(namespace, ID, items, opts)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
+		err = s.checkRevision(ctx, namespace, ID)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	if s.bitmap {
+		err := s.deleteBitmap(ctx, namespace, ID, items)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	} else {
+		err := s.delete(ctx, namespace, ID, items)
+		if err != nil {
+			s.cache.Invalidate(namespace)
 			return microerror.Mask(err)
 		}
-		items, err = valuesToInts(kv)
+
+		s.cache.Remove(namespace, items)
+	}
+
+	if s.latestMode == LatestModePerID {
+		err := deleteBatch(ctx, s.storage, []string{s.key(IDLatestKeyFormat, namespace, ID)})
 		if err != nil {
 			return microerror.Mask(err)
 		}
 	}
 
-	err := s.delete(ctx, namespace, ID, items)
+	// Releases are recorded after the items have been freed, since they cannot
+	// be written in the same batch as the deleted keys.
+	err = s.recordAudit(ctx, AuditActionRelease, namespace, ID, items)
 	if err != nil {
 		return microerror.Mask(err)
 	}
 
+	err = s.recordSticky(ctx, namespace, ID, items)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = s.recordHistory(ctx, namespace, ID, items)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = s.recordFreed(ctx, namespace, items)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	s.notify(ctx, EventTypeReleased, namespace, ID, items)
+
 	return nil
 }
 
+// InvalidateCache drops the cached used and burned items, the cached policy
+// and the memoized reads of the given namespace, so that the next operation
+// fetches them from the storage again. This is useful in case the namespace
+// was modified by other processes. See also Config.BurnedCacheTTL,
+// Config.CacheTTL, Config.PolicyCacheTTL and Config.ReadCacheTTL.
+func (s *Service) InvalidateCache(namespace string) {
+	s.burned.Invalidate(namespace)
+	s.cache.Invalidate(namespace)
+	s.policies.Invalidate(namespace)
+	s.reads.Invalidate(namespace)
+}
+
+// Search returns the items of the given ID within the given namespace in
+// numerically ascending order, no matter the order in which the storage lists
+// them. In case the ID does not have any items, an error is returned which can
+// be asserted using IsItemsNotFound.
 func (s *Service) Search(ctx context.Context, namespace, ID string) ([]int, error) {
+	key := "search/" + ID
+
+	v, ok := s.reads.Get(namespace, key)
+	if ok && !isLinearizable(ctx) {
+		return append([]int{}, v.([]int)...), nil
+	}
+
 	var used []int
 	{
-		k, err := microstorage.NewK(fmt.Sprintf(ItemSearchKeyFormat, namespace, ID))
+		var err error
+		used, err = s.searchItems(ctx, s.key(ItemSearchKeyFormat, namespace, ID))
 		if err != nil {
 			return nil, microerror.Mask(err)
 		}
-		kv, err := s.storage.List(ctx, k)
-		if microstorage.IsNotFound(err) || len(kv) == 0 {
+		if len(used) == 0 {
 			return nil, microerror.Maskf(itemsNotFoundError, "no items in namespace '%s' for ID '%s'", namespace, ID)
-		} else if err != nil {
-			return nil, microerror.Mask(err)
-		}
-		used, err = valuesToInts(kv)
-		if err != nil {
-			return nil, microerror.Mask(err)
 		}
 	}
 
 	sort.Ints(used)
 
+	s.reads.Set(namespace, key, append([]int{}, used...))
+
 	return used, nil
 }
 
-// create is used to persist new items.
-func (s *Service) create(ctx context.Context, namespace, ID string, items []int) error {
+// create is used to persist new items. The latest item is only persisted in
+// case it is not latestItemException. All keys are written in a single batch
+// in case the storage supports it, see BatchStorage.
+func (s *Service) create(ctx context.Context, namespace, ID string, items []int, latest int, opts callOptions) error {
+	var kvs []KV
 	for _, item := range items {
 		i := strconv.Itoa(item)
+		k := s.encodeItem(item)
 
 		// We store the relationship between the namespace and its corresponding
 		// item to be able to list all of the items later.
-		kv1, err := microstorage.NewKV(fmt.Sprintf(ItemKeyFormat, namespace, i), i)
-		if err != nil {
-			return microerror.Mask(err)
-		}
+		kvs = append(kvs, KV{Key: s.key(ItemKeyFormat, namespace, k), Value: i})
 
 		// We store the relationship between the ID and its corresponding item to be
 		// able to delete it later based on the ID.
-		kv2, err := microstorage.NewKV(fmt.Sprintf(IDKeyFormat, namespace, ID, i), i)
-		if err != nil {
-			return microerror.Mask(err)
-		}
-
-		err = s.storage.Put(ctx, kv1)
-		if err != nil {
-			return microerror.Mask(err)
-		}
-		err = s.storage.Put(ctx, kv2)
-		if err != nil {
-			return microerror.Mask(err)
-		}
-	}
+		kvs = append(kvs, KV{Key: s.key(IDKeyFormat, namespace, ID, k), Value: i})
+	}
 
 	// We store the latest item to have a pointer from which we can derive the
 	// next item to use.
-	lastItem := strconv.Itoa(items[len(items)-1])
-	kv, err := microstorage.NewKV(fmt.Sprintf(LatestKeyFormat, namespace), lastItem)
+	if latest != latestItemException {
+		kvs = append(kvs, KV{Key: s.latestKey(namespace, opts), Value: s.encodeLatest(latest)})
+	}
+
+	// We record the allocation within the same batch, so that allocations are
+	// never persisted without their audit entry.
+	kv, ok, err := s.auditKV(ctx, AuditActionAllocate, namespace, ID, items)
 	if err != nil {
 		return microerror.Mask(err)
 	}
-	err = s.storage.Put(ctx, kv)
+	if ok {
+		kvs = append(kvs, kv)
+	}
+
+	err = createBatch(ctx, s.storage, kvs)
 	if err != nil {
 		return microerror.Mask(err)
 	}
@@ -265,70 +1182,447 @@ func (s *Service) create(ctx context.Context, namespace, ID string, items []int)
 }
 
 func (s *Service) delete(ctx context.Context, namespace, ID string, items []int) error {
+	var keys []string
 	for _, item := range items {
-		i := strconv.Itoa(item)
+		k := s.encodeItem(item)
 
-		k, err := microstorage.NewK(fmt.Sprintf(ItemKeyFormat, namespace, i))
+		keys = append(keys, s.key(ItemKeyFormat, namespace, k))
+		keys = append(keys, s.key(IDKeyFormat, namespace, ID, k))
+	}
+	keys = append(keys, s.key(IDListKeyFormat, namespace, ID))
+
+	err := deleteBatch(ctx, s.storage, keys)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	empty, err := isEmpty(ctx, s.storage, s.key(ItemListKeyFormat, namespace))
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	if empty {
+		err := s.storage.Delete(ctx, s.key(ItemListKeyFormat, namespace))
 		if err != nil {
 			return microerror.Mask(err)
 		}
-		err = s.storage.Delete(ctx, k)
-		if microstorage.IsNotFound(err) {
-			// In case there is no item anymore, we just go ahead to delete the rest
-			// of the data.
-		} else if err != nil {
+	}
+	if empty && s.latestMode == LatestModeResetOnEmpty {
+		err := deleteBatch(ctx, s.storage, s.latestKeys(namespace))
+		if err != nil {
 			return microerror.Mask(err)
 		}
-		k, err = microstorage.NewK(fmt.Sprintf(IDKeyFormat, namespace, ID, i))
+	}
+
+	return nil
+}
+
+// createBitmap finds and persists the next items in case the items of the
+// namespace are persisted as bitmap. The bitmap is written once, no matter how
+// many items are allocated.
+func (s *Service) createBitmap(ctx context.Context, namespace, ID string, num, min, max int, opts callOptions) ([]int, error) {
+	used, err := s.searchBitmap(ctx, namespace)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	err = s.checkNamespaceQuota(namespace, used.Len(), num)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	// Blocked items are never handed out, but they are not persisted as used
+	// items either, so we look for free items in a copy of the bitmap.
+	blocked := append(bitmap{}, used...)
+	{
+		l, err := s.searchBlocked(ctx, namespace)
 		if err != nil {
-			return microerror.Mask(err)
+			return nil, microerror.Mask(err)
+		}
+		for _, item := range l {
+			blocked.Set(item)
 		}
-		err = s.storage.Delete(ctx, k)
-		if microstorage.IsNotFound(err) {
-			// In case there is no item anymore, we just go ahead to delete the rest
-			// of the data.
+	}
+
+	latest, err := s.searchStartLatest(ctx, namespace, ID, min, max, opts)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	items, err := s.searchSticky(ctx, namespace, ID, num, min, max, blocked.IsSet)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+	for _, item := range items {
+		blocked.Set(item)
+	}
+
+	freed, err := s.searchLeastRecentlyFreed(ctx, namespace, num-len(items), min, max, blocked.Items())
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+	for _, item := range freed {
+		blocked.Set(item)
+	}
+	items = append(items, freed...)
+
+	newLatest := latestItemException
+	for i := len(items); i < num; i++ {
+		item, err := s.nextWindowItem(min, max, latest, func(min, max, latest int) (int, error) {
+			return s.nextBitmapItem(blocked, min, max, latest)
+		})
+		if IsCapacityReached(err) {
+			return nil, microerror.Mask(&CapacityReachedError{Free: len(items), Max: max, Min: min, Namespace: namespace, Num: num})
 		} else if err != nil {
-			return microerror.Mask(err)
+			return nil, microerror.Mask(err)
 		}
+		items = append(items, item)
+		blocked.Set(item)
+		if s.persistsLatest() {
+			newLatest = item
+		}
+	}
+	for _, item := range items {
+		used.Set(item)
 	}
 
-	k, err := microstorage.NewK(fmt.Sprintf(IDListKeyFormat, namespace, ID))
+	var kvs []KV
+	{
+		kvs = append(kvs, KV{Key: s.key(BitmapKeyFormat, namespace), Value: s.encodeBitmap(used)})
+
+		for _, item := range items {
+			kvs = append(kvs, KV{Key: s.key(IDKeyFormat, namespace, ID, s.encodeItem(item)), Value: strconv.Itoa(item)})
+		}
+
+		if newLatest != latestItemException {
+			kvs = append(kvs, KV{Key: s.latestKey(namespace, opts), Value: s.encodeLatest(newLatest)})
+		}
+
+		kv, ok, err := s.auditKV(ctx, AuditActionAllocate, namespace, ID, items)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+		if ok {
+			kvs = append(kvs, kv)
+		}
+	}
+
+	err = createBatch(ctx, s.storage, kvs)
 	if err != nil {
-		return microerror.Mask(err)
+		return nil, microerror.Mask(err)
 	}
-	err = s.storage.Delete(ctx, k)
-	if microstorage.IsNotFound(err) {
-		// In case there is no item anymore, we just go ahead to delete the rest of
-		// the data.
-	} else if err != nil {
+
+	s.warnAlmostFull(ctx, namespace, num, countInRange(blocked.Items(), min, max), min, max)
+
+	return items, nil
+}
+
+// deleteBitmap frees the given items of the ID in case the items of the
+// namespace are persisted as bitmap.
+func (s *Service) deleteBitmap(ctx context.Context, namespace, ID string, items []int) error {
+	used, err := s.searchBitmap(ctx, namespace)
+	if err != nil {
 		return microerror.Mask(err)
 	}
 
-	k, err = microstorage.NewK(fmt.Sprintf(ItemListKeyFormat, namespace))
+	for _, item := range items {
+		used.Unset(item)
+	}
+
+	var keys []string
+	if used.Len() == 0 {
+		keys = append(keys, s.key(BitmapKeyFormat, namespace))
+		if s.latestMode == LatestModeResetOnEmpty {
+			keys = append(keys, s.latestKeys(namespace)...)
+		}
+	} else {
+		err = s.storage.Create(ctx, s.key(BitmapKeyFormat, namespace), s.encodeBitmap(used))
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	for _, item := range items {
+		keys = append(keys, s.key(IDKeyFormat, namespace, ID, s.encodeItem(item)))
+	}
+	keys = append(keys, s.key(IDListKeyFormat, namespace, ID))
+
+	err = deleteBatch(ctx, s.storage, keys)
 	if err != nil {
 		return microerror.Mask(err)
 	}
-	list, err := s.storage.List(ctx, k)
-	if microstorage.IsNotFound(err) {
-		// In case there is no item anymore, we just go ahead to delete the complete
-		// item list key and latest item key.
+
+	return nil
+}
+
+// encodeBitmap encodes the given bitmap so that it can be persisted, see
+// Config.Checksums and Config.CompressBitmaps.
+func (s *Service) encodeBitmap(m bitmap) string {
+	if s.compressBitmaps {
+		return s.withChecksum(m.Compress())
+	}
+
+	return s.withChecksum(m.String())
+}
+
+// searchBitmap fetches the bitmap of the used items of the given namespace.
+// In case there is no bitmap yet, an empty bitmap is returned.
+func (s *Service) searchBitmap(ctx context.Context, namespace string) (bitmap, error) {
+	key := s.key(BitmapKeyFormat, namespace)
+
+	v, err := s.storage.Search(ctx, key)
+	if IsNotFound(err) {
+		return bitmap{}, nil
 	} else if err != nil {
-		return microerror.Mask(err)
+		return nil, microerror.Mask(err)
+	}
+
+	v, err = verifyChecksum(key, v, s.checksums)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	used, err := newBitmap(v)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return used, nil
+}
+
+// searchUsed fetches the items being used in the given namespace. In case
+// caching is enabled and the items of the namespace are cached, the storage is
+// not asked, unless the context requests ConsistencyLinearizable.
+func (s *Service) searchUsed(ctx context.Context, namespace string) ([]int, error) {
+	if !isLinearizable(ctx) {
+		used, ok := s.cache.Get(namespace)
+		if ok {
+			return used, nil
+		}
+	}
+
+	used, err := s.searchItems(ctx, s.key(ItemListKeyFormat, namespace))
+	if err != nil {
+		return nil, microerror.Mask(err)
 	}
-	if len(list) == 0 {
-		k, err := microstorage.NewK(fmt.Sprintf(ItemListKeyFormat, namespace))
+
+	s.cache.Set(namespace, used)
+
+	return used, nil
+}
+
+// searchItems fetches the items persisted below the given key. The keys are
+// iterated one by one in case the storage supports it, so that only the items
+// themselves are held in memory, see WalkStorage.
+func (s *Service) searchItems(ctx context.Context, key string) ([]int, error) {
+	var items []int
+
+	err := walk(ctx, s.storage, key, func(kv KV) error {
+		i, err := strconv.Atoi(kv.Value)
 		if err != nil {
 			return microerror.Mask(err)
 		}
-		err = s.storage.Delete(ctx, k)
-		if microstorage.IsNotFound(err) {
-			// Fall through in case what we want to remove is already gone.
-		} else if err != nil {
-			return microerror.Mask(err)
+		items = append(items, i)
+
+		return nil
+	})
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return items, nil
+}
+
+// searchLatest fetches the latest item used in the given namespace. In case
+// there is no latest item yet, the special case -1 is returned. This indicates
+// the first item for the algorithm being invoked by nextItem.
+func (s *Service) searchLatest(ctx context.Context, namespace string, opts callOptions) (int, error) {
+	key := s.latestKey(namespace, opts)
+
+	v, err := s.storage.Search(ctx, key)
+	if IsNotFound(err) {
+		return latestItemException, nil
+	} else if err != nil {
+		return 0, microerror.Mask(err)
+	}
+
+	v, err = verifyChecksum(key, v, s.checksums)
+	if err != nil {
+		return 0, microerror.Mask(err)
+	}
+
+	latest, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, microerror.Maskf(corruptedError, "value of key '%s' is not an item", key)
+	}
+
+	return latest, nil
+}
+
+// persistsLatest returns whether allocations persist the latest item, which
+// is not the case for latest modes deriving the item allocations start at
+// from something else, see Config.LatestMode.
+func (s *Service) persistsLatest() bool {
+	return s.latestMode != LatestModeHash && s.latestMode != LatestModeRandom
+}
+
+// searchStartLatest fetches the latest item the next allocation of the given
+// ID in the given namespace continues from, see Config.LatestMode.
+func (s *Service) searchStartLatest(ctx context.Context, namespace, ID string, min, max int, opts callOptions) (int, error) {
+	if s.latestMode == LatestModeLowestFree || s.latestMode == LatestModeLeastRecentlyFreed {
+		return latestItemException, nil
+	}
+	if s.latestMode == LatestModeHash || s.latestMode == LatestModeRandom {
+		// Invalid boundaries are reported by nextItem.
+		if max < min || min < 0 {
+			return latestItemException, nil
 		}
+
+		var offset int
+		if s.latestMode == LatestModeHash {
+			h := fnv.New64a()
+			h.Write([]byte(ID))
+			offset = int(h.Sum64() % uint64(max-min+1))
+		} else {
+			offset = rand.Intn(max - min + 1)
+		}
+
+		// Allocations continue after the latest item, so the item right before
+		// the first item to look at is returned. min-1 stands for starting at
+		// min.
+		latest := min - 1 + offset
+		if latest < min {
+			return latestItemException, nil
+		}
+
+		return latest, nil
 	}
 
-	return nil
+	latest, err := s.searchLatest(ctx, namespace, opts)
+	if err != nil {
+		return 0, microerror.Mask(err)
+	}
+
+	return latest, nil
+}
+
+// nextBitmapItem works like nextItem, but looks up the items being used in the
+// given bitmap. Instead of iterating over every single item, the bitmap is
+// scanned word by word.
+func nextBitmapItem(used bitmap, min, max, latest int) (int, error) {
+	err := validateBoundaries(min, max, latest)
+	if err != nil {
+		return 0, microerror.Mask(err)
+	}
+
+	if latest != latestItemException {
+		item := used.NextUnset(latest+1, max)
+		if item != -1 {
+			return item, nil
+		}
+	}
+
+	item := used.NextUnset(min, max)
+	if item != -1 {
+		return item, nil
+	}
+
+	return 0, microerror.Maskf(capacityReachedError, "cannot find next item")
+}
+
+// prevBitmapItem works like nextBitmapItem, but scans the bitmap from latest
+// downwards, see Config.Descending.
+func prevBitmapItem(used bitmap, min, max, latest int) (int, error) {
+	err := validateBoundaries(min, max, latest)
+	if err != nil {
+		return 0, microerror.Mask(err)
+	}
+
+	if latest != latestItemException {
+		item := used.PrevUnset(min, latest-1)
+		if item != -1 {
+			return item, nil
+		}
+	}
+
+	item := used.PrevUnset(min, max)
+	if item != -1 {
+		return item, nil
+	}
+
+	return 0, microerror.Maskf(capacityReachedError, "cannot find next item")
+}
+
+// nextItems works like the function nextSortedItems, but hands out items from
+// max downwards in case Config.Descending is enabled, see Service.nextItem.
+// used must be sorted in ascending order.
+func (s *Service) nextItems(used []int, min, max, latest, num int) ([]int, error) {
+	if !s.descending {
+		return nextSortedItems(used, min, max, latest, num)
+	}
+
+	err := validateBoundaries(min, max, latest)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	mirrored := make([]int, 0, len(used))
+	for i := len(used) - 1; i >= 0; i-- {
+		mirrored = append(mirrored, min+max-used[i])
+	}
+	if latest != latestItemException {
+		latest = min + max - latest
+	}
+
+	items, err := nextSortedItems(mirrored, min, max, latest, num)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+	for i := range items {
+		items[i] = min + max - items[i]
+	}
+
+	return items, nil
+}
+
+// nextBitmapItem works like the function nextBitmapItem, but hands out items
+// from max downwards in case Config.Descending is enabled.
+func (s *Service) nextBitmapItem(used bitmap, min, max, latest int) (int, error) {
+	if s.descending {
+		return prevBitmapItem(used, min, max, latest)
+	}
+
+	return nextBitmapItem(used, min, max, latest)
+}
+
+// nextItem works like the function nextSortedItem, but hands out items from
+// max downwards in case Config.Descending is enabled. The range is mirrored
+// for that matter, so that the ascending algorithm can be reused. used must be
+// sorted in ascending order.
+func (s *Service) nextItem(used []int, min, max, latest int) (int, error) {
+	if !s.descending {
+		return nextSortedItem(used, min, max, latest)
+	}
+
+	err := validateBoundaries(min, max, latest)
+	if err != nil {
+		return 0, microerror.Mask(err)
+	}
+
+	// Mirroring the used items in reverse order keeps them sorted.
+	mirrored := make([]int, 0, len(used))
+	for i := len(used) - 1; i >= 0; i-- {
+		mirrored = append(mirrored, min+max-used[i])
+	}
+	if latest != latestItemException {
+		latest = min + max - latest
+	}
+
+	item, err := nextSortedItem(mirrored, min, max, latest)
+	if err != nil {
+		return 0, microerror.Mask(err)
+	}
+
+	return min + max - item, nil
 }
 
 // nextItem implements a stateless algorithm to sort out the next item to use.
@@ -339,50 +1633,38 @@ func (s *Service) delete(ctx context.Context, namespace, ID string, items []int)
 // latest item being used. It is used make up the next item in the series by
 // incrementing it by 1. latest is special because it can be -1, which means
 // there is no latest known item already, which implies the very first item
-// being created by the range pool.
+// being created by the range pool. used is sorted in place.
 func nextItem(used []int, min, max, latest int) (int, error) {
-	if min <= -1 {
-		return 0, microerror.Maskf(executionFailedError, "min must be negative")
-	}
-	if max <= -1 {
-		return 0, microerror.Maskf(executionFailedError, "max must be negative")
-	}
-	if min >= max {
-		return 0, microerror.Maskf(executionFailedError, "min must be greater than max")
-	}
-	if latest != latestItemException && latest < min {
-		return 0, microerror.Maskf(executionFailedError, "latest must not be lower than min")
-	}
-	if latest != latestItemException && latest > max {
-		return 0, microerror.Maskf(executionFailedError, "latest must not be greater than max")
-	}
-
 	sort.Ints(used)
 
-	iterator := func(min, max int) int {
-		for i := min; i <= max; i++ {
-			// Ignore the items being used already.
-			if containsInt(used, i) {
-				continue
-			}
+	item, err := nextSortedItem(used, min, max, latest)
+	if err != nil {
+		return 0, microerror.Mask(err)
+	}
 
-			return i
-		}
+	return item, nil
+}
 
-		// We couldn't find any item in the given range.
-		return latestItemException
+// nextSortedItem works like nextItem, but expects used to be sorted in
+// ascending order already. Callers looking up many items in a row sort used
+// once and keep it sorted using insertSorted, instead of sorting it for every
+// item.
+func nextSortedItem(used []int, min, max, latest int) (int, error) {
+	err := validateBoundaries(min, max, latest)
+	if err != nil {
+		return 0, microerror.Mask(err)
 	}
 
 	var nextItem int
 
 	if latest != latestItemException {
-		nextItem = iterator(latest+1, max)
+		nextItem = firstGap(used, latest+1, max)
 		if nextItem != latestItemException {
 			return nextItem, nil
 		}
 	}
 
-	nextItem = iterator(min, max)
+	nextItem = firstGap(used, min, max)
 	if nextItem != latestItemException {
 		return nextItem, nil
 	}
@@ -390,29 +1672,127 @@ func nextItem(used []int, min, max, latest int) (int, error) {
 	return 0, microerror.Maskf(capacityReachedError, "cannot find next item")
 }
 
-func containsInt(list []int, item int) bool {
-	for _, l := range list {
-		if l == item {
-			return true
+// firstGap returns the first item in between from and to, both inclusive,
+// which is not part of used. used must be sorted. Instead of checking every
+// single item, the position of from is looked up using binary search and only
+// the consecutive items following it are visited. In case there is no gap -1
+// is returned.
+func firstGap(used []int, from, to int) int {
+	candidate := from
+
+	for i := sort.SearchInts(used, from); i < len(used) && used[i] <= to; i++ {
+		if used[i] > candidate {
+			break
+		}
+		if used[i] == candidate {
+			candidate++
 		}
 	}
 
-	return false
+	if candidate > to {
+		return latestItemException
+	}
+
+	return candidate
 }
 
-// valuesToInts takes a list of key-values and returns the values list
-// converted to ints.
-func valuesToInts(kvs []microstorage.KV) ([]int, error) {
-	var converted []int
+// NextItems returns the next num items to use in between min and max, both
+// inclusive, like the Service does when allocating num items at once. It is
+// meant for embedders persisting items on their own. used defines the items
+// already in use and may be given in any order. latest is the latest item
+// being used or -1, see Service.Create. The items are found in a single pass
+// over the gaps in between the used items. In case the range does not hold
+// num free items, an error is returned which can be obtained using
+// AsCapacityReached, telling how many items are free.
+func NextItems(used []int, min, max, latest, num int) ([]int, error) {
+	sorted := append([]int{}, used...)
+	sort.Ints(sorted)
 
-	for _, kv := range kvs {
-		s, err := strconv.Atoi(kv.Val())
-		if err != nil {
-			return nil, microerror.Mask(err)
+	items, err := nextSortedItems(sorted, min, max, latest, num)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return items, nil
+}
+
+// nextSortedItems returns the next num items like calling nextSortedItem num
+// times with the same latest item would, adding every item found to used. The
+// items following latest are taken first, before the ones from min on. used
+// must be sorted in ascending order.
+func nextSortedItems(used []int, min, max, latest, num int) ([]int, error) {
+	err := validateBoundaries(min, max, latest)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+	if num <= 0 {
+		return nil, microerror.Maskf(invalidArgumentError, "num must be greater than zero")
+	}
+
+	var items []int
+	collect := func(from, to int) {
+		candidate := from
+		i := sort.SearchInts(used, from)
+		for len(items) < num && candidate <= to {
+			// The gap in front of the next used item is taken as a whole.
+			next := to + 1
+			if i < len(used) && used[i] <= to {
+				next = used[i]
+				i++
+			}
+			for ; candidate < next && len(items) < num; candidate++ {
+				items = append(items, candidate)
+			}
+			if next+1 > candidate {
+				candidate = next + 1
+			}
 		}
+	}
+
+	if latest != latestItemException {
+		collect(latest+1, max)
+		collect(min, latest)
+	} else {
+		collect(min, max)
+	}
+
+	if len(items) < num {
+		return nil, microerror.Mask(&CapacityReachedError{Free: len(items), Max: max, Min: min, Num: num})
+	}
+
+	return items, nil
+}
+
+// insertSorted inserts the given item into the given items, which must be
+// sorted in ascending order, so that they stay sorted.
+func insertSorted(items []int, item int) []int {
+	i := sort.SearchInts(items, item)
+
+	items = append(items, 0)
+	copy(items[i+1:], items[i:])
+	items[i] = item
+
+	return items
+}
 
-		converted = append(converted, s)
+// validateBoundaries checks the range pool boundaries and the latest item as
+// described by nextItem.
+func validateBoundaries(min, max, latest int) error {
+	var reason string
+	switch {
+	case min <= -1:
+		reason = "min must not be negative"
+	case max <= -1:
+		reason = "max must not be negative"
+	case min >= max:
+		reason = "min must be lower than max"
+	case latest != latestItemException && latest < min:
+		reason = "latest must not be lower than min"
+	case latest != latestItemException && latest > max:
+		reason = "latest must not be greater than max"
+	default:
+		return nil
 	}
 
-	return converted, nil
+	return microerror.Mask(&InvalidRangeError{Latest: latest, Max: max, Min: min, Reason: reason})
 }
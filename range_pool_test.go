@@ -2,10 +2,15 @@ package rangepool
 
 import (
 	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/giantswarm/microerror"
 	"github.com/giantswarm/micrologger/microloggertest"
-	"github.com/giantswarm/microstorage"
 	"github.com/giantswarm/microstorage/memory"
 )
 
@@ -13,13 +18,43 @@ const (
 	namespace = "test-namespace"
 )
 
+func Test_DefaultConfig(t *testing.T) {
+	newStorage, err := newMemoryStorage()
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+
+	// The default configuration must only require a storage.
+	{
+		config := DefaultConfig()
+		config.Storage = newStorage
+		s, err := New(config)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		_, err = s.Create(context.TODO(), namespace, "test-id", 1, 2, 3)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+	}
+
+	// The storage must be validated.
+	{
+		_, err := New(DefaultConfig())
+		if !IsInvalidConfig(err) {
+			t.Fatal("expected", true, "got", false)
+		}
+	}
+}
+
 func Test_Service_Create_NumOne(t *testing.T) {
 	// Create a new storage and service.
 	var err error
 	var newService *Service
-	var newStorage microstorage.Storage
+	var newStorage Storage
 	{
-		newStorage, err = memory.New(memory.DefaultConfig())
+		newStorage, err = newMemoryStorage()
 		if err != nil {
 			t.Fatal("expected", nil, "got", err)
 		}
@@ -110,9 +145,9 @@ func Test_Service_Create_Num3_CapacityReached(t *testing.T) {
 	// Create a new storage and service.
 	var err error
 	var newService *Service
-	var newStorage microstorage.Storage
+	var newStorage Storage
 	{
-		newStorage, err = memory.New(memory.DefaultConfig())
+		newStorage, err = newMemoryStorage()
 		if err != nil {
 			t.Fatal("expected", nil, "got", err)
 		}
@@ -166,6 +201,48 @@ func Test_Service_Create_Num3_CapacityReached(t *testing.T) {
 		if !IsCapacityReached(err) {
 			t.Fatal("expected", true, "got", false)
 		}
+
+		// The error must describe the failed allocation.
+		e, ok := AsCapacityReached(err)
+		if !ok {
+			t.Fatal("expected", true, "got", false)
+		}
+		expected := CapacityReachedError{Free: 0, Max: max, Min: min, Namespace: namespace, Num: num}
+		if *e != expected {
+			t.Fatal("expected", expected, "got", *e)
+		}
+	}
+}
+
+func Test_Service_Create_InvalidRange(t *testing.T) {
+	newStorage, err := newMemoryStorage()
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+
+	config := DefaultConfig()
+	config.Logger = microloggertest.New()
+	config.Storage = newStorage
+	newService, err := New(config)
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+
+	_, err = newService.Create(context.TODO(), namespace, "test-id", 1, 7, 2)
+	if !IsInvalidRange(err) {
+		t.Fatal("expected", true, "got", false)
+	}
+	if !IsExecutionFailed(err) {
+		t.Fatal("expected", true, "got", false)
+	}
+
+	// The error must carry the offending boundaries.
+	e, ok := AsInvalidRange(err)
+	if !ok {
+		t.Fatal("expected", true, "got", false)
+	}
+	if e.Min != 7 || e.Max != 2 || e.Latest != -1 {
+		t.Fatal("expected", []int{7, 2, -1}, "got", []int{e.Min, e.Max, e.Latest})
 	}
 }
 
@@ -173,7 +250,7 @@ func Test_Service_Create_NumThree_Rotate(t *testing.T) {
 	// Create a new storage and service.
 	var newService *Service
 	{
-		newStorage, err := memory.New(memory.DefaultConfig())
+		newStorage, err := newMemoryStorage()
 		if err != nil {
 			t.Fatal("expected", nil, "got", err)
 		}
@@ -289,9 +366,9 @@ func Test_Service_Create_NumTwo(t *testing.T) {
 	// Create a new storage and service.
 	var err error
 	var newService *Service
-	var newStorage microstorage.Storage
+	var newStorage Storage
 	{
-		newStorage, err = memory.New(memory.DefaultConfig())
+		newStorage, err = newMemoryStorage()
 		if err != nil {
 			t.Fatal("expected", nil, "got", err)
 		}
@@ -394,9 +471,9 @@ func Test_Service_Search(t *testing.T) {
 	// Create a new storage and service.
 	var err error
 	var newService *Service
-	var newStorage microstorage.Storage
+	var newStorage Storage
 	{
-		newStorage, err = memory.New(memory.DefaultConfig())
+		newStorage, err = newMemoryStorage()
 		if err != nil {
 			t.Fatal("expected", nil, "got", err)
 		}
@@ -492,13 +569,51 @@ func Test_Service_Search(t *testing.T) {
 	}
 }
 
+func Test_Service_Search_Order(t *testing.T) {
+	// Create a new storage listing keys in lexicographic order, like etcd does,
+	// and a service persisting multi-digit items.
+	var err error
+	var newService *Service
+	{
+		s, err := newMemoryStorage()
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		config := DefaultConfig()
+		config.Logger = microloggertest.New()
+		config.Storage = &testLexicographicStorage{Storage: s}
+		newService, err = New(config)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+	}
+
+	ctx := context.TODO()
+
+	_, err = newService.Create(ctx, namespace, "test-id", 5, 8, 20)
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+
+	items, err := newService.Search(ctx, namespace, "test-id")
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+
+	expected := []int{8, 9, 10, 11, 12}
+	if !reflect.DeepEqual(items, expected) {
+		t.Fatal("expected", expected, "got", items)
+	}
+}
+
 func Test_Service_Create_NumTwo_DifferentIDs(t *testing.T) {
 	// Create a new storage and service.
 	var err error
 	var newService *Service
-	var newStorage microstorage.Storage
+	var newStorage Storage
 	{
-		newStorage, err = memory.New(memory.DefaultConfig())
+		newStorage, err = newMemoryStorage()
 		if err != nil {
 			t.Fatal("expected", nil, "got", err)
 		}
@@ -604,6 +719,479 @@ func Test_Service_Create_NumTwo_DifferentIDs(t *testing.T) {
 	testWithNameSpace("test-namespace-3")
 }
 
+func Test_Service_Create_Bitmap(t *testing.T) {
+	// Create a new storage and service persisting items as bitmap.
+	var err error
+	var newService *Service
+	var newStorage Storage
+	{
+		newStorage, err = newMemoryStorage()
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		config := DefaultConfig()
+		config.Logger = microloggertest.New()
+		config.Storage = newStorage
+		config.Bitmap = true
+		newService, err = New(config)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+	}
+
+	// Prepare the test variables.
+	ctx := context.TODO()
+	num := 3
+	min := 2
+	max := 4
+
+	// Saturate the configured range with the first ID.
+	{
+		items, err := newService.Create(ctx, namespace, "test-id-1", num, min, max)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		if len(items) != 3 || items[0] != 2 || items[1] != 3 || items[2] != 4 {
+			t.Fatal("expected", []int{2, 3, 4}, "got", items)
+		}
+	}
+
+	// The items must be persisted as bitmap instead of item keys.
+	{
+		kvs, err := newStorage.List(ctx, fmt.Sprintf(ItemListKeyFormat, namespace))
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		if len(kvs) != 0 {
+			t.Fatal("expected", 0, "got", len(kvs))
+		}
+
+		_, err = newStorage.Search(ctx, fmt.Sprintf(BitmapKeyFormat, namespace))
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+	}
+
+	// Allocating more items must fail since the capacity is reached.
+	{
+		_, err := newService.Create(ctx, namespace, "test-id-2", 1, min, max)
+		if !IsCapacityReached(err) {
+			t.Fatal("expected", true, "got", false)
+		}
+	}
+
+	// Searching must return the items of the first ID.
+	{
+		items, err := newService.Search(ctx, namespace, "test-id-1")
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		if len(items) != 3 {
+			t.Fatal("expected", 3, "got", len(items))
+		}
+	}
+
+	// Delete the items of the first ID and allocate again. The latest item was
+	// the max boundary, so we expect the allocation to rotate to the min
+	// boundary.
+	{
+		err := newService.Delete(ctx, namespace, "test-id-1")
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		_, err = newService.Search(ctx, namespace, "test-id-1")
+		if !IsItemsNotFound(err) {
+			t.Fatal("expected", true, "got", false)
+		}
+
+		items, err := newService.Create(ctx, namespace, "test-id-2", 1, min, max)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		if len(items) != 1 || items[0] != 2 {
+			t.Fatal("expected", []int{2}, "got", items)
+		}
+	}
+}
+
+func Test_Service_Create_LatestMode(t *testing.T) {
+	testCases := []struct {
+		LatestMode   string
+		KeepOtherID  bool
+		ExpectedItem int
+	}{
+		// Case 1 ensures allocations continue after the latest item by default.
+		{
+			LatestMode:   LatestModeContinue,
+			KeepOtherID:  false,
+			ExpectedItem: 5,
+		},
+		// Case 2 ensures the lowest free item is used in case the latest item
+		// is disabled.
+		{
+			LatestMode:   LatestModeLowestFree,
+			KeepOtherID:  true,
+			ExpectedItem: 2,
+		},
+		// Case 3 ensures allocations start at the beginning of the range once
+		// the namespace became empty.
+		{
+			LatestMode:   LatestModeResetOnEmpty,
+			KeepOtherID:  false,
+			ExpectedItem: 2,
+		},
+		// Case 4 ensures allocations continue after the latest item as long as
+		// the namespace is not empty.
+		{
+			LatestMode:   LatestModeResetOnEmpty,
+			KeepOtherID:  true,
+			ExpectedItem: 5,
+		},
+	}
+
+	for i, tc := range testCases {
+		for _, b := range []bool{false, true} {
+			var err error
+			var newService *Service
+			{
+				newStorage, err := newMemoryStorage()
+				if err != nil {
+					t.Fatal("case", i+1, "expected", nil, "got", err)
+				}
+
+				config := DefaultConfig()
+				config.Logger = microloggertest.New()
+				config.Storage = newStorage
+				config.Bitmap = b
+				config.LatestMode = tc.LatestMode
+				newService, err = New(config)
+				if err != nil {
+					t.Fatal("case", i+1, "expected", nil, "got", err)
+				}
+			}
+
+			ctx := context.TODO()
+
+			_, err = newService.Create(ctx, namespace, "test-id-1", 2, 2, 10)
+			if err != nil {
+				t.Fatal("case", i+1, "expected", nil, "got", err)
+			}
+			_, err = newService.Create(ctx, namespace, "test-id-2", 1, 2, 10)
+			if err != nil {
+				t.Fatal("case", i+1, "expected", nil, "got", err)
+			}
+
+			err = newService.Delete(ctx, namespace, "test-id-1")
+			if err != nil {
+				t.Fatal("case", i+1, "expected", nil, "got", err)
+			}
+			if !tc.KeepOtherID {
+				err = newService.Delete(ctx, namespace, "test-id-2")
+				if err != nil {
+					t.Fatal("case", i+1, "expected", nil, "got", err)
+				}
+			}
+
+			items, err := newService.Create(ctx, namespace, "test-id-3", 1, 2, 10)
+			if err != nil {
+				t.Fatal("case", i+1, "expected", nil, "got", err)
+			}
+			if items[0] != tc.ExpectedItem {
+				t.Fatal("case", i+1, "expected", tc.ExpectedItem, "got", items[0])
+			}
+		}
+	}
+}
+
+func Test_Service_Create_LatestModeRandom(t *testing.T) {
+	for _, b := range []bool{false, true} {
+		var err error
+		var newStorage Storage
+		var newService *Service
+		{
+			newStorage, err = newMemoryStorage()
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+
+			config := DefaultConfig()
+			config.Logger = microloggertest.New()
+			config.Storage = newStorage
+			config.Bitmap = b
+			config.LatestMode = LatestModeRandom
+			newService, err = New(config)
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+		}
+
+		ctx := context.TODO()
+
+		// Allocations must start at different items of the range, while the
+		// items of a single allocation stay consecutive.
+		starts := map[int]struct{}{}
+		for i := 0; i < 20; i++ {
+			ID := fmt.Sprintf("test-id-%d", i)
+
+			items, err := newService.Create(ctx, namespace, ID, 2, 0, 999)
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+			if items[0] < 0 || items[0] > 999 || items[1] < 0 || items[1] > 999 {
+				t.Fatal("expected", "items in between 0 and 999", "got", items)
+			}
+			if items[1] != items[0]+1 && items[1] != 0 && items[0] != 999 {
+				t.Fatal("expected", items[0]+1, "got", items[1])
+			}
+			starts[items[0]] = struct{}{}
+
+			err = newService.Delete(ctx, namespace, ID)
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+		}
+		if len(starts) < 2 {
+			t.Fatal("expected", "different items", "got", starts)
+		}
+
+		// The latest item must not be persisted.
+		_, err = newStorage.Search(ctx, fmt.Sprintf(LatestKeyFormat, namespace))
+		if !IsNotFound(err) {
+			t.Fatal("expected", true, "got", false)
+		}
+
+		// All items of the range must still be found.
+		{
+			items, err := newService.Create(ctx, namespace, "test-id-all", 1000, 0, 999)
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+			if len(items) != 1000 {
+				t.Fatal("expected", 1000, "got", len(items))
+			}
+		}
+	}
+}
+
+func Test_Service_Create_LatestModeHash(t *testing.T) {
+	for _, b := range []bool{false, true} {
+		newService := func() *Service {
+			newStorage, err := newMemoryStorage()
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+
+			config := DefaultConfig()
+			config.Logger = microloggertest.New()
+			config.Storage = newStorage
+			config.Bitmap = b
+			config.LatestMode = LatestModeHash
+			s, err := New(config)
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+
+			return s
+		}
+
+		ctx := context.TODO()
+
+		// The same ID must get the same items in independent environments,
+		// no matter what has been allocated before.
+		var expected []int
+		{
+			items, err := newService().Create(ctx, namespace, "test-cluster", 2, 0, 999)
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+			expected = items
+
+			s := newService()
+			_, err = s.Create(ctx, namespace, "other-cluster", 1, 0, 999)
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+
+			items, err = s.Create(ctx, namespace, "test-cluster", 2, 0, 999)
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+			if !reflect.DeepEqual(items, expected) {
+				t.Fatal("expected", expected, "got", items)
+			}
+		}
+
+		// Used items must be skipped by probing the following items.
+		{
+			s := newService()
+			_, err := s.Create(ctx, namespace, "test-cluster", 2, 0, 999)
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+
+			items, err := s.Create(ctx, namespace, "test-cluster-2", 1, expected[0], expected[0]+10)
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+			if contains(expected, items[0]) {
+				t.Fatal("expected", "free item", "got", items[0])
+			}
+		}
+	}
+}
+
+func contains(items []int, item int) bool {
+	for _, i := range items {
+		if i == item {
+			return true
+		}
+	}
+
+	return false
+}
+
+func Test_Service_Create_Cache(t *testing.T) {
+	// Create a new storage and service caching the used items.
+	var err error
+	var newService *Service
+	var newStorage Storage
+	{
+		newStorage, err = newMemoryStorage()
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		config := DefaultConfig()
+		config.Logger = microloggertest.New()
+		config.Storage = newStorage
+		config.CacheTTL = time.Hour
+		newService, err = New(config)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+	}
+
+	// Prepare the test variables.
+	ctx := context.TODO()
+	min := 2
+	max := 9
+
+	// Allocate the first item, which caches the used items of the namespace.
+	{
+		items, err := newService.Create(ctx, namespace, "test-id-1", 1, min, max)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		if len(items) != 1 || items[0] != 2 {
+			t.Fatal("expected", []int{2}, "got", items)
+		}
+	}
+
+	// Simulate another process allocating item 3 and resetting the latest
+	// pointer. The cached items do not know about item 3 yet.
+	{
+		err := newStorage.Create(ctx, fmt.Sprintf(ItemKeyFormat, namespace, "3"), "3")
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		err = newStorage.Delete(ctx, fmt.Sprintf(LatestKeyFormat, namespace))
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		items, err := newService.Create(ctx, namespace, "test-id-2", 1, min, max)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		if len(items) != 1 || items[0] != 3 {
+			t.Fatal("expected", []int{3}, "got", items)
+		}
+	}
+
+	// After invalidating the cache, the used items are fetched from the
+	// storage again, which makes item 3 being skipped.
+	{
+		err := newService.Delete(ctx, namespace, "test-id-2")
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		err = newStorage.Create(ctx, fmt.Sprintf(ItemKeyFormat, namespace, "3"), "3")
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		err = newStorage.Delete(ctx, fmt.Sprintf(LatestKeyFormat, namespace))
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		newService.InvalidateCache(namespace)
+
+		items, err := newService.Create(ctx, namespace, "test-id-2", 1, min, max)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		if len(items) != 1 || items[0] != 4 {
+			t.Fatal("expected", []int{4}, "got", items)
+		}
+	}
+}
+
+func Test_Service_Create_Batch(t *testing.T) {
+	// Create a new batch storage and service.
+	var newService *Service
+	var newStorage *testBatchStorage
+	{
+		s, err := newMemoryStorage()
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		newStorage = &testBatchStorage{Storage: s}
+
+		config := DefaultConfig()
+		config.Logger = microloggertest.New()
+		config.Storage = newStorage
+		newService, err = New(config)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+	}
+
+	ctx := context.TODO()
+
+	// Allocating many items must result in a single batched write.
+	{
+		items, err := newService.Create(ctx, namespace, "test-id", 500, 0, 1000)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		if len(items) != 500 {
+			t.Fatal("expected", 500, "got", len(items))
+		}
+		if newStorage.CreateBatches != 1 {
+			t.Fatal("expected", 1, "got", newStorage.CreateBatches)
+		}
+	}
+
+	// Releasing the items must result in a single batched delete.
+	{
+		err := newService.Delete(ctx, namespace, "test-id")
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		if newStorage.DeleteBatches != 1 {
+			t.Fatal("expected", 1, "got", newStorage.DeleteBatches)
+		}
+
+		_, err = newService.Search(ctx, namespace, "test-id")
+		if !IsItemsNotFound(err) {
+			t.Fatal("expected", true, "got", false)
+		}
+	}
+}
+
 func Test_nextItem(t *testing.T) {
 	var used []int = []int{3, 4, 6}
 	var min int = 2
@@ -695,3 +1283,271 @@ func Test_nextItem(t *testing.T) {
 		}
 	}
 }
+
+// newMemoryStorage creates a Storage backed by the microstorage memory
+// implementation.
+func newMemoryStorage() (Storage, error) {
+	memoryStorage, err := memory.New(memory.DefaultConfig())
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	c := DefaultMicrostorageConfig()
+	c.Storage = memoryStorage
+	newStorage, err := NewMicrostorage(c)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return newStorage, nil
+}
+
+// testBatchStorage implements BatchStorage on top of the given Storage and
+// counts the batches written.
+type testBatchStorage struct {
+	Storage
+
+	CreateBatches int
+	DeleteBatches int
+}
+
+func (s *testBatchStorage) CreateBatch(ctx context.Context, kvs []KV) error {
+	s.CreateBatches++
+
+	for _, kv := range kvs {
+		err := s.Create(ctx, kv.Key, kv.Value)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	return nil
+}
+
+func (s *testBatchStorage) DeleteBatch(ctx context.Context, keys []string) error {
+	s.DeleteBatches++
+
+	for _, k := range keys {
+		err := s.Delete(ctx, k)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	return nil
+}
+
+// testSwapStorage implements SwapStorage on top of the given Storage using a
+// mutex, which is good enough for candidates sharing the same process.
+type testSwapStorage struct {
+	Storage
+
+	mutex sync.Mutex
+}
+
+func (s *testSwapStorage) Swap(ctx context.Context, key, old, value string) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	v, err := s.Search(ctx, key)
+	if IsNotFound(err) {
+		v = ""
+	} else if err != nil {
+		return false, microerror.Mask(err)
+	}
+
+	if v != old {
+		return false, nil
+	}
+
+	err = s.Create(ctx, key, value)
+	if err != nil {
+		return false, microerror.Mask(err)
+	}
+
+	return true, nil
+}
+
+func Test_firstGap(t *testing.T) {
+	testCases := []struct {
+		Used     []int
+		From     int
+		To       int
+		Expected int
+	}{
+		{
+			Used:     nil,
+			From:     2,
+			To:       9,
+			Expected: 2,
+		},
+		{
+			Used:     []int{2, 3, 4, 6},
+			From:     2,
+			To:       9,
+			Expected: 5,
+		},
+		{
+			Used:     []int{2, 3, 4, 6},
+			From:     5,
+			To:       9,
+			Expected: 5,
+		},
+		{
+			Used:     []int{2, 3, 3, 4, 6},
+			From:     3,
+			To:       9,
+			Expected: 5,
+		},
+		{
+			Used:     []int{2, 3, 4, 5},
+			From:     2,
+			To:       5,
+			Expected: -1,
+		},
+		{
+			Used:     []int{2, 3, 4, 5},
+			From:     6,
+			To:       5,
+			Expected: -1,
+		},
+		{
+			Used:     []int{1, 10},
+			From:     4,
+			To:       9,
+			Expected: 4,
+		},
+	}
+
+	for i, tc := range testCases {
+		item := firstGap(tc.Used, tc.From, tc.To)
+		if item != tc.Expected {
+			t.Fatal("case", i+1, "expected", tc.Expected, "got", item)
+		}
+	}
+}
+
+// testLexicographicStorage lists the keys of the given Storage in
+// lexicographic order.
+type testLexicographicStorage struct {
+	Storage
+}
+
+func (s *testLexicographicStorage) List(ctx context.Context, key string) ([]KV, error) {
+	kvs, err := s.Storage.List(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(kvs, func(i, j int) bool {
+		return kvs[i].Key < kvs[j].Key
+	})
+
+	return kvs, nil
+}
+
+func Test_insertSorted(t *testing.T) {
+	testCases := []struct {
+		Items    []int
+		Item     int
+		Expected []int
+	}{
+		// Case 1 ensures items are inserted into empty lists.
+		{
+			Items:    nil,
+			Item:     3,
+			Expected: []int{3},
+		},
+		// Case 2 ensures items are inserted in front.
+		{
+			Items:    []int{2, 4},
+			Item:     1,
+			Expected: []int{1, 2, 4},
+		},
+		// Case 3 ensures items are inserted in between.
+		{
+			Items:    []int{2, 4},
+			Item:     3,
+			Expected: []int{2, 3, 4},
+		},
+		// Case 4 ensures items are appended.
+		{
+			Items:    []int{2, 4},
+			Item:     5,
+			Expected: []int{2, 4, 5},
+		},
+	}
+
+	for i, tc := range testCases {
+		items := insertSorted(tc.Items, tc.Item)
+		if !reflect.DeepEqual(items, tc.Expected) {
+			t.Fatal("case", i+1, "expected", tc.Expected, "got", items)
+		}
+	}
+}
+
+func Test_NextItems(t *testing.T) {
+	testCases := []struct {
+		Used         []int
+		Latest       int
+		Num          int
+		Expected     []int
+		ExpectedFree int
+	}{
+		// Case 1 ensures items are taken from min on without latest item.
+		{
+			Used:     []int{6, 3, 4},
+			Latest:   -1,
+			Num:      3,
+			Expected: []int{2, 5, 7},
+		},
+		// Case 2 ensures items following the latest item are taken first.
+		{
+			Used:     []int{3, 4, 6},
+			Latest:   4,
+			Num:      4,
+			Expected: []int{5, 7, 8, 9},
+		},
+		// Case 3 ensures the search wraps around at max.
+		{
+			Used:     []int{3, 4, 6},
+			Latest:   7,
+			Num:      4,
+			Expected: []int{8, 9, 2, 5},
+		},
+		// Case 4 ensures duplicate used items are ignored.
+		{
+			Used:     []int{2, 2, 3, 3},
+			Latest:   -1,
+			Num:      2,
+			Expected: []int{4, 5},
+		},
+		// Case 5 ensures the number of free items is reported in case there
+		// are not enough.
+		{
+			Used:         []int{2, 3, 4, 6, 8},
+			Latest:       4,
+			Num:          4,
+			Expected:     nil,
+			ExpectedFree: 3,
+		},
+	}
+
+	for i, tc := range testCases {
+		items, err := NextItems(tc.Used, 2, 9, tc.Latest, tc.Num)
+		if tc.Expected == nil {
+			c, ok := AsCapacityReached(err)
+			if !ok {
+				t.Fatal("case", i+1, "expected", true, "got", false)
+			}
+			if c.Free != tc.ExpectedFree {
+				t.Fatal("case", i+1, "expected", tc.ExpectedFree, "got", c.Free)
+			}
+		} else if err != nil {
+			t.Fatal("case", i+1, "expected", nil, "got", err)
+		}
+		if !reflect.DeepEqual(items, tc.Expected) {
+			t.Fatal("case", i+1, "expected", tc.Expected, "got", items)
+		}
+	}
+}
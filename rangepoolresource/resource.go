@@ -0,0 +1,207 @@
+// Package rangepoolresource implements an operatorkit compatible resource
+// allocating range pool items for the custom objects reconciled by an
+// operator. Items are allocated by EnsureCreated and released by
+// EnsureDeleted, so operators do not have to write the glue themselves. The
+// package does not import operatorkit. Resource satisfies its
+// resource.Interface structurally.
+package rangepoolresource
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+
+	"github.com/giantswarm/rangepool"
+)
+
+const (
+	// Name is the default name of the resource.
+	Name = "rangepool"
+)
+
+// Object is implemented by all Kubernetes objects, e.g. by embedding
+// metav1.ObjectMeta. It is used to derive the default ID of objects.
+type Object interface {
+	GetName() string
+	GetNamespace() string
+}
+
+// Config represents the configuration used to create a new resource.
+type Config struct {
+	// Dependencies.
+	Logger  micrologger.Logger
+	Service rangepool.Interface
+
+	// Settings.
+
+	// Allocated is called by EnsureCreated with the items of the reconciled
+	// object, e.g. to put them into the controller context or the status of
+	// the object. It is optional.
+	Allocated func(ctx context.Context, obj interface{}, items []int) error
+	// ID returns the ID the items of the reconciled object are allocated for.
+	// It defaults to the namespace and name of the object joined by a dot,
+	// which requires objects to implement Object.
+	ID func(obj interface{}) (string, error)
+	// Max is the max boundary of the range items are allocated from.
+	Max int
+	// Min is the min boundary of the range items are allocated from.
+	Min int
+	// Name is the name of the resource, which defaults to Name.
+	Name string
+	// Namespace is the range pool namespace items are allocated in.
+	Namespace string
+	// Num is the number of items allocated per object.
+	Num int
+}
+
+// DefaultConfig provides a default configuration to create a new resource by
+// best effort.
+func DefaultConfig() Config {
+	return Config{
+		// Dependencies.
+		Logger:  nil,
+		Service: nil,
+
+		// Settings.
+		Allocated: nil,
+		ID:        defaultID,
+		Max:       0,
+		Min:       0,
+		Name:      Name,
+		Namespace: "",
+		Num:       1,
+	}
+}
+
+// New creates a new configured resource.
+func New(config Config) (*Resource, error) {
+	// Dependencies.
+	if config.Logger == nil {
+		return nil, microerror.Maskf(invalidConfigError, "logger must not be empty")
+	}
+	if config.Service == nil {
+		return nil, microerror.Maskf(invalidConfigError, "service must not be empty")
+	}
+
+	// Settings.
+	if config.ID == nil {
+		return nil, microerror.Maskf(invalidConfigError, "ID must not be empty")
+	}
+	if config.Name == "" {
+		return nil, microerror.Maskf(invalidConfigError, "name must not be empty")
+	}
+	// IDs are only known per object, so a placeholder is validated here.
+	err := rangepool.Validate(config.Namespace, "id", config.Num, config.Min, config.Max)
+	if err != nil {
+		return nil, microerror.Maskf(invalidConfigError, "%s", err.Error())
+	}
+
+	r := &Resource{
+		// Dependencies.
+		logger:  config.Logger,
+		service: config.Service,
+
+		// Settings.
+		allocated: config.Allocated,
+		id:        config.ID,
+		max:       config.Max,
+		min:       config.Min,
+		name:      config.Name,
+		namespace: config.Namespace,
+		num:       config.Num,
+	}
+
+	return r, nil
+}
+
+type Resource struct {
+	// Dependencies.
+	logger  micrologger.Logger
+	service rangepool.Interface
+
+	// Settings.
+	allocated func(ctx context.Context, obj interface{}, items []int) error
+	id        func(obj interface{}) (string, error)
+	max       int
+	min       int
+	name      string
+	namespace string
+	num       int
+}
+
+// EnsureCreated allocates the items of the given object, unless they have
+// been allocated already, and passes them to Config.Allocated.
+func (r *Resource) EnsureCreated(ctx context.Context, obj interface{}) error {
+	ID, err := r.id(obj)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	r.logger.LogCtx(ctx, "level", "debug", "message", fmt.Sprintf("finding items of ID '%s'", ID))
+
+	items, err := r.service.Search(ctx, r.namespace, ID)
+	if rangepool.IsItemsNotFound(err) {
+		r.logger.LogCtx(ctx, "level", "debug", "message", fmt.Sprintf("did not find items of ID '%s'", ID))
+		r.logger.LogCtx(ctx, "level", "debug", "message", fmt.Sprintf("allocating items for ID '%s'", ID))
+
+		items, err = r.service.Create(ctx, r.namespace, ID, r.num, r.min, r.max)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
+		r.logger.LogCtx(ctx, "level", "debug", "message", fmt.Sprintf("allocated items %v for ID '%s'", items, ID))
+	} else if err != nil {
+		return microerror.Mask(err)
+	} else {
+		r.logger.LogCtx(ctx, "level", "debug", "message", fmt.Sprintf("found items %v of ID '%s'", items, ID))
+	}
+
+	if r.allocated != nil {
+		err = r.allocated(ctx, obj, items)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	return nil
+}
+
+// EnsureDeleted releases the items of the given object. Objects without items
+// are ignored.
+func (r *Resource) EnsureDeleted(ctx context.Context, obj interface{}) error {
+	ID, err := r.id(obj)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	r.logger.LogCtx(ctx, "level", "debug", "message", fmt.Sprintf("releasing items of ID '%s'", ID))
+
+	err = r.service.Delete(ctx, r.namespace, ID)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	r.logger.LogCtx(ctx, "level", "debug", "message", fmt.Sprintf("released items of ID '%s'", ID))
+
+	return nil
+}
+
+// Name returns the name of the resource.
+func (r *Resource) Name() string {
+	return r.name
+}
+
+func defaultID(obj interface{}) (string, error) {
+	o, ok := obj.(Object)
+	if !ok {
+		return "", microerror.Maskf(wrongTypeError, "expected object implementing GetName and GetNamespace, got '%T'", obj)
+	}
+
+	if o.GetNamespace() == "" {
+		return o.GetName(), nil
+	}
+
+	return o.GetNamespace() + "." + o.GetName(), nil
+}
@@ -0,0 +1,104 @@
+package rangepoolresource
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/giantswarm/micrologger/microloggertest"
+
+	"github.com/giantswarm/rangepool"
+	"github.com/giantswarm/rangepool/rangepooltest"
+)
+
+type testObject struct {
+	Name      string
+	Namespace string
+}
+
+func (o testObject) GetName() string {
+	return o.Name
+}
+
+func (o testObject) GetNamespace() string {
+	return o.Namespace
+}
+
+func Test_Resource(t *testing.T) {
+	var err error
+
+	var fake *rangepooltest.Fake
+	{
+		fake, err = rangepooltest.NewFake(rangepooltest.DefaultFakeConfig())
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+	}
+
+	var allocated []int
+	var r *Resource
+	{
+		c := DefaultConfig()
+		c.Logger = microloggertest.New()
+		c.Service = fake
+		c.Allocated = func(ctx context.Context, obj interface{}, items []int) error {
+			allocated = items
+			return nil
+		}
+		c.Max = 10
+		c.Min = 1
+		c.Namespace = "vni"
+		c.Num = 2
+		r, err = New(c)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+	}
+
+	ctx := context.TODO()
+	obj := testObject{Name: "cluster-1", Namespace: "default"}
+
+	// Reconciling an object twice must allocate its items only once.
+	for i := 0; i < 2; i++ {
+		err := r.EnsureCreated(ctx, obj)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		expected := []int{1, 2}
+		if !reflect.DeepEqual(allocated, expected) {
+			t.Fatal("expected", expected, "got", allocated)
+		}
+	}
+
+	{
+		items, err := fake.Search(ctx, "vni", "default.cluster-1")
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		expected := []int{1, 2}
+		if !reflect.DeepEqual(items, expected) {
+			t.Fatal("expected", expected, "got", items)
+		}
+	}
+
+	// Deleting the object must release its items.
+	{
+		err := r.EnsureDeleted(ctx, obj)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		_, err = fake.Search(ctx, "vni", "default.cluster-1")
+		if !rangepool.IsItemsNotFound(err) {
+			t.Fatal("expected", true, "got", false)
+		}
+	}
+
+	// Objects the default ID cannot be derived from must be rejected.
+	{
+		err := r.EnsureCreated(ctx, "cluster-1")
+		if !IsWrongType(err) {
+			t.Fatal("expected", true, "got", false)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package rangepooltest
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock is a rangepool.Clock which only moves when told to, so that leases,
+// cooldowns and reservations expire deterministically in tests, see
+// rangepool.Config.Clock. It is safe for concurrent use.
+type Clock struct {
+	mutex sync.Mutex
+	now   time.Time
+}
+
+// NewClock creates a new clock showing the given time.
+func NewClock(now time.Time) *Clock {
+	c := &Clock{
+		mutex: sync.Mutex{},
+		now:   now,
+	}
+
+	return c
+}
+
+// Add moves the clock forward by the given duration.
+func (c *Clock) Add(d time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.now = c.now.Add(d)
+}
+
+// Now returns the time the clock shows.
+func (c *Clock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.now
+}
+
+// Set moves the clock to the given time.
+func (c *Clock) Set(now time.Time) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.now = now
+}
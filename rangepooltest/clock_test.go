@@ -0,0 +1,90 @@
+package rangepooltest
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/giantswarm/micrologger/microloggertest"
+
+	"github.com/giantswarm/rangepool"
+)
+
+var _ rangepool.Clock = &Clock{}
+
+func Test_Clock_Expiry(t *testing.T) {
+	clock := NewClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	var err error
+	var service *rangepool.Service
+	{
+		c := rangepool.DefaultConfig()
+		c.Clock = clock
+		c.Logger = microloggertest.New()
+		c.ReservationTimeout = time.Minute
+		c.Storage = newMapStorage()
+		service, err = rangepool.New(c)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+	}
+
+	ctx := context.TODO()
+
+	err = service.SetPolicy(ctx, "test-namespace", rangepool.Policy{Cooldown: time.Hour})
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+
+	// Reservations must be committable until the clock passes their expiry.
+	{
+		r, err := service.Reserve(ctx, "test-namespace", "test-id-1", 1, 1, 2)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		if !r.ExpiresAt.Equal(clock.Now().Add(time.Minute)) {
+			t.Fatal("expected", clock.Now().Add(time.Minute), "got", r.ExpiresAt)
+		}
+
+		clock.Add(time.Minute + time.Nanosecond)
+
+		err = service.Commit(ctx, r.Token)
+		if !rangepool.IsReservationExpired(err) {
+			t.Fatal("expected", true, "got", false)
+		}
+	}
+
+	// Released items must cool down until the clock passes the cooldown.
+	{
+		items, err := service.Create(ctx, "test-namespace", "test-id-2", 1, 1, 2)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		if !reflect.DeepEqual(items, []int{2}) {
+			t.Fatal("expected", []int{2}, "got", items)
+		}
+
+		err = service.Delete(ctx, "test-namespace", "test-id-2")
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		clock.Add(59 * time.Minute)
+
+		_, err = service.Create(ctx, "test-namespace", "test-id-3", 2, 1, 2)
+		if !rangepool.IsCapacityReached(err) {
+			t.Fatal("expected", true, "got", false)
+		}
+
+		clock.Add(time.Minute)
+
+		items, err = service.Create(ctx, "test-namespace", "test-id-3", 2, 1, 2)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		if !reflect.DeepEqual(items, []int{1, 2}) {
+			t.Fatal("expected", []int{1, 2}, "got", items)
+		}
+	}
+}
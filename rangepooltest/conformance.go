@@ -0,0 +1,352 @@
+// Package rangepooltest provides helpers to test Storage implementations
+// used by the range pool, and Fake, a deterministic in-memory range pool for
+// unit tests of range pool consumers.
+package rangepooltest
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"testing"
+
+	"github.com/giantswarm/rangepool"
+)
+
+// StorageFactory creates a new and empty Storage. It is called once for every
+// test executed by RunStorageConformance.
+type StorageFactory func(t *testing.T) rangepool.Storage
+
+// RunStorageConformance executes a set of tests asserting the semantics the
+// range pool Service requires from its Storage. Backend authors can use it to
+// validate the compatibility of their implementations.
+//
+//     func Test_Storage_Conformance(t *testing.T) {
+//         rangepooltest.RunStorageConformance(t, func(t *testing.T) rangepool.Storage {
+//             return newStorage(t)
+//         })
+//     }
+//
+func RunStorageConformance(t *testing.T, factory StorageFactory) {
+	t.Run("Collision", func(t *testing.T) {
+		testCollision(t, factory(t))
+	})
+	t.Run("NotFound", func(t *testing.T) {
+		testNotFound(t, factory(t))
+	})
+	t.Run("ListPrefix", func(t *testing.T) {
+		testListPrefix(t, factory(t))
+	})
+	t.Run("Delete", func(t *testing.T) {
+		testDelete(t, factory(t))
+	})
+	t.Run("Walk", func(t *testing.T) {
+		testWalk(t, factory(t))
+	})
+	t.Run("Swap", func(t *testing.T) {
+		testSwap(t, factory(t))
+	})
+	t.Run("Apply", func(t *testing.T) {
+		testApply(t, factory(t))
+	})
+}
+
+// testCollision ensures keys of different namespaces and IDs do not collide
+// and that creating an existing key overwrites its value.
+func testCollision(t *testing.T, storage rangepool.Storage) {
+	ctx := context.TODO()
+
+	mustCreate(t, storage, "range-pool/namespace-1/latest", "3")
+	mustCreate(t, storage, "range-pool/namespace-2/latest", "5")
+	mustCreate(t, storage, "range-pool/namespace-1/id/id-1/item/3", "3")
+	mustCreate(t, storage, "range-pool/namespace-1/id/id-2/item/4", "4")
+
+	assertValue(t, storage, "range-pool/namespace-1/latest", "3")
+	assertValue(t, storage, "range-pool/namespace-2/latest", "5")
+
+	mustCreate(t, storage, "range-pool/namespace-1/latest", "4")
+
+	assertValue(t, storage, "range-pool/namespace-1/latest", "4")
+	assertValue(t, storage, "range-pool/namespace-2/latest", "5")
+
+	kvs, err := storage.List(ctx, "range-pool/namespace-1/id/id-1/item")
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+	assertKeys(t, kvs, []string{"3"})
+}
+
+// testNotFound ensures the not found semantics of all Storage methods.
+func testNotFound(t *testing.T, storage rangepool.Storage) {
+	ctx := context.TODO()
+
+	{
+		_, err := storage.Search(ctx, "range-pool/namespace-1/latest")
+		if !rangepool.IsNotFound(err) {
+			t.Fatal("expected", true, "got", false)
+		}
+	}
+
+	{
+		kvs, err := storage.List(ctx, "range-pool/namespace-1/item")
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		if len(kvs) != 0 {
+			t.Fatal("expected", 0, "got", len(kvs))
+		}
+	}
+
+	{
+		err := storage.Delete(ctx, "range-pool/namespace-1/item/3")
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+	}
+}
+
+// testListPrefix ensures List only returns the keys below the given key,
+// relative to the given key.
+func testListPrefix(t *testing.T, storage rangepool.Storage) {
+	ctx := context.TODO()
+
+	mustCreate(t, storage, "range-pool/namespace-1/item/2", "2")
+	mustCreate(t, storage, "range-pool/namespace-1/item/3", "3")
+	mustCreate(t, storage, "range-pool/namespace-1/items/4", "4")
+	mustCreate(t, storage, "range-pool/namespace-10/item/5", "5")
+	mustCreate(t, storage, "range-pool/namespace-1/latest", "3")
+
+	kvs, err := storage.List(ctx, "range-pool/namespace-1/item")
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+	assertKeys(t, kvs, []string{"2", "3"})
+
+	for _, kv := range kvs {
+		if kv.Key != kv.Value {
+			t.Fatal("expected", kv.Key, "got", kv.Value)
+		}
+	}
+}
+
+// testDelete ensures deleted keys are gone while other keys are kept.
+func testDelete(t *testing.T, storage rangepool.Storage) {
+	ctx := context.TODO()
+
+	mustCreate(t, storage, "range-pool/namespace-1/item/2", "2")
+	mustCreate(t, storage, "range-pool/namespace-1/item/3", "3")
+	mustCreate(t, storage, "range-pool/namespace-1/latest", "3")
+
+	{
+		err := storage.Delete(ctx, "range-pool/namespace-1/item/2")
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		_, err = storage.Search(ctx, "range-pool/namespace-1/item/2")
+		if !rangepool.IsNotFound(err) {
+			t.Fatal("expected", true, "got", false)
+		}
+
+		kvs, err := storage.List(ctx, "range-pool/namespace-1/item")
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		assertKeys(t, kvs, []string{"3"})
+
+		assertValue(t, storage, "range-pool/namespace-1/latest", "3")
+	}
+
+	// Deleting a key twice must not fail.
+	{
+		err := storage.Delete(ctx, "range-pool/namespace-1/item/2")
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+	}
+}
+
+// testWalk ensures Walk visits the same keys List returns and stops on
+// errors. It is skipped for storages not implementing rangepool.WalkStorage.
+func testWalk(t *testing.T, storage rangepool.Storage) {
+	w, ok := storage.(rangepool.WalkStorage)
+	if !ok {
+		t.Skip("storage does not implement rangepool.WalkStorage")
+	}
+
+	ctx := context.TODO()
+
+	mustCreate(t, storage, "range-pool/namespace-1/item/2", "2")
+	mustCreate(t, storage, "range-pool/namespace-1/item/3", "3")
+	mustCreate(t, storage, "range-pool/namespace-1/items/4", "4")
+
+	{
+		var kvs []rangepool.KV
+		err := w.Walk(ctx, "range-pool/namespace-1/item", func(kv rangepool.KV) error {
+			kvs = append(kvs, kv)
+			return nil
+		})
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		assertKeys(t, kvs, []string{"2", "3"})
+	}
+
+	{
+		var n int
+		stop := errors.New("stop")
+		err := w.Walk(ctx, "range-pool/namespace-1/item", func(kv rangepool.KV) error {
+			n++
+			return stop
+		})
+		if err == nil {
+			t.Fatal("expected", stop, "got", nil)
+		}
+		if n != 1 {
+			t.Fatal("expected", 1, "got", n)
+		}
+	}
+}
+
+// testSwap ensures Swap only writes in case the current value matches. It is
+// skipped for storages not able to swap values, see rangepool.CanSwap.
+func testSwap(t *testing.T, storage rangepool.Storage) {
+	if !rangepool.CanSwap(storage) {
+		t.Skip("storage does not implement rangepool.SwapStorage")
+	}
+	w := storage.(rangepool.SwapStorage)
+
+	ctx := context.TODO()
+	key := "range-pool/leader/default"
+
+	testCases := []struct {
+		Old        string
+		Value      string
+		ExpectedOK bool
+		Expected   string
+	}{
+		// Case 1 ensures missing keys are created in case no value is expected.
+		{
+			Old:        "",
+			Value:      "1",
+			ExpectedOK: true,
+			Expected:   "1",
+		},
+		// Case 2 ensures existing keys are not overwritten in case no value is
+		// expected.
+		{
+			Old:        "",
+			Value:      "2",
+			ExpectedOK: false,
+			Expected:   "1",
+		},
+		// Case 3 ensures keys are not overwritten in case their value differs.
+		{
+			Old:        "3",
+			Value:      "2",
+			ExpectedOK: false,
+			Expected:   "1",
+		},
+		// Case 4 ensures keys are overwritten in case their value matches.
+		{
+			Old:        "1",
+			Value:      "2",
+			ExpectedOK: true,
+			Expected:   "2",
+		},
+	}
+
+	for i, tc := range testCases {
+		ok, err := w.Swap(ctx, key, tc.Old, tc.Value)
+		if err != nil {
+			t.Fatal("case", i+1, "expected", nil, "got", err)
+		}
+		if ok != tc.ExpectedOK {
+			t.Fatal("case", i+1, "expected", tc.ExpectedOK, "got", ok)
+		}
+		assertValue(t, storage, key, tc.Expected)
+	}
+}
+
+// testApply ensures Apply writes and removes the given keys. It is skipped for
+// storages not implementing rangepool.AtomicStorage. Atomicity itself cannot be
+// observed by a single client.
+func testApply(t *testing.T, storage rangepool.Storage) {
+	a, ok := storage.(rangepool.AtomicStorage)
+	if !ok {
+		t.Skip("storage does not implement rangepool.AtomicStorage")
+	}
+
+	ctx := context.TODO()
+
+	mustCreate(t, storage, "range-pool/namespace-1/id/id-1/item/2", "2")
+	mustCreate(t, storage, "range-pool/namespace-1/id/id-1/item/3", "3")
+
+	kvs := []rangepool.KV{
+		{Key: "range-pool/namespace-1/id/id-2/item/2", Value: "2"},
+		{Key: "range-pool/namespace-1/id/id-2/item/3", Value: "3"},
+	}
+	keys := []string{
+		"range-pool/namespace-1/id/id-1/item/2",
+		"range-pool/namespace-1/id/id-1/item/3",
+	}
+	err := a.Apply(ctx, kvs, keys)
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+
+	{
+		kvs, err := storage.List(ctx, "range-pool/namespace-1/id/id-1/item")
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		assertKeys(t, kvs, nil)
+	}
+
+	{
+		kvs, err := storage.List(ctx, "range-pool/namespace-1/id/id-2/item")
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		assertKeys(t, kvs, []string{"2", "3"})
+	}
+}
+
+func assertKeys(t *testing.T, kvs []rangepool.KV, expected []string) {
+	t.Helper()
+
+	var keys []string
+	for _, kv := range kvs {
+		keys = append(keys, kv.Key)
+	}
+	sort.Strings(keys)
+
+	if len(keys) != len(expected) {
+		t.Fatal("expected", expected, "got", keys)
+	}
+	for i := range keys {
+		if keys[i] != expected[i] {
+			t.Fatal("expected", expected, "got", keys)
+		}
+	}
+}
+
+func assertValue(t *testing.T, storage rangepool.Storage, key, expected string) {
+	t.Helper()
+
+	v, err := storage.Search(context.TODO(), key)
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+	if v != expected {
+		t.Fatal("expected", expected, "got", v)
+	}
+}
+
+func mustCreate(t *testing.T, storage rangepool.Storage, key, value string) {
+	t.Helper()
+
+	err := storage.Create(context.TODO(), key, value)
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+}
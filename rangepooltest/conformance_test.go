@@ -0,0 +1,33 @@
+package rangepooltest
+
+import (
+	"testing"
+
+	"github.com/giantswarm/microstorage/memory"
+
+	"github.com/giantswarm/rangepool"
+)
+
+func Test_RunStorageConformance_Microstorage(t *testing.T) {
+	RunStorageConformance(t, func(t *testing.T) rangepool.Storage {
+		memoryStorage, err := memory.New(memory.DefaultConfig())
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		c := rangepool.DefaultMicrostorageConfig()
+		c.Storage = memoryStorage
+		newStorage, err := rangepool.NewMicrostorage(c)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		return newStorage
+	})
+}
+
+func Test_RunStorageConformance_mapStorage(t *testing.T) {
+	RunStorageConformance(t, func(t *testing.T) rangepool.Storage {
+		return newMapStorage()
+	})
+}
@@ -0,0 +1,23 @@
+package rangepooltest
+
+import (
+	"github.com/giantswarm/microerror"
+)
+
+var invalidConfigError = &microerror.Error{
+	Kind: "invalidConfigError",
+}
+
+// IsInvalidConfig asserts invalidConfigError.
+func IsInvalidConfig(err error) bool {
+	return microerror.Cause(err) == invalidConfigError
+}
+
+var injectedError = &microerror.Error{
+	Kind: "injectedError",
+}
+
+// IsInjected asserts the default error of faults injected by FaultyStorage.
+func IsInjected(err error) bool {
+	return microerror.Cause(err) == injectedError
+}
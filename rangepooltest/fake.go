@@ -0,0 +1,615 @@
+package rangepooltest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/giantswarm/microerror"
+
+	"github.com/giantswarm/rangepool"
+)
+
+// FakeConfig represents the configuration used to create a new fake range
+// pool.
+type FakeConfig struct {
+	// Settings.
+
+	// Allocations pre-seeds the fake with items allocated to IDs, grouped by
+	// namespace and ID.
+	Allocations map[string]map[string][]int
+	// Capacity is the maximum number of items allocated per namespace, no
+	// matter the range requested. Allocations exceeding it fail with
+	// rangepool.CapacityReachedError. A capacity of 0 disables the limit.
+	Capacity int
+	// Errors are canned errors returned by the methods of the fake, keyed by
+	// method name, e.g. "Create". See also Fake.SetError.
+	Errors map[string]error
+	// Ranges maps namespaces to their canonical range, see
+	// rangepool.Config.Ranges.
+	Ranges map[string]rangepool.Range
+}
+
+// DefaultFakeConfig provides a default configuration to create a new fake
+// range pool by best effort.
+func DefaultFakeConfig() FakeConfig {
+	return FakeConfig{
+		// Settings.
+		Allocations: nil,
+		Capacity:    0,
+		Errors:      nil,
+		Ranges:      nil,
+	}
+}
+
+// NewFake creates a new fake range pool. The fake is a rangepool.Service
+// backed by an in-memory storage, so it behaves deterministically like the
+// real range pool without wiring any dependency.
+func NewFake(config FakeConfig) (*Fake, error) {
+	// Settings.
+	if config.Capacity < 0 {
+		return nil, microerror.Maskf(invalidConfigError, "capacity must not be negative")
+	}
+
+	storage := newMapStorage()
+
+	var service *rangepool.Service
+	{
+		c := rangepool.DefaultConfig()
+		c.Ranges = config.Ranges
+		c.Storage = storage
+
+		var err error
+		service, err = rangepool.New(c)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
+	for namespace, IDs := range config.Allocations {
+		latest := -1
+		for ID, items := range IDs {
+			for _, item := range items {
+				i := strconv.Itoa(item)
+				storage.data[fmt.Sprintf(rangepool.ItemKeyFormat, namespace, i)] = i
+				storage.data[fmt.Sprintf(rangepool.IDKeyFormat, namespace, ID, i)] = i
+				if item > latest {
+					latest = item
+				}
+			}
+		}
+		if latest != -1 {
+			storage.data[fmt.Sprintf(rangepool.LatestKeyFormat, namespace)] = strconv.Itoa(latest)
+		}
+	}
+
+	errors := map[string]error{}
+	for method, err := range config.Errors {
+		errors[method] = err
+	}
+
+	f := &Fake{
+		// Internals.
+		errors:  errors,
+		mutex:   sync.Mutex{},
+		service: service,
+
+		// Settings.
+		capacity: config.Capacity,
+		ranges:   config.Ranges,
+	}
+
+	return f, nil
+}
+
+// Fake is a deterministic in-memory implementation of rangepool.Interface for
+// unit tests of range pool consumers.
+type Fake struct {
+	// Internals.
+	errors  map[string]error
+	mutex   sync.Mutex
+	service *rangepool.Service
+
+	// Settings.
+	capacity int
+	ranges   map[string]rangepool.Range
+}
+
+var _ rangepool.Interface = &Fake{}
+
+// SetError configures the canned error returned by the given method, e.g.
+// "Create". A nil error removes the canned error.
+func (f *Fake) SetError(method string, err error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if err == nil {
+		delete(f.errors, method)
+		return
+	}
+	f.errors[method] = err
+}
+
+func (f *Fake) Abort(ctx context.Context, token string) error {
+	err := f.err("Abort")
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return f.service.Abort(ctx, token)
+}
+
+func (f *Fake) Adopt(ctx context.Context, namespace, ID string, items []int, min, max int) (rangepool.AdoptReport, error) {
+	err := f.err("Adopt")
+	if err != nil {
+		return rangepool.AdoptReport{}, microerror.Mask(err)
+	}
+
+	return f.service.Adopt(ctx, namespace, ID, items, min, max)
+}
+
+func (f *Fake) Allocate(ctx context.Context, namespace, ID string, num, min, max int) (rangepool.Allocation, error) {
+	err := f.check(ctx, "Allocate", namespace, num, min, max)
+	if err != nil {
+		return rangepool.Allocation{}, microerror.Mask(err)
+	}
+
+	return f.service.Allocate(ctx, namespace, ID, num, min, max)
+}
+
+func (f *Fake) AuditLog(ctx context.Context, namespace string, since time.Time) ([]rangepool.AuditEntry, error) {
+	err := f.err("AuditLog")
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return f.service.AuditLog(ctx, namespace, since)
+}
+
+func (f *Fake) Backup(ctx context.Context, w io.Writer) error {
+	err := f.err("Backup")
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return f.service.Backup(ctx, w)
+}
+
+func (f *Fake) Burn(ctx context.Context, namespace string, items []int) error {
+	err := f.err("Burn")
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return f.service.Burn(ctx, namespace, items)
+}
+
+func (f *Fake) Close(ctx context.Context) error {
+	err := f.err("Close")
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return f.service.Close(ctx)
+}
+
+func (f *Fake) CloneNamespace(ctx context.Context, src, dst string) error {
+	err := f.err("CloneNamespace")
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return f.service.CloneNamespace(ctx, src, dst)
+}
+
+func (f *Fake) Commit(ctx context.Context, token string) error {
+	err := f.err("Commit")
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return f.service.Commit(ctx, token)
+}
+
+func (f *Fake) Compact(ctx context.Context, namespace string) error {
+	err := f.err("Compact")
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return f.service.Compact(ctx, namespace)
+}
+
+func (f *Fake) Create(ctx context.Context, namespace, ID string, num, min, max int) ([]int, error) {
+	err := f.check(ctx, "Create", namespace, num, min, max)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return f.service.Create(ctx, namespace, ID, num, min, max)
+}
+
+func (f *Fake) CreateDefault(ctx context.Context, namespace, ID string, num int) ([]int, error) {
+	r := f.ranges[namespace]
+	err := f.check(ctx, "CreateDefault", namespace, num, r.Min, r.Max)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return f.service.CreateDefault(ctx, namespace, ID, num)
+}
+
+func (f *Fake) CreateIf(ctx context.Context, namespace, ID string, num, min, max int, precondition rangepool.Precondition) ([]int, error) {
+	err := f.check(ctx, "CreateIf", namespace, num, min, max)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return f.service.CreateIf(ctx, namespace, ID, num, min, max, precondition)
+}
+
+func (f *Fake) CreateInSubPool(ctx context.Context, namespace, subPool, ID string, num int) ([]int, error) {
+	p, err := f.service.Policy(ctx, namespace)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	sp := p.SubPools[subPool]
+	err = f.check(ctx, "CreateInSubPool", namespace, num, sp.Min, sp.Max)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return f.service.CreateInSubPool(ctx, namespace, subPool, ID, num)
+}
+
+func (f *Fake) CreateTuple(ctx context.Context, ID string, dimensions []rangepool.Dimension) ([]int, error) {
+	for _, d := range dimensions {
+		err := f.check(ctx, "CreateTuple", d.Namespace, 1, d.Min, d.Max)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
+	return f.service.CreateTuple(ctx, ID, dimensions)
+}
+
+func (f *Fake) CreateWait(ctx context.Context, namespace, ID string, num, min, max int) ([]int, error) {
+	err := f.check(ctx, "CreateWait", namespace, num, min, max)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return f.service.CreateWait(ctx, namespace, ID, num, min, max)
+}
+
+func (f *Fake) Delete(ctx context.Context, namespace, ID string) error {
+	err := f.err("Delete")
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return f.service.Delete(ctx, namespace, ID)
+}
+
+func (f *Fake) DeleteIf(ctx context.Context, namespace, ID string, expected []int) error {
+	err := f.err("DeleteIf")
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return f.service.DeleteIf(ctx, namespace, ID, expected)
+}
+
+func (f *Fake) Dump(ctx context.Context, namespace string) (rangepool.Dump, error) {
+	err := f.err("Dump")
+	if err != nil {
+		return rangepool.Dump{}, microerror.Mask(err)
+	}
+
+	return f.service.Dump(ctx, namespace)
+}
+
+func (f *Fake) Export(ctx context.Context, namespace string) (rangepool.Snapshot, error) {
+	err := f.err("Export")
+	if err != nil {
+		return rangepool.Snapshot{}, microerror.Mask(err)
+	}
+
+	return f.service.Export(ctx, namespace)
+}
+
+func (f *Fake) Forecast(ctx context.Context, namespace string, min, max int, ratePerDay float64) (rangepool.Forecast, error) {
+	err := f.err("Forecast")
+	if err != nil {
+		return rangepool.Forecast{}, microerror.Mask(err)
+	}
+
+	return f.service.Forecast(ctx, namespace, min, max, ratePerDay)
+}
+
+func (f *Fake) ForceRelease(ctx context.Context, namespace string, item int) error {
+	err := f.err("ForceRelease")
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return f.service.ForceRelease(ctx, namespace, item)
+}
+
+func (f *Fake) GC(ctx context.Context, namespace string, dryRun bool) (rangepool.GCReport, error) {
+	err := f.err("GC")
+	if err != nil {
+		return rangepool.GCReport{}, microerror.Mask(err)
+	}
+
+	return f.service.GC(ctx, namespace, dryRun)
+}
+
+func (f *Fake) Healthz(ctx context.Context) error {
+	err := f.err("Healthz")
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return f.service.Healthz(ctx)
+}
+
+func (f *Fake) History(ctx context.Context, namespace string, item int) ([]rangepool.HistoryEntry, error) {
+	err := f.err("History")
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return f.service.History(ctx, namespace, item)
+}
+
+func (f *Fake) Import(ctx context.Context, snapshot rangepool.Snapshot) error {
+	err := f.err("Import")
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return f.service.Import(ctx, snapshot)
+}
+
+func (f *Fake) InvalidateCache(namespace string) {
+	f.service.InvalidateCache(namespace)
+}
+
+func (f *Fake) Latest(ctx context.Context, namespace string) (int, bool, error) {
+	err := f.err("Latest")
+	if err != nil {
+		return 0, false, microerror.Mask(err)
+	}
+
+	return f.service.Latest(ctx, namespace)
+}
+
+func (f *Fake) MergeIDs(ctx context.Context, namespace, fromID, intoID string) error {
+	err := f.err("MergeIDs")
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return f.service.MergeIDs(ctx, namespace, fromID, intoID)
+}
+
+func (f *Fake) MigrateKeys(ctx context.Context, namespace string) error {
+	err := f.err("MigrateKeys")
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return f.service.MigrateKeys(ctx, namespace)
+}
+
+func (f *Fake) NotifyFree(ctx context.Context, namespace string) (<-chan struct{}, error) {
+	err := f.err("NotifyFree")
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return f.service.NotifyFree(ctx, namespace)
+}
+
+func (f *Fake) Policy(ctx context.Context, namespace string) (rangepool.Policy, error) {
+	err := f.err("Policy")
+	if err != nil {
+		return rangepool.Policy{}, microerror.Mask(err)
+	}
+
+	return f.service.Policy(ctx, namespace)
+}
+
+func (f *Fake) RenameID(ctx context.Context, namespace, oldID, newID string) error {
+	err := f.err("RenameID")
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return f.service.RenameID(ctx, namespace, oldID, newID)
+}
+
+func (f *Fake) Reserve(ctx context.Context, namespace, ID string, num, min, max int) (rangepool.Reservation, error) {
+	err := f.check(ctx, "Reserve", namespace, num, min, max)
+	if err != nil {
+		return rangepool.Reservation{}, microerror.Mask(err)
+	}
+
+	return f.service.Reserve(ctx, namespace, ID, num, min, max)
+}
+
+func (f *Fake) ResetLatest(ctx context.Context, namespace string) error {
+	err := f.err("ResetLatest")
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return f.service.ResetLatest(ctx, namespace)
+}
+
+func (f *Fake) Restore(ctx context.Context, r io.Reader) error {
+	err := f.err("Restore")
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return f.service.Restore(ctx, r)
+}
+
+func (f *Fake) Rollback(ctx context.Context, namespace string, version int) error {
+	err := f.err("Rollback")
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return f.service.Rollback(ctx, namespace, version)
+}
+
+func (f *Fake) Search(ctx context.Context, namespace, ID string) ([]int, error) {
+	err := f.err("Search")
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return f.service.Search(ctx, namespace, ID)
+}
+
+func (f *Fake) SearchAll(ctx context.Context, ID string) (map[string][]int, error) {
+	err := f.err("SearchAll")
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return f.service.SearchAll(ctx, ID)
+}
+
+func (f *Fake) SearchIDs(ctx context.Context, namespace, pattern string) (map[string][]int, error) {
+	err := f.err("SearchIDs")
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return f.service.SearchIDs(ctx, namespace, pattern)
+}
+
+func (f *Fake) SearchMany(ctx context.Context, namespace string, IDs []string) (map[string][]int, error) {
+	err := f.err("SearchMany")
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return f.service.SearchMany(ctx, namespace, IDs)
+}
+
+func (f *Fake) SearchRevision(ctx context.Context, namespace, ID string) ([]int, string, error) {
+	err := f.err("SearchRevision")
+	if err != nil {
+		return nil, "", microerror.Mask(err)
+	}
+
+	return f.service.SearchRevision(ctx, namespace, ID)
+}
+
+func (f *Fake) Seek(ctx context.Context, namespace string, item int) error {
+	err := f.err("Seek")
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return f.service.Seek(ctx, namespace, item)
+}
+
+func (f *Fake) SetPolicy(ctx context.Context, namespace string, policy rangepool.Policy) error {
+	err := f.err("SetPolicy")
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return f.service.SetPolicy(ctx, namespace, policy)
+}
+
+func (f *Fake) Snapshot(ctx context.Context, namespace string) (int, error) {
+	err := f.err("Snapshot")
+	if err != nil {
+		return 0, microerror.Mask(err)
+	}
+
+	return f.service.Snapshot(ctx, namespace)
+}
+
+func (f *Fake) Status(ctx context.Context, namespace string, min, max int) (rangepool.Status, error) {
+	err := f.err("Status")
+	if err != nil {
+		return rangepool.Status{}, microerror.Mask(err)
+	}
+
+	return f.service.Status(ctx, namespace, min, max)
+}
+
+func (f *Fake) Verify(ctx context.Context, namespace string, min, max int) (rangepool.VerifyReport, error) {
+	err := f.err("Verify")
+	if err != nil {
+		return rangepool.VerifyReport{}, microerror.Mask(err)
+	}
+
+	return f.service.Verify(ctx, namespace, min, max)
+}
+
+func (f *Fake) Warm(ctx context.Context, namespaces ...string) error {
+	err := f.err("Warm")
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return f.service.Warm(ctx, namespaces...)
+}
+
+func (f *Fake) Watch(ctx context.Context, namespace string) (<-chan rangepool.Event, error) {
+	err := f.err("Watch")
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return f.service.Watch(ctx, namespace)
+}
+
+// check returns the canned error of the given method, or an error in case the
+// allocation of num items would exceed the configured capacity.
+func (f *Fake) check(ctx context.Context, method, namespace string, num, min, max int) error {
+	err := f.err(method)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	if f.capacity == 0 {
+		return nil
+	}
+
+	d, err := f.service.Dump(ctx, namespace)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	free := f.capacity - len(d.Used)
+	if free < 0 {
+		free = 0
+	}
+	if num > free {
+		return microerror.Mask(&rangepool.CapacityReachedError{Free: free, Max: max, Min: min, Namespace: namespace, Num: num})
+	}
+
+	return nil
+}
+
+// err returns the canned error of the given method.
+func (f *Fake) err(method string) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return f.errors[method]
+}
@@ -0,0 +1,74 @@
+package rangepooltest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/giantswarm/microerror"
+
+	"github.com/giantswarm/rangepool"
+)
+
+func Test_Fake(t *testing.T) {
+	config := DefaultFakeConfig()
+	config.Allocations = map[string]map[string][]int{
+		"test-namespace": {
+			"test-id-1": {2, 3},
+		},
+	}
+	config.Capacity = 3
+	f, err := NewFake(config)
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+
+	ctx := context.TODO()
+
+	// Pre-seeded allocations must be searchable.
+	{
+		items, err := f.Search(ctx, "test-namespace", "test-id-1")
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		if len(items) != 2 || items[0] != 2 || items[1] != 3 {
+			t.Fatal("expected", []int{2, 3}, "got", items)
+		}
+	}
+
+	// New allocations must continue after the pre-seeded ones.
+	{
+		items, err := f.Create(ctx, "test-namespace", "test-id-2", 1, 2, 10)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		if len(items) != 1 || items[0] != 4 {
+			t.Fatal("expected", []int{4}, "got", items)
+		}
+	}
+
+	// Allocations exceeding the capacity must fail, even though the range is
+	// not exhausted.
+	{
+		_, err := f.Create(ctx, "test-namespace", "test-id-3", 1, 2, 10)
+		if !rangepool.IsCapacityReached(err) {
+			t.Fatal("expected", true, "got", false)
+		}
+	}
+
+	// Canned errors must be returned until they are removed.
+	{
+		cannedError := &microerror.Error{Kind: "cannedError"}
+
+		f.SetError("Delete", cannedError)
+		err := f.Delete(ctx, "test-namespace", "test-id-1")
+		if microerror.Cause(err) != cannedError {
+			t.Fatal("expected", cannedError, "got", err)
+		}
+
+		f.SetError("Delete", nil)
+		err = f.Delete(ctx, "test-namespace", "test-id-1")
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+	}
+}
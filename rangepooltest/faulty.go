@@ -0,0 +1,239 @@
+package rangepooltest
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/giantswarm/microerror"
+
+	"github.com/giantswarm/rangepool"
+)
+
+// Fault describes a failure injected by FaultyStorage.
+type Fault struct {
+	// Call is the number of the matching call the fault is injected on,
+	// counted from 1. A call of 0 injects the fault on every matching call.
+	Call int
+	// Delay blocks the call for the given duration before it is executed or
+	// fails. In case the context of the call is done in the meantime, the
+	// error of the context is returned, which simulates timeouts.
+	Delay time.Duration
+	// Err is the error returned by the call. In case it is nil, faults without
+	// Delay return an error asserted by IsInjected, while faults with Delay
+	// only slow the call down.
+	Err error
+	// Method is the Storage method the fault is injected on, e.g. "Create".
+	// Calls of all methods match in case it is empty.
+	Method string
+	// Persist executes the call before the error is returned, simulating
+	// writes which succeeded although the storage reported a failure.
+	Persist bool
+}
+
+// FaultyStorageConfig represents the configuration used to create a new
+// faulty storage.
+type FaultyStorageConfig struct {
+	// Dependencies.
+
+	// Storage is the storage calls are passed to. It defaults to an in-memory
+	// storage.
+	Storage rangepool.Storage
+
+	// Settings.
+
+	// Faults are the failures injected from the start. See also
+	// FaultyStorage.AddFault.
+	Faults []Fault
+}
+
+// DefaultFaultyStorageConfig provides a default configuration to create a new
+// faulty storage by best effort.
+func DefaultFaultyStorageConfig() FaultyStorageConfig {
+	return FaultyStorageConfig{
+		// Dependencies.
+		Storage: nil,
+
+		// Settings.
+		Faults: nil,
+	}
+}
+
+// NewFaultyStorage creates a new faulty storage. It decorates a
+// rangepool.Storage and injects errors, timeouts and partial failures on
+// configured calls, so that consumers can verify their behaviour in case
+// storage operations fail halfway through. The faulty storage deliberately
+// does not implement rangepool.BatchStorage, so that the range pool writes
+// the keys of an allocation one by one and a fault on the Nth Create call
+// leaves a partially persisted allocation behind.
+func NewFaultyStorage(config FaultyStorageConfig) (*FaultyStorage, error) {
+	storage := config.Storage
+	if storage == nil {
+		storage = newMapStorage()
+	}
+
+	for _, f := range config.Faults {
+		if f.Call < 0 || f.Delay < 0 {
+			return nil, microerror.Maskf(invalidConfigError, "call and delay of faults must not be negative")
+		}
+	}
+
+	s := &FaultyStorage{
+		// Dependencies.
+		storage: storage,
+
+		// Internals.
+		calls:  map[string]int{},
+		faults: append([]Fault{}, config.Faults...),
+		mutex:  sync.Mutex{},
+	}
+
+	return s, nil
+}
+
+type FaultyStorage struct {
+	// Dependencies.
+	storage rangepool.Storage
+
+	// Internals.
+	calls  map[string]int
+	faults []Fault
+	mutex  sync.Mutex
+}
+
+var _ rangepool.Storage = &FaultyStorage{}
+
+// AddFault injects the given fault. Calls are counted from the creation of
+// the faulty storage, so Fault.Call must account for the calls executed so
+// far, see Calls.
+func (s *FaultyStorage) AddFault(f Fault) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.faults = append(s.faults, f)
+}
+
+// Calls returns the number of calls of the given method executed so far. In
+// case the method is empty, the calls of all methods are counted.
+func (s *FaultyStorage) Calls(method string) int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.calls[method]
+}
+
+// Reset removes all faults. Calls are still counted.
+func (s *FaultyStorage) Reset() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.faults = nil
+}
+
+func (s *FaultyStorage) Create(ctx context.Context, key, value string) error {
+	err := s.call(ctx, "Create", func() error {
+		return s.storage.Create(ctx, key, value)
+	})
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+func (s *FaultyStorage) Delete(ctx context.Context, key string) error {
+	err := s.call(ctx, "Delete", func() error {
+		return s.storage.Delete(ctx, key)
+	})
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+func (s *FaultyStorage) List(ctx context.Context, key string) ([]rangepool.KV, error) {
+	var kvs []rangepool.KV
+	err := s.call(ctx, "List", func() error {
+		var err error
+		kvs, err = s.storage.List(ctx, key)
+		return err
+	})
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return kvs, nil
+}
+
+func (s *FaultyStorage) Search(ctx context.Context, key string) (string, error) {
+	var v string
+	err := s.call(ctx, "Search", func() error {
+		var err error
+		v, err = s.storage.Search(ctx, key)
+		return err
+	})
+	if err != nil {
+		return "", microerror.Mask(err)
+	}
+
+	return v, nil
+}
+
+// call counts the call of the given method and executes o, unless a matching
+// fault is injected.
+func (s *FaultyStorage) call(ctx context.Context, method string, o func() error) error {
+	f, ok := s.match(method)
+	if !ok {
+		return o()
+	}
+
+	if f.Delay > 0 {
+		select {
+		case <-ctx.Done():
+			return microerror.Mask(ctx.Err())
+		case <-time.After(f.Delay):
+		}
+	}
+
+	if f.Err == nil && f.Delay > 0 {
+		return o()
+	}
+
+	if f.Persist {
+		err := o()
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	err := f.Err
+	if err == nil {
+		err = microerror.Maskf(injectedError, "%s call %d", method, f.Call)
+	}
+
+	return err
+}
+
+// match counts the call of the given method and returns the first fault
+// matching it.
+func (s *FaultyStorage) match(method string) (Fault, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.calls[""]++
+	s.calls[method]++
+
+	for _, f := range s.faults {
+		if f.Method != "" && f.Method != method {
+			continue
+		}
+		if f.Call != 0 && f.Call != s.calls[f.Method] {
+			continue
+		}
+
+		return f, true
+	}
+
+	return Fault{}, false
+}
@@ -0,0 +1,88 @@
+package rangepooltest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/giantswarm/rangepool"
+)
+
+func Test_RunStorageConformance_FaultyStorage(t *testing.T) {
+	RunStorageConformance(t, func(t *testing.T) rangepool.Storage {
+		newStorage, err := NewFaultyStorage(DefaultFaultyStorageConfig())
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		return newStorage
+	})
+}
+
+func Test_FaultyStorage_Create(t *testing.T) {
+	var err error
+
+	var newStorage *FaultyStorage
+	{
+		c := DefaultFaultyStorageConfig()
+		c.Faults = []Fault{
+			{Call: 3, Method: "Create"},
+		}
+		newStorage, err = NewFaultyStorage(c)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+	}
+
+	var newService *rangepool.Service
+	{
+		newService, err = rangepool.NewWithOptions(newStorage)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+	}
+
+	ctx := context.TODO()
+
+	// The schema marker of the namespace is written first, so the third call
+	// fails on the second key of the allocation, which must fail halfway
+	// through.
+	{
+		_, err := newService.Create(ctx, "test-namespace", "test-id", 2, 1, 5)
+		if !IsInjected(err) {
+			t.Fatal("expected", true, "got", false)
+		}
+
+		_, err = newStorage.Search(ctx, "range-pool/test-namespace/item/1")
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		_, err = newStorage.Search(ctx, "range-pool/test-namespace/id/test-id/item/1")
+		if !rangepool.IsNotFound(err) {
+			t.Fatal("expected", true, "got", false)
+		}
+	}
+
+	// Delayed calls must time out once the context is done.
+	{
+		newStorage.AddFault(Fault{Delay: time.Minute, Method: "Search"})
+
+		ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+
+		_, err := newStorage.Search(ctx, "range-pool/test-namespace/latest")
+		if err == nil {
+			t.Fatal("expected", "error", "got", nil)
+		}
+	}
+
+	// Removing the faults must make all calls succeed again.
+	{
+		newStorage.Reset()
+
+		_, err := newService.Create(ctx, "test-namespace", "test-id", 2, 1, 5)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package rangepooltest
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/giantswarm/microerror"
+
+	"github.com/giantswarm/rangepool"
+)
+
+// mapStorage is a minimal rangepool.Storage keeping all keys in a map. It is
+// used by Fake.
+type mapStorage struct {
+	data  map[string]string
+	mutex sync.RWMutex
+}
+
+func newMapStorage() *mapStorage {
+	s := &mapStorage{
+		data:  map[string]string{},
+		mutex: sync.RWMutex{},
+	}
+
+	return s
+}
+
+func (s *mapStorage) Create(ctx context.Context, key, value string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.data[key] = value
+
+	return nil
+}
+
+func (s *mapStorage) Delete(ctx context.Context, key string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delete(s.data, key)
+
+	return nil
+}
+
+func (s *mapStorage) List(ctx context.Context, key string) ([]rangepool.KV, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	prefix := strings.TrimSuffix(key, "/") + "/"
+
+	var list []rangepool.KV
+	for k, v := range s.data {
+		if !strings.HasPrefix(k, prefix) || len(k) == len(prefix) {
+			continue
+		}
+
+		list = append(list, rangepool.KV{Key: k[len(prefix):], Value: v})
+	}
+
+	return list, nil
+}
+
+func (s *mapStorage) Search(ctx context.Context, key string) (string, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	v, ok := s.data[key]
+	if !ok {
+		return "", microerror.Maskf(rangepool.NotFoundError, "%s", key)
+	}
+
+	return v, nil
+}
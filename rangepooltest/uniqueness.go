@@ -0,0 +1,170 @@
+package rangepooltest
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/giantswarm/rangepool"
+)
+
+// UniquenessConfig represents the configuration used by RunUniqueness.
+type UniquenessConfig struct {
+	// IDs is the number of IDs competing for the items of the range.
+	IDs int
+	// Max is the max boundary of the range, inclusive.
+	Max int
+	// Min is the min boundary of the range, inclusive.
+	Min int
+	// Namespace is the namespace the items are allocated in. It must be empty
+	// when RunUniqueness is called.
+	Namespace string
+	// Operations is the number of randomly chosen operations executed.
+	Operations int
+	// Seed seeds the random choice of operations, so that failures can be
+	// reproduced.
+	Seed int64
+}
+
+// DefaultUniquenessConfig provides a default configuration for RunUniqueness
+// by best effort. The range is kept small compared to the number of IDs, so
+// that it is exhausted and refilled frequently.
+func DefaultUniquenessConfig() UniquenessConfig {
+	return UniquenessConfig{
+		IDs:        16,
+		Max:        63,
+		Min:        0,
+		Namespace:  "uniqueness",
+		Operations: 2000,
+		Seed:       1,
+	}
+}
+
+// RunUniqueness executes randomly interleaved Create, Delete and ForceRelease
+// calls against the given range pool and asserts after every call that no
+// item is ever owned by two IDs at the same time, that all items stay within
+// the range and that the items of every ID match the ones handed out to it.
+// Exhausted ranges are expected and tolerated. Any other error fails the
+// test.
+//
+//     func Test_Service_Uniqueness(t *testing.T) {
+//         rangepooltest.RunUniqueness(t, newService(t), rangepooltest.DefaultUniquenessConfig())
+//     }
+//
+func RunUniqueness(t *testing.T, service rangepool.Interface, config UniquenessConfig) {
+	t.Helper()
+
+	ctx := context.TODO()
+	r := rand.New(rand.NewSource(config.Seed))
+
+	var IDs []string
+	for i := 0; i < config.IDs; i++ {
+		IDs = append(IDs, fmt.Sprintf("id-%d", i))
+	}
+
+	// owned tracks the items expected to be owned by every ID.
+	owned := map[string][]int{}
+
+	for op := 0; op < config.Operations; op++ {
+		ID := IDs[r.Intn(len(IDs))]
+
+		switch n := r.Intn(10); {
+		case n < 6:
+			num := r.Intn(4) + 1
+			items, err := service.Create(ctx, config.Namespace, ID, num, config.Min, config.Max)
+			if rangepool.IsCapacityReached(err) {
+				break
+			} else if err != nil {
+				t.Fatal("operation", op, "expected", nil, "got", err)
+			}
+			if len(items) != num {
+				t.Fatal("operation", op, "expected", num, "items", "got", items)
+			}
+			for _, item := range items {
+				if item < config.Min || item > config.Max {
+					t.Fatal("operation", op, "expected", "item in between", config.Min, "and", config.Max, "got", item)
+				}
+				if owner, ok := ownerOf(owned, item); ok {
+					t.Fatal("operation", op, "expected", "no owner of item", item, "got", owner)
+				}
+				owned[ID] = append(owned[ID], item)
+			}
+		case n < 9:
+			err := service.Delete(ctx, config.Namespace, ID)
+			if err != nil {
+				t.Fatal("operation", op, "expected", nil, "got", err)
+			}
+			delete(owned, ID)
+		default:
+			if len(owned[ID]) == 0 {
+				break
+			}
+			item := owned[ID][r.Intn(len(owned[ID]))]
+			err := service.ForceRelease(ctx, config.Namespace, item)
+			if err != nil {
+				t.Fatal("operation", op, "expected", nil, "got", err)
+			}
+			owned[ID] = removeItem(owned[ID], item)
+		}
+
+		assertUniqueness(t, service, config.Namespace, IDs, owned, op)
+	}
+}
+
+// assertUniqueness ensures the items persisted for every ID match the
+// expected ones and are not owned by any other ID.
+func assertUniqueness(t *testing.T, service rangepool.Interface, namespace string, IDs []string, owned map[string][]int, op int) {
+	t.Helper()
+
+	owners := map[int]string{}
+	for _, ID := range IDs {
+		items, err := service.Search(context.TODO(), namespace, ID)
+		if rangepool.IsItemsNotFound(err) {
+			items = nil
+		} else if err != nil {
+			t.Fatal("operation", op, "expected", nil, "got", err)
+		}
+
+		expected := append([]int{}, owned[ID]...)
+		sort.Ints(expected)
+		if len(expected) == 0 {
+			expected = nil
+		}
+		if !reflect.DeepEqual(items, expected) {
+			t.Fatal("operation", op, "ID", ID, "expected", expected, "got", items)
+		}
+
+		for _, item := range items {
+			if owner, ok := owners[item]; ok {
+				t.Fatal("operation", op, "expected", "single owner of item", item, "got", []string{owner, ID})
+			}
+			owners[item] = ID
+		}
+	}
+}
+
+func ownerOf(owned map[string][]int, item int) (string, bool) {
+	for ID, items := range owned {
+		for _, i := range items {
+			if i == item {
+				return ID, true
+			}
+		}
+	}
+
+	return "", false
+}
+
+func removeItem(items []int, item int) []int {
+	var l []int
+	for _, i := range items {
+		if i != item {
+			l = append(l, i)
+		}
+	}
+
+	return l
+}
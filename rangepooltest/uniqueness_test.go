@@ -0,0 +1,65 @@
+package rangepooltest
+
+import (
+	"testing"
+
+	"github.com/giantswarm/micrologger/microloggertest"
+
+	"github.com/giantswarm/rangepool"
+)
+
+func Test_RunUniqueness_Service(t *testing.T) {
+	testCases := []struct {
+		Name   string
+		Config func(c rangepool.Config) rangepool.Config
+	}{
+		// Case 0 ensures the default configuration hands out unique items.
+		{
+			Name: "Default",
+			Config: func(c rangepool.Config) rangepool.Config {
+				return c
+			},
+		},
+		// Case 1 ensures items persisted as bitmap are unique.
+		{
+			Name: "Bitmap",
+			Config: func(c rangepool.Config) rangepool.Config {
+				c.Bitmap = true
+				return c
+			},
+		},
+		// Case 2 ensures sticky items are unique.
+		{
+			Name: "Sticky",
+			Config: func(c rangepool.Config) rangepool.Config {
+				c.Sticky = true
+				return c
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			c := rangepool.DefaultConfig()
+			c.Logger = microloggertest.New()
+			c.Storage = newMapStorage()
+			service, err := rangepool.New(tc.Config(c))
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+
+			RunUniqueness(t, service, DefaultUniquenessConfig())
+		})
+	}
+}
+
+func Test_RunUniqueness_Fake(t *testing.T) {
+	c := DefaultFakeConfig()
+	c.Capacity = 32
+	fake, err := NewFake(c)
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+
+	RunUniqueness(t, fake, DefaultUniquenessConfig())
+}
@@ -0,0 +1,70 @@
+package rangepool
+
+import (
+	"context"
+
+	"github.com/giantswarm/microerror"
+)
+
+// Range is the canonical range of a namespace, see Config.Ranges.
+type Range struct {
+	// Max is the max boundary of the range, inclusive.
+	Max int
+	// Min is the min boundary of the range, inclusive.
+	Min int
+}
+
+// CreateDefault works like Create, but allocates num items within the range
+// configured for the namespace using Config.Ranges. In case the ID belongs to
+// an ID class, see Policy.IDClasses, the range is narrowed to the sub-range of
+// the class. In case no range is configured for the namespace, an error is
+// returned which can be asserted using IsRangeNotFound.
+func (s *Service) CreateDefault(ctx context.Context, namespace, ID string, num int) ([]int, error) {
+	ctx = withOperation(ctx, "CreateDefault", namespace, ID)
+
+	r, ok := s.ranges[namespace]
+	if !ok {
+		return nil, microerror.Maskf(rangeNotFoundError, "range of namespace '%s'", namespace)
+	}
+
+	p, err := s.withPolicy(ctx, namespace)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	min, max := r.Min, r.Max
+	c, ok := matchIDClass(p.idClasses, ID)
+	if ok {
+		if c.Min > min {
+			min = c.Min
+		}
+		if c.Max < max {
+			max = c.Max
+		}
+	}
+
+	items, err := s.Create(ctx, namespace, ID, num, min, max)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return items, nil
+}
+
+// checkRange ensures that allocations within namespaces having a range
+// configured, see Config.Ranges, do not reach beyond the range. Narrower
+// boundaries are accepted, since sub-pools and ID classes allocate within
+// sub-ranges. Otherwise an error is returned which can be asserted using
+// IsRangeConflict.
+func (s *Service) checkRange(namespace string, min, max int) error {
+	r, ok := s.ranges[namespace]
+	if !ok {
+		return nil
+	}
+
+	if min < r.Min || max > r.Max {
+		return microerror.Maskf(rangeConflictError, "namespace '%s' must allocate within %d-%d, got %d-%d", namespace, r.Min, r.Max, min, max)
+	}
+
+	return nil
+}
@@ -0,0 +1,127 @@
+package rangepool
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func Test_Service_Ranges(t *testing.T) {
+	newStorage, err := newMemoryStorage()
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+
+	newService, err := NewWithOptions(newStorage, WithRanges(map[string]Range{namespace: {Min: 10, Max: 20}, "test-namespace-2": {Min: 10, Max: 20}}))
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+
+	ctx := context.TODO()
+
+	// Allocations must use the configured range.
+	{
+		items, err := newService.CreateDefault(ctx, namespace, "test-id-1", 2)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		expected := []int{10, 11}
+		if !reflect.DeepEqual(items, expected) {
+			t.Fatal("expected", expected, "got", items)
+		}
+	}
+
+	// Explicit boundaries within the configured range must be accepted.
+	{
+		items, err := newService.Create(ctx, namespace, "test-id-2", 1, 10, 15)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		expected := []int{12}
+		if !reflect.DeepEqual(items, expected) {
+			t.Fatal("expected", expected, "got", items)
+		}
+	}
+
+	// Explicit boundaries reaching beyond the configured range must be
+	// refused.
+	{
+		_, err := newService.Create(ctx, namespace, "test-id-3", 1, 1, 20)
+		if !IsRangeConflict(err) {
+			t.Fatal("expected", true, "got", err)
+		}
+		_, err = newService.Adopt(ctx, namespace, "test-id-3", []int{21}, 10, 30)
+		if !IsRangeConflict(err) {
+			t.Fatal("expected", true, "got", err)
+		}
+	}
+
+	// ID classes must narrow the configured range.
+	{
+		err := newService.SetPolicy(ctx, "test-namespace-2", Policy{IDClasses: []IDClass{{Prefix: "tenant-", Min: 18, Max: 20}}})
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		items, err := newService.CreateDefault(ctx, "test-namespace-2", "tenant-1", 1)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		expected := []int{18}
+		if !reflect.DeepEqual(items, expected) {
+			t.Fatal("expected", expected, "got", items)
+		}
+	}
+
+	// Namespaces without configured range must be refused.
+	{
+		_, err := newService.CreateDefault(ctx, "other-namespace", "test-id-1", 1)
+		if !IsRangeNotFound(err) {
+			t.Fatal("expected", true, "got", err)
+		}
+	}
+}
+
+func Test_New_Ranges(t *testing.T) {
+	newStorage, err := newMemoryStorage()
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+
+	testCases := []struct {
+		Range        Range
+		ErrorMatcher func(err error) bool
+	}{
+		// Case 1 ensures valid ranges are accepted.
+		{
+			Range:        Range{Min: 10, Max: 20},
+			ErrorMatcher: nil,
+		},
+		// Case 2 ensures ranges of a single item are refused, since Create
+		// refuses them as well.
+		{
+			Range:        Range{Min: 10, Max: 10},
+			ErrorMatcher: IsInvalidConfig,
+		},
+		// Case 3 ensures ranges with min above max are refused.
+		{
+			Range:        Range{Min: 20, Max: 10},
+			ErrorMatcher: IsInvalidConfig,
+		},
+		// Case 4 ensures ranges with a negative min are refused.
+		{
+			Range:        Range{Min: -1, Max: 10},
+			ErrorMatcher: IsInvalidConfig,
+		},
+	}
+
+	for i, tc := range testCases {
+		_, err := NewWithOptions(newStorage, WithRanges(map[string]Range{namespace: tc.Range}))
+		if err != nil && tc.ErrorMatcher == nil {
+			t.Fatal("case", i+1, "expected", nil, "got", err)
+		}
+		if tc.ErrorMatcher != nil && !tc.ErrorMatcher(err) {
+			t.Fatal("case", i+1, "expected", true, "got", false)
+		}
+	}
+}
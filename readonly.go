@@ -0,0 +1,13 @@
+package rangepool
+
+import "github.com/giantswarm/microerror"
+
+// checkReadOnly fails with an error which can be asserted using IsReadOnly in
+// case the Service is read-only, see Config.ReadOnly.
+func (s *Service) checkReadOnly() error {
+	if s.readOnly {
+		return microerror.Maskf(readOnlyError, "service is read-only")
+	}
+
+	return nil
+}
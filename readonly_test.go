@@ -0,0 +1,95 @@
+package rangepool
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/giantswarm/micrologger/microloggertest"
+)
+
+func Test_Service_ReadOnly(t *testing.T) {
+	newStorage, err := newMemoryStorage()
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+
+	ctx := context.TODO()
+
+	{
+		s, err := NewWithOptions(newStorage, WithLogger(microloggertest.New()))
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		_, err = s.Create(ctx, namespace, "test-id-1", 2, 2, 10)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+	}
+
+	s, err := NewWithOptions(newStorage, WithLogger(microloggertest.New()), WithReadOnly(true))
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+
+	// Reading operations must work as usual.
+	{
+		items, err := s.Search(ctx, namespace, "test-id-1")
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		expected := []int{2, 3}
+		if !reflect.DeepEqual(items, expected) {
+			t.Fatal("expected", expected, "got", items)
+		}
+
+		st, err := s.Status(ctx, namespace, 2, 10)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		if st.Used != 2 {
+			t.Fatal("expected", 2, "got", st.Used)
+		}
+
+		_, err = s.GC(ctx, namespace, true)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+	}
+
+	// Modifying operations must be refused.
+	{
+		_, err := s.Create(ctx, namespace, "test-id-2", 1, 2, 10)
+		if !IsReadOnly(err) {
+			t.Fatal("expected", true, "got", false)
+		}
+
+		err = s.Delete(ctx, namespace, "test-id-1")
+		if !IsReadOnly(err) {
+			t.Fatal("expected", true, "got", false)
+		}
+
+		err = s.SetPolicy(ctx, namespace, Policy{IDQuota: 1})
+		if !IsReadOnly(err) {
+			t.Fatal("expected", true, "got", false)
+		}
+
+		_, err = s.GC(ctx, namespace, false)
+		if !IsReadOnly(err) {
+			t.Fatal("expected", true, "got", false)
+		}
+	}
+
+	// Refused operations must not have modified the storage.
+	{
+		items, err := s.Search(ctx, namespace, "test-id-1")
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		expected := []int{2, 3}
+		if !reflect.DeepEqual(items, expected) {
+			t.Fatal("expected", expected, "got", items)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package rangepool
+
+import (
+	"context"
+	"sync"
+)
+
+// ReadVersions records the versions of the storage objects read during a
+// single allocation, e.g. the resource versions of Kubernetes objects holding
+// the keys of a namespace. Storages persisting keys in versioned objects record
+// the version of every object the first time it is read and refuse writes to
+// objects which changed since with ConflictError, see
+// ReadVersionsFromContext. That way the version of the listing an allocation
+// is decided upon is carried into the writes persisting it, and concurrent
+// processes cannot allocate the same items. Reads served by the caches of the
+// Service, e.g. see Config.CacheTTL, are not recorded and therefore not
+// guarded.
+type ReadVersions struct {
+	mutex    sync.Mutex
+	versions map[string]string
+}
+
+// NewReadVersions creates a new empty record of read versions.
+func NewReadVersions() *ReadVersions {
+	return &ReadVersions{
+		versions: map[string]string{},
+	}
+}
+
+// Record records the given version of the given object, unless a version of
+// the object has been recorded already.
+func (r *ReadVersions) Record(object, version string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	_, ok := r.versions[object]
+	if !ok {
+		r.versions[object] = version
+	}
+}
+
+// Update records the given version of the given object, e.g. after it has
+// been written, so that later writes of the same allocation do not conflict
+// with the earlier ones.
+func (r *ReadVersions) Update(object, version string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.versions[object] = version
+}
+
+// Version returns the version recorded for the given object and whether one
+// has been recorded.
+func (r *ReadVersions) Version(object string) (string, bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	v, ok := r.versions[object]
+
+	return v, ok
+}
+
+// merge records all versions recorded by the given record, see Record.
+func (r *ReadVersions) merge(o *ReadVersions) {
+	o.mutex.Lock()
+	versions := make(map[string]string, len(o.versions))
+	for k, v := range o.versions {
+		versions[k] = v
+	}
+	o.mutex.Unlock()
+
+	for k, v := range versions {
+		r.Record(k, v)
+	}
+}
+
+type readVersionsContextKey struct{}
+
+// NewReadVersionsContext returns a new context carrying the given record of
+// read versions.
+func NewReadVersionsContext(ctx context.Context, r *ReadVersions) context.Context {
+	return context.WithValue(ctx, readVersionsContextKey{}, r)
+}
+
+// ReadVersionsFromContext returns the record of read versions carried by the
+// given context, see NewReadVersionsContext, and whether there is one.
+func ReadVersionsFromContext(ctx context.Context) (*ReadVersions, bool) {
+	r, ok := ctx.Value(readVersionsContextKey{}).(*ReadVersions)
+	return r, ok && r != nil
+}
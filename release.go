@@ -0,0 +1,163 @@
+package rangepool
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/giantswarm/microerror"
+)
+
+// ForceRelease frees the given item within the given namespace, no matter
+// which ID owns it. The item is removed from the items of its owners as well.
+// It is meant for recovering from leaked allocations when the owning workload
+// is long gone. In case the item is neither used nor owned by any ID, an error
+// is returned which can be asserted using IsItemsNotFound.
+func (s *Service) ForceRelease(ctx context.Context, namespace string, item int) error {
+	ctx = withOperation(ctx, "ForceRelease", namespace, "")
+
+	err := s.checkReadOnly()
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = s.checkLeader()
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	s, err = s.withPolicy(ctx, namespace)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = s.ensureSchema(ctx, namespace)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	// Collect the ID keys of the item and the number of items of its owners.
+	var keys []string
+	var owners []string
+	counts := map[string]int{}
+	{
+		prefix := s.key(IDPrefixKeyFormat, namespace)
+		err := walk(ctx, s.storage, prefix, func(kv KV) error {
+			// The keys are relative to the ID prefix, e.g. ${id1}/item/${item1}.
+			i := strings.LastIndex(kv.Key, "/item/")
+			if i == -1 {
+				return nil
+			}
+			ID := kv.Key[:i]
+			counts[ID]++
+
+			v, err := strconv.Atoi(kv.Value)
+			if err != nil {
+				return microerror.Mask(err)
+			}
+			if v == item {
+				keys = append(keys, prefix+"/"+kv.Key)
+				owners = append(owners, ID)
+			}
+
+			return nil
+		})
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+	sort.Strings(owners)
+
+	// Free the item. The item key is looked up as persisted, since its
+	// encoding may differ from the configured one, see Config.ZeroPaddedKeys.
+	var used bool
+	var empty bool
+	if s.bitmap {
+		b, err := s.searchBitmap(ctx, namespace)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
+		used = b.IsSet(item)
+		if used {
+			b.Unset(item)
+			empty = b.Len() == 0
+
+			if empty {
+				keys = append(keys, s.key(BitmapKeyFormat, namespace))
+			} else {
+				err = s.storage.Create(ctx, s.key(BitmapKeyFormat, namespace), s.encodeBitmap(b))
+				if err != nil {
+					return microerror.Mask(err)
+				}
+			}
+		}
+	} else {
+		prefix := s.key(ItemListKeyFormat, namespace)
+		var n int
+		err := walk(ctx, s.storage, prefix, func(kv KV) error {
+			n++
+			if kv.Value == strconv.Itoa(item) {
+				keys = append(keys, prefix+"/"+kv.Key)
+				used = true
+			}
+
+			return nil
+		})
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
+		empty = used && n == 1
+		if empty {
+			keys = append(keys, prefix)
+		}
+	}
+
+	if !used && len(owners) == 0 {
+		return microerror.Maskf(itemsNotFoundError, "item %d is not used in namespace '%s'", item, namespace)
+	}
+
+	for _, ID := range owners {
+		if counts[ID] == 1 {
+			keys = append(keys, s.key(IDListKeyFormat, namespace, ID))
+		}
+	}
+	if empty && s.latestMode == LatestModeResetOnEmpty {
+		keys = append(keys, s.latestKeys(namespace)...)
+	}
+
+	err = deleteBatch(ctx, s.storage, keys)
+	if err != nil {
+		s.cache.Invalidate(namespace)
+		return microerror.Mask(err)
+	}
+	s.cache.Remove(namespace, []int{item})
+
+	for _, ID := range owners {
+		err := s.recordAudit(ctx, AuditActionRelease, namespace, ID, []int{item})
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
+		err = s.recordHistory(ctx, namespace, ID, []int{item})
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	err = s.recordFreed(ctx, namespace, []int{item})
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	s.logger.LogCtx(ctx, "level", "warning", "message", fmt.Sprintf("force released item %d of namespace '%s' owned by IDs %v", item, namespace, owners))
+
+	for _, ID := range owners {
+		s.notify(ctx, EventTypeReleased, namespace, ID, []int{item})
+	}
+
+	return nil
+}
@@ -0,0 +1,136 @@
+package rangepool
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/giantswarm/micrologger/microloggertest"
+)
+
+func Test_Service_ForceRelease(t *testing.T) {
+	for _, b := range []bool{false, true} {
+		var err error
+		var newService *Service
+		{
+			newStorage, err := newMemoryStorage()
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+
+			config := DefaultConfig()
+			config.Logger = microloggertest.New()
+			config.Storage = newStorage
+			config.Audit = true
+			config.Bitmap = b
+			config.LatestMode = LatestModeResetOnEmpty
+			newService, err = New(config)
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+		}
+
+		ctx := context.TODO()
+
+		_, err = newService.Create(ctx, namespace, "test-id-1", 2, 2, 10)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		_, err = newService.Create(ctx, namespace, "test-id-2", 1, 2, 10)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		// Releasing an item must remove it from its owner.
+		{
+			err = newService.ForceRelease(ctx, namespace, 3)
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+
+			d, err := newService.Dump(ctx, namespace)
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+			expected := Dump{
+				IDs: map[string][]int{
+					"test-id-1": {2},
+					"test-id-2": {4},
+				},
+				Latest:    4,
+				Namespace: namespace,
+				Used:      []int{2, 4},
+			}
+			if !reflect.DeepEqual(d, expected) {
+				t.Fatal("expected", expected, "got", d)
+			}
+		}
+
+		// Releasing an item owned by multiple IDs must remove it from all of
+		// them.
+		{
+			err = newService.storage.Create(ctx, fmt.Sprintf(IDKeyFormat, namespace, "test-id-3", "4"), "4")
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+
+			err = newService.ForceRelease(ctx, namespace, 4)
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+
+			d, err := newService.Dump(ctx, namespace)
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+			expected := map[string][]int{"test-id-1": {2}}
+			if !reflect.DeepEqual(d.IDs, expected) {
+				t.Fatal("expected", expected, "got", d.IDs)
+			}
+		}
+
+		// Releasing the last item must reset the latest item.
+		{
+			err = newService.ForceRelease(ctx, namespace, 2)
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+
+			d, err := newService.Dump(ctx, namespace)
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+			expected := Dump{IDs: map[string][]int{}, Latest: -1, Namespace: namespace}
+			if !reflect.DeepEqual(d, expected) {
+				t.Fatal("expected", expected, "got", d)
+			}
+		}
+
+		// Every release must be audited.
+		{
+			entries, err := newService.AuditLog(ctx, namespace, time.Time{})
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+			var releases int
+			for _, e := range entries {
+				if e.Action == AuditActionRelease {
+					releases++
+				}
+			}
+			if releases != 4 {
+				t.Fatal("expected", 4, "got", releases)
+			}
+		}
+
+		// Releasing an unused item must fail.
+		{
+			err = newService.ForceRelease(ctx, namespace, 7)
+			if !IsItemsNotFound(err) {
+				t.Fatal("expected", true, "got", false)
+			}
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package rangepool
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/giantswarm/microerror"
+)
+
+// RenameID hands all items of oldID within the given namespace over to newID,
+// e.g. when cluster identifiers change during migrations. The items stay used
+// the whole time. The keys of newID are written in a single batch before the
+// keys of oldID are removed, see BatchStorage, so that the items are never
+// left without owner. In case oldID does not own any items, an error is
+// returned which can be asserted using IsItemsNotFound. In case newID owns
+// items already, an error is returned which can be asserted using
+// IsInvalidArgument. newID is checked like by Create, see checkIntoID.
+func (s *Service) RenameID(ctx context.Context, namespace, oldID, newID string) error {
+	ctx = withOperation(ctx, "RenameID", namespace, oldID)
+
+	err := s.checkReadOnly()
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = s.checkLeader()
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	s, err = s.withPolicy(ctx, namespace)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = s.ensureSchema(ctx, namespace)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	if newID == "" || newID == oldID {
+		return microerror.Maskf(invalidArgumentError, "new ID must not be empty and must differ from old ID")
+	}
+
+	items, err := s.searchItems(ctx, s.key(IDListKeyFormat, namespace, oldID))
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	if len(items) == 0 {
+		return microerror.Maskf(itemsNotFoundError, "no items in namespace '%s' for ID '%s'", namespace, oldID)
+	}
+
+	{
+		l, err := s.searchItems(ctx, s.key(IDListKeyFormat, namespace, newID))
+		if err != nil {
+			return microerror.Mask(err)
+		}
+		if len(l) != 0 {
+			return microerror.Maskf(invalidArgumentError, "ID '%s' owns items in namespace '%s' already", newID, namespace)
+		}
+	}
+
+	err = s.checkIntoID(ctx, namespace, newID, items)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = s.moveItems(ctx, namespace, oldID, newID, items)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// MergeIDs hands all items of fromID within the given namespace over to
+// intoID, which keeps its own items as well, e.g. when workloads are merged.
+// Like RenameID, the items stay used the whole time. In case fromID does not
+// own any items, an error is returned which can be asserted using
+// IsItemsNotFound. intoID is checked like by Create, see checkIntoID.
+func (s *Service) MergeIDs(ctx context.Context, namespace, fromID, intoID string) error {
+	ctx = withOperation(ctx, "MergeIDs", namespace, fromID)
+
+	err := s.checkReadOnly()
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = s.checkLeader()
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	s, err = s.withPolicy(ctx, namespace)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = s.ensureSchema(ctx, namespace)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	if intoID == "" || intoID == fromID {
+		return microerror.Maskf(invalidArgumentError, "ID to merge into must not be empty and must differ from ID to merge from")
+	}
+
+	items, err := s.searchItems(ctx, s.key(IDListKeyFormat, namespace, fromID))
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	if len(items) == 0 {
+		return microerror.Maskf(itemsNotFoundError, "no items in namespace '%s' for ID '%s'", namespace, fromID)
+	}
+
+	err = s.checkIntoID(ctx, namespace, intoID, items)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = s.moveItems(ctx, namespace, fromID, intoID, items)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// checkIntoID applies the checks of Create to the ID the given items are
+// handed over to. The items must be within the sub-range of the class of the
+// ID, see Policy.IDClasses, the ID must not exceed its quota, see
+// Config.IDQuota, and its revision must match the one carried by the context,
+// see NewRevisionContext. Otherwise errors are returned which can be asserted
+// using IsIDClassViolation, IsQuotaExceeded and IsRevisionConflict.
+func (s *Service) checkIntoID(ctx context.Context, namespace, ID string, items []int) error {
+	min, max := items[0], items[0]
+	for _, item := range items {
+		if item < min {
+			min = item
+		}
+		if item > max {
+			max = item
+		}
+	}
+
+	err := s.checkIDClass(namespace, ID, min, max)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = s.checkIDQuota(ctx, namespace, ID, len(items), true)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = s.checkRevision(ctx, namespace, ID)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// moveItems hands the given items of fromID over to intoID. In case the
+// storage implements AtomicStorage, the keys of intoID are written and the keys
+// of fromID are removed atomically. Otherwise the keys of intoID are written
+// first, so that a failure in between leaves the items owned by both IDs, but
+// never without owner.
+func (s *Service) moveItems(ctx context.Context, namespace, fromID, intoID string, items []int) error {
+	var kvs []KV
+	var keys []string
+	for _, item := range items {
+		k := s.encodeItem(item)
+		kvs = append(kvs, KV{Key: s.key(IDKeyFormat, namespace, intoID, k), Value: strconv.Itoa(item)})
+		keys = append(keys, s.key(IDKeyFormat, namespace, fromID, k))
+	}
+
+	kv, ok, err := s.auditKV(ctx, AuditActionAllocate, namespace, intoID, items)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	if ok {
+		kvs = append(kvs, kv)
+	}
+	kv, ok, err = s.auditKV(ctx, AuditActionRelease, namespace, fromID, items)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	if ok {
+		kvs = append(kvs, kv)
+	}
+
+	err = apply(ctx, s.storage, kvs, keys)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	s.notify(ctx, EventTypeReleased, namespace, fromID, items)
+	s.notify(ctx, EventTypeAllocated, namespace, intoID, items)
+
+	return nil
+}
@@ -0,0 +1,274 @@
+package rangepool
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/giantswarm/micrologger/microloggertest"
+)
+
+func Test_Service_RenameID(t *testing.T) {
+	for _, b := range []bool{false, true} {
+		var newService *Service
+		{
+			newStorage, err := newMemoryStorage()
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+
+			config := DefaultConfig()
+			config.Logger = microloggertest.New()
+			config.Storage = newStorage
+			config.Bitmap = b
+			newService, err = New(config)
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+		}
+
+		ctx := context.TODO()
+
+		_, err := newService.Create(ctx, namespace, "test-id-1", 2, 2, 10)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		_, err = newService.Create(ctx, namespace, "test-id-2", 1, 2, 10)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		// Renaming to an ID owning items must fail.
+		{
+			err := newService.RenameID(ctx, namespace, "test-id-1", "test-id-2")
+			if !IsInvalidArgument(err) {
+				t.Fatal("expected", true, "got", false)
+			}
+		}
+
+		// Renaming an ID without items must fail.
+		{
+			err := newService.RenameID(ctx, namespace, "test-id-3", "test-id-4")
+			if !IsItemsNotFound(err) {
+				t.Fatal("expected", true, "got", false)
+			}
+		}
+
+		// The items must move to the new ID and stay used.
+		{
+			err := newService.RenameID(ctx, namespace, "test-id-1", "test-id-3")
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+
+			_, err = newService.Search(ctx, namespace, "test-id-1")
+			if !IsItemsNotFound(err) {
+				t.Fatal("expected", true, "got", false)
+			}
+
+			items, err := newService.Search(ctx, namespace, "test-id-3")
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+			expected := []int{2, 3}
+			if !reflect.DeepEqual(items, expected) {
+				t.Fatal("expected", expected, "got", items)
+			}
+
+			d, err := newService.Dump(ctx, namespace)
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+			expected = []int{2, 3, 4}
+			if !reflect.DeepEqual(d.Used, expected) {
+				t.Fatal("expected", expected, "got", d.Used)
+			}
+		}
+
+		// The new ID must be able to release the items.
+		{
+			err := newService.Delete(ctx, namespace, "test-id-3")
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+
+			d, err := newService.Dump(ctx, namespace)
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+			expected := []int{4}
+			if !reflect.DeepEqual(d.Used, expected) {
+				t.Fatal("expected", expected, "got", d.Used)
+			}
+		}
+	}
+}
+
+func Test_Service_MergeIDs(t *testing.T) {
+	var newService *Service
+	{
+		newStorage, err := newMemoryStorage()
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		config := DefaultConfig()
+		config.Logger = microloggertest.New()
+		config.Storage = newStorage
+		config.IDQuota = 4
+		newService, err = New(config)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+	}
+
+	ctx := context.TODO()
+
+	for _, c := range []struct {
+		ID  string
+		Num int
+	}{{"test-id-1", 2}, {"test-id-2", 1}, {"test-id-3", 2}} {
+		_, err := newService.Create(ctx, namespace, c.ID, c.Num, 2, 10)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+	}
+
+	// Both item sets must be owned by the ID merged into.
+	{
+		err := newService.MergeIDs(ctx, namespace, "test-id-1", "test-id-2")
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		_, err = newService.Search(ctx, namespace, "test-id-1")
+		if !IsItemsNotFound(err) {
+			t.Fatal("expected", true, "got", false)
+		}
+
+		items, err := newService.Search(ctx, namespace, "test-id-2")
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		expected := []int{2, 3, 4}
+		if !reflect.DeepEqual(items, expected) {
+			t.Fatal("expected", expected, "got", items)
+		}
+	}
+
+	// Merges must not exceed the quota of the ID merged into.
+	{
+		err := newService.MergeIDs(ctx, namespace, "test-id-3", "test-id-2")
+		if !IsQuotaExceeded(err) {
+			t.Fatal("expected", true, "got", false)
+		}
+	}
+
+	// Merging an ID without items must fail.
+	{
+		err := newService.MergeIDs(ctx, namespace, "test-id-1", "test-id-2")
+		if !IsItemsNotFound(err) {
+			t.Fatal("expected", true, "got", false)
+		}
+	}
+}
+
+func Test_Service_RenameID_Checks(t *testing.T) {
+	var err error
+	var newStorage *testAtomicStorage
+	var newService *Service
+	{
+		s, err := newMemoryStorage()
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		newStorage = &testAtomicStorage{Storage: s}
+
+		config := DefaultConfig()
+		config.Logger = microloggertest.New()
+		config.Storage = newStorage
+		config.IDQuota = 2
+		newService, err = New(config)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+	}
+
+	ctx := context.TODO()
+
+	err = newService.SetPolicy(ctx, namespace, Policy{IDClasses: []IDClass{{Max: 5, Min: 2, Prefix: "system-"}}})
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+	_, err = newService.Create(ctx, namespace, "test-id-1", 2, 6, 10)
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+	_, err = newService.Create(ctx, namespace, "test-id-2", 1, 6, 10)
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+
+	// Items must not be handed over to IDs of classes they are not within.
+	{
+		err := newService.RenameID(ctx, namespace, "test-id-1", "system-id-1")
+		if !IsIDClassViolation(err) {
+			t.Fatal("expected", true, "got", false)
+		}
+	}
+
+	// Items must not be handed over to IDs exceeding their quota.
+	{
+		err := newService.MergeIDs(ctx, namespace, "test-id-1", "test-id-2")
+		if !IsQuotaExceeded(err) {
+			t.Fatal("expected", true, "got", false)
+		}
+	}
+
+	// Items must not be handed over to IDs whose revision changed.
+	{
+		_, r, err := newService.SearchRevision(ctx, namespace, "test-id-3")
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		_, err = newService.Create(ctx, namespace, "test-id-3", 1, 6, 10)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		err = newService.MergeIDs(NewRevisionContext(ctx, r), namespace, "test-id-2", "test-id-3")
+		if !IsRevisionConflict(err) {
+			t.Fatal("expected", true, "got", false)
+		}
+	}
+
+	// Items must be handed over atomically in case the storage supports it.
+	{
+		err := newService.RenameID(ctx, namespace, "test-id-1", "test-id-4")
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		if newStorage.Applies != 1 {
+			t.Fatal("expected", 1, "got", newStorage.Applies)
+		}
+	}
+}
+
+// testAtomicStorage implements AtomicStorage on top of the given Storage and
+// counts the changes applied. It is not actually atomic.
+type testAtomicStorage struct {
+	Storage
+
+	Applies int
+}
+
+func (s *testAtomicStorage) Apply(ctx context.Context, kvs []KV, keys []string) error {
+	s.Applies++
+
+	err := createBatch(ctx, s.Storage, kvs)
+	if err != nil {
+		return err
+	}
+
+	return deleteBatch(ctx, s.Storage, keys)
+}
@@ -0,0 +1,403 @@
+package rangepool
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/giantswarm/microerror"
+)
+
+// Reservation describes items held for an ID until they are committed or
+// aborted, see Service.Reserve.
+type Reservation struct {
+	// ExpiresAt is the time after which the reservation cannot be committed
+	// anymore, see Config.ReservationTimeout.
+	ExpiresAt time.Time `json:"expiresAt"`
+	// ID is the ID the items are committed to.
+	ID string `json:"id"`
+	// Items are the reserved items.
+	Items []int `json:"items"`
+	// Namespace is the namespace the items are reserved in.
+	Namespace string `json:"namespace"`
+	// Token identifies the reservation when committing or aborting it.
+	Token string `json:"token"`
+}
+
+// Reserve allocates num items in between min and max, both inclusive, and
+// holds them for the given ID until the returned reservation is committed
+// using Commit or aborted using Abort. Until then the items are owned by the
+// reservation ID of the reservation, which starts with "reservation-". This
+// allows configuring external systems with the items first, without leaking
+// them in case that fails. The reserved items count towards the quota of the
+// ID, see Config.IDQuota.
+func (s *Service) Reserve(ctx context.Context, namespace, ID string, num, min, max int) (Reservation, error) {
+	ctx = withOperation(ctx, "Reserve", namespace, ID)
+
+	p, err := s.withPolicy(ctx, namespace)
+	if err != nil {
+		return Reservation{}, microerror.Mask(err)
+	}
+
+	err = p.checkIDQuota(ctx, namespace, ID, num, true)
+	if err != nil {
+		return Reservation{}, microerror.Mask(err)
+	}
+
+	b := make([]byte, 16)
+	_, err = rand.Read(b)
+	if err != nil {
+		return Reservation{}, microerror.Mask(err)
+	}
+	key := hex.EncodeToString(b)
+
+	r := Reservation{
+		ExpiresAt: s.clock.Now().UTC().Add(s.reservationTimeout),
+		ID:        ID,
+		Namespace: namespace,
+		Token:     namespace + "/" + key,
+	}
+
+	// The reservation is persisted before its items are allocated, so that the
+	// items are released once it expires, even in case we fail in between.
+	err = s.putReservation(ctx, r, key)
+	if err != nil {
+		return Reservation{}, microerror.Mask(err)
+	}
+	s.sweeps.Lower(namespace, r.ExpiresAt)
+
+	r.Items, err = s.Create(ctx, namespace, reservationID(key), num, min, max)
+	if err != nil {
+		deleteErr := deleteBatch(ctx, s.storage, []string{s.key(ReservationKeyFormat, namespace, key)})
+		if deleteErr != nil {
+			s.logger.LogCtx(ctx, "level", "error", "message", fmt.Sprintf("failed removing reservation '%s'", r.Token), "stack", fmt.Sprintf("%#v", deleteErr))
+		}
+
+		return Reservation{}, microerror.Mask(err)
+	}
+
+	err = s.putReservation(ctx, r, key)
+	if err != nil {
+		// The items of the reservation cannot be tracked, so we release them
+		// right away instead of waiting for the reservation to expire.
+		abortErr := s.abort(ctx, r, key)
+		if abortErr != nil {
+			s.logger.LogCtx(ctx, "level", "error", "message", fmt.Sprintf("failed releasing items of reservation '%s'", r.Token), "stack", fmt.Sprintf("%#v", abortErr))
+		}
+
+		return Reservation{}, microerror.Mask(err)
+	}
+
+	return r, nil
+}
+
+// putReservation persists the given reservation under the given key.
+func (s *Service) putReservation(ctx context.Context, r Reservation, key string) error {
+	v, err := json.Marshal(r)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = s.storage.Create(ctx, s.key(ReservationKeyFormat, r.Namespace, key), string(v))
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// Commit hands the items of the reservation identified by the given token
+// over to the ID of the reservation. In case the reservation does not exist
+// anymore, e.g. because it was aborted or committed already, an error is
+// returned which can be asserted using IsReservationNotFound. In case the
+// reservation expired, it is aborted and an error is returned which can be
+// asserted using IsReservationExpired. In case the ID would exceed its quota,
+// e.g. because of concurrent reservations, an error is returned which can be
+// asserted using IsQuotaExceeded and the reservation is kept until it is
+// aborted or expires.
+func (s *Service) Commit(ctx context.Context, token string) error {
+	ctx = withOperation(ctx, "Commit", "", "")
+
+	err := s.checkReadOnly()
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = s.checkLeader()
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	r, key, err := s.searchReservation(ctx, token)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	if s.clock.Now().After(r.ExpiresAt) {
+		err := s.abort(ctx, r, key)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
+		return microerror.Maskf(reservationExpiredError, "reservation '%s' expired at %s", token, r.ExpiresAt)
+	}
+
+	p, err := s.withPolicy(ctx, r.Namespace)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	// Reserve counts the items of pending reservations towards the quota of
+	// the ID, but concurrent reservations may still exceed it. So the
+	// committed items are checked once more.
+	err = p.checkIDQuota(ctx, r.Namespace, r.ID, len(r.Items), false)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	// The items are handed over like by RenameID, which also records the
+	// release of the reservation ID and the allocation of the ID.
+	err = p.moveItems(ctx, r.Namespace, reservationID(key), r.ID, r.Items)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	keys := []string{
+		s.key(IDListKeyFormat, r.Namespace, reservationID(key)),
+		s.key(ReservationKeyFormat, r.Namespace, key),
+	}
+	err = deleteBatch(ctx, s.storage, keys)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	s.reads.Invalidate(r.Namespace)
+
+	return nil
+}
+
+// Abort releases the items of the reservation identified by the given token.
+// In case the reservation does not exist anymore, an error is returned which
+// can be asserted using IsReservationNotFound.
+func (s *Service) Abort(ctx context.Context, token string) error {
+	ctx = withOperation(ctx, "Abort", "", "")
+
+	err := s.checkReadOnly()
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = s.checkLeader()
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	r, key, err := s.searchReservation(ctx, token)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = s.abort(ctx, r, key)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// abort releases the items of the given reservation and removes it.
+func (s *Service) abort(ctx context.Context, r Reservation, key string) error {
+	err := s.Delete(ctx, r.Namespace, reservationID(key))
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	// Reservation IDs never return, so the items remembered for them by
+	// Config.Sticky are dropped as well.
+	keys := []string{
+		s.key(PreviousKeyFormat, r.Namespace, reservationID(key)),
+		s.key(ReservationKeyFormat, r.Namespace, key),
+	}
+	err = deleteBatch(ctx, s.storage, keys)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// releaseExpired aborts the expired reservations of the given namespace, so
+// that abandoned reservations never permanently shrink the range. It is called
+// lazily by allocations. Reservations are only listed once the earliest
+// reservation seen by the Service expires, or Config.ReservationTimeout after
+// the last listing, in order to catch reservations created by other Services.
+func (s *Service) releaseExpired(ctx context.Context, namespace string) error {
+	now := s.clock.Now()
+
+	if !s.sweeps.Due(namespace, now) {
+		return nil
+	}
+
+	dueAt := now.Add(s.reservationTimeout)
+
+	var expired []Reservation
+	var keys []string
+	err := walk(ctx, s.storage, s.key(ReservationListKeyFormat, namespace), func(kv KV) error {
+		var r Reservation
+		err := json.Unmarshal([]byte(kv.Value), &r)
+		if err != nil {
+			return microerror.Maskf(executionFailedError, "decoding reservation '%s': %s", kv.Key, err.Error())
+		}
+
+		if now.After(r.ExpiresAt) {
+			expired = append(expired, r)
+			keys = append(keys, kv.Key)
+		} else if r.ExpiresAt.Before(dueAt) {
+			dueAt = r.ExpiresAt
+		}
+
+		return nil
+	})
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	// The reservations are aborted after the walk, since storages may not
+	// allow writes while walking, see WalkStorage.
+	for i, r := range expired {
+		err := s.abort(ctx, r, keys[i])
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
+		s.logger.LogCtx(ctx, "level", "debug", "message", fmt.Sprintf("released items %v of reservation '%s' expired at %s", r.Items, r.Token, r.ExpiresAt))
+	}
+
+	s.sweeps.Set(namespace, dueAt)
+
+	return nil
+}
+
+// reservedItems returns the number of items of the pending reservations for
+// the given ID within the given namespace. Expired reservations are not
+// counted, since they are released by the next allocation.
+func (s *Service) reservedItems(ctx context.Context, namespace, ID string) (int, error) {
+	now := s.clock.Now()
+
+	var n int
+	err := walk(ctx, s.storage, s.key(ReservationListKeyFormat, namespace), func(kv KV) error {
+		var r Reservation
+		err := json.Unmarshal([]byte(kv.Value), &r)
+		if err != nil {
+			return microerror.Maskf(executionFailedError, "decoding reservation '%s': %s", kv.Key, err.Error())
+		}
+
+		if r.ID == ID && !now.After(r.ExpiresAt) {
+			n += len(r.Items)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, microerror.Mask(err)
+	}
+
+	return n, nil
+}
+
+// searchReservation fetches the reservation identified by the given token,
+// which is made of the namespace and the key of the reservation.
+func (s *Service) searchReservation(ctx context.Context, token string) (Reservation, string, error) {
+	i := strings.LastIndex(token, "/")
+	if i == -1 {
+		return Reservation{}, "", microerror.Maskf(invalidArgumentError, "token '%s' must contain a namespace", token)
+	}
+	namespace, key := token[:i], token[i+1:]
+
+	v, err := s.storage.Search(ctx, s.key(ReservationKeyFormat, namespace, key))
+	if IsNotFound(err) {
+		return Reservation{}, "", microerror.Maskf(reservationNotFoundError, "reservation '%s'", token)
+	} else if err != nil {
+		return Reservation{}, "", microerror.Mask(err)
+	}
+
+	var r Reservation
+	err = json.Unmarshal([]byte(v), &r)
+	if err != nil {
+		return Reservation{}, "", microerror.Maskf(executionFailedError, "decoding reservation '%s': %s", token, err.Error())
+	}
+
+	return r, key, nil
+}
+
+// reservationKey returns the key of the reservation identified by the given
+// token, see Service.searchReservation. It is empty in case the token is
+// malformed.
+func reservationKey(token string) string {
+	i := strings.LastIndex(token, "/")
+	if i == -1 {
+		return ""
+	}
+
+	return token[i+1:]
+}
+
+// reservationID returns the ID owning the items of the reservation with the
+// given key until they are committed.
+func reservationID(key string) string {
+	return "reservation-" + key
+}
+
+// sweepCache remembers per namespace when the expired reservations have to be
+// released next, see Service.releaseExpired. It is shared by the copies of a
+// Service created by Service.withPolicy.
+type sweepCache struct {
+	dueAt map[string]time.Time
+	mutex sync.Mutex
+}
+
+func newSweepCache() *sweepCache {
+	c := &sweepCache{
+		dueAt: map[string]time.Time{},
+		mutex: sync.Mutex{},
+	}
+
+	return c
+}
+
+// Due returns whether the expired reservations of the given namespace have to
+// be released at the given time. This is the case for namespaces which have
+// not been swept yet.
+func (c *sweepCache) Due(namespace string, now time.Time) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	dueAt, ok := c.dueAt[namespace]
+	return !ok || !now.Before(dueAt)
+}
+
+// Lower moves the next release of the given namespace to the given time in
+// case it is earlier, e.g. because a reservation expiring at this time has
+// been created.
+func (c *sweepCache) Lower(namespace string, dueAt time.Time) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	d, ok := c.dueAt[namespace]
+	if ok && d.After(dueAt) {
+		c.dueAt[namespace] = dueAt
+	}
+}
+
+// Set sets the next release of the given namespace after it has been swept.
+func (c *sweepCache) Set(namespace string, dueAt time.Time) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.dueAt[namespace] = dueAt
+}
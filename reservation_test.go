@@ -0,0 +1,297 @@
+package rangepool
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/giantswarm/micrologger/microloggertest"
+)
+
+func Test_Service_Reserve(t *testing.T) {
+	for _, b := range []bool{false, true} {
+		var err error
+		var newService *Service
+		{
+			newStorage, err := newMemoryStorage()
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+
+			config := DefaultConfig()
+			config.Logger = microloggertest.New()
+			config.Storage = newStorage
+			config.Bitmap = b
+			newService, err = New(config)
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+		}
+
+		ctx := context.TODO()
+
+		// Reserved items must not be owned by the ID before they are committed,
+		// but must not be handed out to other IDs either.
+		var r Reservation
+		{
+			r, err = newService.Reserve(ctx, namespace, "test-id-1", 2, 2, 10)
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+			expected := []int{2, 3}
+			if !reflect.DeepEqual(r.Items, expected) {
+				t.Fatal("expected", expected, "got", r.Items)
+			}
+
+			_, err = newService.Search(ctx, namespace, "test-id-1")
+			if !IsItemsNotFound(err) {
+				t.Fatal("expected", true, "got", false)
+			}
+
+			items, err := newService.Create(ctx, namespace, "test-id-2", 1, 2, 10)
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+			expected = []int{4}
+			if !reflect.DeepEqual(items, expected) {
+				t.Fatal("expected", expected, "got", items)
+			}
+		}
+
+		// Committed items must be owned by the ID.
+		{
+			err = newService.Commit(ctx, r.Token)
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+
+			d, err := newService.Dump(ctx, namespace)
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+			expected := map[string][]int{"test-id-1": {2, 3}, "test-id-2": {4}}
+			if !reflect.DeepEqual(d.IDs, expected) {
+				t.Fatal("expected", expected, "got", d.IDs)
+			}
+			if !reflect.DeepEqual(d.Used, []int{2, 3, 4}) {
+				t.Fatal("expected", []int{2, 3, 4}, "got", d.Used)
+			}
+
+			err = newService.Commit(ctx, r.Token)
+			if !IsReservationNotFound(err) {
+				t.Fatal("expected", true, "got", false)
+			}
+		}
+
+		// Aborted items must be released.
+		{
+			r, err = newService.Reserve(ctx, namespace, "test-id-3", 1, 2, 10)
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+			err = newService.Abort(ctx, r.Token)
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+
+			d, err := newService.Dump(ctx, namespace)
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+			if !reflect.DeepEqual(d.Used, []int{2, 3, 4}) {
+				t.Fatal("expected", []int{2, 3, 4}, "got", d.Used)
+			}
+
+			err = newService.Abort(ctx, r.Token)
+			if !IsReservationNotFound(err) {
+				t.Fatal("expected", true, "got", false)
+			}
+		}
+
+		// Expired reservations must not be committed, but released.
+		{
+			newService.reservationTimeout = time.Nanosecond
+
+			r, err = newService.Reserve(ctx, namespace, "test-id-4", 1, 2, 10)
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+			time.Sleep(time.Millisecond)
+
+			err = newService.Commit(ctx, r.Token)
+			if !IsReservationExpired(err) {
+				t.Fatal("expected", true, "got", false)
+			}
+
+			d, err := newService.Dump(ctx, namespace)
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+			if !reflect.DeepEqual(d.Used, []int{2, 3, 4}) {
+				t.Fatal("expected", []int{2, 3, 4}, "got", d.Used)
+			}
+		}
+	}
+}
+
+func Test_Service_Reserve_Expiry(t *testing.T) {
+	var err error
+	var newService *Service
+	{
+		newStorage, err := newMemoryStorage()
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		config := DefaultConfig()
+		config.Logger = microloggertest.New()
+		config.Storage = newStorage
+		newService, err = New(config)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+	}
+
+	ctx := context.TODO()
+
+	// Valid reservations must hold their items.
+	var valid Reservation
+	{
+		valid, err = newService.Reserve(ctx, namespace, "test-id-1", 1, 2, 3)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		_, err = newService.Create(ctx, namespace, "test-id-2", 2, 2, 3)
+		if !IsCapacityReached(err) {
+			t.Fatal("expected", true, "got", false)
+		}
+	}
+
+	// Allocations must release expired reservations. The reservation is
+	// created expired already.
+	{
+		newService.reservationTimeout = -time.Hour
+		expired, err := newService.Reserve(ctx, namespace, "test-id-3", 1, 2, 3)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		items, err := newService.Create(ctx, namespace, "test-id-2", 1, 2, 3)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		if !reflect.DeepEqual(items, expired.Items) {
+			t.Fatal("expected", expired.Items, "got", items)
+		}
+
+		err = newService.Commit(ctx, expired.Token)
+		if !IsReservationNotFound(err) {
+			t.Fatal("expected", true, "got", false)
+		}
+	}
+
+	// Valid reservations must not be released.
+	{
+		err = newService.Commit(ctx, valid.Token)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+	}
+}
+
+func Test_Service_Reserve_Quota(t *testing.T) {
+	var err error
+	var newService *Service
+	{
+		newStorage, err := newMemoryStorage()
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		config := DefaultConfig()
+		config.Logger = microloggertest.New()
+		config.Storage = newStorage
+		config.Audit = true
+		newService, err = New(config)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+	}
+
+	ctx := context.TODO()
+	start := time.Now()
+
+	// Reserve items for the ID before it gets a quota, e.g. like concurrent
+	// reservations checking the quota at the same time would.
+	var first, second Reservation
+	{
+		first, err = newService.Reserve(ctx, namespace, "test-id-1", 1, 2, 10)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		second, err = newService.Reserve(ctx, namespace, "test-id-1", 1, 2, 10)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		newService.idQuota = 1
+	}
+
+	// Pending reservations must count towards the quota of the ID.
+	{
+		_, err = newService.Reserve(ctx, namespace, "test-id-1", 1, 2, 10)
+		if !IsQuotaExceeded(err) {
+			t.Fatal("expected", true, "got", false)
+		}
+		_, err = newService.Create(ctx, namespace, "test-id-1", 1, 2, 10)
+		if !IsQuotaExceeded(err) {
+			t.Fatal("expected", true, "got", false)
+		}
+	}
+
+	// Commits must not exceed the quota of the ID.
+	{
+		err = newService.Commit(ctx, first.Token)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		err = newService.Commit(ctx, second.Token)
+		if !IsQuotaExceeded(err) {
+			t.Fatal("expected", true, "got", false)
+		}
+
+		items, err := newService.Search(ctx, namespace, "test-id-1")
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		if !reflect.DeepEqual(items, first.Items) {
+			t.Fatal("expected", first.Items, "got", items)
+		}
+	}
+
+	// Commits must record the allocation of the ID and the release of the
+	// reservation ID.
+	{
+		entries, err := newService.AuditLog(ctx, namespace, start)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		if len(entries) != 4 {
+			t.Fatal("expected", 4, "got", len(entries))
+		}
+
+		e := entries[2]
+		if e.Action != AuditActionAllocate || e.ID != "test-id-1" {
+			t.Fatal("expected", AuditActionAllocate, "got", e.Action, e.ID)
+		}
+		if !reflect.DeepEqual(e.Items, first.Items) {
+			t.Fatal("expected", first.Items, "got", e.Items)
+		}
+		e = entries[3]
+		if e.Action != AuditActionRelease || e.ID != "reservation-"+first.Token[len(namespace)+1:] {
+			t.Fatal("expected", AuditActionRelease, "got", e.Action, e.ID)
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package rangepool
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+)
+
+// retryStorage retries failed operations of the given Storage using
+// exponential backoff, so that transient storage errors, e.g. caused by etcd
+// leader elections, do not fail allocations right away. Errors asserted by
+// IsNotFound and IsConflict are never retried, since they are part of the
+// Storage contract.
+type retryStorage struct {
+	// Dependencies.
+	logger  micrologger.Logger
+	storage Storage
+
+	// Settings.
+	attempts int
+	backoff  time.Duration
+	jitter   float64
+}
+
+func (r *retryStorage) Apply(ctx context.Context, kvs []KV, keys []string) error {
+	err := r.retry(ctx, func() error {
+		return apply(ctx, r.storage, kvs, keys)
+	})
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+func (r *retryStorage) Create(ctx context.Context, key, value string) error {
+	err := r.retry(ctx, func() error {
+		return r.storage.Create(ctx, key, value)
+	})
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+func (r *retryStorage) CreateBatch(ctx context.Context, kvs []KV) error {
+	err := r.retry(ctx, func() error {
+		return createBatch(ctx, r.storage, kvs)
+	})
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+func (r *retryStorage) Delete(ctx context.Context, key string) error {
+	err := r.retry(ctx, func() error {
+		return r.storage.Delete(ctx, key)
+	})
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+func (r *retryStorage) DeleteBatch(ctx context.Context, keys []string) error {
+	err := r.retry(ctx, func() error {
+		return deleteBatch(ctx, r.storage, keys)
+	})
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+func (r *retryStorage) List(ctx context.Context, key string) ([]KV, error) {
+	var kvs []KV
+	err := r.retry(ctx, func() error {
+		var err error
+		kvs, err = r.storage.List(ctx, key)
+		return err
+	})
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return kvs, nil
+}
+
+func (r *retryStorage) Search(ctx context.Context, key string) (string, error) {
+	var v string
+	err := r.retry(ctx, func() error {
+		var err error
+		v, err = r.storage.Search(ctx, key)
+		return err
+	})
+	if err != nil {
+		return "", microerror.Mask(err)
+	}
+
+	return v, nil
+}
+
+// Walk is only retried in case the underlying storage does not implement
+// WalkStorage. Otherwise fn may have been called for some keys already, which
+// would be called again on retries.
+func (r *retryStorage) Walk(ctx context.Context, key string, fn func(kv KV) error) error {
+	_, ok := r.storage.(WalkStorage)
+	if ok {
+		err := walk(ctx, r.storage, key, fn)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
+		return nil
+	}
+
+	kvs, err := r.List(ctx, key)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	for _, kv := range kvs {
+		err := fn(kv)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	return nil
+}
+
+// retry executes o until it succeeds, the configured number of attempts is
+// exhausted or the given context is done. The delay between attempts doubles
+// every time and is varied by the configured jitter.
+func (r *retryStorage) retry(ctx context.Context, o func() error) error {
+	delay := r.backoff
+
+	var err error
+	for i := 1; ; i++ {
+		err = o()
+		if err == nil || IsNotFound(err) || IsConflict(err) || i >= r.attempts {
+			return err
+		}
+
+		d := delay
+		if r.jitter > 0 {
+			d += time.Duration(r.jitter * (rand.Float64()*2 - 1) * float64(delay))
+		}
+
+		r.logger.LogCtx(ctx, "level", "warning", "message", fmt.Sprintf("retrying storage operation in %s after attempt %d of %d failed", d, i, r.attempts), "stack", fmt.Sprintf("%#v", err))
+
+		select {
+		case <-ctx.Done():
+			return microerror.Mask(err)
+		case <-time.After(d):
+		}
+
+		delay *= 2
+	}
+}
+
+// unwrapStorage returns the storage wrapped by breakerStorage, flightStorage
+// and retryStorage, in case the given storage is one of them. It is used to detect
+// the optional interfaces the wrappers do not implement themselves, like
+// WatchStorage.
+func unwrapStorage(storage Storage) Storage {
+	for {
+		switch s := storage.(type) {
+		case *breakerStorage:
+			storage = s.storage
+		case *codecStorage:
+			storage = s.storage
+		case *flightStorage:
+			storage = s.storage
+		case *retryStorage:
+			storage = s.storage
+		default:
+			return storage
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package rangepool
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger/microloggertest"
+)
+
+func Test_Service_Retry(t *testing.T) {
+	testCases := []struct {
+		RetryAttempts int
+		Failures      int
+		ErrorMatcher  func(err error) bool
+	}{
+		// Case 1 ensures transient errors are retried.
+		{
+			RetryAttempts: 3,
+			Failures:      2,
+			ErrorMatcher:  nil,
+		},
+		// Case 2 ensures errors are returned once all attempts are exhausted.
+		{
+			RetryAttempts: 3,
+			Failures:      3,
+			ErrorMatcher:  IsExecutionFailed,
+		},
+		// Case 3 ensures errors are not retried by default.
+		{
+			RetryAttempts: 1,
+			Failures:      1,
+			ErrorMatcher:  IsExecutionFailed,
+		},
+	}
+
+	for i, tc := range testCases {
+		s, err := newMemoryStorage()
+		if err != nil {
+			t.Fatal("case", i+1, "expected", nil, "got", err)
+		}
+		newStorage := &testFlakyStorage{Storage: s, Failures: tc.Failures}
+
+		config := DefaultConfig()
+		config.Logger = microloggertest.New()
+		config.Storage = newStorage
+		config.RetryAttempts = tc.RetryAttempts
+		config.RetryBackoff = time.Millisecond
+		newService, err := New(config)
+		if err != nil {
+			t.Fatal("case", i+1, "expected", nil, "got", err)
+		}
+
+		_, err = newService.Create(context.TODO(), namespace, "test-id", 1, 2, 3)
+		if err != nil && tc.ErrorMatcher == nil {
+			t.Fatal("case", i+1, "expected", nil, "got", err)
+		}
+		if tc.ErrorMatcher != nil && !tc.ErrorMatcher(err) {
+			t.Fatal("case", i+1, "expected", true, "got", false)
+		}
+	}
+}
+
+// testFlakyStorage fails the first Failures calls to Search of the given
+// Storage.
+type testFlakyStorage struct {
+	Storage
+
+	Failures int
+	mutex    sync.Mutex
+}
+
+func (s *testFlakyStorage) Search(ctx context.Context, key string) (string, error) {
+	s.mutex.Lock()
+	fail := s.Failures > 0
+	s.Failures--
+	s.mutex.Unlock()
+
+	if fail {
+		return "", microerror.Maskf(executionFailedError, "storage unavailable")
+	}
+
+	return s.Storage.Search(ctx, key)
+}
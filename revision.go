@@ -0,0 +1,85 @@
+package rangepool
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/giantswarm/microerror"
+)
+
+type revisionContextKey struct{}
+
+// NewRevisionContext returns a new context carrying the given revision of an
+// ID, as returned by Service.SearchRevision or Allocation.Revision. Create and
+// Delete executed using the returned context only modify the ID in case its
+// current revision still matches. Otherwise they fail with an error which can
+// be asserted using IsRevisionConflict. This allows management tools to
+// safely apply changes based on what they displayed before.
+func NewRevisionContext(ctx context.Context, revision string) context.Context {
+	return context.WithValue(ctx, revisionContextKey{}, revision)
+}
+
+// RevisionFromContext returns the revision carried by the given context, see
+// NewRevisionContext. The returned bool is false in case the context does not
+// carry a revision.
+func RevisionFromContext(ctx context.Context) (string, bool) {
+	revision, ok := ctx.Value(revisionContextKey{}).(string)
+	return revision, ok
+}
+
+// SearchRevision returns the items of the given ID within the given namespace
+// in ascending order, together with the current revision of the ID. The
+// revision changes whenever the items of the ID change. It is derived from the
+// items, so it is the same for IDs holding the same items. IDs without items
+// do not fail, but return no items and the revision of an empty ID. The
+// storage is read bypassing all caches.
+func (s *Service) SearchRevision(ctx context.Context, namespace, ID string) ([]int, string, error) {
+	ctx = withOperation(ctx, "SearchRevision", namespace, ID)
+
+	items, err := s.searchItems(ctx, s.key(ItemSearchKeyFormat, namespace, ID))
+	if err != nil {
+		return nil, "", microerror.Mask(err)
+	}
+
+	sort.Ints(items)
+
+	return items, revision(items), nil
+}
+
+// checkRevision returns an error in case the given context carries a revision
+// which is not the current revision of the given ID, see NewRevisionContext.
+// The storage is only read in case the context carries a revision.
+func (s *Service) checkRevision(ctx context.Context, namespace, ID string) error {
+	expected, ok := RevisionFromContext(ctx)
+	if !ok {
+		return nil
+	}
+
+	items, err := s.searchItems(ctx, s.key(IDListKeyFormat, namespace, ID))
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	actual := revision(items)
+	if actual != expected {
+		return microerror.Mask(&RevisionConflictError{Actual: actual, Expected: expected, ID: ID, Namespace: namespace})
+	}
+
+	return nil
+}
+
+// revision returns the revision of an ID holding the given items, no matter
+// their order.
+func revision(items []int) string {
+	l := append([]int{}, items...)
+	sort.Ints(l)
+
+	var s []string
+	for _, item := range l {
+		s = append(s, strconv.Itoa(item))
+	}
+
+	return checksum(strings.Join(s, ","))
+}
@@ -0,0 +1,88 @@
+package rangepool
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func Test_Service_Revision(t *testing.T) {
+	newStorage, err := newMemoryStorage()
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+	newService, err := NewWithOptions(newStorage)
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+
+	ctx := context.TODO()
+
+	// IDs without items have a revision as well, so that their first
+	// allocation can be guarded.
+	items, empty, err := newService.SearchRevision(ctx, namespace, "test-id")
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+	if len(items) != 0 {
+		t.Fatal("expected", 0, "got", len(items))
+	}
+
+	_, err = newService.Create(NewRevisionContext(ctx, empty), namespace, "test-id", 2, 2, 10)
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+
+	items, current, err := newService.SearchRevision(ctx, namespace, "test-id")
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+	if !reflect.DeepEqual(items, []int{2, 3}) {
+		t.Fatal("expected", []int{2, 3}, "got", items)
+	}
+	if current == empty {
+		t.Fatal("expected", "changed revision", "got", current)
+	}
+
+	// Modifications against stale revisions must fail with details.
+	_, err = newService.Create(NewRevisionContext(ctx, empty), namespace, "test-id", 1, 2, 10)
+	if !IsRevisionConflict(err) {
+		t.Fatal("expected", true, "got", false)
+	}
+	e, ok := AsRevisionConflict(err)
+	if !ok {
+		t.Fatal("expected", true, "got", false)
+	}
+	expected := RevisionConflictError{Actual: current, Expected: empty, ID: "test-id", Namespace: namespace}
+	if *e != expected {
+		t.Fatal("expected", expected, "got", *e)
+	}
+
+	err = newService.Delete(NewRevisionContext(ctx, empty), namespace, "test-id")
+	if !IsRevisionConflict(err) {
+		t.Fatal("expected", true, "got", false)
+	}
+
+	// Modifications against the current revision must succeed.
+	err = newService.Delete(NewRevisionContext(ctx, current), namespace, "test-id")
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+
+	_, revision, err := newService.SearchRevision(ctx, namespace, "test-id")
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+	if revision != empty {
+		t.Fatal("expected", empty, "got", revision)
+	}
+}
+
+func Test_revision(t *testing.T) {
+	if revision([]int{3, 1, 2}) != revision([]int{1, 2, 3}) {
+		t.Fatal("expected", "same revision", "got", "different revisions")
+	}
+	if revision([]int{1, 23}) == revision([]int{12, 3}) {
+		t.Fatal("expected", "different revisions", "got", "same revision")
+	}
+}
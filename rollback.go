@@ -0,0 +1,187 @@
+package rangepool
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/giantswarm/microerror"
+)
+
+// Snapshot persists the portable state of the given namespace, as returned by
+// Export, alongside the namespace and returns its version. Versions start at 1
+// and increase with every snapshot of the namespace, so that the namespace can
+// be rolled back to the state it had before e.g. a bad bulk operation, see
+// Rollback. Snapshot must not be executed concurrently with other snapshots of
+// the same namespace.
+func (s *Service) Snapshot(ctx context.Context, namespace string) (int, error) {
+	ctx = withOperation(ctx, "Snapshot", namespace, "")
+
+	err := s.checkReadOnly()
+	if err != nil {
+		return 0, microerror.Mask(err)
+	}
+
+	err = s.checkLeader()
+	if err != nil {
+		return 0, microerror.Mask(err)
+	}
+
+	versions, err := s.searchSnapshotVersions(ctx, namespace)
+	if err != nil {
+		return 0, microerror.Mask(err)
+	}
+
+	version := 1
+	for _, v := range versions {
+		if v >= version {
+			version = v + 1
+		}
+	}
+
+	snapshot, err := s.Export(ctx, namespace)
+	if err != nil {
+		return 0, microerror.Mask(err)
+	}
+
+	b, err := json.Marshal(snapshot)
+	if err != nil {
+		return 0, microerror.Mask(err)
+	}
+
+	err = s.storage.Create(ctx, s.key(SnapshotKeyFormat, namespace, version), string(b))
+	if err != nil {
+		return 0, microerror.Mask(err)
+	}
+
+	s.logger.LogCtx(ctx, "level", "debug", "message", fmt.Sprintf("created snapshot %d of namespace '%s' holding %d IDs", version, namespace, len(snapshot.IDs)))
+
+	return version, nil
+}
+
+// Rollback restores the state the given namespace had when the snapshot of the
+// given version was created, see Snapshot. All allocations, the latest item
+// and the pending reservations of the namespace are replaced. The policy and
+// the history of the namespace are kept. Burned items stay burned, since
+// burning cannot be undone. In case the version does not exist, an error is returned
+// which can be asserted using IsNotFound. The snapshot itself is kept, so that
+// a failed rollback can be retried. Rollback must not be executed concurrently
+// with other operations on the same namespace, including pending reservations.
+func (s *Service) Rollback(ctx context.Context, namespace string, version int) error {
+	ctx = withOperation(ctx, "Rollback", namespace, "")
+
+	err := s.checkReadOnly()
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	err = s.checkLeader()
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	var snapshot Snapshot
+	{
+		v, err := s.storage.Search(ctx, s.key(SnapshotKeyFormat, namespace, version))
+		if IsNotFound(err) {
+			return microerror.Maskf(NotFoundError, "snapshot %d of namespace '%s'", version, namespace)
+		} else if err != nil {
+			return microerror.Mask(err)
+		}
+
+		err = json.Unmarshal([]byte(v), &snapshot)
+		if err != nil {
+			return microerror.Maskf(invalidSnapshotError, "decoding snapshot %d: %s", version, err.Error())
+		}
+		if snapshot.Namespace != namespace {
+			return microerror.Maskf(invalidSnapshotError, "snapshot %d belongs to namespace '%s'", version, snapshot.Namespace)
+		}
+		err = validateSnapshot(snapshot)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	// The policy of the namespace is kept.
+	snapshot.Policy = nil
+
+	// The cached items of the namespace are going to be rewritten.
+	defer s.InvalidateCache(namespace)
+
+	// Collect the keys making up the current allocations of the namespace, so
+	// that the snapshot can be imported into the emptied namespace.
+	keys := []string{s.key(BitmapKeyFormat, namespace)}
+	keys = append(keys, s.latestKeys(namespace)...)
+	{
+		prefix := s.key(ItemListKeyFormat, namespace)
+		err := walk(ctx, s.storage, prefix, func(kv KV) error {
+			keys = append(keys, prefix+"/"+kv.Key)
+			return nil
+		})
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+	{
+		prefix := s.key(IDPrefixKeyFormat, namespace)
+		err := walk(ctx, s.storage, prefix, func(kv KV) error {
+			// The keys are relative to the ID prefix, e.g. ${id1}/item/${item1}.
+			if strings.Contains(kv.Key, "/item/") {
+				keys = append(keys, prefix+"/"+kv.Key)
+			}
+			return nil
+		})
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	// The reservations own items of the current allocations, so they are
+	// replaced by the ones of the snapshot.
+	{
+		prefix := s.key(ReservationListKeyFormat, namespace)
+		err := walk(ctx, s.storage, prefix, func(kv KV) error {
+			keys = append(keys, prefix+"/"+kv.Key)
+			return nil
+		})
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	err = deleteBatch(ctx, s.storage, keys)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = s.Import(ctx, snapshot)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	s.logger.LogCtx(ctx, "level", "debug", "message", fmt.Sprintf("rolled back namespace '%s' to snapshot %d", namespace, version))
+
+	return nil
+}
+
+// searchSnapshotVersions fetches the versions of all snapshots of the given
+// namespace.
+func (s *Service) searchSnapshotVersions(ctx context.Context, namespace string) ([]int, error) {
+	var versions []int
+
+	err := walk(ctx, s.storage, s.key(SnapshotListKeyFormat, namespace), func(kv KV) error {
+		v, err := strconv.Atoi(kv.Key)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+		versions = append(versions, v)
+
+		return nil
+	})
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return versions, nil
+}
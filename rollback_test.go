@@ -0,0 +1,88 @@
+package rangepool
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func Test_Service_SnapshotRollback(t *testing.T) {
+	for _, bitmap := range []bool{false, true} {
+		newStorage, err := newMemoryStorage()
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		newService, err := NewWithOptions(newStorage, WithBitmap(bitmap))
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		ctx := context.TODO()
+
+		_, err = newService.Create(ctx, namespace, "test-id-1", 2, 1, 10)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		var version int
+		for _, expected := range []int{1, 2} {
+			version, err = newService.Snapshot(ctx, namespace)
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+			if version != expected {
+				t.Fatal("expected", expected, "got", version)
+			}
+		}
+
+		// Simulate a bad bulk operation.
+		err = newService.Delete(ctx, namespace, "test-id-1")
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		_, err = newService.Create(ctx, namespace, "test-id-2", 3, 1, 10)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		err = newService.Rollback(ctx, namespace, version)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		// The namespace must hold the state it had when the snapshot was
+		// created.
+		{
+			d, err := newService.Dump(ctx, namespace)
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+			expected := Dump{
+				IDs:       map[string][]int{"test-id-1": {1, 2}},
+				Latest:    2,
+				Namespace: namespace,
+				Used:      []int{1, 2},
+			}
+			if !reflect.DeepEqual(d, expected) {
+				t.Fatal("expected", expected, "got", d)
+			}
+
+			items, err := newService.Create(ctx, namespace, "test-id-3", 1, 1, 10)
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+			if !reflect.DeepEqual(items, []int{3}) {
+				t.Fatal("expected", []int{3}, "got", items)
+			}
+		}
+
+		// Unknown versions must be refused.
+		{
+			err := newService.Rollback(ctx, namespace, 3)
+			if !IsNotFound(err) {
+				t.Fatal("expected", true, "got", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,249 @@
+package rangepool
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/giantswarm/microerror"
+)
+
+// SchemaVersion is the version of the storage layout written by this version
+// of the range pool, see SchemaKeyFormat. It is increased whenever the layout
+// changes in a way older versions cannot read.
+const SchemaVersion = 1
+
+// schema is the value persisted at SchemaKeyFormat. It describes the storage
+// layout a namespace is persisted with.
+type schema struct {
+	Bitmap         bool `json:"bitmap"`
+	Version        int  `json:"version"`
+	ZeroPaddedKeys bool `json:"zeroPaddedKeys"`
+}
+
+// String describes the layout in a human readable way for error messages.
+func (s schema) String() string {
+	layout := "item keys"
+	if s.Bitmap {
+		layout = "bitmap"
+	} else if s.ZeroPaddedKeys {
+		layout = "zero padded item keys"
+	}
+
+	if s.Version == 0 {
+		return fmt.Sprintf("%s (without schema marker)", layout)
+	}
+
+	return fmt.Sprintf("%s (schema version %d)", layout, s.Version)
+}
+
+// sameLayout returns whether the given schemas describe the same storage
+// layout, no matter the schema version they were written with.
+func sameLayout(a, b schema) bool {
+	return a.Bitmap == b.Bitmap && a.ZeroPaddedKeys == b.ZeroPaddedKeys
+}
+
+// schemaCache remembers the namespaces whose storage layout has been
+// verified, so that the schema marker is only read once per namespace. It is
+// shared by the copies of a Service created by Service.withPolicy.
+type schemaCache struct {
+	mutex      sync.Mutex
+	namespaces map[string]struct{}
+}
+
+func newSchemaCache() *schemaCache {
+	c := &schemaCache{
+		mutex:      sync.Mutex{},
+		namespaces: map[string]struct{}{},
+	}
+
+	return c
+}
+
+func (c *schemaCache) Add(namespace string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.namespaces[namespace] = struct{}{}
+}
+
+func (c *schemaCache) Has(namespace string) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	_, ok := c.namespaces[namespace]
+	return ok
+}
+
+// ensureSchema verifies that the given namespace is persisted with the
+// storage layout configured for the Service, see Config.Bitmap and
+// Config.ZeroPaddedKeys. Namespaces persisted before schema markers existed
+// are inspected to find their layout. Namespaces persisted with another
+// layout are migrated using Service.Compact and Service.MigrateKeys in case
+// Config.SchemaMigration is enabled. Otherwise, and for namespaces persisted
+// by newer versions of the range pool, an error is returned which can be
+// asserted using IsSchemaMismatch. ensureSchema is called by all operations
+// modifying the items of a namespace. Once verified, namespaces are not
+// verified again during the lifetime of the Service.
+func (s *Service) ensureSchema(ctx context.Context, namespace string) error {
+	if s.schemas.Has(namespace) {
+		return nil
+	}
+
+	current, ok, err := s.searchSchema(ctx, namespace)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	if !ok {
+		current, err = s.searchLayout(ctx, namespace)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	expected := s.schema()
+
+	if current.Version > SchemaVersion {
+		return microerror.Maskf(schemaMismatchError, "namespace '%s' is persisted as %s, but only schema version %d is supported", namespace, current, SchemaVersion)
+	}
+
+	if !sameLayout(current, expected) {
+		if !s.schemaMigration {
+			return microerror.Maskf(schemaMismatchError, "namespace '%s' is persisted as %s, but configured as %s", namespace, current, expected)
+		}
+
+		if current.Bitmap != expected.Bitmap {
+			err := s.Compact(ctx, namespace)
+			if err != nil {
+				return microerror.Mask(err)
+			}
+		}
+		if current.ZeroPaddedKeys != expected.ZeroPaddedKeys {
+			err := s.MigrateKeys(ctx, namespace)
+			if err != nil {
+				return microerror.Mask(err)
+			}
+		}
+
+		s.logger.LogCtx(ctx, "level", "info", "message", fmt.Sprintf("migrated namespace '%s' from %s to %s", namespace, current, expected))
+	}
+
+	if !ok || current != expected {
+		err := s.createSchema(ctx, namespace, expected)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	s.schemas.Add(namespace)
+
+	return nil
+}
+
+func (s *Service) createSchema(ctx context.Context, namespace string, l schema) error {
+	b, err := json.Marshal(l)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = s.storage.Create(ctx, s.key(SchemaKeyFormat, namespace), string(b))
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// schema returns the storage layout configured for the Service.
+func (s *Service) schema() schema {
+	return schema{
+		Bitmap:         s.bitmap,
+		Version:        SchemaVersion,
+		ZeroPaddedKeys: s.zeroPaddedKeys,
+	}
+}
+
+// searchLayout finds the storage layout of a namespace persisted without
+// schema marker. Item keys not matching the configured encoding indicate the
+// other encoding. Layouts which cannot be told apart, e.g. of empty
+// namespaces, are considered to match the configured one. The version of the
+// returned schema is 0, since the namespace was not written with a marker.
+func (s *Service) searchLayout(ctx context.Context, namespace string) (schema, error) {
+	l := s.schema()
+	l.Version = 0
+
+	_, err := s.storage.Search(ctx, s.key(BitmapKeyFormat, namespace))
+	if IsNotFound(err) {
+		// Fall through since the namespace might be empty.
+	} else if err != nil {
+		return schema{}, microerror.Mask(err)
+	} else {
+		l.Bitmap = true
+		return l, nil
+	}
+
+	err = walk(ctx, s.storage, s.key(ItemListKeyFormat, namespace), func(kv KV) error {
+		item, err := strconv.Atoi(kv.Value)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+		l.Bitmap = false
+		if kv.Key != s.encodeItem(item) {
+			l.ZeroPaddedKeys = !s.zeroPaddedKeys
+			return microerror.Mask(stopWalkError)
+		}
+
+		return nil
+	})
+	if isStopWalk(err) {
+		// Fall through since we stopped the walk ourselves.
+	} else if err != nil {
+		return schema{}, microerror.Mask(err)
+	}
+
+	return l, nil
+}
+
+// searchSchema fetches the schema marker of the given namespace. The second
+// return value is false in case the namespace does not have a marker yet.
+func (s *Service) searchSchema(ctx context.Context, namespace string) (schema, bool, error) {
+	var l schema
+
+	v, err := s.storage.Search(ctx, s.key(SchemaKeyFormat, namespace))
+	if IsNotFound(err) {
+		return schema{}, false, nil
+	} else if err != nil {
+		return schema{}, false, microerror.Mask(err)
+	}
+
+	err = json.Unmarshal([]byte(v), &l)
+	if err != nil {
+		return schema{}, false, microerror.Maskf(executionFailedError, "decoding schema of namespace '%s': %s", namespace, err.Error())
+	}
+
+	return l, true, nil
+}
+
+// updateSchema applies the given change to the schema marker of the given
+// namespace, in case the namespace has a marker. It is used by migrations
+// changing the layout of a namespace, so that the marker keeps describing it.
+func (s *Service) updateSchema(ctx context.Context, namespace string, update func(l *schema)) error {
+	l, ok, err := s.searchSchema(ctx, namespace)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	if !ok {
+		return nil
+	}
+
+	update(&l)
+
+	err = s.createSchema(ctx, namespace, l)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
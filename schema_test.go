@@ -0,0 +1,158 @@
+package rangepool
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/giantswarm/micrologger/microloggertest"
+)
+
+func Test_Service_ensureSchema(t *testing.T) {
+	var err error
+	var newStorage Storage
+	{
+		newStorage, err = newMemoryStorage()
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+	}
+
+	newService := func(bitmap, zeroPaddedKeys, migration bool) *Service {
+		config := DefaultConfig()
+		config.Logger = microloggertest.New()
+		config.Storage = newStorage
+		config.Bitmap = bitmap
+		config.SchemaMigration = migration
+		config.ZeroPaddedKeys = zeroPaddedKeys
+		s, err := New(config)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		return s
+	}
+
+	assertSchema := func(expected schema) {
+		l, ok, err := newService(false, false, false).searchSchema(context.TODO(), namespace)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		if !ok {
+			t.Fatal("expected", true, "got", false)
+		}
+		if l != expected {
+			t.Fatal("expected", expected, "got", l)
+		}
+	}
+
+	ctx := context.TODO()
+
+	// Allocations must mark new namespaces with the configured layout.
+	{
+		_, err := newService(false, false, true).Create(ctx, namespace, "test-id-1", 2, 8, 12)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		assertSchema(schema{Version: SchemaVersion})
+	}
+
+	// Namespaces persisted with another layout must be refused in case
+	// migrations are disabled.
+	{
+		_, err := newService(true, false, false).Create(ctx, namespace, "test-id-2", 1, 8, 12)
+		if !IsSchemaMismatch(err) {
+			t.Fatal("expected", true, "got", false)
+		}
+	}
+
+	// Namespaces persisted without marker must be inspected and migrated. The
+	// items must be preserved.
+	{
+		err := newStorage.Delete(ctx, fmt.Sprintf(SchemaKeyFormat, namespace))
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		s := newService(false, true, true)
+
+		items, err := s.Create(ctx, namespace, "test-id-2", 1, 8, 12)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		if !reflect.DeepEqual(items, []int{10}) {
+			t.Fatal("expected", []int{10}, "got", items)
+		}
+
+		assertSchema(schema{Version: SchemaVersion, ZeroPaddedKeys: true})
+
+		_, err = newStorage.Search(ctx, fmt.Sprintf(ItemKeyFormat, namespace, "0000000008"))
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+	}
+
+	// Namespaces must be migrated into bitmaps transparently.
+	{
+		s := newService(true, true, true)
+
+		err := s.Delete(ctx, namespace, "test-id-2")
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		assertSchema(schema{Bitmap: true, Version: SchemaVersion, ZeroPaddedKeys: true})
+
+		d, err := s.Dump(ctx, namespace)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		if !reflect.DeepEqual(d.Used, []int{8, 9}) {
+			t.Fatal("expected", []int{8, 9}, "got", d.Used)
+		}
+
+		isEmpty, err := isEmpty(ctx, newStorage, fmt.Sprintf(ItemListKeyFormat, namespace))
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		if !isEmpty {
+			t.Fatal("expected", true, "got", false)
+		}
+	}
+
+	// Empty namespaces without marker must be considered to match the
+	// configured layout, even in case migrations are disabled.
+	{
+		_, err := newService(true, false, false).Create(ctx, "empty-namespace", "test-id-1", 1, 8, 12)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		l, ok, err := newService(false, false, false).searchSchema(ctx, "empty-namespace")
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		if !ok {
+			t.Fatal("expected", true, "got", false)
+		}
+		expected := schema{Bitmap: true, Version: SchemaVersion}
+		if l != expected {
+			t.Fatal("expected", expected, "got", l)
+		}
+	}
+
+	// Namespaces persisted by newer versions must always be refused.
+	{
+		err := newStorage.Create(ctx, fmt.Sprintf(SchemaKeyFormat, namespace), fmt.Sprintf(`{"bitmap":true,"version":%d}`, SchemaVersion+1))
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		_, err = newService(true, false, true).Create(ctx, namespace, "test-id-3", 1, 8, 12)
+		if !IsSchemaMismatch(err) {
+			t.Fatal("expected", true, "got", false)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package rangepool
+
+import (
+	"context"
+	"path"
+
+	"github.com/giantswarm/microerror"
+)
+
+// SearchAll returns the items of the given ID within every namespace, keyed
+// by namespace, so that teardown code can find everything an ID owns without
+// knowing each namespace. Namespaces are discovered like for Backup. In case
+// the ID does not have any items, an error is returned which can be asserted
+// using IsItemsNotFound.
+func (s *Service) SearchAll(ctx context.Context, ID string) (map[string][]int, error) {
+	namespaces, err := s.searchNamespaces(ctx)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	all := map[string][]int{}
+	for _, namespace := range namespaces {
+		items, err := s.Search(ctx, namespace, ID)
+		if IsItemsNotFound(err) {
+			continue
+		} else if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		all[namespace] = items
+	}
+
+	if len(all) == 0 {
+		return nil, microerror.Maskf(itemsNotFoundError, "no items in any namespace for ID '%s'", ID)
+	}
+
+	return all, nil
+}
+
+// SearchIDs returns the items of all IDs within the given namespace matching
+// the given glob pattern, keyed by ID, e.g. to clean up families of related
+// IDs. The pattern syntax is the one of path.Match, so "cluster-abc-*" matches
+// all IDs starting with "cluster-abc-". In case the pattern is malformed, an
+// error is returned which can be asserted using IsInvalidArgument. In case no
+// ID matches, an error is returned which can be asserted using
+// IsItemsNotFound.
+func (s *Service) SearchIDs(ctx context.Context, namespace, pattern string) (map[string][]int, error) {
+	_, err := path.Match(pattern, "")
+	if err != nil {
+		return nil, microerror.Maskf(invalidArgumentError, "pattern '%s': %s", pattern, err.Error())
+	}
+
+	owned, err := s.searchOwnedByID(ctx, namespace)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	for ID := range owned {
+		ok, _ := path.Match(pattern, ID)
+		if !ok {
+			delete(owned, ID)
+		}
+	}
+
+	if len(owned) == 0 {
+		return nil, microerror.Maskf(itemsNotFoundError, "no items in namespace '%s' for IDs matching '%s'", namespace, pattern)
+	}
+
+	return owned, nil
+}
+
+// SearchMany returns the items of the given IDs within the given namespace,
+// keyed by ID. Unlike calling Search for every ID, the namespace is listed
+// only once. IDs without items are omitted from the result.
+func (s *Service) SearchMany(ctx context.Context, namespace string, IDs []string) (map[string][]int, error) {
+	owned, err := s.searchOwnedByID(ctx, namespace)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	found := map[string][]int{}
+	for _, ID := range IDs {
+		items, ok := owned[ID]
+		if ok {
+			found[ID] = items
+		}
+	}
+
+	return found, nil
+}
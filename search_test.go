@@ -0,0 +1,185 @@
+package rangepool
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/giantswarm/micrologger/microloggertest"
+)
+
+func Test_Service_SearchAll(t *testing.T) {
+	var newService *Service
+	{
+		newStorage, err := newMemoryStorage()
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		config := DefaultConfig()
+		config.Logger = microloggertest.New()
+		config.Storage = newStorage
+		newService, err = New(config)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+	}
+
+	ctx := context.TODO()
+
+	// IDs without items must not be found.
+	{
+		_, err := newService.SearchAll(ctx, "test-id-1")
+		if !IsItemsNotFound(err) {
+			t.Fatal("expected", true, "got", false)
+		}
+	}
+
+	for _, c := range []struct {
+		Namespace string
+		ID        string
+		Num       int
+	}{{"vlan", "test-id-1", 1}, {"vni", "test-id-1", 2}, {"vni", "test-id-2", 1}, {"port", "test-id-2", 1}} {
+		_, err := newService.Create(ctx, c.Namespace, c.ID, c.Num, 2, 10)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+	}
+
+	// The items of the ID must be found in every namespace.
+	{
+		all, err := newService.SearchAll(ctx, "test-id-1")
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		expected := map[string][]int{
+			"vlan": {2},
+			"vni":  {2, 3},
+		}
+		if !reflect.DeepEqual(all, expected) {
+			t.Fatal("expected", expected, "got", all)
+		}
+	}
+}
+
+func Test_Service_SearchIDs(t *testing.T) {
+	var newService *Service
+	{
+		newStorage, err := newMemoryStorage()
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		config := DefaultConfig()
+		config.Logger = microloggertest.New()
+		config.Storage = newStorage
+		newService, err = New(config)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+	}
+
+	ctx := context.TODO()
+
+	for _, ID := range []string{"cluster-abc-1", "cluster-abc-2", "cluster-abd-1"} {
+		_, err := newService.Create(ctx, namespace, ID, 1, 2, 10)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+	}
+
+	testCases := []struct {
+		Pattern      string
+		Expected     map[string][]int
+		ErrorMatcher func(error) bool
+	}{
+		// Case 1 ensures prefixes match all IDs starting with them.
+		{
+			Pattern: "cluster-abc-*",
+			Expected: map[string][]int{
+				"cluster-abc-1": {2},
+				"cluster-abc-2": {3},
+			},
+			ErrorMatcher: nil,
+		},
+		// Case 2 ensures globs match anywhere within IDs.
+		{
+			Pattern: "cluster-ab?-1",
+			Expected: map[string][]int{
+				"cluster-abc-1": {2},
+				"cluster-abd-1": {4},
+			},
+			ErrorMatcher: nil,
+		},
+		// Case 3 ensures patterns without matches are reported.
+		{
+			Pattern:      "cluster-xyz-*",
+			Expected:     nil,
+			ErrorMatcher: IsItemsNotFound,
+		},
+		// Case 4 ensures malformed patterns are rejected.
+		{
+			Pattern:      "cluster-[",
+			Expected:     nil,
+			ErrorMatcher: IsInvalidArgument,
+		},
+	}
+
+	for i, tc := range testCases {
+		owned, err := newService.SearchIDs(ctx, namespace, tc.Pattern)
+		if tc.ErrorMatcher != nil {
+			if !tc.ErrorMatcher(err) {
+				t.Fatal("case", i+1, "expected", true, "got", false)
+			}
+			continue
+		} else if err != nil {
+			t.Fatal("case", i+1, "expected", nil, "got", err)
+		}
+
+		if !reflect.DeepEqual(owned, tc.Expected) {
+			t.Fatal("case", i+1, "expected", tc.Expected, "got", owned)
+		}
+	}
+}
+
+func Test_Service_SearchMany(t *testing.T) {
+	var newService *Service
+	{
+		newStorage, err := newMemoryStorage()
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		config := DefaultConfig()
+		config.Logger = microloggertest.New()
+		config.Storage = newStorage
+		newService, err = New(config)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+	}
+
+	ctx := context.TODO()
+
+	for _, ID := range []string{"test-id-1", "test-id-2", "test-id-3"} {
+		_, err := newService.Create(ctx, namespace, ID, 2, 2, 10)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+	}
+
+	// Only the requested IDs owning items must be returned.
+	{
+		found, err := newService.SearchMany(ctx, namespace, []string{"test-id-1", "test-id-3", "test-id-4"})
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		expected := map[string][]int{
+			"test-id-1": {2, 3},
+			"test-id-3": {6, 7},
+		}
+		if !reflect.DeepEqual(found, expected) {
+			t.Fatal("expected", expected, "got", found)
+		}
+	}
+}
@@ -0,0 +1,301 @@
+package rangepool
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/giantswarm/microerror"
+)
+
+// SnapshotVersion is the version of the snapshot format written by
+// Service.Export. It is increased whenever the format changes in an
+// incompatible way.
+const SnapshotVersion = 1
+
+// Snapshot is the portable state of a namespace, see Service.Export and
+// Service.Import. It does not depend on the storage representation of the
+// namespace, e.g. Config.Bitmap or Config.KeyPrefix, so that namespaces can be
+// moved between storage backends or environments. Its JSON encoding is
+// stable.
+type Snapshot struct {
+	// Burned are the permanently retired items of the namespace in ascending
+	// order, see Service.Burn.
+	Burned []int `json:"burned,omitempty"`
+	// IDs maps the IDs of the namespace to their items in ascending order.
+	// Reserved items are owned by the reservation IDs of their reservations,
+	// see Reservations.
+	IDs map[string][]int `json:"ids"`
+	// Latest is the latest item allocated within the namespace. It is -1 in
+	// case no item has ever been allocated.
+	Latest int `json:"latest"`
+	// Namespace is the namespace the state belongs to.
+	Namespace string `json:"namespace"`
+	// Policy is the allocation policy of the namespace, see
+	// Service.SetPolicy. It is nil in case no policy has been set.
+	Policy *Policy `json:"policy,omitempty"`
+	// Reservations are the pending reservations of the namespace ordered by
+	// token, see Service.Reserve. Their items are released once they expire,
+	// so they must be kept alongside the items owned by their reservation IDs.
+	Reservations []Reservation `json:"reservations,omitempty"`
+	// Version is the version of the snapshot format, see SnapshotVersion.
+	Version int `json:"version"`
+}
+
+// Export returns the portable state of the given namespace, which is made of
+// its allocations, burned items, policy and pending reservations. The
+// relationships between IDs and items are the source of truth, so items not
+// owned by any ID are not exported.
+func (s *Service) Export(ctx context.Context, namespace string) (Snapshot, error) {
+	IDs, err := s.searchOwnedByID(ctx, namespace)
+	if err != nil {
+		return Snapshot{}, microerror.Mask(err)
+	}
+
+	latest, err := s.searchLatest(ctx, namespace, callOptions{})
+	if err != nil {
+		return Snapshot{}, microerror.Mask(err)
+	}
+
+	// The burned items are read from the storage, since cached ones might be
+	// incomplete, see Config.BurnedCacheTTL.
+	burned, err := s.searchItems(ctx, s.key(BurnedListKeyFormat, namespace))
+	if err != nil {
+		return Snapshot{}, microerror.Mask(err)
+	}
+	sort.Ints(burned)
+
+	var policy *Policy
+	{
+		p, err := s.Policy(ctx, namespace)
+		if err != nil {
+			return Snapshot{}, microerror.Mask(err)
+		}
+		if !isEmptyPolicy(p) {
+			policy = &p
+		}
+	}
+
+	var reservations []Reservation
+	err = walk(ctx, s.storage, s.key(ReservationListKeyFormat, namespace), func(kv KV) error {
+		var r Reservation
+		err := json.Unmarshal([]byte(kv.Value), &r)
+		if err != nil {
+			return microerror.Maskf(executionFailedError, "decoding reservation '%s': %s", kv.Key, err.Error())
+		}
+		reservations = append(reservations, r)
+
+		return nil
+	})
+	if err != nil {
+		return Snapshot{}, microerror.Mask(err)
+	}
+	sort.Slice(reservations, func(i, j int) bool {
+		return reservations[i].Token < reservations[j].Token
+	})
+
+	snapshot := Snapshot{
+		Burned:       burned,
+		IDs:          IDs,
+		Latest:       latest,
+		Namespace:    namespace,
+		Policy:       policy,
+		Reservations: reservations,
+		Version:      SnapshotVersion,
+	}
+
+	return snapshot, nil
+}
+
+// Import persists the given snapshot using the storage representation
+// configured for the Service. The namespace of the snapshot must not hold any
+// items yet, otherwise an error is returned which can be asserted using
+// IsNamespaceNotEmpty. To import a snapshot into a different namespace, change
+// its Namespace before importing it. Import must not be executed concurrently
+// with other operations on the same namespace.
+func (s *Service) Import(ctx context.Context, snapshot Snapshot) error {
+	ctx = withOperation(ctx, "Import", snapshot.Namespace, "")
+
+	err := s.checkReadOnly()
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = s.checkLeader()
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = validateSnapshot(snapshot)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	namespace := snapshot.Namespace
+
+	{
+		d, err := s.Dump(ctx, namespace)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+		if len(d.IDs) != 0 || len(d.Used) != 0 {
+			return microerror.Maskf(namespaceNotEmptyError, "namespace '%s' holds %d items", namespace, len(d.Used))
+		}
+	}
+
+	// The cached items of the namespace are going to be rewritten.
+	defer s.InvalidateCache(namespace)
+
+	var IDs []string
+	for ID := range snapshot.IDs {
+		IDs = append(IDs, ID)
+	}
+	sort.Strings(IDs)
+
+	var kvs []KV
+	var used bitmap
+	for _, ID := range IDs {
+		for _, item := range snapshot.IDs[ID] {
+			i := strconv.Itoa(item)
+			k := s.encodeItem(item)
+
+			if s.bitmap {
+				used.Set(item)
+			} else {
+				kvs = append(kvs, KV{Key: s.key(ItemKeyFormat, namespace, k), Value: i})
+			}
+			kvs = append(kvs, KV{Key: s.key(IDKeyFormat, namespace, ID, k), Value: i})
+		}
+	}
+	if s.bitmap && used.Len() != 0 {
+		kvs = append(kvs, KV{Key: s.key(BitmapKeyFormat, namespace), Value: s.encodeBitmap(used)})
+	}
+	if snapshot.Latest != latestItemException {
+		kvs = append(kvs, KV{Key: s.key(LatestKeyFormat, namespace), Value: s.encodeLatest(snapshot.Latest)})
+	}
+	for _, item := range snapshot.Burned {
+		kvs = append(kvs, KV{Key: s.key(BurnedKeyFormat, namespace, s.encodeItem(item)), Value: strconv.Itoa(item)})
+	}
+	if snapshot.Policy != nil && !isEmptyPolicy(*snapshot.Policy) {
+		b, err := json.Marshal(*snapshot.Policy)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+		kvs = append(kvs, KV{Key: s.key(PolicyKeyFormat, namespace), Value: string(b)})
+	}
+	for _, r := range snapshot.Reservations {
+		// The reservations are moved along with the snapshot, so that their
+		// tokens refer to the namespace they are imported into.
+		key := reservationKey(r.Token)
+		r.Namespace = namespace
+		r.Token = namespace + "/" + key
+
+		b, err := json.Marshal(r)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+		kvs = append(kvs, KV{Key: s.key(ReservationKeyFormat, namespace, key), Value: string(b)})
+	}
+
+	if len(kvs) == 0 {
+		return nil
+	}
+
+	err = createBatch(ctx, s.storage, kvs)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	for _, r := range snapshot.Reservations {
+		s.sweeps.Lower(namespace, r.ExpiresAt)
+	}
+
+	s.logger.LogCtx(ctx, "level", "debug", "message", fmt.Sprintf("imported %d IDs into namespace '%s'", len(IDs), namespace))
+
+	return nil
+}
+
+// CloneNamespace copies the portable state of the namespace src into the
+// namespace dst, e.g. to stand up a staging environment mirroring the
+// assignments of production, see Export. The namespace dst must not hold any
+// items yet, otherwise an error is returned which can be asserted using
+// IsNamespaceNotEmpty. CloneNamespace must not be executed concurrently with
+// other operations on dst.
+func (s *Service) CloneNamespace(ctx context.Context, src, dst string) error {
+	ctx = withOperation(ctx, "CloneNamespace", dst, "")
+
+	if src == dst {
+		return microerror.Maskf(invalidArgumentError, "source and destination namespace must differ")
+	}
+
+	snapshot, err := s.Export(ctx, src)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	snapshot.Namespace = dst
+
+	err = s.Import(ctx, snapshot)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// validateSnapshot checks whether the given snapshot can be imported.
+func validateSnapshot(snapshot Snapshot) error {
+	if snapshot.Version != SnapshotVersion {
+		return microerror.Maskf(invalidSnapshotError, "version must be %d, got %d", SnapshotVersion, snapshot.Version)
+	}
+	if snapshot.Namespace == "" {
+		return microerror.Maskf(invalidSnapshotError, "namespace must not be empty")
+	}
+	if snapshot.Latest < latestItemException {
+		return microerror.Maskf(invalidSnapshotError, "latest must not be less than %d", latestItemException)
+	}
+
+	for _, item := range snapshot.Burned {
+		if item < 0 {
+			return microerror.Maskf(invalidSnapshotError, "burned item %d must not be negative", item)
+		}
+	}
+	if snapshot.Policy != nil {
+		err := validatePolicy(*snapshot.Policy)
+		if err != nil {
+			return microerror.Maskf(invalidSnapshotError, "policy: %s", err.Error())
+		}
+	}
+	for _, r := range snapshot.Reservations {
+		key := reservationKey(r.Token)
+		if key == "" {
+			return microerror.Maskf(invalidSnapshotError, "token '%s' of reservation must not be empty", r.Token)
+		}
+		if r.ID == "" {
+			return microerror.Maskf(invalidSnapshotError, "ID of reservation '%s' must not be empty", r.Token)
+		}
+	}
+
+	owners := map[int]string{}
+	for ID, items := range snapshot.IDs {
+		if ID == "" || strings.Contains(ID, "/") {
+			return microerror.Maskf(invalidSnapshotError, "ID '%s' must not be empty or contain '/'", ID)
+		}
+
+		for _, item := range items {
+			if item < 0 {
+				return microerror.Maskf(invalidSnapshotError, "item %d of ID '%s' must not be negative", item, ID)
+			}
+
+			owner, ok := owners[item]
+			if ok {
+				return microerror.Maskf(invalidSnapshotError, "item %d is owned by ID '%s' and ID '%s'", item, owner, ID)
+			}
+			owners[item] = ID
+		}
+	}
+
+	return nil
+}
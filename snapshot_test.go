@@ -0,0 +1,249 @@
+package rangepool
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/giantswarm/micrologger/microloggertest"
+)
+
+func Test_Service_ExportImport(t *testing.T) {
+	newTestService := func(bitmap, zeroPaddedKeys bool) *Service {
+		newStorage, err := newMemoryStorage()
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		config := DefaultConfig()
+		config.Logger = microloggertest.New()
+		config.Storage = newStorage
+		config.Bitmap = bitmap
+		config.ZeroPaddedKeys = zeroPaddedKeys
+		newService, err := New(config)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		return newService
+	}
+
+	ctx := context.TODO()
+
+	source := newTestService(true, false)
+	target := newTestService(false, true)
+
+	_, err := source.Create(ctx, namespace, "test-id-1", 2, 2, 10)
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+	_, err = source.Create(ctx, namespace, "test-id-2", 2, 2, 10)
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+	err = source.Delete(ctx, namespace, "test-id-1")
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+
+	var snapshot Snapshot
+	{
+		s, err := source.Export(ctx, namespace)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		b, err := json.Marshal(s)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		expected := `{"ids":{"test-id-2":[4,5]},"latest":5,"namespace":"test-namespace","version":1}`
+		if string(b) != expected {
+			t.Fatal("expected", expected, "got", string(b))
+		}
+
+		err = json.Unmarshal(b, &snapshot)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+	}
+
+	// The imported namespace must match the exported one, no matter the
+	// storage representation.
+	{
+		err = target.Import(ctx, snapshot)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		s, err := source.Dump(ctx, namespace)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		d, err := target.Dump(ctx, namespace)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		if !reflect.DeepEqual(d, s) {
+			t.Fatal("expected", s, "got", d)
+		}
+	}
+
+	// Allocations must continue from the imported latest item.
+	{
+		items, err := target.Create(ctx, namespace, "test-id-3", 1, 2, 10)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		expected := []int{6}
+		if !reflect.DeepEqual(items, expected) {
+			t.Fatal("expected", expected, "got", items)
+		}
+	}
+
+	// Importing into a namespace holding items must fail.
+	{
+		err = target.Import(ctx, snapshot)
+		if !IsNamespaceNotEmpty(err) {
+			t.Fatal("expected", true, "got", false)
+		}
+	}
+}
+
+func Test_Service_CloneNamespace(t *testing.T) {
+	var newService *Service
+	{
+		newStorage, err := newMemoryStorage()
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		config := DefaultConfig()
+		config.Logger = microloggertest.New()
+		config.Storage = newStorage
+		newService, err = New(config)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+	}
+
+	ctx := context.TODO()
+
+	_, err := newService.Create(ctx, "production", "test-id-1", 2, 2, 10)
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+
+	err = newService.CloneNamespace(ctx, "production", "staging")
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+
+	// The clone must mirror the allocations of the source.
+	{
+		items, err := newService.Search(ctx, "staging", "test-id-1")
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		expected := []int{2, 3}
+		if !reflect.DeepEqual(items, expected) {
+			t.Fatal("expected", expected, "got", items)
+		}
+	}
+
+	// Allocations of the clone must continue from the latest item of the
+	// source, without affecting the source.
+	{
+		items, err := newService.Create(ctx, "staging", "test-id-2", 1, 2, 10)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		expected := []int{4}
+		if !reflect.DeepEqual(items, expected) {
+			t.Fatal("expected", expected, "got", items)
+		}
+
+		_, err = newService.Search(ctx, "production", "test-id-2")
+		if !IsItemsNotFound(err) {
+			t.Fatal("expected", true, "got", false)
+		}
+	}
+
+	// Cloning into a namespace holding items must fail.
+	{
+		err := newService.CloneNamespace(ctx, "production", "staging")
+		if !IsNamespaceNotEmpty(err) {
+			t.Fatal("expected", true, "got", false)
+		}
+	}
+}
+
+func Test_validateSnapshot(t *testing.T) {
+	testCases := []struct {
+		Snapshot     Snapshot
+		ErrorMatcher func(err error) bool
+	}{
+		// Case 1 ensures valid snapshots are accepted.
+		{
+			Snapshot:     Snapshot{IDs: map[string][]int{"a": {1, 2}, "b": {3}}, Latest: 3, Namespace: namespace, Version: SnapshotVersion},
+			ErrorMatcher: nil,
+		},
+		// Case 2 ensures snapshots of empty namespaces are accepted.
+		{
+			Snapshot:     Snapshot{Latest: -1, Namespace: namespace, Version: SnapshotVersion},
+			ErrorMatcher: nil,
+		},
+		// Case 3 ensures unknown versions are rejected.
+		{
+			Snapshot:     Snapshot{Latest: -1, Namespace: namespace, Version: SnapshotVersion + 1},
+			ErrorMatcher: IsInvalidSnapshot,
+		},
+		// Case 4 ensures empty namespaces are rejected.
+		{
+			Snapshot:     Snapshot{Latest: -1, Version: SnapshotVersion},
+			ErrorMatcher: IsInvalidSnapshot,
+		},
+		// Case 5 ensures items owned by multiple IDs are rejected.
+		{
+			Snapshot:     Snapshot{IDs: map[string][]int{"a": {1, 2}, "b": {2}}, Latest: 2, Namespace: namespace, Version: SnapshotVersion},
+			ErrorMatcher: IsInvalidSnapshot,
+		},
+		// Case 6 ensures negative items are rejected.
+		{
+			Snapshot:     Snapshot{IDs: map[string][]int{"a": {-2}}, Latest: -1, Namespace: namespace, Version: SnapshotVersion},
+			ErrorMatcher: IsInvalidSnapshot,
+		},
+		// Case 7 ensures IDs containing slashes are rejected.
+		{
+			Snapshot:     Snapshot{IDs: map[string][]int{"a/b": {1}}, Latest: 1, Namespace: namespace, Version: SnapshotVersion},
+			ErrorMatcher: IsInvalidSnapshot,
+		},
+		// Case 8 ensures negative burned items are rejected.
+		{
+			Snapshot:     Snapshot{Burned: []int{-1}, Latest: -1, Namespace: namespace, Version: SnapshotVersion},
+			ErrorMatcher: IsInvalidSnapshot,
+		},
+		// Case 9 ensures invalid policies are rejected.
+		{
+			Snapshot:     Snapshot{Latest: -1, Namespace: namespace, Policy: &Policy{IDQuota: -1}, Version: SnapshotVersion},
+			ErrorMatcher: IsInvalidSnapshot,
+		},
+		// Case 10 ensures reservations with malformed tokens are rejected.
+		{
+			Snapshot:     Snapshot{Latest: -1, Namespace: namespace, Reservations: []Reservation{{ID: "a", Token: "key"}}, Version: SnapshotVersion},
+			ErrorMatcher: IsInvalidSnapshot,
+		},
+	}
+
+	for i, tc := range testCases {
+		err := validateSnapshot(tc.Snapshot)
+
+		if err != nil && tc.ErrorMatcher == nil {
+			t.Fatal("case", i+1, "expected", nil, "got", err)
+		}
+		if tc.ErrorMatcher != nil && !tc.ErrorMatcher(err) {
+			t.Fatal("case", i+1, "expected", true, "got", false)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package rangepool
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// Interface is implemented by Service. Consumers of the range pool should
+// depend on Interface, so that it can be replaced by a mock or fake in their
+// unit tests, see rangepooltest.
+type Interface interface {
+	// Abort releases the items of the reservation identified by the given
+	// token.
+	Abort(ctx context.Context, token string) error
+	// Adopt registers the given items, which were assigned outside of the
+	// range pool, as owned by the given ID within the given namespace. Items
+	// outside of the range or used already are reported instead of adopted.
+	Adopt(ctx context.Context, namespace, ID string, items []int, min, max int) (AdoptReport, error)
+	// Allocate works like Create, but returns an Allocation describing the
+	// allocated items.
+	Allocate(ctx context.Context, namespace, ID string, num, min, max int) (Allocation, error)
+	// AuditLog returns the audit entries of the given namespace which have
+	// been recorded at or after since.
+	AuditLog(ctx context.Context, namespace string, since time.Time) ([]AuditEntry, error)
+	// Backup writes the snapshots of all namespaces to the given writer.
+	Backup(ctx context.Context, w io.Writer) error
+	// Burn permanently retires the given items of the given namespace.
+	Burn(ctx context.Context, namespace string, items []int) error
+	// Close stops the background goroutines of the Service and releases the
+	// lease of its elector.
+	Close(ctx context.Context) error
+	// CloneNamespace copies all allocations and the latest item of the
+	// namespace src into the empty namespace dst.
+	CloneNamespace(ctx context.Context, src, dst string) error
+	// Commit hands the items of the reservation identified by the given token
+	// over to the ID of the reservation.
+	Commit(ctx context.Context, token string) error
+	// Compact rewrites the given namespace into its most compact storage
+	// representation.
+	Compact(ctx context.Context, namespace string) error
+	// Create allocates num items in between min and max, both inclusive, for
+	// the given ID within the given namespace.
+	Create(ctx context.Context, namespace, ID string, num, min, max int) ([]int, error)
+	// CreateDefault allocates num items within the range configured for the
+	// given namespace for the given ID.
+	CreateDefault(ctx context.Context, namespace, ID string, num int) ([]int, error)
+	// CreateIf allocates num items like Create, but only in case the given
+	// precondition is met.
+	CreateIf(ctx context.Context, namespace, ID string, num, min, max int, precondition Precondition) ([]int, error)
+	// CreateInSubPool allocates num items within the given sub-pool of the
+	// given namespace for the given ID.
+	CreateInSubPool(ctx context.Context, namespace, subPool, ID string, num int) ([]int, error)
+	// CreateWait allocates num items like Create, but waits for capacity in
+	// the order the callers started waiting.
+	CreateWait(ctx context.Context, namespace, ID string, num, min, max int) ([]int, error)
+	// CreateTuple allocates one item from every given dimension for the given
+	// ID, either all of them or none.
+	CreateTuple(ctx context.Context, ID string, dimensions []Dimension) ([]int, error)
+	// Delete releases all items of the given ID within the given namespace.
+	Delete(ctx context.Context, namespace, ID string) error
+	// DeleteIf releases the items of the given ID like Delete, but only in
+	// case the ID holds exactly the expected items.
+	DeleteIf(ctx context.Context, namespace, ID string, expected []int) error
+	// Dump returns the full state of the given namespace.
+	Dump(ctx context.Context, namespace string) (Dump, error)
+	// Export returns the portable state of the given namespace.
+	Export(ctx context.Context, namespace string) (Snapshot, error)
+	// Forecast estimates when the range of the given namespace runs out of
+	// free items.
+	Forecast(ctx context.Context, namespace string, min, max int, ratePerDay float64) (Forecast, error)
+	// ForceRelease frees the given item within the given namespace, no matter
+	// which ID owns it.
+	ForceRelease(ctx context.Context, namespace string, item int) error
+	// GC removes the keys of the given namespace which are missing their
+	// counterparts.
+	GC(ctx context.Context, namespace string, dryRun bool) (GCReport, error)
+	// Healthz checks whether the storage is reachable.
+	Healthz(ctx context.Context) error
+	// History returns the previous owners of the given item within the given
+	// namespace, starting with the most recent one.
+	History(ctx context.Context, namespace string, item int) ([]HistoryEntry, error)
+	// Import persists the given snapshot into its empty namespace.
+	Import(ctx context.Context, snapshot Snapshot) error
+	// InvalidateCache drops the cached items of the given namespace.
+	InvalidateCache(namespace string)
+	// Latest returns the latest item allocated within the given namespace,
+	// which the next allocation continues after.
+	Latest(ctx context.Context, namespace string) (int, bool, error)
+	// MergeIDs hands all items of fromID within the given namespace over to
+	// intoID, which keeps its own items as well.
+	MergeIDs(ctx context.Context, namespace, fromID, intoID string) error
+	// MigrateKeys rewrites the item keys of the given namespace to the
+	// configured encoding.
+	MigrateKeys(ctx context.Context, namespace string) error
+	// NotifyFree signals items being released within the given namespace.
+	NotifyFree(ctx context.Context, namespace string) (<-chan struct{}, error)
+	// Policy returns the allocation policy persisted for the given namespace.
+	Policy(ctx context.Context, namespace string) (Policy, error)
+	// RenameID hands all items of oldID within the given namespace over to
+	// newID.
+	RenameID(ctx context.Context, namespace, oldID, newID string) error
+	// Reserve allocates items and holds them for the given ID until the
+	// returned reservation is committed or aborted.
+	Reserve(ctx context.Context, namespace, ID string, num, min, max int) (Reservation, error)
+	// ResetLatest removes the latest item of the given namespace, so that the
+	// next allocation starts at min again.
+	ResetLatest(ctx context.Context, namespace string) error
+	// Restore imports all snapshots read from the given reader.
+	Restore(ctx context.Context, r io.Reader) error
+	// Rollback restores the state the given namespace had when the snapshot
+	// of the given version was created.
+	Rollback(ctx context.Context, namespace string, version int) error
+	// Search returns the items of the given ID within the given namespace in
+	// numerically ascending order.
+	Search(ctx context.Context, namespace, ID string) ([]int, error)
+	// SearchAll returns the items of the given ID within every namespace.
+	SearchAll(ctx context.Context, ID string) (map[string][]int, error)
+	// SearchIDs returns the items of all IDs within the given namespace
+	// matching the given glob pattern.
+	SearchIDs(ctx context.Context, namespace, pattern string) (map[string][]int, error)
+	// SearchMany returns the items of the given IDs within the given
+	// namespace using a single listing.
+	SearchMany(ctx context.Context, namespace string, IDs []string) (map[string][]int, error)
+	// SearchRevision returns the items of the given ID within the given
+	// namespace together with the current revision of the ID.
+	SearchRevision(ctx context.Context, namespace, ID string) ([]int, string, error)
+	// Seek moves the latest item of the given namespace to the given item, so
+	// that the next allocation continues after it.
+	Seek(ctx context.Context, namespace string, item int) error
+	// SetPolicy persists the allocation policy of the given namespace.
+	SetPolicy(ctx context.Context, namespace string, policy Policy) error
+	// Snapshot persists the portable state of the given namespace and returns
+	// its version.
+	Snapshot(ctx context.Context, namespace string) (int, error)
+	// Status returns the utilization of the given namespace within the range
+	// defined by min and max.
+	Status(ctx context.Context, namespace string, min, max int) (Status, error)
+	// Verify checks the invariants of the given namespace against the range
+	// defined by min and max.
+	Verify(ctx context.Context, namespace string, min, max int) (VerifyReport, error)
+	// Warm preloads the state of the given namespaces, or all namespaces in
+	// case none is given, into the memory of the Service.
+	Warm(ctx context.Context, namespaces ...string) error
+	// Watch emits events for items being allocated and released within the
+	// given namespace.
+	Watch(ctx context.Context, namespace string) (<-chan Event, error)
+}
+
+var _ Interface = &Service{}
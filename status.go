@@ -0,0 +1,156 @@
+package rangepool
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/giantswarm/microerror"
+)
+
+// Status describes the utilization of the range of a namespace, see
+// Service.Status.
+type Status struct {
+	// AlmostFull is true in case the utilization is above the threshold
+	// configured using Config.AlmostFullThreshold.
+	AlmostFull bool
+	// Capacity is the number of items within the range.
+	Capacity int
+	// Free is the number of items within the range which are not used.
+	Free int
+	// FreeBlocks is the number of contiguous blocks of free items within the
+	// range. The more blocks the free items are split into, the more
+	// fragmented the range is.
+	FreeBlocks int
+	// LargestFreeBlock is the number of items of the largest contiguous block
+	// of free items within the range. Allocations of contiguous blocks of more
+	// items fail, no matter how many items are free.
+	LargestFreeBlock int
+	// Used is the number of items within the range which are used.
+	Used int
+	// Utilization is the ratio of used items within the range, from 0 to 1.
+	Utilization float64
+}
+
+// Status returns the utilization of the given namespace within the range
+// defined by min and max, both inclusive. Items used outside of the range are
+// not taken into account.
+func (s *Service) Status(ctx context.Context, namespace string, min, max int) (Status, error) {
+	ctx = withOperation(ctx, "Status", namespace, "")
+
+	err := validateBoundaries(min, max, latestItemException)
+	if err != nil {
+		return Status{}, microerror.Mask(err)
+	}
+
+	key := fmt.Sprintf("status/%d/%d", min, max)
+
+	v, ok := s.reads.Get(namespace, key)
+	if ok && !isLinearizable(ctx) {
+		return v.(Status), nil
+	}
+
+	var used []int
+	if s.bitmap {
+		b, err := s.searchBitmap(ctx, namespace)
+		if err != nil {
+			return Status{}, microerror.Mask(err)
+		}
+		used = b.Items()
+	} else {
+		used, err = s.searchUsed(ctx, namespace)
+		if err != nil {
+			return Status{}, microerror.Mask(err)
+		}
+	}
+
+	st := s.newStatus(countInRange(used, min, max), min, max)
+	st.FreeBlocks, st.LargestFreeBlock = freeBlocks(used, min, max)
+
+	s.reads.Set(namespace, key, st)
+
+	return st, nil
+}
+
+// newStatus computes the status of a range defined by min and max holding the
+// given number of used items.
+func (s *Service) newStatus(used, min, max int) Status {
+	capacity := max - min + 1
+	utilization := float64(used) / float64(capacity)
+
+	st := Status{
+		AlmostFull:  s.almostFullThreshold > 0 && utilization > s.almostFullThreshold,
+		Capacity:    capacity,
+		Free:        capacity - used,
+		Used:        used,
+		Utilization: utilization,
+	}
+
+	return st
+}
+
+// warnAlmostFull logs a warning in case the allocation of num items pushed the
+// utilization of the namespace above the threshold configured using
+// Config.AlmostFullThreshold. used is the number of items within the range
+// after the allocation.
+func (s *Service) warnAlmostFull(ctx context.Context, namespace string, num, used, min, max int) {
+	if s.almostFullThreshold <= 0 {
+		return
+	}
+
+	before := s.newStatus(used-num, min, max)
+	after := s.newStatus(used, min, max)
+	if before.AlmostFull || !after.AlmostFull {
+		return
+	}
+
+	s.logger.LogCtx(ctx,
+		"level", "warning",
+		"message", fmt.Sprintf("namespace '%s' is almost full", namespace),
+		"capacity", after.Capacity,
+		"free", after.Free,
+		"used", after.Used,
+		"utilization", fmt.Sprintf("%.2f", after.Utilization),
+		"threshold", fmt.Sprintf("%.2f", s.almostFullThreshold),
+	)
+}
+
+// freeBlocks returns the number of contiguous blocks of items in between min
+// and max, both inclusive, which are not part of the given items, and the
+// size of the largest of them.
+func freeBlocks(used []int, min, max int) (int, int) {
+	var inRange []int
+	for _, i := range used {
+		if i >= min && i <= max {
+			inRange = append(inRange, i)
+		}
+	}
+	sort.Ints(inRange)
+
+	var blocks, largest int
+	next := min
+	for _, i := range append(inRange, max+1) {
+		if i > next {
+			blocks++
+			if i-next > largest {
+				largest = i - next
+			}
+		}
+		next = i + 1
+	}
+
+	return blocks, largest
+}
+
+// countInRange returns the number of the given items in between min and max,
+// both inclusive.
+func countInRange(items []int, min, max int) int {
+	var n int
+	for _, i := range items {
+		if i >= min && i <= max {
+			n++
+		}
+	}
+
+	return n
+}
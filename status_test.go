@@ -0,0 +1,131 @@
+package rangepool
+
+import (
+	"context"
+	"testing"
+
+	"github.com/giantswarm/micrologger/microloggertest"
+)
+
+func Test_Service_Status(t *testing.T) {
+	for _, b := range []bool{false, true} {
+		var err error
+		var newService *Service
+		{
+			newStorage, err := newMemoryStorage()
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+
+			config := DefaultConfig()
+			config.Logger = microloggertest.New()
+			config.Storage = newStorage
+			config.AlmostFullThreshold = 0.5
+			config.Bitmap = b
+			newService, err = New(config)
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+		}
+
+		ctx := context.TODO()
+
+		// Using half of the range must not be considered almost full.
+		{
+			_, err = newService.Create(ctx, namespace, "test-id-1", 2, 2, 5)
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+
+			st, err := newService.Status(ctx, namespace, 2, 5)
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+			expected := Status{AlmostFull: false, Capacity: 4, Free: 2, FreeBlocks: 1, LargestFreeBlock: 2, Used: 2, Utilization: 0.5}
+			if st != expected {
+				t.Fatal("expected", expected, "got", st)
+			}
+		}
+
+		// Using more than half of the range must be considered almost full.
+		{
+			_, err = newService.Create(ctx, namespace, "test-id-2", 1, 2, 5)
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+
+			st, err := newService.Status(ctx, namespace, 2, 5)
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+			expected := Status{AlmostFull: true, Capacity: 4, Free: 1, FreeBlocks: 1, LargestFreeBlock: 1, Used: 3, Utilization: 0.75}
+			if st != expected {
+				t.Fatal("expected", expected, "got", st)
+			}
+		}
+
+		// Items outside of the range must not be taken into account.
+		{
+			st, err := newService.Status(ctx, namespace, 4, 9)
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+			if st.Used != 1 {
+				t.Fatal("expected", 1, "got", st.Used)
+			}
+		}
+	}
+}
+
+func Test_freeBlocks(t *testing.T) {
+	testCases := []struct {
+		Used                     []int
+		Min                      int
+		Max                      int
+		ExpectedFreeBlocks       int
+		ExpectedLargestFreeBlock int
+	}{
+		// Case 1 ensures an empty range is a single free block.
+		{
+			Used:                     nil,
+			Min:                      1,
+			Max:                      10,
+			ExpectedFreeBlocks:       1,
+			ExpectedLargestFreeBlock: 10,
+		},
+		// Case 2 ensures a full range does not have any free block.
+		{
+			Used:                     []int{3, 1, 2},
+			Min:                      1,
+			Max:                      3,
+			ExpectedFreeBlocks:       0,
+			ExpectedLargestFreeBlock: 0,
+		},
+		// Case 3 ensures gaps at the boundaries and in between are counted.
+		{
+			Used:                     []int{7, 2, 3},
+			Min:                      1,
+			Max:                      10,
+			ExpectedFreeBlocks:       3,
+			ExpectedLargestFreeBlock: 3,
+		},
+		// Case 4 ensures items outside of the range are ignored.
+		{
+			Used:                     []int{0, 5, 11},
+			Min:                      1,
+			Max:                      10,
+			ExpectedFreeBlocks:       2,
+			ExpectedLargestFreeBlock: 5,
+		},
+	}
+
+	for i, tc := range testCases {
+		blocks, largest := freeBlocks(tc.Used, tc.Min, tc.Max)
+		if blocks != tc.ExpectedFreeBlocks {
+			t.Fatal("case", i+1, "expected", tc.ExpectedFreeBlocks, "got", blocks)
+		}
+		if largest != tc.ExpectedLargestFreeBlock {
+			t.Fatal("case", i+1, "expected", tc.ExpectedLargestFreeBlock, "got", largest)
+		}
+	}
+}
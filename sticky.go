@@ -0,0 +1,66 @@
+package rangepool
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+
+	"github.com/giantswarm/microerror"
+)
+
+// recordSticky persists the items the given ID held before its release in
+// case Config.Sticky is enabled.
+func (s *Service) recordSticky(ctx context.Context, namespace, ID string, items []int) error {
+	if !s.sticky || len(items) == 0 {
+		return nil
+	}
+
+	b, err := json.Marshal(items)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = s.storage.Create(ctx, s.key(PreviousKeyFormat, namespace, ID), string(b))
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// searchSticky returns up to num of the items the given ID held before its
+// last release in ascending order, which are within the range defined by min and max and not
+// used according to isUsed, see Config.Sticky.
+func (s *Service) searchSticky(ctx context.Context, namespace, ID string, num, min, max int, isUsed func(item int) bool) ([]int, error) {
+	if !s.sticky {
+		return nil, nil
+	}
+
+	v, err := s.storage.Search(ctx, s.key(PreviousKeyFormat, namespace, ID))
+	if IsNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	var previous []int
+	err = json.Unmarshal([]byte(v), &previous)
+	if err != nil {
+		return nil, microerror.Maskf(executionFailedError, "decoding previous items of ID '%s': %s", ID, err.Error())
+	}
+	sort.Ints(previous)
+
+	var items []int
+	for _, item := range previous {
+		if len(items) == num {
+			break
+		}
+		if item < min || item > max || isUsed(item) {
+			continue
+		}
+
+		items = append(items, item)
+	}
+
+	return items, nil
+}
@@ -0,0 +1,101 @@
+package rangepool
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/giantswarm/micrologger/microloggertest"
+)
+
+func Test_Service_Create_Sticky(t *testing.T) {
+	for _, b := range []bool{false, true} {
+		var err error
+		var newService *Service
+		{
+			newStorage, err := newMemoryStorage()
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+
+			config := DefaultConfig()
+			config.Logger = microloggertest.New()
+			config.Storage = newStorage
+			config.Bitmap = b
+			config.Sticky = true
+			newService, err = New(config)
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+		}
+
+		ctx := context.TODO()
+
+		_, err = newService.Create(ctx, namespace, "test-id-1", 2, 2, 10)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		_, err = newService.Create(ctx, namespace, "test-id-2", 1, 2, 10)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		err = newService.Delete(ctx, namespace, "test-id-1")
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		// Another ID must not get the previous items of the deleted ID, since
+		// allocations continue after the latest item.
+		{
+			items, err := newService.Create(ctx, namespace, "test-id-3", 1, 2, 10)
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+			expected := []int{5}
+			if !reflect.DeepEqual(items, expected) {
+				t.Fatal("expected", expected, "got", items)
+			}
+		}
+
+		// The returning ID must get its previous items back first.
+		{
+			items, err := newService.Create(ctx, namespace, "test-id-1", 3, 2, 10)
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+			expected := []int{2, 3, 6}
+			if !reflect.DeepEqual(items, expected) {
+				t.Fatal("expected", expected, "got", items)
+			}
+		}
+
+		// Previous items taken by other IDs in the meantime must be skipped.
+		{
+			err = newService.Delete(ctx, namespace, "test-id-1")
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+			_, err = newService.Create(ctx, namespace, "test-id-4", 4, 2, 10)
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+			items, err := newService.Create(ctx, namespace, "test-id-5", 1, 2, 10)
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+			expected := []int{2}
+			if !reflect.DeepEqual(items, expected) {
+				t.Fatal("expected", expected, "got", items)
+			}
+
+			items, err = newService.Create(ctx, namespace, "test-id-1", 2, 2, 10)
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+			expected = []int{3, 6}
+			if !reflect.DeepEqual(items, expected) {
+				t.Fatal("expected", expected, "got", items)
+			}
+		}
+	}
+}
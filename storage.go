@@ -0,0 +1,262 @@
+package rangepool
+
+import (
+	"context"
+
+	"github.com/giantswarm/microerror"
+)
+
+// KV is a key-value pair as returned by Storage.List.
+type KV struct {
+	Key   string
+	Value string
+}
+
+// Storage is the interface the range pool uses to persist its state. Keys are
+// slash separated paths as described by IDKeyFormat, ItemKeyFormat and
+// LatestKeyFormat. Implementations must be safe for concurrent use.
+type Storage interface {
+	// Create persists the given value under the given key. The value of an
+	// already existing key is overwritten.
+	Create(ctx context.Context, key, value string) error
+	// Delete removes the given key. Deleting a key which does not exist is not
+	// an error.
+	Delete(ctx context.Context, key string) error
+	// List returns all key-value pairs below the given key. The returned keys
+	// are relative to the given key. In case there are no keys below the given
+	// key an empty list is returned.
+	List(ctx context.Context, key string) ([]KV, error)
+	// Search returns the value of the given key. In case the key does not
+	// exist an error is returned which can be asserted using IsNotFound.
+	Search(ctx context.Context, key string) (string, error)
+}
+
+// BatchStorage can optionally be implemented by Storage implementations which
+// are able to write multiple keys in a single round trip. In case the
+// configured Storage implements BatchStorage, the range pool persists all keys
+// of an allocation or release in a single batch.
+type BatchStorage interface {
+	Storage
+
+	// CreateBatch works like Create for all of the given key-value pairs.
+	CreateBatch(ctx context.Context, kvs []KV) error
+	// DeleteBatch works like Delete for all of the given keys.
+	DeleteBatch(ctx context.Context, keys []string) error
+}
+
+// createBatch persists the given key-value pairs in a single batch in case the
+// storage implements BatchStorage, otherwise one by one.
+func createBatch(ctx context.Context, storage Storage, kvs []KV) error {
+	b, ok := storage.(BatchStorage)
+	if ok {
+		err := b.CreateBatch(ctx, kvs)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
+		return nil
+	}
+
+	for _, kv := range kvs {
+		err := storage.Create(ctx, kv.Key, kv.Value)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	return nil
+}
+
+// deleteBatch removes the given keys in a single batch in case the storage
+// implements BatchStorage, otherwise one by one.
+func deleteBatch(ctx context.Context, storage Storage, keys []string) error {
+	b, ok := storage.(BatchStorage)
+	if ok {
+		err := b.DeleteBatch(ctx, keys)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
+		return nil
+	}
+
+	for _, k := range keys {
+		err := storage.Delete(ctx, k)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	return nil
+}
+
+// AtomicStorage can optionally be implemented by Storage implementations which
+// are able to write and remove multiple keys atomically. In case the
+// configured Storage implements AtomicStorage, items handed over from one ID to
+// another, e.g. by Service.RenameID, are never owned by both IDs, even in case
+// the process fails in between.
+type AtomicStorage interface {
+	Storage
+
+	// Apply persists the given key-value pairs and removes the given keys, so
+	// that either all or none of the changes are visible. Implementations only
+	// need to guarantee this for keys of the same namespace.
+	Apply(ctx context.Context, kvs []KV, keys []string) error
+}
+
+// apply persists the given key-value pairs and removes the given keys
+// atomically in case the storage implements AtomicStorage. Otherwise the
+// key-value pairs are persisted before the keys are removed, see createBatch
+// and deleteBatch, so that a failure in between leaves both.
+func apply(ctx context.Context, storage Storage, kvs []KV, keys []string) error {
+	a, ok := storage.(AtomicStorage)
+	if ok {
+		err := a.Apply(ctx, kvs, keys)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
+		return nil
+	}
+
+	err := createBatch(ctx, storage, kvs)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = deleteBatch(ctx, storage, keys)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// SwapStorage can optionally be implemented by Storage implementations which
+// are able to replace the value of a key atomically. The storage of an Elector
+// must implement SwapStorage, so that the lease is acquired, renewed and
+// released using compare-and-swap and at most one candidate holds it at any
+// time, see CanSwap.
+type SwapStorage interface {
+	Storage
+
+	// Swap persists the given value under the given key in case the current
+	// value of the key is old. An empty old value requires the key to not
+	// exist. In case the current value differs, nothing is written and false
+	// is returned.
+	Swap(ctx context.Context, key, old, value string) (bool, error)
+}
+
+// CanSwap returns whether the given storage is able to replace the value of a
+// key atomically, see SwapStorage. The microstorage adapter is only able to in
+// case its microstorage.Storage implements MicrostorageSwap.
+func CanSwap(storage Storage) bool {
+	m, ok := storage.(*Microstorage)
+	if ok {
+		_, ok = m.storage.(MicrostorageSwap)
+		return ok
+	}
+
+	_, ok = storage.(SwapStorage)
+	return ok
+}
+
+// TypedStorage can optionally be implemented by Storage and
+// microstorage.Storage implementations which persist some values in typed
+// columns instead of as they are, e.g. the latest item of a namespace as an
+// integer, like the storage/postgres package does. Such storages cannot hold
+// values transformed by the Service, so New refuses them in combination with
+// Config.KeyEncrypter or Config.Checksums.
+type TypedStorage interface {
+	// TypedValues returns whether some values are persisted in typed columns.
+	TypedValues() bool
+}
+
+// hasTypedValues returns whether the given storage persists some values in
+// typed columns, see TypedStorage. The microstorage adapter does in case its
+// microstorage.Storage does.
+func hasTypedValues(storage Storage) bool {
+	var v interface{} = storage
+	m, ok := storage.(*Microstorage)
+	if ok {
+		v = m.storage
+	}
+
+	t, ok := v.(TypedStorage)
+	return ok && t.TypedValues()
+}
+
+// WalkStorage can optionally be implemented by Storage implementations which
+// are able to iterate over keys without loading all of them into memory at
+// once. In case the configured Storage implements WalkStorage, the range pool
+// uses it instead of List, which keeps the memory bounded for very large
+// namespaces.
+type WalkStorage interface {
+	Storage
+
+	// Walk calls fn for every key-value pair below the given key, like List
+	// would return them. In case fn returns an error, Walk stops and returns
+	// the error. fn must not call the storage itself.
+	Walk(ctx context.Context, key string, fn func(kv KV) error) error
+}
+
+// isEmpty returns whether there are no keys below the given key. The walk is
+// stopped at the first key found in case the storage implements WalkStorage.
+func isEmpty(ctx context.Context, storage Storage, key string) (bool, error) {
+	empty := true
+
+	err := walk(ctx, storage, key, func(kv KV) error {
+		empty = false
+		return microerror.Mask(stopWalkError)
+	})
+	if isStopWalk(err) {
+		// Fall through since we stopped the walk ourselves.
+	} else if err != nil {
+		return false, microerror.Mask(err)
+	}
+
+	return empty, nil
+}
+
+// walk calls fn for every key-value pair below the given key, see
+// WalkStorage. In case the storage does not implement WalkStorage, the
+// key-value pairs are listed at once.
+func walk(ctx context.Context, storage Storage, key string, fn func(kv KV) error) error {
+	w, ok := storage.(WalkStorage)
+	if ok {
+		err := w.Walk(ctx, key, fn)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
+		return nil
+	}
+
+	kvs, err := storage.List(ctx, key)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	for _, kv := range kvs {
+		err := fn(kv)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	return nil
+}
+
+// WatchStorage can optionally be implemented by Storage implementations which
+// are able to notify about changes. In case the configured Storage implements
+// WatchStorage, Service.Watch reacts to notifications instead of polling the
+// storage.
+type WatchStorage interface {
+	Storage
+
+	// Watch returns a channel receiving a value whenever keys below the given
+	// key have changed. Notifications may be coalesced, so a single value may
+	// represent multiple changes. The channel is closed once the given context
+	// is done.
+	Watch(ctx context.Context, key string) (<-chan struct{}, error)
+}
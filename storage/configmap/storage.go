@@ -0,0 +1,189 @@
+// Package configmap implements a microstorage.Storage backed by Kubernetes
+// config maps. The full state of a single range pool namespace is persisted
+// as JSON in one config map. Writes are guarded by the resource version of the
+// config map and fail with rangepool.ConflictError in case the config map has
+// been changed concurrently. This backend is meant for small pools managed by controllers
+// which only have a Kubernetes client at hand.
+package configmap
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/giantswarm/rangepool/storage/internal/objectstorage"
+)
+
+const (
+	// StateKey is the key of the config map data holding the JSON encoded
+	// state of a range pool namespace.
+	StateKey = "state"
+)
+
+// Config represents the configuration used to create a new config map
+// storage.
+type Config struct {
+	// Dependencies.
+	K8sClient kubernetes.Interface
+	Logger    micrologger.Logger
+
+	// Settings.
+
+	// Namespace is the Kubernetes namespace the config maps are managed in.
+	Namespace string
+}
+
+// DefaultConfig provides a default configuration to create a new config map
+// storage by best effort.
+func DefaultConfig() Config {
+	return Config{
+		// Dependencies.
+		K8sClient: nil,
+		Logger:    nil,
+
+		// Settings.
+		Namespace: "",
+	}
+}
+
+// New creates a new configured config map storage.
+func New(config Config) (*Storage, error) {
+	// Dependencies.
+	if config.K8sClient == nil {
+		return nil, microerror.Maskf(invalidConfigError, "k8s client must not be empty")
+	}
+	if config.Logger == nil {
+		return nil, microerror.Maskf(invalidConfigError, "logger must not be empty")
+	}
+
+	// Settings.
+	if config.Namespace == "" {
+		return nil, microerror.Maskf(invalidConfigError, "namespace must not be empty")
+	}
+
+	var err error
+
+	var objectStorage *objectstorage.Storage
+	{
+		c := objectstorage.DefaultConfig()
+		c.Objects = &configMaps{
+			k8sClient: config.K8sClient,
+			namespace: config.Namespace,
+		}
+		objectStorage, err = objectstorage.New(c)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
+	newStorage := &Storage{
+		// Dependencies.
+		Storage: objectStorage,
+		logger:  config.Logger,
+	}
+
+	return newStorage, nil
+}
+
+// Storage implements microstorage.Storage. Its methods are shared with the
+// storage/crd package.
+type Storage struct {
+	// Dependencies.
+	*objectstorage.Storage
+	logger micrologger.Logger
+}
+
+// configMaps implements objectstorage.Objects using config maps.
+type configMaps struct {
+	// Dependencies.
+	k8sClient kubernetes.Interface
+
+	// Settings.
+	namespace string
+}
+
+// Get fetches the config map of the given group and returns its decoded state.
+// In case the config map does not exist, empty data and a nil object are
+// returned.
+func (c *configMaps) Get(ctx context.Context, group string) (map[string]string, interface{}, error) {
+	name, err := objectstorage.ObjectName(group)
+	if err != nil {
+		return nil, nil, microerror.Mask(err)
+	}
+
+	cm, err := c.k8sClient.CoreV1().ConfigMaps(c.namespace).Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return map[string]string{}, nil, nil
+	} else if err != nil {
+		return nil, nil, microerror.Mask(err)
+	}
+
+	data := map[string]string{}
+	if cm.Data[StateKey] != "" {
+		err := json.Unmarshal([]byte(cm.Data[StateKey]), &data)
+		if err != nil {
+			return nil, nil, microerror.Maskf(invalidStateError, "config map '%s/%s': %s", cm.Namespace, cm.Name, err.Error())
+		}
+	}
+
+	return data, cm, nil
+}
+
+func (c *configMaps) Create(ctx context.Context, group string, data map[string]string) (interface{}, error) {
+	name, err := objectstorage.ObjectName(group)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	b, err := json.Marshal(data)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: c.namespace,
+		},
+		Data: map[string]string{
+			StateKey: string(b),
+		},
+	}
+
+	created, err := c.k8sClient.CoreV1().ConfigMaps(c.namespace).Create(ctx, cm, metav1.CreateOptions{})
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return created, nil
+}
+
+func (c *configMaps) Update(ctx context.Context, group string, obj interface{}, data map[string]string) (interface{}, error) {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	cm := obj.(*corev1.ConfigMap)
+	if cm.Data == nil {
+		cm.Data = map[string]string{}
+	}
+	cm.Data[StateKey] = string(b)
+
+	updated, err := c.k8sClient.CoreV1().ConfigMaps(c.namespace).Update(ctx, cm, metav1.UpdateOptions{})
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return updated, nil
+}
+
+func (c *configMaps) Version(obj interface{}) string {
+	return obj.(*corev1.ConfigMap).ResourceVersion
+}
@@ -0,0 +1,147 @@
+package configmap
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"github.com/giantswarm/micrologger/microloggertest"
+	"github.com/giantswarm/microstorage"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/giantswarm/rangepool"
+	"github.com/giantswarm/rangepool/rangepooltest"
+)
+
+func Test_Storage(t *testing.T) {
+	var err error
+	var newStorage *Storage
+	{
+		config := DefaultConfig()
+		config.K8sClient = fake.NewSimpleClientset()
+		config.Logger = microloggertest.New()
+		config.Namespace = "default"
+		newStorage, err = New(config)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+	}
+
+	ctx := context.TODO()
+
+	// Searching a key of a namespace that was never written to must result in a
+	// not found error.
+	{
+		_, err := newStorage.Search(ctx, microstorage.MustK(microstorage.NewK("range-pool/test-namespace/latest")))
+		if !microstorage.IsNotFound(err) {
+			t.Fatal("expected", true, "got", false)
+		}
+	}
+
+	// Persist a couple of keys.
+	{
+		kvs := []microstorage.KV{
+			microstorage.MustKV(microstorage.NewKV("range-pool/test-namespace/item/2", "2")),
+			microstorage.MustKV(microstorage.NewKV("range-pool/test-namespace/item/3", "3")),
+			microstorage.MustKV(microstorage.NewKV("range-pool/test-namespace/id/test-id/item/2", "2")),
+			microstorage.MustKV(microstorage.NewKV("range-pool/test-namespace/latest", "3")),
+			microstorage.MustKV(microstorage.NewKV("range-pool/other-namespace/item/4", "4")),
+		}
+		for _, kv := range kvs {
+			err := newStorage.Put(ctx, kv)
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+		}
+	}
+
+	// Listing must only return the keys below the given key, relative to it.
+	{
+		list, err := newStorage.List(ctx, microstorage.MustK(microstorage.NewK("range-pool/test-namespace/item")))
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		var keys []string
+		for _, kv := range list {
+			keys = append(keys, kv.KeyNoLeadingSlash())
+		}
+		sort.Strings(keys)
+
+		if len(keys) != 2 || keys[0] != "2" || keys[1] != "3" {
+			t.Fatal("expected", []string{"2", "3"}, "got", keys)
+		}
+	}
+
+	// Searching must return the latest value.
+	{
+		kv, err := newStorage.Search(ctx, microstorage.MustK(microstorage.NewK("range-pool/test-namespace/latest")))
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		if kv.Val() != "3" {
+			t.Fatal("expected", "3", "got", kv.Val())
+		}
+	}
+
+	// Deleting a key must remove it from the config map.
+	{
+		err := newStorage.Delete(ctx, microstorage.MustK(microstorage.NewK("range-pool/test-namespace/item/2")))
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		ok, err := newStorage.Exists(ctx, microstorage.MustK(microstorage.NewK("range-pool/test-namespace/item/2")))
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		if ok {
+			t.Fatal("expected", false, "got", true)
+		}
+
+		ok, err = newStorage.Exists(ctx, microstorage.MustK(microstorage.NewK("range-pool/other-namespace/item/4")))
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		if !ok {
+			t.Fatal("expected", true, "got", false)
+		}
+	}
+
+	// Keys without namespace cannot be mapped to a config map.
+	{
+		err := newStorage.Put(ctx, microstorage.MustKV(microstorage.NewKV("range-pool", "foo")))
+		if !IsInvalidKey(err) {
+			t.Fatal("expected", true, "got", false)
+		}
+	}
+}
+
+func Test_Storage_Conformance(t *testing.T) {
+	rangepooltest.RunStorageConformance(t, func(t *testing.T) rangepool.Storage {
+		var err error
+		var newStorage *Storage
+		{
+			config := DefaultConfig()
+			config.K8sClient = fake.NewSimpleClientset()
+			config.Logger = microloggertest.New()
+			config.Namespace = "default"
+			newStorage, err = New(config)
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+		}
+
+		var newMicrostorage *rangepool.Microstorage
+		{
+			c := rangepool.DefaultMicrostorageConfig()
+			c.Storage = newStorage
+			newMicrostorage, err = rangepool.NewMicrostorage(c)
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+		}
+
+		return newMicrostorage
+	})
+}
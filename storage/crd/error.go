@@ -0,0 +1,23 @@
+package crd
+
+import (
+	"github.com/giantswarm/microerror"
+
+	"github.com/giantswarm/rangepool/storage/internal/objectstorage"
+)
+
+var invalidConfigError = &microerror.Error{
+	Kind: "invalidConfigError",
+}
+
+// IsInvalidConfig asserts invalidConfigError.
+func IsInvalidConfig(err error) bool {
+	return microerror.Cause(err) == invalidConfigError
+}
+
+var invalidKeyError = objectstorage.InvalidKeyError
+
+// IsInvalidKey asserts invalidKeyError.
+func IsInvalidKey(err error) bool {
+	return microerror.Cause(err) == invalidKeyError
+}
@@ -0,0 +1,210 @@
+// Package crd implements a microstorage.Storage backed by RangePoolAllocation
+// custom resources. All keys of a single range pool namespace are persisted in
+// one custom resource, which makes allocations visible via kubectl. The custom
+// resource definition can be found in rangepoolallocation.yaml.
+package crd
+
+import (
+	"context"
+	"strings"
+
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+
+	"github.com/giantswarm/rangepool/storage/internal/objectstorage"
+)
+
+const (
+	// Group is the API group of the RangePoolAllocation custom resource.
+	Group = "rangepool.giantswarm.io"
+	// Kind is the kind of the RangePoolAllocation custom resource.
+	Kind = "RangePoolAllocation"
+	// Version is the API version of the RangePoolAllocation custom resource.
+	Version = "v1alpha1"
+)
+
+// Resource is the group version resource used to access RangePoolAllocation
+// custom resources.
+var Resource = schema.GroupVersionResource{
+	Group:    Group,
+	Version:  Version,
+	Resource: "rangepoolallocations",
+}
+
+// Config represents the configuration used to create a new CRD storage.
+type Config struct {
+	// Dependencies.
+	K8sClient dynamic.Interface
+	Logger    micrologger.Logger
+
+	// Settings.
+
+	// Namespace is the Kubernetes namespace the RangePoolAllocation custom
+	// resources are managed in.
+	Namespace string
+}
+
+// DefaultConfig provides a default configuration to create a new CRD storage
+// by best effort.
+func DefaultConfig() Config {
+	return Config{
+		// Dependencies.
+		K8sClient: nil,
+		Logger:    nil,
+
+		// Settings.
+		Namespace: "",
+	}
+}
+
+// New creates a new configured CRD storage.
+func New(config Config) (*Storage, error) {
+	// Dependencies.
+	if config.K8sClient == nil {
+		return nil, microerror.Maskf(invalidConfigError, "k8s client must not be empty")
+	}
+	if config.Logger == nil {
+		return nil, microerror.Maskf(invalidConfigError, "logger must not be empty")
+	}
+
+	// Settings.
+	if config.Namespace == "" {
+		return nil, microerror.Maskf(invalidConfigError, "namespace must not be empty")
+	}
+
+	var err error
+
+	var objectStorage *objectstorage.Storage
+	{
+		c := objectstorage.DefaultConfig()
+		c.Objects = &customResources{
+			k8sClient: config.K8sClient,
+			namespace: config.Namespace,
+		}
+		objectStorage, err = objectstorage.New(c)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
+	newStorage := &Storage{
+		// Dependencies.
+		Storage: objectStorage,
+		logger:  config.Logger,
+	}
+
+	return newStorage, nil
+}
+
+// Storage implements microstorage.Storage. Its methods are shared with the
+// storage/configmap package.
+type Storage struct {
+	// Dependencies.
+	*objectstorage.Storage
+	logger micrologger.Logger
+}
+
+// customResources implements objectstorage.Objects using RangePoolAllocation
+// custom resources.
+type customResources struct {
+	// Dependencies.
+	k8sClient dynamic.Interface
+
+	// Settings.
+	namespace string
+}
+
+// Get fetches the custom resource of the given group and returns its data. In
+// case the custom resource does not exist, empty data and a nil object are
+// returned.
+func (c *customResources) Get(ctx context.Context, group string) (map[string]string, interface{}, error) {
+	name, err := objectstorage.ObjectName(group)
+	if err != nil {
+		return nil, nil, microerror.Mask(err)
+	}
+
+	obj, err := c.k8sClient.Resource(Resource).Namespace(c.namespace).Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return map[string]string{}, nil, nil
+	} else if err != nil {
+		return nil, nil, microerror.Mask(err)
+	}
+
+	data, _, err := unstructured.NestedStringMap(obj.Object, "spec", "data")
+	if err != nil {
+		return nil, nil, microerror.Mask(err)
+	}
+	if data == nil {
+		data = map[string]string{}
+	}
+
+	return data, obj, nil
+}
+
+func (c *customResources) Create(ctx context.Context, group string, data map[string]string) (interface{}, error) {
+	name, err := objectstorage.ObjectName(group)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion(Group + "/" + Version)
+	obj.SetKind(Kind)
+	obj.SetName(name)
+	obj.SetNamespace(c.namespace)
+
+	err = setData(obj, group, data)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	created, err := c.k8sClient.Resource(Resource).Namespace(c.namespace).Create(ctx, obj, metav1.CreateOptions{})
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return created, nil
+}
+
+func (c *customResources) Update(ctx context.Context, group string, obj interface{}, data map[string]string) (interface{}, error) {
+	u := obj.(*unstructured.Unstructured)
+
+	err := setData(u, group, data)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	updated, err := c.k8sClient.Resource(Resource).Namespace(c.namespace).Update(ctx, u, metav1.UpdateOptions{})
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return updated, nil
+}
+
+func (c *customResources) Version(obj interface{}) string {
+	return obj.(*unstructured.Unstructured).GetResourceVersion()
+}
+
+func setData(obj *unstructured.Unstructured, group string, data map[string]string) error {
+	m := map[string]interface{}{}
+	for k, v := range data {
+		m[k] = v
+	}
+
+	err := unstructured.SetNestedField(obj.Object, strings.SplitN(group, "/", 2)[1], "spec", "namespace")
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	err = unstructured.SetNestedMap(obj.Object, m, "spec", "data")
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
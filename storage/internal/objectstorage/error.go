@@ -0,0 +1,25 @@
+package objectstorage
+
+import (
+	"github.com/giantswarm/microerror"
+)
+
+var invalidConfigError = &microerror.Error{
+	Kind: "invalidConfigError",
+}
+
+// IsInvalidConfig asserts invalidConfigError.
+func IsInvalidConfig(err error) bool {
+	return microerror.Cause(err) == invalidConfigError
+}
+
+// InvalidKeyError is returned in case a key does not address a group of keys.
+// It is exported, so that the storage packages can assert it.
+var InvalidKeyError = &microerror.Error{
+	Kind: "invalidKeyError",
+}
+
+// IsInvalidKey asserts InvalidKeyError.
+func IsInvalidKey(err error) bool {
+	return microerror.Cause(err) == InvalidKeyError
+}
@@ -0,0 +1,486 @@
+// Package objectstorage implements the parts of a microstorage.Storage shared
+// by the storage/crd and storage/configmap packages. Keys are grouped by their
+// first two segments, e.g. range-pool/${namespace1}, and every group is
+// persisted in a single Kubernetes object. Writes are guarded by the resource
+// version of the object. In case the context carries rangepool.ReadVersions,
+// e.g. during an allocation of the Service, the resource version of every
+// object is recorded the first time it is read, and writes are guarded by the
+// recorded version instead, so that the Service does not apply its change to
+// data it has not seen. Conflicting writes are not retried but fail with
+// rangepool.ConflictError.
+package objectstorage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/microstorage"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	"github.com/giantswarm/rangepool"
+)
+
+// objectNameHashLength is the number of hexadecimal characters of the hash of
+// a group used as suffix of object names, see ObjectName.
+const objectNameHashLength = 16
+
+// Objects reads and writes the Kubernetes objects holding the data of groups
+// of keys.
+type Objects interface {
+	// Get fetches the object of the given group and returns its data together
+	// with the object itself. In case the object does not exist, empty data and
+	// a nil object are returned.
+	Get(ctx context.Context, group string) (map[string]string, interface{}, error)
+	// Create creates the object of the given group holding the given data and
+	// returns the object created.
+	Create(ctx context.Context, group string, data map[string]string) (interface{}, error)
+	// Update writes the given data to the given object of the given group, as
+	// returned by Get, and returns the object updated. The write must be
+	// guarded by the resource version of the object.
+	Update(ctx context.Context, group string, obj interface{}, data map[string]string) (interface{}, error)
+	// Version returns the resource version of the given object, as returned
+	// by Get, Create or Update.
+	Version(obj interface{}) string
+}
+
+// Config represents the configuration used to create a new object storage.
+type Config struct {
+	// Dependencies.
+	Objects Objects
+}
+
+// DefaultConfig provides a default configuration to create a new object
+// storage by best effort.
+func DefaultConfig() Config {
+	return Config{
+		// Dependencies.
+		Objects: nil,
+	}
+}
+
+// New creates a new configured object storage.
+func New(config Config) (*Storage, error) {
+	// Dependencies.
+	if config.Objects == nil {
+		return nil, microerror.Maskf(invalidConfigError, "objects must not be empty")
+	}
+
+	newStorage := &Storage{
+		// Dependencies.
+		objects: config.Objects,
+	}
+
+	return newStorage, nil
+}
+
+type Storage struct {
+	// Dependencies.
+	objects Objects
+}
+
+// Apply persists the given key-value pairs and removes the given keys using a
+// single update per object. The changes of keys sharing a group, e.g. all keys
+// of a range pool namespace, are therefore applied atomically, see
+// rangepool.MicrostorageAtomic.
+func (s *Storage) Apply(ctx context.Context, kvs []microstorage.KV, keys []microstorage.K) error {
+	puts := map[string]map[string]string{}
+	deletes := map[string][]string{}
+	var groups []string
+	for _, kv := range kvs {
+		group, rel, err := splitKey(kv.K())
+		if err != nil {
+			return microerror.Mask(err)
+		}
+		if rel == "" {
+			return microerror.Maskf(InvalidKeyError, "key '%s' must not address a namespace", kv.Key())
+		}
+		if puts[group] == nil && deletes[group] == nil {
+			groups = append(groups, group)
+		}
+		if puts[group] == nil {
+			puts[group] = map[string]string{}
+		}
+		puts[group][rel] = kv.Val()
+	}
+	for _, key := range keys {
+		group, rel, err := splitKey(key)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+		if puts[group] == nil && deletes[group] == nil {
+			groups = append(groups, group)
+		}
+		deletes[group] = append(deletes[group], rel)
+	}
+
+	for _, group := range groups {
+		err := s.update(ctx, group, func(data map[string]string) bool {
+			var changed bool
+			for rel, val := range puts[group] {
+				v, ok := data[rel]
+				data[rel] = val
+				changed = changed || !ok || v != val
+			}
+			for _, rel := range deletes[group] {
+				_, ok := data[rel]
+				delete(data, rel)
+				changed = changed || ok
+			}
+			return changed
+		})
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	return nil
+}
+
+func (s *Storage) Delete(ctx context.Context, key microstorage.K) error {
+	group, rel, err := splitKey(key)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = s.update(ctx, group, func(data map[string]string) bool {
+		_, ok := data[rel]
+		delete(data, rel)
+		return ok
+	})
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// DeleteBatch removes all of the given keys using a single update per object.
+func (s *Storage) DeleteBatch(ctx context.Context, keys []microstorage.K) error {
+	groups := map[string][]string{}
+	for _, key := range keys {
+		group, rel, err := splitKey(key)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+		groups[group] = append(groups[group], rel)
+	}
+
+	for group, rels := range groups {
+		err := s.update(ctx, group, func(data map[string]string) bool {
+			var changed bool
+			for _, rel := range rels {
+				_, ok := data[rel]
+				delete(data, rel)
+				changed = changed || ok
+			}
+			return changed
+		})
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	return nil
+}
+
+func (s *Storage) Exists(ctx context.Context, key microstorage.K) (bool, error) {
+	group, rel, err := splitKey(key)
+	if err != nil {
+		return false, microerror.Mask(err)
+	}
+
+	data, err := s.read(ctx, group)
+	if err != nil {
+		return false, microerror.Mask(err)
+	}
+
+	_, ok := data[rel]
+
+	return ok, nil
+}
+
+func (s *Storage) List(ctx context.Context, key microstorage.K) ([]microstorage.KV, error) {
+	group, rel, err := splitKey(key)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	data, err := s.read(ctx, group)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	// List returns the keys relative to the given key. Keys which are not
+	// separated from the given key by a slash are ignored.
+	prefix := rel + "/"
+	if rel == "" {
+		prefix = ""
+	}
+
+	var list []microstorage.KV
+	for k, v := range data {
+		if !strings.HasPrefix(k, prefix) || len(k) == len(prefix) {
+			continue
+		}
+
+		kv, err := microstorage.NewKV(k[len(prefix):], v)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+		list = append(list, kv)
+	}
+
+	return list, nil
+}
+
+func (s *Storage) Put(ctx context.Context, kv microstorage.KV) error {
+	group, rel, err := splitKey(kv.K())
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	if rel == "" {
+		return microerror.Maskf(InvalidKeyError, "key '%s' must not address a namespace", kv.Key())
+	}
+
+	err = s.update(ctx, group, func(data map[string]string) bool {
+		v, ok := data[rel]
+		data[rel] = kv.Val()
+		return !ok || v != kv.Val()
+	})
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// PutBatch persists all of the given key-value pairs using a single update per
+// object.
+func (s *Storage) PutBatch(ctx context.Context, kvs []microstorage.KV) error {
+	groups := map[string]map[string]string{}
+	for _, kv := range kvs {
+		group, rel, err := splitKey(kv.K())
+		if err != nil {
+			return microerror.Mask(err)
+		}
+		if rel == "" {
+			return microerror.Maskf(InvalidKeyError, "key '%s' must not address a namespace", kv.Key())
+		}
+		if groups[group] == nil {
+			groups[group] = map[string]string{}
+		}
+		groups[group][rel] = kv.Val()
+	}
+
+	for group, values := range groups {
+		err := s.update(ctx, group, func(data map[string]string) bool {
+			var changed bool
+			for rel, val := range values {
+				v, ok := data[rel]
+				data[rel] = val
+				changed = changed || !ok || v != val
+			}
+			return changed
+		})
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	return nil
+}
+
+func (s *Storage) Search(ctx context.Context, key microstorage.K) (microstorage.KV, error) {
+	group, rel, err := splitKey(key)
+	if err != nil {
+		return microstorage.KV{}, microerror.Mask(err)
+	}
+
+	data, err := s.read(ctx, group)
+	if err != nil {
+		return microstorage.KV{}, microerror.Mask(err)
+	}
+
+	v, ok := data[rel]
+	if !ok || rel == "" {
+		return microstorage.KV{}, microerror.Maskf(microstorage.NotFoundError, "%s", key.Key())
+	}
+
+	kv, err := microstorage.NewKV(key.Key(), v)
+	if err != nil {
+		return microstorage.KV{}, microerror.Mask(err)
+	}
+
+	return kv, nil
+}
+
+// Swap persists the given value under the given key in case its current value
+// is old, see rangepool.MicrostorageSwap. The write is guarded by the resource
+// version of the object read for the comparison. In case the object has been
+// changed concurrently, nothing is written and false is returned.
+func (s *Storage) Swap(ctx context.Context, key microstorage.K, old, value string) (bool, error) {
+	group, rel, err := splitKey(key)
+	if err != nil {
+		return false, microerror.Mask(err)
+	}
+	if rel == "" {
+		return false, microerror.Maskf(InvalidKeyError, "key '%s' must not address a namespace", key.Key())
+	}
+
+	var swapped bool
+	err = s.update(ctx, group, func(data map[string]string) bool {
+		v, ok := data[rel]
+		if (old == "" && ok) || (old != "" && v != old) {
+			return false
+		}
+
+		data[rel] = value
+		swapped = true
+		return true
+	})
+	if rangepool.IsConflict(err) {
+		return false, nil
+	} else if err != nil {
+		return false, microerror.Mask(err)
+	}
+
+	return swapped, nil
+}
+
+// read fetches the data of the object of the given group and records the
+// resource version of the object, see rangepool.ReadVersions.
+func (s *Storage) read(ctx context.Context, group string) (map[string]string, error) {
+	data, obj, err := s.objects.Get(ctx, group)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	r, ok := rangepool.ReadVersionsFromContext(ctx)
+	if ok {
+		r.Record(group, s.version(obj))
+	}
+
+	return data, nil
+}
+
+// update applies the modification implemented by fn to the data of the object
+// of the given group. fn returns whether it changed the data. In case the
+// object was created or changed by somebody else since it was read, the update
+// is not retried but fails with rangepool.ConflictError. In case the context
+// carries rangepool.ReadVersions, the object must not have changed since it
+// was read first.
+func (s *Storage) update(ctx context.Context, group string, fn func(data map[string]string) bool) error {
+	data, obj, err := s.objects.Get(ctx, group)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	r, ok := rangepool.ReadVersionsFromContext(ctx)
+	if ok {
+		v, recorded := r.Version(group)
+		if recorded && v != s.version(obj) {
+			return microerror.Maskf(rangepool.ConflictError, "object of group '%s' has been changed since it was read", group)
+		}
+		r.Record(group, s.version(obj))
+	}
+
+	if !fn(data) {
+		return nil
+	}
+
+	if obj == nil {
+		obj, err = s.objects.Create(ctx, group, data)
+		if apierrors.IsAlreadyExists(microerror.Cause(err)) {
+			return microerror.Maskf(rangepool.ConflictError, "object of group '%s' has been created concurrently", group)
+		} else if err != nil {
+			return microerror.Mask(err)
+		}
+	} else {
+		obj, err = s.objects.Update(ctx, group, obj, data)
+		if apierrors.IsConflict(microerror.Cause(err)) {
+			return microerror.Maskf(rangepool.ConflictError, "object of group '%s' has been changed concurrently", group)
+		} else if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	// The version written is recorded, so that later writes using the same
+	// context do not conflict with this one.
+	if ok {
+		r.Update(group, s.version(obj))
+	}
+
+	return nil
+}
+
+// version returns the resource version of the given object, which is empty in
+// case the object does not exist.
+func (s *Storage) version(obj interface{}) string {
+	if obj == nil {
+		return ""
+	}
+
+	return s.objects.Version(obj)
+}
+
+// ObjectName returns the name of the object holding the data of the given
+// group, e.g. range-pool-${namespace1}-${hash}. The readable part is derived
+// from the group by replacing all characters not allowed within object names.
+// Since this may map different groups to the same readable part, the name is
+// suffixed with a hash of the group, which keeps names of different groups
+// apart. The name is validated as a DNS-1123 subdomain, which Kubernetes
+// requires for the names of config maps and custom resources.
+func ObjectName(group string) (string, error) {
+	sum := sha256.Sum256([]byte(group))
+	suffix := hex.EncodeToString(sum[:])[:objectNameHashLength]
+
+	name := strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '-' {
+			return r
+		}
+		if r >= 'A' && r <= 'Z' {
+			return r - 'A' + 'a'
+		}
+		return '-'
+	}, group)
+	if len(name) > validation.DNS1123SubdomainMaxLength-len(suffix)-1 {
+		name = name[:validation.DNS1123SubdomainMaxLength-len(suffix)-1]
+	}
+	name = strings.Trim(name, "-")
+	if name == "" {
+		name = suffix
+	} else {
+		name = name + "-" + suffix
+	}
+
+	errs := validation.IsDNS1123Subdomain(name)
+	if len(errs) != 0 {
+		return "", microerror.Maskf(InvalidKeyError, "object name '%s' of group '%s' is invalid: %s", name, group, strings.Join(errs, ", "))
+	}
+
+	return name, nil
+}
+
+// splitKey splits the given key into the group of keys persisted in a single
+// object and the key relative to this group. The group is made of the first
+// two segments of a key, e.g. range-pool/${namespace1}.
+func splitKey(key microstorage.K) (string, string, error) {
+	parts := strings.SplitN(key.KeyNoLeadingSlash(), "/", 3)
+	if len(parts) < 2 {
+		return "", "", microerror.Maskf(InvalidKeyError, "key '%s' must contain a namespace", key.Key())
+	}
+
+	group := fmt.Sprintf("%s/%s", parts[0], parts[1])
+
+	var rel string
+	if len(parts) == 3 {
+		rel = parts[2]
+	}
+
+	return group, rel, nil
+}
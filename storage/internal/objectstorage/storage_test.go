@@ -0,0 +1,217 @@
+package objectstorage
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/giantswarm/microstorage"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/giantswarm/rangepool"
+)
+
+type testObjects struct {
+	createErr error
+	data      map[string]string
+	obj       interface{}
+	updateErr error
+	writes    int
+}
+
+func (o *testObjects) Get(ctx context.Context, group string) (map[string]string, interface{}, error) {
+	data := map[string]string{}
+	for k, v := range o.data {
+		data[k] = v
+	}
+
+	return data, o.obj, nil
+}
+
+func (o *testObjects) Create(ctx context.Context, group string, data map[string]string) (interface{}, error) {
+	o.writes++
+	if o.createErr != nil {
+		return nil, o.createErr
+	}
+
+	o.obj = fmt.Sprintf("%d", o.writes)
+
+	return o.obj, nil
+}
+
+func (o *testObjects) Update(ctx context.Context, group string, obj interface{}, data map[string]string) (interface{}, error) {
+	o.writes++
+	if o.updateErr != nil {
+		return nil, o.updateErr
+	}
+
+	o.obj = fmt.Sprintf("%d", o.writes)
+
+	return o.obj, nil
+}
+
+// Version returns the object itself in case it is a string, so that tests can
+// change the resource version by setting the object.
+func (o *testObjects) Version(obj interface{}) string {
+	v, _ := obj.(string)
+	return v
+}
+
+func Test_Storage_Conflict(t *testing.T) {
+	gr := schema.GroupResource{Resource: "configmaps"}
+
+	testCases := []struct {
+		objects       *testObjects
+		errorMatcher  func(error) bool
+		expectedWrite int
+	}{
+		// Case 1 ensures a successful update is written once.
+		{
+			objects:       &testObjects{obj: struct{}{}},
+			errorMatcher:  nil,
+			expectedWrite: 1,
+		},
+		// Case 2 ensures an object created concurrently results in a conflict
+		// which is not retried.
+		{
+			objects:       &testObjects{createErr: apierrors.NewAlreadyExists(gr, "range-pool-test-namespace")},
+			errorMatcher:  rangepool.IsConflict,
+			expectedWrite: 1,
+		},
+		// Case 3 ensures an object changed concurrently results in a conflict
+		// which is not retried.
+		{
+			objects:       &testObjects{obj: struct{}{}, updateErr: apierrors.NewConflict(gr, "range-pool-test-namespace", nil)},
+			errorMatcher:  rangepool.IsConflict,
+			expectedWrite: 1,
+		},
+		// Case 4 ensures nothing is written in case the data does not change.
+		{
+			objects:       &testObjects{data: map[string]string{"item/2": "2"}, obj: struct{}{}},
+			errorMatcher:  nil,
+			expectedWrite: 0,
+		},
+	}
+
+	for i, tc := range testCases {
+		config := DefaultConfig()
+		config.Objects = tc.objects
+		newStorage, err := New(config)
+		if err != nil {
+			t.Fatal("case", i+1, "expected", nil, "got", err)
+		}
+
+		err = newStorage.Put(context.TODO(), microstorage.MustKV(microstorage.NewKV("range-pool/test-namespace/item/2", "2")))
+		if tc.errorMatcher == nil && err != nil {
+			t.Fatal("case", i+1, "expected", nil, "got", err)
+		}
+		if tc.errorMatcher != nil && !tc.errorMatcher(err) {
+			t.Fatal("case", i+1, "expected", true, "got", false)
+		}
+		if tc.objects.writes != tc.expectedWrite {
+			t.Fatal("case", i+1, "expected", tc.expectedWrite, "got", tc.objects.writes)
+		}
+	}
+}
+
+func Test_Storage_ReadVersions(t *testing.T) {
+	objects := &testObjects{obj: "1"}
+
+	var err error
+	var newStorage *Storage
+	{
+		config := DefaultConfig()
+		config.Objects = objects
+		newStorage, err = New(config)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+	}
+
+	ctx := rangepool.NewReadVersionsContext(context.TODO(), rangepool.NewReadVersions())
+
+	_, err = newStorage.List(ctx, microstorage.MustK(microstorage.NewK("range-pool/test-namespace/item")))
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+
+	// Writes of the same context must not conflict with each other, since the
+	// versions written are recorded.
+	for i := 0; i < 2; i++ {
+		err = newStorage.Put(ctx, microstorage.MustKV(microstorage.NewKV(fmt.Sprintf("range-pool/test-namespace/item/%d", i), "1")))
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+	}
+	if objects.writes != 2 {
+		t.Fatal("expected", 2, "got", objects.writes)
+	}
+
+	// Another process changing the object after it has been read must result
+	// in a conflict, even though the write itself would not conflict.
+	objects.obj = "3"
+
+	err = newStorage.Put(ctx, microstorage.MustKV(microstorage.NewKV("range-pool/test-namespace/item/3", "3")))
+	if !rangepool.IsConflict(err) {
+		t.Fatal("expected", true, "got", false)
+	}
+	if objects.writes != 2 {
+		t.Fatal("expected", 2, "got", objects.writes)
+	}
+
+	// Contexts not carrying read versions only guard the write itself.
+	err = newStorage.Put(context.TODO(), microstorage.MustKV(microstorage.NewKV("range-pool/test-namespace/item/3", "3")))
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+	if objects.writes != 3 {
+		t.Fatal("expected", 3, "got", objects.writes)
+	}
+}
+
+func Test_ObjectName(t *testing.T) {
+	testCases := []struct {
+		groups []string
+		prefix string
+	}{
+		// Case 1 ensures groups which only differ in the position of the slash
+		// result in different names.
+		{
+			groups: []string{"a-b/c", "a/b-c"},
+			prefix: "a-b-c-",
+		},
+		// Case 2 ensures characters not allowed within names are replaced, but
+		// the names still differ.
+		{
+			groups: []string{"range-pool/Test_Namespace", "range-pool/test-namespace", "range-pool/test.namespace"},
+			prefix: "range-pool-test-namespace-",
+		},
+		// Case 3 ensures names of long groups are shortened.
+		{
+			groups: []string{"range-pool/" + strings.Repeat("a", 300), "range-pool/" + strings.Repeat("a", 301)},
+			prefix: "range-pool-aaa",
+		},
+	}
+
+	for i, tc := range testCases {
+		names := map[string]bool{}
+		for _, group := range tc.groups {
+			name, err := ObjectName(group)
+			if err != nil {
+				t.Fatal("case", i+1, "expected", nil, "got", err)
+			}
+			if !strings.HasPrefix(name, tc.prefix) {
+				t.Fatal("case", i+1, "expected", tc.prefix, "got", name)
+			}
+			if len(name) > 253 {
+				t.Fatal("case", i+1, "expected", 253, "got", len(name))
+			}
+			if names[name] {
+				t.Fatal("case", i+1, "expected", false, "got", true)
+			}
+			names[name] = true
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package memory
+
+import (
+	"github.com/giantswarm/microerror"
+)
+
+var invalidConfigError = &microerror.Error{
+	Kind: "invalidConfigError",
+}
+
+// IsInvalidConfig asserts invalidConfigError.
+func IsInvalidConfig(err error) bool {
+	return microerror.Cause(err) == invalidConfigError
+}
+
+var invalidSnapshotError = &microerror.Error{
+	Kind: "invalidSnapshotError",
+}
+
+// IsInvalidSnapshot asserts invalidSnapshotError.
+func IsInvalidSnapshot(err error) bool {
+	return microerror.Cause(err) == invalidSnapshotError
+}
@@ -0,0 +1,499 @@
+// Package memory implements a rangepool.Storage serving all reads and writes
+// from memory. Its state is periodically persisted as a JSON snapshot, either
+// to a file or to a key of another rangepool.Storage. On creation the latest
+// snapshot is loaded so that allocations survive process restarts.
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+
+	"github.com/giantswarm/rangepool"
+)
+
+// Config represents the configuration used to create a new memory storage.
+type Config struct {
+	// Dependencies.
+	Logger micrologger.Logger
+	// Storage is the storage the snapshots are persisted to in case SnapshotKey
+	// is configured.
+	Storage rangepool.Storage
+
+	// Settings.
+
+	// SnapshotFile is the path of the file the snapshots are persisted to.
+	// Either SnapshotFile or SnapshotKey must be configured.
+	SnapshotFile string
+	// SnapshotInterval is the interval in which snapshots are persisted in case
+	// the state changed.
+	SnapshotInterval time.Duration
+	// SnapshotKey is the key of Storage the snapshots are persisted to. Either
+	// SnapshotFile or SnapshotKey must be configured.
+	SnapshotKey string
+}
+
+// DefaultConfig provides a default configuration to create a new memory
+// storage by best effort.
+func DefaultConfig() Config {
+	return Config{
+		// Dependencies.
+		Logger:  nil,
+		Storage: nil,
+
+		// Settings.
+		SnapshotFile:     "",
+		SnapshotInterval: 10 * time.Second,
+		SnapshotKey:      "",
+	}
+}
+
+// New creates a new configured memory storage, restores the latest snapshot
+// and starts persisting snapshots in the background. Close must be called to
+// persist the final snapshot and stop the background persistence.
+func New(config Config) (*Storage, error) {
+	// Dependencies.
+	if config.Logger == nil {
+		return nil, microerror.Maskf(invalidConfigError, "logger must not be empty")
+	}
+
+	// Settings.
+	if config.SnapshotFile == "" && config.SnapshotKey == "" {
+		return nil, microerror.Maskf(invalidConfigError, "snapshot file or snapshot key must not be empty")
+	}
+	if config.SnapshotFile != "" && config.SnapshotKey != "" {
+		return nil, microerror.Maskf(invalidConfigError, "snapshot file and snapshot key must not both be configured")
+	}
+	if config.SnapshotKey != "" && config.Storage == nil {
+		return nil, microerror.Maskf(invalidConfigError, "storage must not be empty when snapshot key is configured")
+	}
+	if config.SnapshotInterval <= 0 {
+		return nil, microerror.Maskf(invalidConfigError, "snapshot interval must be greater than zero")
+	}
+
+	newStorage := &Storage{
+		// Dependencies.
+		logger:  config.Logger,
+		storage: config.Storage,
+
+		// Internals.
+		data:       map[string]string{},
+		done:       make(chan struct{}),
+		mutex:      sync.RWMutex{},
+		wait:       sync.WaitGroup{},
+		watchers:   map[*watcher]struct{}{},
+		watchMutex: sync.Mutex{},
+
+		// Settings.
+		snapshotFile:     config.SnapshotFile,
+		snapshotInterval: config.SnapshotInterval,
+		snapshotKey:      config.SnapshotKey,
+	}
+
+	err := newStorage.restore(context.Background())
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	newStorage.wait.Add(1)
+	go newStorage.persistLoop()
+
+	return newStorage, nil
+}
+
+type Storage struct {
+	// Dependencies.
+	logger  micrologger.Logger
+	storage rangepool.Storage
+
+	// Internals.
+	closeOnce sync.Once
+	data      map[string]string
+	dirty     bool
+	done      chan struct{}
+	mutex     sync.RWMutex
+	wait      sync.WaitGroup
+
+	watchers   map[*watcher]struct{}
+	watchMutex sync.Mutex
+
+	// Settings.
+	snapshotFile     string
+	snapshotInterval time.Duration
+	snapshotKey      string
+}
+
+// Close stops the background persistence and persists the final snapshot.
+// The storage must not be used after Close was called.
+func (s *Storage) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.done)
+	})
+	s.wait.Wait()
+
+	err := s.Snapshot(context.Background())
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// Apply persists the given key-value pairs and removes the given keys while
+// holding the lock, see rangepool.AtomicStorage.
+func (s *Storage) Apply(ctx context.Context, kvs []rangepool.KV, keys []string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, kv := range kvs {
+		s.data[kv.Key] = kv.Value
+		s.notify(kv.Key)
+	}
+	for _, k := range keys {
+		_, ok := s.data[k]
+		if ok {
+			delete(s.data, k)
+			s.notify(k)
+		}
+	}
+	s.dirty = true
+
+	return nil
+}
+
+func (s *Storage) Create(ctx context.Context, key, value string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.data[key] = value
+	s.dirty = true
+	s.notify(key)
+
+	return nil
+}
+
+func (s *Storage) CreateBatch(ctx context.Context, kvs []rangepool.KV) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, kv := range kvs {
+		s.data[kv.Key] = kv.Value
+		s.notify(kv.Key)
+	}
+	s.dirty = true
+
+	return nil
+}
+
+func (s *Storage) Delete(ctx context.Context, key string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	_, ok := s.data[key]
+	if ok {
+		delete(s.data, key)
+		s.dirty = true
+		s.notify(key)
+	}
+
+	return nil
+}
+
+func (s *Storage) DeleteBatch(ctx context.Context, keys []string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, k := range keys {
+		_, ok := s.data[k]
+		if ok {
+			delete(s.data, k)
+			s.dirty = true
+			s.notify(k)
+		}
+	}
+
+	return nil
+}
+
+func (s *Storage) List(ctx context.Context, key string) ([]rangepool.KV, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	prefix := strings.TrimSuffix(key, "/") + "/"
+
+	var list []rangepool.KV
+	for k, v := range s.data {
+		if !strings.HasPrefix(k, prefix) || len(k) == len(prefix) {
+			continue
+		}
+
+		list = append(list, rangepool.KV{Key: k[len(prefix):], Value: v})
+	}
+
+	return list, nil
+}
+
+func (s *Storage) Search(ctx context.Context, key string) (string, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	v, ok := s.data[key]
+	if !ok {
+		return "", microerror.Maskf(rangepool.NotFoundError, "%s", key)
+	}
+
+	return v, nil
+}
+
+// Swap replaces the value of the given key in case it is old, see
+// rangepool.SwapStorage.
+func (s *Storage) Swap(ctx context.Context, key, old, value string) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	v, ok := s.data[key]
+	if (old == "" && ok) || (old != "" && v != old) {
+		return false, nil
+	}
+
+	s.data[key] = value
+	s.dirty = true
+	s.notify(key)
+
+	return true, nil
+}
+
+// Walk calls fn for every key-value pair below the given key while holding a
+// read lock. fn must therefore not call the storage itself.
+func (s *Storage) Walk(ctx context.Context, key string, fn func(kv rangepool.KV) error) error {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	prefix := strings.TrimSuffix(key, "/") + "/"
+
+	for k, v := range s.data {
+		if !strings.HasPrefix(k, prefix) || len(k) == len(prefix) {
+			continue
+		}
+
+		err := fn(rangepool.KV{Key: k[len(prefix):], Value: v})
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	return nil
+}
+
+// Watch returns a channel receiving a value whenever keys below the given key
+// have changed. The channel is closed once the given context is done.
+func (s *Storage) Watch(ctx context.Context, key string) (<-chan struct{}, error) {
+	w := &watcher{
+		ch:     make(chan struct{}, 1),
+		prefix: strings.TrimSuffix(key, "/") + "/",
+	}
+
+	s.watchMutex.Lock()
+	s.watchers[w] = struct{}{}
+	s.watchMutex.Unlock()
+
+	go func() {
+		<-ctx.Done()
+
+		s.watchMutex.Lock()
+		delete(s.watchers, w)
+		close(w.ch)
+		s.watchMutex.Unlock()
+	}()
+
+	return w.ch, nil
+}
+
+// Snapshot persists the current state in case it changed since the last
+// snapshot.
+func (s *Storage) Snapshot(ctx context.Context) error {
+	var b []byte
+	{
+		s.mutex.Lock()
+		if !s.dirty {
+			s.mutex.Unlock()
+			return nil
+		}
+
+		var err error
+		b, err = json.Marshal(s.data)
+		if err != nil {
+			s.mutex.Unlock()
+			return microerror.Mask(err)
+		}
+		s.dirty = false
+		s.mutex.Unlock()
+	}
+
+	err := s.write(ctx, b)
+	if err != nil {
+		// The snapshot could not be persisted. We mark the state dirty again so
+		// the next attempt persists it.
+		s.mutex.Lock()
+		s.dirty = true
+		s.mutex.Unlock()
+
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// notify informs the watchers of the given key about a change. Watchers which
+// have not yet received the previous notification are not notified again, so
+// notifications are coalesced.
+func (s *Storage) notify(key string) {
+	s.watchMutex.Lock()
+	defer s.watchMutex.Unlock()
+
+	for w := range s.watchers {
+		if !strings.HasPrefix(key, w.prefix) {
+			continue
+		}
+
+		select {
+		case w.ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (s *Storage) persistLoop() {
+	defer s.wait.Done()
+
+	ticker := time.NewTicker(s.snapshotInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			err := s.Snapshot(context.Background())
+			if err != nil {
+				s.logger.Log("level", "error", "message", "failed persisting snapshot", "stack", fmt.Sprintf("%#v", err))
+			}
+		}
+	}
+}
+
+// restore loads the latest snapshot into memory. In case no snapshot exists
+// yet, the storage starts empty.
+func (s *Storage) restore(ctx context.Context) error {
+	var b []byte
+	if s.snapshotFile != "" {
+		var err error
+		b, err = ioutil.ReadFile(s.snapshotFile)
+		if os.IsNotExist(err) {
+			return nil
+		} else if err != nil {
+			return microerror.Mask(err)
+		}
+	} else {
+		v, err := s.storage.Search(ctx, s.snapshotKey)
+		if rangepool.IsNotFound(err) {
+			return nil
+		} else if err != nil {
+			return microerror.Mask(err)
+		}
+		b = []byte(v)
+	}
+
+	data := map[string]string{}
+	err := json.Unmarshal(b, &data)
+	if err != nil {
+		return microerror.Maskf(invalidSnapshotError, "%s", err.Error())
+	}
+
+	s.mutex.Lock()
+	s.data = data
+	s.mutex.Unlock()
+
+	return nil
+}
+
+// write persists the given snapshot. Files are written and synced to a
+// temporary file first and renamed afterwards, and the directory is synced
+// after the rename, so that a crash never leaves a partially written snapshot
+// behind and never loses a completed one.
+func (s *Storage) write(ctx context.Context, b []byte) error {
+	if s.snapshotFile != "" {
+		f, err := ioutil.TempFile(filepath.Dir(s.snapshotFile), filepath.Base(s.snapshotFile)+".tmp")
+		if err != nil {
+			return microerror.Mask(err)
+		}
+		_, err = f.Write(b)
+		if err != nil {
+			f.Close()
+			os.Remove(f.Name())
+			return microerror.Mask(err)
+		}
+		// The snapshot is synced before it replaces the previous one, so that
+		// a crash never leaves a truncated snapshot behind.
+		err = f.Sync()
+		if err != nil {
+			f.Close()
+			os.Remove(f.Name())
+			return microerror.Mask(err)
+		}
+		err = f.Close()
+		if err != nil {
+			os.Remove(f.Name())
+			return microerror.Mask(err)
+		}
+		err = os.Rename(f.Name(), s.snapshotFile)
+		if err != nil {
+			os.Remove(f.Name())
+			return microerror.Mask(err)
+		}
+		err = syncDir(filepath.Dir(s.snapshotFile))
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
+		return nil
+	}
+
+	err := s.storage.Create(ctx, s.snapshotKey, string(b))
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// syncDir syncs the given directory, so that a file renamed into it survives
+// a crash.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	defer d.Close()
+
+	err = d.Sync()
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+type watcher struct {
+	ch     chan struct{}
+	prefix string
+}
@@ -0,0 +1,202 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/giantswarm/micrologger/microloggertest"
+
+	"github.com/giantswarm/rangepool"
+	"github.com/giantswarm/rangepool/rangepooltest"
+)
+
+func Test_Storage_Conformance(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rangepool-memory")
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+	defer os.RemoveAll(dir)
+
+	var i int
+	rangepooltest.RunStorageConformance(t, func(t *testing.T) rangepool.Storage {
+		i++
+
+		config := DefaultConfig()
+		config.Logger = microloggertest.New()
+		config.SnapshotFile = filepath.Join(dir, fmt.Sprintf("snapshot-%d.json", i))
+		newStorage, err := New(config)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		return newStorage
+	})
+}
+
+func Test_Storage_Snapshot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rangepool-memory")
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+	defer os.RemoveAll(dir)
+
+	ctx := context.TODO()
+
+	config := DefaultConfig()
+	config.Logger = microloggertest.New()
+	config.SnapshotFile = filepath.Join(dir, "snapshot.json")
+	config.SnapshotInterval = time.Millisecond
+
+	// Write some state and close the storage, which persists the final
+	// snapshot.
+	{
+		newStorage, err := New(config)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		err = newStorage.Create(ctx, "range-pool/test-namespace/item/2", "2")
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		err = newStorage.Create(ctx, "range-pool/test-namespace/latest", "2")
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		err = newStorage.Close()
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+	}
+
+	// A new storage must restore the state from the snapshot.
+	{
+		newStorage, err := New(config)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		defer newStorage.Close()
+
+		v, err := newStorage.Search(ctx, "range-pool/test-namespace/latest")
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		if v != "2" {
+			t.Fatal("expected", "2", "got", v)
+		}
+
+		kvs, err := newStorage.List(ctx, "range-pool/test-namespace/item")
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		if len(kvs) != 1 {
+			t.Fatal("expected", 1, "got", len(kvs))
+		}
+	}
+
+	// Snapshots must also be persisted to storage keys.
+	{
+		c := config
+		c.SnapshotFile = filepath.Join(dir, "backend.json")
+		backend, err := New(c)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		defer backend.Close()
+
+		c = config
+		c.SnapshotFile = ""
+		c.SnapshotKey = "range-pool-snapshot"
+		c.Storage = backend
+		newStorage, err := New(c)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		err = newStorage.Create(ctx, "range-pool/test-namespace/latest", "5")
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		err = newStorage.Close()
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		_, err = backend.Search(ctx, "range-pool-snapshot")
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+	}
+}
+
+func Test_Storage_Watch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rangepool-memory")
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+	defer os.RemoveAll(dir)
+
+	var newStorage *Storage
+	{
+		config := DefaultConfig()
+		config.Logger = microloggertest.New()
+		config.SnapshotFile = filepath.Join(dir, "snapshot.json")
+		newStorage, err = New(config)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		defer newStorage.Close()
+	}
+
+	// The service must not poll, since the storage notifies about changes.
+	var newService *rangepool.Service
+	{
+		config := rangepool.DefaultConfig()
+		config.Logger = microloggertest.New()
+		config.Storage = newStorage
+		config.WatchInterval = time.Hour
+		newService, err = rangepool.New(config)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events, err := newService.Watch(ctx, "test-namespace")
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+
+	_, err = newService.Create(ctx, "test-namespace", "test-id", 2, 2, 10)
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+
+	select {
+	case e := <-events:
+		if e.Type != rangepool.EventTypeAllocated {
+			t.Fatal("expected", rangepool.EventTypeAllocated, "got", e.Type)
+		}
+		if e.ID != "test-id" {
+			t.Fatal("expected", "test-id", "got", e.ID)
+		}
+		if len(e.Items) != 2 {
+			t.Fatal("expected", 2, "got", len(e.Items))
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected", "event", "got", "timeout")
+	}
+
+	// The events channel must be closed once the context is done.
+	cancel()
+	for range events {
+	}
+}
@@ -0,0 +1,32 @@
+package postgres
+
+import (
+	"github.com/giantswarm/microerror"
+)
+
+var invalidConfigError = &microerror.Error{
+	Kind: "invalidConfigError",
+}
+
+// IsInvalidConfig asserts invalidConfigError.
+func IsInvalidConfig(err error) bool {
+	return microerror.Cause(err) == invalidConfigError
+}
+
+var invalidKeyError = &microerror.Error{
+	Kind: "invalidKeyError",
+}
+
+// IsInvalidKey asserts invalidKeyError.
+func IsInvalidKey(err error) bool {
+	return microerror.Cause(err) == invalidKeyError
+}
+
+var itemAllocatedError = &microerror.Error{
+	Kind: "itemAllocatedError",
+}
+
+// IsItemAllocated asserts itemAllocatedError.
+func IsItemAllocated(err error) bool {
+	return microerror.Cause(err) == itemAllocatedError
+}
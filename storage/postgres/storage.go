@@ -0,0 +1,630 @@
+// Package postgres implements a microstorage.Storage backed by a Postgres
+// database. Other than the generic key-value backends, the range pool keys are
+// mapped onto an allocations table with a unique constraint on namespace and
+// item. That way the database enforces the uniqueness of items even under
+// concurrent writers. Keys not describing allocations, e.g. policies, audit
+// entries or leases, are persisted in a generic values table. Batches are
+// written within a single transaction. The required tables can be created
+// using Schema.
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+	"github.com/giantswarm/microstorage"
+
+	"github.com/giantswarm/rangepool"
+)
+
+// Schema is the SQL statement used to create the tables required by the
+// Postgres storage.
+const Schema = `
+CREATE TABLE IF NOT EXISTS rangepool_allocations (
+	namespace TEXT NOT NULL,
+	item INTEGER NOT NULL,
+	id TEXT NOT NULL DEFAULT '',
+	UNIQUE (namespace, item)
+);
+CREATE INDEX IF NOT EXISTS rangepool_allocations_id ON rangepool_allocations (namespace, id);
+CREATE TABLE IF NOT EXISTS rangepool_latest (
+	namespace TEXT NOT NULL PRIMARY KEY,
+	item INTEGER NOT NULL
+);
+CREATE TABLE IF NOT EXISTS rangepool_values (
+	key TEXT NOT NULL PRIMARY KEY,
+	value TEXT NOT NULL
+);
+`
+
+const (
+	keyKindNamespace = iota
+	keyKindItemList
+	keyKindItem
+	keyKindIDList
+	keyKindID
+	keyKindLatest
+	keyKindBitmap
+	keyKindSchema
+	keyKindValue
+)
+
+// Config represents the configuration used to create a new Postgres storage.
+type Config struct {
+	// Dependencies.
+	DB     *sql.DB
+	Logger micrologger.Logger
+}
+
+// DefaultConfig provides a default configuration to create a new Postgres
+// storage by best effort.
+func DefaultConfig() Config {
+	return Config{
+		// Dependencies.
+		DB:     nil,
+		Logger: nil,
+	}
+}
+
+// New creates a new configured Postgres storage.
+func New(config Config) (*Storage, error) {
+	// Dependencies.
+	if config.DB == nil {
+		return nil, microerror.Maskf(invalidConfigError, "db must not be empty")
+	}
+	if config.Logger == nil {
+		return nil, microerror.Maskf(invalidConfigError, "logger must not be empty")
+	}
+
+	newStorage := &Storage{
+		// Dependencies.
+		db:     config.DB,
+		logger: config.Logger,
+	}
+
+	return newStorage, nil
+}
+
+type Storage struct {
+	// Dependencies.
+	db     *sql.DB
+	logger micrologger.Logger
+}
+
+// execer is implemented by *sql.DB and *sql.Tx, so that writes can be executed
+// within transactions.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// Apply persists the given key-value pairs and removes the given keys within a
+// single transaction, see rangepool.MicrostorageAtomic.
+func (s *Storage) Apply(ctx context.Context, kvs []microstorage.KV, keys []microstorage.K) error {
+	err := s.transact(ctx, func(tx *sql.Tx) error {
+		for _, kv := range kvs {
+			err := s.put(ctx, tx, kv)
+			if err != nil {
+				return microerror.Mask(err)
+			}
+		}
+		for _, key := range keys {
+			err := s.delete(ctx, tx, key)
+			if err != nil {
+				return microerror.Mask(err)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// CreateSchema creates the tables required by the Postgres storage in case
+// they do not exist yet.
+func (s *Storage) CreateSchema(ctx context.Context) error {
+	_, err := s.db.ExecContext(ctx, Schema)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+func (s *Storage) Delete(ctx context.Context, key microstorage.K) error {
+	err := s.delete(ctx, s.db, key)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// DeleteBatch removes all of the given keys within a single transaction.
+func (s *Storage) DeleteBatch(ctx context.Context, keys []microstorage.K) error {
+	err := s.transact(ctx, func(tx *sql.Tx) error {
+		for _, key := range keys {
+			err := s.delete(ctx, tx, key)
+			if err != nil {
+				return microerror.Mask(err)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+func (s *Storage) Exists(ctx context.Context, key microstorage.K) (bool, error) {
+	_, err := s.Search(ctx, key)
+	if microstorage.IsNotFound(err) {
+		return false, nil
+	} else if err != nil {
+		return false, microerror.Mask(err)
+	}
+
+	return true, nil
+}
+
+func (s *Storage) List(ctx context.Context, key microstorage.K) ([]microstorage.KV, error) {
+	k, err := parseKey(key)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	var rows *sql.Rows
+	switch k.kind {
+	case keyKindItemList:
+		rows, err = s.db.QueryContext(ctx, `SELECT item FROM rangepool_allocations WHERE namespace = $1`, k.namespace)
+	case keyKindIDList:
+		rows, err = s.db.QueryContext(ctx, `SELECT item FROM rangepool_allocations WHERE namespace = $1 AND id = $2`, k.namespace, k.id)
+	case keyKindValue:
+		list, err := s.listValues(ctx, k.key)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		return list, nil
+	default:
+		return nil, microerror.Maskf(invalidKeyError, "key '%s' cannot be listed", key.Key())
+	}
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+	defer rows.Close()
+
+	var list []microstorage.KV
+	for rows.Next() {
+		var item int
+		err := rows.Scan(&item)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		i := strconv.Itoa(item)
+		kv, err := microstorage.NewKV(i, i)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+		list = append(list, kv)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return list, nil
+}
+
+func (s *Storage) Put(ctx context.Context, kv microstorage.KV) error {
+	err := s.put(ctx, s.db, kv)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// PutBatch persists all of the given key-value pairs within a single
+// transaction. That way the item and ID keys of an allocation are written
+// together, so that failing to relate an item to its ID does not leave the
+// item allocated without owner.
+func (s *Storage) PutBatch(ctx context.Context, kvs []microstorage.KV) error {
+	err := s.transact(ctx, func(tx *sql.Tx) error {
+		for _, kv := range kvs {
+			err := s.put(ctx, tx, kv)
+			if err != nil {
+				return microerror.Mask(err)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+func (s *Storage) Search(ctx context.Context, key microstorage.K) (microstorage.KV, error) {
+	k, err := parseKey(key)
+	if err != nil {
+		return microstorage.KV{}, microerror.Mask(err)
+	}
+
+	var item int
+	switch k.kind {
+	case keyKindItem:
+		err = s.db.QueryRowContext(ctx, `SELECT item FROM rangepool_allocations WHERE namespace = $1 AND item = $2`, k.namespace, k.item).Scan(&item)
+	case keyKindID:
+		err = s.db.QueryRowContext(ctx, `SELECT item FROM rangepool_allocations WHERE namespace = $1 AND item = $2 AND id = $3`, k.namespace, k.item, k.id).Scan(&item)
+	case keyKindLatest:
+		err = s.db.QueryRowContext(ctx, `SELECT item FROM rangepool_latest WHERE namespace = $1`, k.namespace).Scan(&item)
+	case keyKindValue:
+		var v string
+		err = s.db.QueryRowContext(ctx, `SELECT value FROM rangepool_values WHERE key = $1`, k.key).Scan(&v)
+		if err == sql.ErrNoRows {
+			return microstorage.KV{}, microerror.Maskf(microstorage.NotFoundError, "%s", key.Key())
+		} else if err != nil {
+			return microstorage.KV{}, microerror.Mask(err)
+		}
+
+		kv, err := microstorage.NewKV(key.Key(), v)
+		if err != nil {
+			return microstorage.KV{}, microerror.Mask(err)
+		}
+
+		return kv, nil
+	default:
+		return microstorage.KV{}, microerror.Maskf(microstorage.NotFoundError, "%s", key.Key())
+	}
+	if err == sql.ErrNoRows {
+		return microstorage.KV{}, microerror.Maskf(microstorage.NotFoundError, "%s", key.Key())
+	} else if err != nil {
+		return microstorage.KV{}, microerror.Mask(err)
+	}
+
+	kv, err := microstorage.NewKV(key.Key(), strconv.Itoa(item))
+	if err != nil {
+		return microstorage.KV{}, microerror.Mask(err)
+	}
+
+	return kv, nil
+}
+
+// Swap persists the given value under the given key in case its current value
+// is old, see rangepool.MicrostorageSwap. Only keys of the values table can be
+// swapped, e.g. the leases of rangepool.Elector.
+func (s *Storage) Swap(ctx context.Context, key microstorage.K, old, value string) (bool, error) {
+	k, err := parseKey(key)
+	if err != nil {
+		return false, microerror.Mask(err)
+	}
+	if k.kind != keyKindValue {
+		return false, microerror.Maskf(invalidKeyError, "key '%s' cannot be swapped", key.Key())
+	}
+
+	var res sql.Result
+	if old == "" {
+		res, err = s.db.ExecContext(ctx, `INSERT INTO rangepool_values (key, value) VALUES ($1, $2) ON CONFLICT (key) DO NOTHING`, k.key, value)
+	} else {
+		res, err = s.db.ExecContext(ctx, `UPDATE rangepool_values SET value = $3 WHERE key = $1 AND value = $2`, k.key, old, value)
+	}
+	if err != nil {
+		return false, microerror.Mask(err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, microerror.Mask(err)
+	}
+
+	return n == 1, nil
+}
+
+// TypedValues returns true, since the latest items of namespaces are persisted
+// as integers, see rangepool.TypedStorage.
+func (s *Storage) TypedValues() bool {
+	return true
+}
+
+// delete removes the given key using the given execer.
+func (s *Storage) delete(ctx context.Context, e execer, key microstorage.K) error {
+	k, err := parseKey(key)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	switch k.kind {
+	case keyKindNamespace:
+		_, err = e.ExecContext(ctx, `DELETE FROM rangepool_allocations WHERE namespace = $1`, k.namespace)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+		_, err = e.ExecContext(ctx, `DELETE FROM rangepool_latest WHERE namespace = $1`, k.namespace)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+		_, err = e.ExecContext(ctx, `DELETE FROM rangepool_values WHERE starts_with(key, $1)`, key.KeyNoLeadingSlash()+"/")
+	case keyKindItemList:
+		_, err = e.ExecContext(ctx, `DELETE FROM rangepool_allocations WHERE namespace = $1`, k.namespace)
+	case keyKindItem:
+		_, err = e.ExecContext(ctx, `DELETE FROM rangepool_allocations WHERE namespace = $1 AND item = $2`, k.namespace, k.item)
+	case keyKindIDList:
+		_, err = e.ExecContext(ctx, `DELETE FROM rangepool_allocations WHERE namespace = $1 AND id = $2`, k.namespace, k.id)
+	case keyKindID:
+		// The relationship between the ID and the item is removed while the item
+		// itself stays allocated until its item key is deleted.
+		_, err = e.ExecContext(ctx, `UPDATE rangepool_allocations SET id = '' WHERE namespace = $1 AND item = $2 AND id = $3`, k.namespace, k.item, k.id)
+	case keyKindLatest:
+		_, err = e.ExecContext(ctx, `DELETE FROM rangepool_latest WHERE namespace = $1`, k.namespace)
+	case keyKindValue:
+		_, err = e.ExecContext(ctx, `DELETE FROM rangepool_values WHERE key = $1`, k.key)
+	}
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// listValues returns the key-value pairs of the values table below the given
+// key, relative to the given key.
+func (s *Storage) listValues(ctx context.Context, key string) ([]microstorage.KV, error) {
+	prefix := key + "/"
+
+	rows, err := s.db.QueryContext(ctx, `SELECT key, value FROM rangepool_values WHERE starts_with(key, $1)`, prefix)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+	defer rows.Close()
+
+	var list []microstorage.KV
+	for rows.Next() {
+		var k, v string
+		err := rows.Scan(&k, &v)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		kv, err := microstorage.NewKV(strings.TrimPrefix(k, prefix), v)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+		list = append(list, kv)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return list, nil
+}
+
+// put persists the given key-value pair using the given execer.
+func (s *Storage) put(ctx context.Context, e execer, kv microstorage.KV) error {
+	k, err := parseKey(kv.K())
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	switch k.kind {
+	case keyKindItem:
+		_, err = e.ExecContext(ctx, `INSERT INTO rangepool_allocations (namespace, item) VALUES ($1, $2) ON CONFLICT (namespace, item) DO NOTHING`, k.namespace, k.item)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	case keyKindID:
+		// The item is only assigned to the ID in case it is not yet owned by any
+		// other ID. The conflicting row is locked by the database while the
+		// statement is executed, so concurrent writers cannot both succeed.
+		res, err := e.ExecContext(ctx, `INSERT INTO rangepool_allocations (namespace, item, id) VALUES ($1, $2, $3) ON CONFLICT (namespace, item) DO UPDATE SET id = EXCLUDED.id WHERE rangepool_allocations.id = '' OR rangepool_allocations.id = EXCLUDED.id`, k.namespace, k.item, k.id)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return microerror.Mask(err)
+		}
+		if n == 0 {
+			return microerror.Maskf(itemAllocatedError, "item '%d' in namespace '%s' is already allocated", k.item, k.namespace)
+		}
+	case keyKindLatest:
+		item, err := strconv.Atoi(kv.Val())
+		if err != nil {
+			return microerror.Maskf(invalidKeyError, "value '%s' of key '%s' must be a number", kv.Val(), kv.Key())
+		}
+		_, err = e.ExecContext(ctx, `INSERT INTO rangepool_latest (namespace, item) VALUES ($1, $2) ON CONFLICT (namespace) DO UPDATE SET item = EXCLUDED.item`, k.namespace, item)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	case keyKindSchema:
+		// The layout of the tables is fixed, see parseKey.
+	case keyKindValue:
+		_, err = e.ExecContext(ctx, `INSERT INTO rangepool_values (key, value) VALUES ($1, $2) ON CONFLICT (key) DO UPDATE SET value = EXCLUDED.value`, k.key, kv.Val())
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	default:
+		return microerror.Maskf(invalidKeyError, "key '%s' cannot be written", kv.Key())
+	}
+
+	return nil
+}
+
+// transact executes fn within a transaction, which is committed in case fn
+// succeeds and rolled back otherwise.
+func (s *Storage) transact(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = fn(tx)
+	if err != nil {
+		rollbackErr := tx.Rollback()
+		if rollbackErr != nil {
+			s.logger.LogCtx(ctx, "level", "error", "message", "failed rolling back transaction", "stack", fmt.Sprintf("%#v", rollbackErr))
+		}
+
+		return microerror.Mask(err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+type parsedKey struct {
+	kind      int
+	namespace string
+	id        string
+	item      int
+	key       string
+}
+
+// parseKey maps the given range pool key onto the rows of the allocations and
+// latest tables. Supported are the following keys.
+//
+//     range-pool/${namespace1}
+//     range-pool/${namespace1}/item
+//     range-pool/${namespace1}/item/${item1}
+//     range-pool/${namespace1}/id/${id1}/item
+//     range-pool/${namespace1}/id/${id1}/item/${item1}
+//     range-pool/${namespace1}/latest
+//     range-pool/${namespace1}/subpool/${subpool1}/latest
+//     range-pool/${namespace1}/id/${id1}/latest
+//
+// The bitmap and schema keys of namespaces are recognized as well, but never
+// found. The layout of the tables is fixed, so schema markers are not
+// persisted, and namespaces always match the configured layout. Bitmaps are
+// not supported.
+//
+// The following keys are persisted in the values table under their full key,
+// e.g. for rangepool.Config.Audit, rangepool.Config.HistorySize or
+// rangepool.Elector.
+//
+//     range-pool/${namespace1}/audit/${entry1}
+//     range-pool/${namespace1}/burned/${item1}
+//     range-pool/${namespace1}/freed/${item1}
+//     range-pool/${namespace1}/history/${item1}
+//     range-pool/${namespace1}/policy
+//     range-pool/${namespace1}/previous/${id1}
+//     range-pool/${namespace1}/reservation/${reservation1}
+//     range-pool/${namespace1}/snapshot/${snapshot1}
+//     range-pool/${namespace1}/waiter/${waiter1}
+//     range-pool/leader/${lease1}
+//
+// Keys using another prefix than rangepool.DefaultKeyPrefix, see
+// rangepool.Config.KeyPrefix, are persisted using the prefix as part of the
+// namespace, e.g. ${prefix}/${namespace1}. That way pools of different prefixes
+// do not collide within the tables.
+func parseKey(key microstorage.K) (parsedKey, error) {
+	parts := strings.SplitN(key.KeyNoLeadingSlash(), "/", 3)
+	if len(parts) < 2 {
+		return parsedKey{}, microerror.Maskf(invalidKeyError, "key '%s' must contain a namespace", key.Key())
+	}
+
+	k := parsedKey{
+		namespace: parts[1],
+	}
+	if parts[0] != rangepool.DefaultKeyPrefix {
+		k.namespace = parts[0] + "/" + parts[1]
+	}
+
+	var rel string
+	if len(parts) == 3 {
+		rel = parts[2]
+	}
+
+	var item string
+	switch {
+	case rel == "":
+		k.kind = keyKindNamespace
+	case parts[1] == "leader" && !strings.Contains(rel, "/"):
+		k.kind = keyKindValue
+	case rel == "latest":
+		k.kind = keyKindLatest
+	case rel == "bitmap":
+		k.kind = keyKindBitmap
+	case rel == "schema":
+		k.kind = keyKindSchema
+	case isValue(rel):
+		k.kind = keyKindValue
+	case strings.HasPrefix(rel, "subpool/") && strings.HasSuffix(rel, "/latest") && strings.Count(rel, "/") == 2:
+		// The latest items of sub-pools are persisted like the ones of
+		// namespaces named after the sub-pool, which cannot collide since
+		// namespaces do not contain slashes.
+		k.kind = keyKindLatest
+		k.namespace = k.namespace + "/subpool/" + strings.TrimSuffix(strings.TrimPrefix(rel, "subpool/"), "/latest")
+	case strings.HasPrefix(rel, "id/") && strings.HasSuffix(rel, "/latest") && strings.Count(rel, "/") == 2:
+		// The latest items of IDs are persisted like the ones of sub-pools.
+		k.kind = keyKindLatest
+		k.namespace = k.namespace + "/id/" + strings.TrimSuffix(strings.TrimPrefix(rel, "id/"), "/latest")
+	case rel == "item":
+		k.kind = keyKindItemList
+	case strings.HasPrefix(rel, "item/"):
+		k.kind = keyKindItem
+		item = strings.TrimPrefix(rel, "item/")
+	case strings.HasPrefix(rel, "id/") && strings.HasSuffix(rel, "/item"):
+		k.kind = keyKindIDList
+		k.id = strings.TrimSuffix(strings.TrimPrefix(rel, "id/"), "/item")
+	case strings.HasPrefix(rel, "id/") && strings.Contains(rel, "/item/"):
+		i := strings.LastIndex(rel, "/item/")
+		k.kind = keyKindID
+		k.id = rel[len("id/"):i]
+		item = rel[i+len("/item/"):]
+	default:
+		return parsedKey{}, microerror.Maskf(invalidKeyError, "key '%s' is not a range pool key", key.Key())
+	}
+
+	if item != "" {
+		i, err := strconv.Atoi(item)
+		if err != nil {
+			return parsedKey{}, microerror.Maskf(invalidKeyError, "item of key '%s' must be a number", key.Key())
+		}
+		k.item = i
+	}
+	if k.kind == keyKindValue {
+		k.key = key.KeyNoLeadingSlash()
+	}
+	if k.kind == keyKindIDList || k.kind == keyKindID {
+		if k.id == "" {
+			return parsedKey{}, microerror.Maskf(invalidKeyError, "ID of key '%s' must not be empty", key.Key())
+		}
+	}
+
+	return k, nil
+}
+
+// isValue returns whether the given key relative to a namespace is persisted
+// in the values table, see parseKey.
+func isValue(rel string) bool {
+	if rel == "policy" {
+		return true
+	}
+
+	for _, p := range []string{"audit", "burned", "freed", "history", "previous", "reservation", "snapshot", "waiter"} {
+		if rel == p || strings.HasPrefix(rel, p+"/") {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,808 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger/microloggertest"
+	"github.com/giantswarm/microstorage"
+
+	"github.com/giantswarm/rangepool"
+)
+
+func Test_parseKey(t *testing.T) {
+	testCases := []struct {
+		Key          string
+		ExpectedKey  parsedKey
+		ErrorMatcher func(err error) bool
+	}{
+		// Case 0 ensures a namespace key is parsed.
+		{
+			Key: "range-pool/test-namespace",
+			ExpectedKey: parsedKey{
+				kind:      keyKindNamespace,
+				namespace: "test-namespace",
+			},
+			ErrorMatcher: nil,
+		},
+		// Case 1 ensures the item list key is parsed.
+		{
+			Key: "range-pool/test-namespace/item",
+			ExpectedKey: parsedKey{
+				kind:      keyKindItemList,
+				namespace: "test-namespace",
+			},
+			ErrorMatcher: nil,
+		},
+		// Case 2 ensures an item key is parsed.
+		{
+			Key: "range-pool/test-namespace/item/7",
+			ExpectedKey: parsedKey{
+				kind:      keyKindItem,
+				namespace: "test-namespace",
+				item:      7,
+			},
+			ErrorMatcher: nil,
+		},
+		// Case 3 ensures the ID list key is parsed.
+		{
+			Key: "range-pool/test-namespace/id/test-id/item",
+			ExpectedKey: parsedKey{
+				kind:      keyKindIDList,
+				namespace: "test-namespace",
+				id:        "test-id",
+			},
+			ErrorMatcher: nil,
+		},
+		// Case 4 ensures an ID key is parsed.
+		{
+			Key: "range-pool/test-namespace/id/test-id/item/7",
+			ExpectedKey: parsedKey{
+				kind:      keyKindID,
+				namespace: "test-namespace",
+				id:        "test-id",
+				item:      7,
+			},
+			ErrorMatcher: nil,
+		},
+		// Case 5 ensures the latest key is parsed.
+		{
+			Key: "range-pool/test-namespace/latest",
+			ExpectedKey: parsedKey{
+				kind:      keyKindLatest,
+				namespace: "test-namespace",
+			},
+			ErrorMatcher: nil,
+		},
+		// Case 6 ensures items must be numbers.
+		{
+			Key:          "range-pool/test-namespace/item/foo",
+			ExpectedKey:  parsedKey{},
+			ErrorMatcher: IsInvalidKey,
+		},
+		// Case 7 ensures keys without namespace are rejected.
+		{
+			Key:          "range-pool",
+			ExpectedKey:  parsedKey{},
+			ErrorMatcher: IsInvalidKey,
+		},
+		// Case 8 ensures unknown keys are rejected.
+		{
+			Key:          "range-pool/test-namespace/foo",
+			ExpectedKey:  parsedKey{},
+			ErrorMatcher: IsInvalidKey,
+		},
+		// Case 9 ensures custom key prefixes are part of the namespace.
+		{
+			Key: "custom-pool/test-namespace/item/7",
+			ExpectedKey: parsedKey{
+				kind:      keyKindItem,
+				namespace: "custom-pool/test-namespace",
+				item:      7,
+			},
+			ErrorMatcher: nil,
+		},
+		// Case 10 ensures the schema key is parsed.
+		{
+			Key: "range-pool/test-namespace/schema",
+			ExpectedKey: parsedKey{
+				kind:      keyKindSchema,
+				namespace: "test-namespace",
+			},
+			ErrorMatcher: nil,
+		},
+		// Case 11 ensures the policy key is parsed.
+		{
+			Key: "range-pool/test-namespace/policy",
+			ExpectedKey: parsedKey{
+				kind:      keyKindValue,
+				namespace: "test-namespace",
+				key:       "range-pool/test-namespace/policy",
+			},
+			ErrorMatcher: nil,
+		},
+		// Case 12 ensures the latest key of a sub-pool is parsed.
+		{
+			Key: "range-pool/test-namespace/subpool/system/latest",
+			ExpectedKey: parsedKey{
+				kind:      keyKindLatest,
+				namespace: "test-namespace/subpool/system",
+			},
+			ErrorMatcher: nil,
+		},
+		// Case 13 ensures the latest key of an ID is parsed.
+		{
+			Key: "range-pool/test-namespace/id/test-id/latest",
+			ExpectedKey: parsedKey{
+				kind:      keyKindLatest,
+				namespace: "test-namespace/id/test-id",
+			},
+			ErrorMatcher: nil,
+		},
+		// Case 14 ensures the reservation keys are parsed.
+		{
+			Key: "range-pool/test-namespace/reservation/0123abcd",
+			ExpectedKey: parsedKey{
+				kind:      keyKindValue,
+				namespace: "test-namespace",
+				key:       "range-pool/test-namespace/reservation/0123abcd",
+			},
+			ErrorMatcher: nil,
+		},
+		// Case 15 ensures the burned keys are parsed.
+		{
+			Key: "range-pool/test-namespace/burned",
+			ExpectedKey: parsedKey{
+				kind:      keyKindValue,
+				namespace: "test-namespace",
+				key:       "range-pool/test-namespace/burned",
+			},
+			ErrorMatcher: nil,
+		},
+		// Case 16 ensures the freed keys are parsed.
+		{
+			Key: "range-pool/test-namespace/freed/7",
+			ExpectedKey: parsedKey{
+				kind:      keyKindValue,
+				namespace: "test-namespace",
+				key:       "range-pool/test-namespace/freed/7",
+			},
+			ErrorMatcher: nil,
+		},
+		// Case 17 ensures the waiter keys are parsed.
+		{
+			Key: "range-pool/test-namespace/waiter",
+			ExpectedKey: parsedKey{
+				kind:      keyKindValue,
+				namespace: "test-namespace",
+				key:       "range-pool/test-namespace/waiter",
+			},
+			ErrorMatcher: nil,
+		},
+		// Case 18 ensures the history keys are parsed.
+		{
+			Key: "range-pool/test-namespace/history/7",
+			ExpectedKey: parsedKey{
+				kind:      keyKindValue,
+				namespace: "test-namespace",
+				key:       "range-pool/test-namespace/history/7",
+			},
+			ErrorMatcher: nil,
+		},
+		// Case 19 ensures the audit keys are parsed.
+		{
+			Key: "range-pool/test-namespace/audit/00000000000000000001-allocate-test-id",
+			ExpectedKey: parsedKey{
+				kind:      keyKindValue,
+				namespace: "test-namespace",
+				key:       "range-pool/test-namespace/audit/00000000000000000001-allocate-test-id",
+			},
+			ErrorMatcher: nil,
+		},
+		// Case 20 ensures the lease keys are parsed.
+		{
+			Key: "range-pool/leader/default",
+			ExpectedKey: parsedKey{
+				kind:      keyKindValue,
+				namespace: "leader",
+				key:       "range-pool/leader/default",
+			},
+			ErrorMatcher: nil,
+		},
+	}
+
+	for i, tc := range testCases {
+		k, err := parseKey(microstorage.MustK(microstorage.NewK(tc.Key)))
+
+		if err != nil && tc.ErrorMatcher == nil {
+			t.Fatal("case", i, "expected", nil, "got", err)
+		}
+		if tc.ErrorMatcher != nil && !tc.ErrorMatcher(err) {
+			t.Fatal("case", i, "expected", true, "got", false)
+		}
+		if k != tc.ExpectedKey {
+			t.Fatal("case", i, "expected", tc.ExpectedKey, "got", k)
+		}
+	}
+}
+
+// Test_Storage_Create ensures allocations work with the Postgres storage. The
+// database is emulated by testConnector, which implements the statements
+// issued by the Postgres storage.
+func Test_Storage_Create(t *testing.T) {
+	var err error
+
+	var newService *rangepool.Service
+	{
+		c := DefaultConfig()
+		c.DB = sql.OpenDB(&testConnector{db: newTestDB()})
+		c.Logger = microloggertest.New()
+		postgresStorage, err := New(c)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		mc := rangepool.DefaultMicrostorageConfig()
+		mc.Storage = postgresStorage
+		microStorage, err := rangepool.NewMicrostorage(mc)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		config := rangepool.DefaultConfig()
+		config.Logger = microloggertest.New()
+		config.Storage = microStorage
+		newService, err = rangepool.New(config)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+	}
+
+	ctx := context.TODO()
+
+	{
+		items, err := newService.Create(ctx, "test-namespace", "test-id-1", 2, 1, 10)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		expected := []int{1, 2}
+		if !reflect.DeepEqual(items, expected) {
+			t.Fatal("expected", expected, "got", items)
+		}
+	}
+
+	{
+		items, err := newService.Create(ctx, "test-namespace", "test-id-2", 1, 1, 10)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		expected := []int{3}
+		if !reflect.DeepEqual(items, expected) {
+			t.Fatal("expected", expected, "got", items)
+		}
+	}
+
+	{
+		items, err := newService.Search(ctx, "test-namespace", "test-id-1")
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		expected := []int{1, 2}
+		if !reflect.DeepEqual(items, expected) {
+			t.Fatal("expected", expected, "got", items)
+		}
+	}
+
+	{
+		err = newService.Delete(ctx, "test-namespace", "test-id-1")
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		_, err = newService.Search(ctx, "test-namespace", "test-id-1")
+		if !rangepool.IsItemsNotFound(err) {
+			t.Fatal("expected", true, "got", false)
+		}
+	}
+}
+
+// Test_Storage_Values ensures the keys persisted in the values table work with
+// the Postgres storage, e.g. for auditing and the history of items.
+func Test_Storage_Values(t *testing.T) {
+	var err error
+
+	var newService *rangepool.Service
+	{
+		c := DefaultConfig()
+		c.DB = sql.OpenDB(&testConnector{db: newTestDB()})
+		c.Logger = microloggertest.New()
+		postgresStorage, err := New(c)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		mc := rangepool.DefaultMicrostorageConfig()
+		mc.Storage = postgresStorage
+		microStorage, err := rangepool.NewMicrostorage(mc)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		config := rangepool.DefaultConfig()
+		config.Logger = microloggertest.New()
+		config.Storage = microStorage
+		config.Audit = true
+		config.HistorySize = 2
+		newService, err = rangepool.New(config)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+	}
+
+	ctx := context.TODO()
+
+	{
+		_, err = newService.Create(ctx, "test-namespace", "test-id-1", 1, 1, 10)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		err = newService.Delete(ctx, "test-namespace", "test-id-1")
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+	}
+
+	{
+		entries, err := newService.AuditLog(ctx, "test-namespace", time.Time{})
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		if len(entries) != 2 {
+			t.Fatal("expected", 2, "got", len(entries))
+		}
+	}
+
+	{
+		entries, err := newService.History(ctx, "test-namespace", 1)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		if len(entries) != 1 || entries[0].ID != "test-id-1" {
+			t.Fatal("expected", "test-id-1", "got", entries)
+		}
+	}
+}
+
+// Test_Storage_PutBatch ensures batches are written within a single
+// transaction, so that items are not left allocated without owner in case
+// relating them to their ID fails.
+func Test_Storage_PutBatch(t *testing.T) {
+	var err error
+
+	var newStorage *Storage
+	{
+		c := DefaultConfig()
+		c.DB = sql.OpenDB(&testConnector{db: newTestDB()})
+		c.Logger = microloggertest.New()
+		newStorage, err = New(c)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+	}
+
+	ctx := context.TODO()
+
+	{
+		err = newStorage.PutBatch(ctx, []microstorage.KV{
+			microstorage.MustKV(microstorage.NewKV("range-pool/test-namespace/item/6", "6")),
+			microstorage.MustKV(microstorage.NewKV("range-pool/test-namespace/id/test-id-1/item/6", "6")),
+		})
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+	}
+
+	{
+		err = newStorage.PutBatch(ctx, []microstorage.KV{
+			microstorage.MustKV(microstorage.NewKV("range-pool/test-namespace/item/5", "5")),
+			microstorage.MustKV(microstorage.NewKV("range-pool/test-namespace/id/test-id-2/item/5", "5")),
+			microstorage.MustKV(microstorage.NewKV("range-pool/test-namespace/id/test-id-2/item/6", "6")),
+		})
+		if !IsItemAllocated(err) {
+			t.Fatal("expected", true, "got", false)
+		}
+
+		_, err = newStorage.Search(ctx, microstorage.MustK(microstorage.NewK("range-pool/test-namespace/item/5")))
+		if !microstorage.IsNotFound(err) {
+			t.Fatal("expected", true, "got", false)
+		}
+	}
+}
+
+func Test_Storage_Swap(t *testing.T) {
+	var err error
+
+	var newStorage *Storage
+	{
+		c := DefaultConfig()
+		c.DB = sql.OpenDB(&testConnector{db: newTestDB()})
+		c.Logger = microloggertest.New()
+		newStorage, err = New(c)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+	}
+
+	ctx := context.TODO()
+	key := microstorage.MustK(microstorage.NewK("range-pool/leader/default"))
+
+	testCases := []struct {
+		Old        string
+		Value      string
+		ExpectedOK bool
+		Expected   string
+	}{
+		// Case 1 ensures missing keys are created in case no value is expected.
+		{
+			Old:        "",
+			Value:      "1",
+			ExpectedOK: true,
+			Expected:   "1",
+		},
+		// Case 2 ensures existing keys are not overwritten in case no value is
+		// expected.
+		{
+			Old:        "",
+			Value:      "2",
+			ExpectedOK: false,
+			Expected:   "1",
+		},
+		// Case 3 ensures keys are not overwritten in case their value differs.
+		{
+			Old:        "3",
+			Value:      "2",
+			ExpectedOK: false,
+			Expected:   "1",
+		},
+		// Case 4 ensures keys are overwritten in case their value matches.
+		{
+			Old:        "1",
+			Value:      "2",
+			ExpectedOK: true,
+			Expected:   "2",
+		},
+	}
+
+	for i, tc := range testCases {
+		ok, err := newStorage.Swap(ctx, key, tc.Old, tc.Value)
+		if err != nil {
+			t.Fatal("case", i+1, "expected", nil, "got", err)
+		}
+		if ok != tc.ExpectedOK {
+			t.Fatal("case", i+1, "expected", tc.ExpectedOK, "got", ok)
+		}
+
+		kv, err := newStorage.Search(ctx, key)
+		if err != nil {
+			t.Fatal("case", i+1, "expected", nil, "got", err)
+		}
+		if kv.Val() != tc.Expected {
+			t.Fatal("case", i+1, "expected", tc.Expected, "got", kv.Val())
+		}
+	}
+}
+
+type testDB struct {
+	allocations map[string]map[int64]string
+	latest      map[string]int64
+	mutex       sync.Mutex
+	values      map[string]string
+
+	// backup is the state at the beginning of the running transaction.
+	// Transactions are not isolated from each other, which is good enough for
+	// sequential tests.
+	backup *testDB
+}
+
+func newTestDB() *testDB {
+	db := &testDB{
+		allocations: map[string]map[int64]string{},
+		latest:      map[string]int64{},
+		mutex:       sync.Mutex{},
+		values:      map[string]string{},
+	}
+
+	return db
+}
+
+// begin remembers the current state, so that it can be restored by rollback.
+func (db *testDB) begin() {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	b := newTestDB()
+	for ns, rows := range db.allocations {
+		b.allocations[ns] = map[int64]string{}
+		for item, id := range rows {
+			b.allocations[ns][item] = id
+		}
+	}
+	for ns, item := range db.latest {
+		b.latest[ns] = item
+	}
+	for k, v := range db.values {
+		b.values[k] = v
+	}
+	db.backup = b
+}
+
+// end restores the state remembered by begin in case rollback is true.
+func (db *testDB) end(rollback bool) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	if rollback {
+		db.allocations = db.backup.allocations
+		db.latest = db.backup.latest
+		db.values = db.backup.values
+	}
+	db.backup = nil
+}
+
+// exec executes the given statement and returns the selected rows and the
+// number of affected rows.
+func (db *testDB) exec(query string, args []driver.Value) ([][]driver.Value, int64, error) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	switch query {
+	case `DELETE FROM rangepool_values WHERE starts_with(key, $1)`:
+		var n int64
+		for k := range db.values {
+			if strings.HasPrefix(k, args[0].(string)) {
+				delete(db.values, k)
+				n++
+			}
+		}
+		return nil, n, nil
+	case `DELETE FROM rangepool_values WHERE key = $1`:
+		_, ok := db.values[args[0].(string)]
+		delete(db.values, args[0].(string))
+		if ok {
+			return nil, 1, nil
+		}
+		return nil, 0, nil
+	case `INSERT INTO rangepool_values (key, value) VALUES ($1, $2) ON CONFLICT (key) DO UPDATE SET value = EXCLUDED.value`:
+		db.values[args[0].(string)] = args[1].(string)
+		return nil, 1, nil
+	case `INSERT INTO rangepool_values (key, value) VALUES ($1, $2) ON CONFLICT (key) DO NOTHING`:
+		_, ok := db.values[args[0].(string)]
+		if ok {
+			return nil, 0, nil
+		}
+		db.values[args[0].(string)] = args[1].(string)
+		return nil, 1, nil
+	case `UPDATE rangepool_values SET value = $3 WHERE key = $1 AND value = $2`:
+		v, ok := db.values[args[0].(string)]
+		if !ok || v != args[1].(string) {
+			return nil, 0, nil
+		}
+		db.values[args[0].(string)] = args[2].(string)
+		return nil, 1, nil
+	case `SELECT key, value FROM rangepool_values WHERE starts_with(key, $1)`:
+		var rows [][]driver.Value
+		for k, v := range db.values {
+			if strings.HasPrefix(k, args[0].(string)) {
+				rows = append(rows, []driver.Value{k, v})
+			}
+		}
+		return rows, 0, nil
+	case `SELECT value FROM rangepool_values WHERE key = $1`:
+		v, ok := db.values[args[0].(string)]
+		if ok {
+			return [][]driver.Value{{v}}, 0, nil
+		}
+		return nil, 0, nil
+	}
+
+	var ns string
+	if len(args) > 0 {
+		ns = args[0].(string)
+	}
+	if db.allocations[ns] == nil {
+		db.allocations[ns] = map[int64]string{}
+	}
+	rows := db.allocations[ns]
+
+	var items []int64
+	var n int64
+	switch query {
+	case `DELETE FROM rangepool_allocations WHERE namespace = $1`:
+		n = int64(len(rows))
+		delete(db.allocations, ns)
+	case `DELETE FROM rangepool_allocations WHERE namespace = $1 AND item = $2`:
+		_, ok := rows[args[1].(int64)]
+		if ok {
+			n = 1
+		}
+		delete(rows, args[1].(int64))
+	case `DELETE FROM rangepool_allocations WHERE namespace = $1 AND id = $2`:
+		for item, id := range rows {
+			if id == args[1].(string) {
+				delete(rows, item)
+				n++
+			}
+		}
+	case `DELETE FROM rangepool_latest WHERE namespace = $1`:
+		delete(db.latest, ns)
+	case `UPDATE rangepool_allocations SET id = '' WHERE namespace = $1 AND item = $2 AND id = $3`:
+		id, ok := rows[args[1].(int64)]
+		if ok && id == args[2].(string) {
+			rows[args[1].(int64)] = ""
+			n = 1
+		}
+	case `SELECT item FROM rangepool_allocations WHERE namespace = $1`:
+		for item := range rows {
+			items = append(items, item)
+		}
+	case `SELECT item FROM rangepool_allocations WHERE namespace = $1 AND id = $2`:
+		for item, id := range rows {
+			if id == args[1].(string) {
+				items = append(items, item)
+			}
+		}
+	case `SELECT item FROM rangepool_allocations WHERE namespace = $1 AND item = $2`:
+		_, ok := rows[args[1].(int64)]
+		if ok {
+			items = append(items, args[1].(int64))
+		}
+	case `SELECT item FROM rangepool_allocations WHERE namespace = $1 AND item = $2 AND id = $3`:
+		id, ok := rows[args[1].(int64)]
+		if ok && id == args[2].(string) {
+			items = append(items, args[1].(int64))
+		}
+	case `SELECT item FROM rangepool_latest WHERE namespace = $1`:
+		item, ok := db.latest[ns]
+		if ok {
+			items = append(items, item)
+		}
+	case `INSERT INTO rangepool_allocations (namespace, item) VALUES ($1, $2) ON CONFLICT (namespace, item) DO NOTHING`:
+		_, ok := rows[args[1].(int64)]
+		if !ok {
+			rows[args[1].(int64)] = ""
+			n = 1
+		}
+	case `INSERT INTO rangepool_allocations (namespace, item, id) VALUES ($1, $2, $3) ON CONFLICT (namespace, item) DO UPDATE SET id = EXCLUDED.id WHERE rangepool_allocations.id = '' OR rangepool_allocations.id = EXCLUDED.id`:
+		id, ok := rows[args[1].(int64)]
+		if !ok || id == "" || id == args[2].(string) {
+			rows[args[1].(int64)] = args[2].(string)
+			n = 1
+		}
+	case `INSERT INTO rangepool_latest (namespace, item) VALUES ($1, $2) ON CONFLICT (namespace) DO UPDATE SET item = EXCLUDED.item`:
+		db.latest[ns] = args[1].(int64)
+		n = 1
+	default:
+		return nil, 0, microerror.Maskf(invalidKeyError, "unexpected query '%s'", query)
+	}
+
+	sort.Slice(items, func(i, j int) bool { return items[i] < items[j] })
+
+	var selected [][]driver.Value
+	for _, item := range items {
+		selected = append(selected, []driver.Value{item})
+	}
+
+	return selected, n, nil
+}
+
+// testConnector implements driver.Connector, driver.Conn and driver.Driver on
+// top of testDB.
+type testConnector struct {
+	db *testDB
+}
+
+func (c *testConnector) Begin() (driver.Tx, error) {
+	c.db.begin()
+	return &testTx{db: c.db}, nil
+}
+
+func (c *testConnector) Close() error {
+	return nil
+}
+
+func (c *testConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	return c, nil
+}
+
+func (c *testConnector) Driver() driver.Driver {
+	return c
+}
+
+func (c *testConnector) Open(name string) (driver.Conn, error) {
+	return c, nil
+}
+
+func (c *testConnector) Prepare(query string) (driver.Stmt, error) {
+	return &testStmt{db: c.db, query: query}, nil
+}
+
+type testStmt struct {
+	db    *testDB
+	query string
+}
+
+func (s *testStmt) Close() error {
+	return nil
+}
+
+func (s *testStmt) Exec(args []driver.Value) (driver.Result, error) {
+	_, n, err := s.db.exec(s.query, args)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return driver.RowsAffected(n), nil
+}
+
+func (s *testStmt) NumInput() int {
+	return -1
+}
+
+func (s *testStmt) Query(args []driver.Value) (driver.Rows, error) {
+	rows, _, err := s.db.exec(s.query, args)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	// The selected columns are the ones between SELECT and FROM.
+	columns := strings.Split(strings.TrimPrefix(strings.SplitN(s.query, " FROM ", 2)[0], "SELECT "), ", ")
+
+	return &testRows{columns: columns, rows: rows}, nil
+}
+
+type testRows struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+func (r *testRows) Close() error {
+	return nil
+}
+
+func (r *testRows) Columns() []string {
+	return r.columns
+}
+
+func (r *testRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+
+	return nil
+}
+
+type testTx struct {
+	db *testDB
+}
+
+func (t *testTx) Commit() error {
+	t.db.end(false)
+	return nil
+}
+
+func (t *testTx) Rollback() error {
+	t.db.end(true)
+	return nil
+}
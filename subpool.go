@@ -0,0 +1,109 @@
+package rangepool
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/giantswarm/microerror"
+)
+
+// SubPool is a named partition of the range of a namespace, see
+// Policy.SubPools and Service.CreateInSubPool.
+type SubPool struct {
+	// Max is the max boundary of the sub-pool, inclusive.
+	Max int `json:"max"`
+	// Min is the min boundary of the sub-pool, inclusive.
+	Min int `json:"min"`
+}
+
+// CreateInSubPool works like Create, but allocates num items within the
+// boundaries of the given sub-pool of the namespace, see Policy.SubPools.
+// Every sub-pool keeps its own latest item, see SubPoolLatestKeyFormat, so
+// that allocations of different item classes do not interleave. In case the
+// namespace does not define the sub-pool, an error is returned which can be
+// asserted using IsSubPoolNotFound.
+func (s *Service) CreateInSubPool(ctx context.Context, namespace, subPool, ID string, num int) ([]int, error) {
+	ctx = withOperation(ctx, "CreateInSubPool", namespace, ID)
+
+	p, err := s.searchPolicy(ctx, namespace)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	sp, ok := p.SubPools[subPool]
+	if !ok {
+		return nil, microerror.Maskf(subPoolNotFoundError, "sub-pool '%s' of namespace '%s'", subPool, namespace)
+	}
+
+	items, err := s.createWithOptions(ctx, namespace, ID, num, sp.Min, sp.Max, callOptions{subPool: subPool})
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return items, nil
+}
+
+// latestKey returns the storage key of the latest item allocations continue
+// from, which is the one of the sub-pool in case the call allocates within a
+// sub-pool, see Service.CreateInSubPool, or the one of the ID in case
+// Config.LatestMode is LatestModePerID.
+func (s *Service) latestKey(namespace string, opts callOptions) string {
+	if opts.subPool != "" {
+		return s.key(SubPoolLatestKeyFormat, namespace, opts.subPool)
+	}
+	if opts.latestID != "" {
+		return s.key(IDLatestKeyFormat, namespace, opts.latestID)
+	}
+
+	return s.key(LatestKeyFormat, namespace)
+}
+
+// latestKeys returns the storage keys of the latest items of the namespace
+// and all of its sub-pools. They are removed once the namespace runs empty in
+// case Config.LatestMode is LatestModeResetOnEmpty.
+func (s *Service) latestKeys(namespace string) []string {
+	keys := []string{s.key(LatestKeyFormat, namespace)}
+
+	var names []string
+	for name := range s.subPools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		keys = append(keys, s.key(SubPoolLatestKeyFormat, namespace, name))
+	}
+
+	return keys
+}
+
+func validateSubPools(subPools map[string]SubPool) error {
+	var names []string
+	for name := range subPools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for i, name := range names {
+		sp := subPools[name]
+
+		if name == "" || strings.Contains(name, "/") {
+			return microerror.Maskf(invalidArgumentError, "sub-pool name '%s' must not be empty and must not contain slashes", name)
+		}
+		if sp.Min < 0 || sp.Max < sp.Min {
+			return microerror.Maskf(invalidArgumentError, "sub-pool '%s' must define 0 <= min <= max", name)
+		}
+
+		// Sub-pools must not overlap, so that different item classes never
+		// compete for the same items.
+		for _, other := range names[i+1:] {
+			o := subPools[other]
+			if sp.Min <= o.Max && o.Min <= sp.Max {
+				return microerror.Maskf(invalidArgumentError, "sub-pool '%s' must not overlap with sub-pool '%s'", name, other)
+			}
+		}
+	}
+
+	return nil
+}
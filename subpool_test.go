@@ -0,0 +1,110 @@
+package rangepool
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/giantswarm/micrologger/microloggertest"
+)
+
+func Test_Service_CreateInSubPool(t *testing.T) {
+	for _, b := range []bool{false, true} {
+		var err error
+		var newService *Service
+		{
+			newStorage, err := newMemoryStorage()
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+
+			config := DefaultConfig()
+			config.Logger = microloggertest.New()
+			config.Storage = newStorage
+			config.Bitmap = b
+			config.LatestMode = LatestModeResetOnEmpty
+			newService, err = New(config)
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+		}
+
+		ctx := context.TODO()
+
+		create := func(subPool, ID string, num int, expected []int) {
+			items, err := newService.CreateInSubPool(ctx, namespace, subPool, ID, num)
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+			if !reflect.DeepEqual(items, expected) {
+				t.Fatal("expected", expected, "got", items)
+			}
+		}
+
+		// Sub-pools must not be used before they are defined.
+		{
+			_, err := newService.CreateInSubPool(ctx, namespace, "system", "test-id-1", 1)
+			if !IsSubPoolNotFound(err) {
+				t.Fatal("expected", true, "got", false)
+			}
+		}
+
+		// Overlapping sub-pools must be rejected.
+		{
+			p := Policy{
+				SubPools: map[string]SubPool{
+					"system": {Min: 2, Max: 5},
+					"user":   {Min: 5, Max: 9},
+				},
+			}
+			err := newService.SetPolicy(ctx, namespace, p)
+			if !IsInvalidArgument(err) {
+				t.Fatal("expected", true, "got", false)
+			}
+		}
+
+		{
+			p := Policy{
+				SubPools: map[string]SubPool{
+					"system": {Min: 2, Max: 4},
+					"user":   {Min: 5, Max: 9},
+				},
+			}
+			err = newService.SetPolicy(ctx, namespace, p)
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+		}
+
+		// Allocations of different sub-pools must not interleave and must keep
+		// their own latest items.
+		create("system", "test-id-1", 1, []int{2})
+		create("user", "test-id-2", 2, []int{5, 6})
+		create("system", "test-id-3", 1, []int{3})
+		create("user", "test-id-4", 1, []int{7})
+
+		err = newService.Delete(ctx, namespace, "test-id-1")
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		create("system", "test-id-5", 2, []int{4, 2})
+
+		_, err = newService.CreateInSubPool(ctx, namespace, "system", "test-id-6", 1)
+		if !IsCapacityReached(err) {
+			t.Fatal("expected", true, "got", false)
+		}
+
+		// The latest items of all sub-pools must be reset once the namespace
+		// runs empty.
+		for _, ID := range []string{"test-id-2", "test-id-3", "test-id-4", "test-id-5"} {
+			err := newService.Delete(ctx, namespace, ID)
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+		}
+
+		create("system", "test-id-7", 1, []int{2})
+		create("user", "test-id-8", 1, []int{5})
+	}
+}
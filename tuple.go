@@ -0,0 +1,95 @@
+package rangepool
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/giantswarm/microerror"
+)
+
+// Dimension is one of the ranges a tuple is allocated from, see
+// Service.CreateTuple.
+type Dimension struct {
+	// Max is the max boundary of the range, inclusive.
+	Max int `json:"max"`
+	// Min is the min boundary of the range, inclusive.
+	Min int `json:"min"`
+	// Namespace is the namespace of the range.
+	Namespace string `json:"namespace"`
+}
+
+// CreateTuple allocates one item from every given dimension for the given ID,
+// e.g. a VLAN ID and a VNI, or a node port and a health check port. The items
+// are returned in the order of the dimensions. The items are reserved first,
+// see Service.Reserve, and only committed once every dimension could be
+// reserved. In case reserving or committing fails half way, the reserved and
+// committed items are released again. In case this rollback fails as well, an
+// error is returned which can be asserted using IsRollbackFailed and names the
+// items which could not be released.
+//
+// CreateTuple is NOT atomic. The tuple is not persisted as a whole, so other
+// callers may observe some of its items committed while others are still
+// reserved or already released again. In case the process stops half way,
+// reserved items are released once their reservations expire, see
+// Config.ReservationTimeout, but committed items stay allocated to the ID
+// until they are released, e.g. using Delete or ForceRelease.
+func (s *Service) CreateTuple(ctx context.Context, ID string, dimensions []Dimension) ([]int, error) {
+	ctx = withOperation(ctx, "CreateTuple", "", ID)
+
+	if len(dimensions) == 0 {
+		return nil, microerror.Maskf(invalidArgumentError, "dimensions must not be empty")
+	}
+
+	var reservations []Reservation
+	for _, d := range dimensions {
+		r, err := s.Reserve(ctx, d.Namespace, ID, 1, d.Min, d.Max)
+		if err != nil {
+			return nil, microerror.Mask(s.rollbackTuple(ctx, err, nil, reservations))
+		}
+
+		reservations = append(reservations, r)
+	}
+
+	var items []int
+	for i, r := range reservations {
+		err := s.Commit(ctx, r.Token)
+		if err != nil {
+			return nil, microerror.Mask(s.rollbackTuple(ctx, err, reservations[:i], reservations[i:]))
+		}
+
+		items = append(items, r.Items[0])
+	}
+
+	return items, nil
+}
+
+// rollbackTuple releases the items of the given committed reservations and
+// aborts the given pending ones after CreateTuple failed with the given error.
+// It returns the given error in case the rollback succeeds. Otherwise a
+// RollbackFailedError is returned, which wraps the given error.
+func (s *Service) rollbackTuple(ctx context.Context, cause error, committed, pending []Reservation) error {
+	var failures []error
+	leaked := map[string][]int{}
+
+	for _, r := range committed {
+		err := s.ForceRelease(ctx, r.Namespace, r.Items[0])
+		if err != nil {
+			s.logger.LogCtx(ctx, "level", "error", "message", fmt.Sprintf("failed releasing item %d of namespace '%s'", r.Items[0], r.Namespace), "stack", fmt.Sprintf("%#v", err))
+			failures = append(failures, err)
+			leaked[r.Namespace] = append(leaked[r.Namespace], r.Items[0])
+		}
+	}
+	for _, r := range pending {
+		err := s.Abort(ctx, r.Token)
+		if err != nil && !IsReservationNotFound(err) {
+			s.logger.LogCtx(ctx, "level", "error", "message", fmt.Sprintf("failed aborting reservation '%s'", r.Token), "stack", fmt.Sprintf("%#v", err))
+			failures = append(failures, err)
+		}
+	}
+
+	if len(failures) == 0 {
+		return cause
+	}
+
+	return &RollbackFailedError{Cause: cause, Failures: failures, Leaked: leaked}
+}
@@ -0,0 +1,154 @@
+package rangepool
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger/microloggertest"
+)
+
+func Test_Service_CreateTuple(t *testing.T) {
+	var newService *Service
+	{
+		newStorage, err := newMemoryStorage()
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		config := DefaultConfig()
+		config.Logger = microloggertest.New()
+		config.Storage = newStorage
+		newService, err = New(config)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+	}
+
+	ctx := context.TODO()
+
+	dimensions := []Dimension{
+		{Namespace: "vlan", Min: 10, Max: 20},
+		{Namespace: "vni", Min: 100, Max: 101},
+	}
+
+	// Tuples must be allocated from all dimensions.
+	for i, expected := range [][]int{{10, 100}, {11, 101}} {
+		ID := []string{"test-id-1", "test-id-2"}[i]
+
+		items, err := newService.CreateTuple(ctx, ID, dimensions)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		if !reflect.DeepEqual(items, expected) {
+			t.Fatal("expected", expected, "got", items)
+		}
+
+		for j, d := range dimensions {
+			items, err := newService.Search(ctx, d.Namespace, ID)
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+			if !reflect.DeepEqual(items, expected[j:j+1]) {
+				t.Fatal("expected", expected[j:j+1], "got", items)
+			}
+		}
+	}
+
+	// Tuples must not be allocated partially in case one dimension is full.
+	{
+		_, err := newService.CreateTuple(ctx, "test-id-3", dimensions)
+		if !IsCapacityReached(err) {
+			t.Fatal("expected", true, "got", false)
+		}
+
+		d, err := newService.Dump(ctx, "vlan")
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		expected := []int{10, 11}
+		if !reflect.DeepEqual(d.Used, expected) {
+			t.Fatal("expected", expected, "got", d.Used)
+		}
+	}
+
+	// Tuples must require dimensions.
+	{
+		_, err := newService.CreateTuple(ctx, "test-id-3", nil)
+		if !IsInvalidArgument(err) {
+			t.Fatal("expected", true, "got", false)
+		}
+	}
+}
+
+func Test_Service_CreateTuple_RollbackFailed(t *testing.T) {
+	var newStorage *testFailingDeleteStorage
+	var newService *Service
+	{
+		memoryStorage, err := newMemoryStorage()
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		newStorage = &testFailingDeleteStorage{Storage: memoryStorage}
+
+		config := DefaultConfig()
+		config.Logger = microloggertest.New()
+		config.Storage = newStorage
+		newService, err = New(config)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+	}
+
+	ctx := context.TODO()
+
+	dimensions := []Dimension{
+		{Namespace: "vlan", Min: 10, Max: 20},
+		{Namespace: "vni", Min: 100, Max: 101},
+	}
+
+	for _, ID := range []string{"test-id-1", "test-id-2"} {
+		_, err := newService.CreateTuple(ctx, ID, dimensions)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+	}
+
+	// Failing rollbacks must be returned, while the error causing the
+	// rollback can still be asserted.
+	{
+		newStorage.Fail = true
+
+		_, err := newService.CreateTuple(ctx, "test-id-3", dimensions)
+		if !IsRollbackFailed(err) {
+			t.Fatal("expected", true, "got", false)
+		}
+		if !IsCapacityReached(err) {
+			t.Fatal("expected", true, "got", false)
+		}
+
+		e, ok := AsRollbackFailed(err)
+		if !ok {
+			t.Fatal("expected", true, "got", false)
+		}
+		if len(e.Failures) != 1 {
+			t.Fatal("expected", 1, "got", len(e.Failures))
+		}
+	}
+}
+
+// testFailingDeleteStorage fails all deletions in case Fail is set.
+type testFailingDeleteStorage struct {
+	Storage
+
+	Fail bool
+}
+
+func (s *testFailingDeleteStorage) Delete(ctx context.Context, key string) error {
+	if s.Fail {
+		return microerror.Maskf(executionFailedError, "storage unavailable")
+	}
+
+	return s.Storage.Delete(ctx, key)
+}
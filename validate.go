@@ -0,0 +1,42 @@
+package rangepool
+
+import (
+	"strings"
+
+	"github.com/giantswarm/microerror"
+)
+
+// Validate checks the arguments of an allocation without touching the
+// storage, so callers can fail fast on bad configuration, e.g. at startup.
+// Namespace and ID must not be empty and must not contain slashes, since they
+// are part of storage keys. num must be positive and must not exceed the
+// capacity of the range. The range itself is checked like Create does, see
+// InvalidRangeError.
+func Validate(namespace, ID string, num, min, max int) error {
+	if namespace == "" {
+		return microerror.Maskf(invalidArgumentError, "namespace must not be empty")
+	}
+	if strings.Contains(namespace, "/") {
+		return microerror.Maskf(invalidArgumentError, "namespace '%s' must not contain slashes", namespace)
+	}
+	if ID == "" {
+		return microerror.Maskf(invalidArgumentError, "ID must not be empty")
+	}
+	if strings.Contains(ID, "/") {
+		return microerror.Maskf(invalidArgumentError, "ID '%s' must not contain slashes", ID)
+	}
+
+	err := validateBoundaries(min, max, latestItemException)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	if num <= 0 {
+		return microerror.Maskf(invalidArgumentError, "num must be greater than zero")
+	}
+	if num > max-min+1 {
+		return microerror.Maskf(invalidArgumentError, "num %d must not exceed the capacity %d of range %d-%d", num, max-min+1, min, max)
+	}
+
+	return nil
+}
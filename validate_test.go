@@ -0,0 +1,128 @@
+package rangepool
+
+import (
+	"context"
+	"testing"
+)
+
+func Test_Validate(t *testing.T) {
+	testCases := []struct {
+		Namespace    string
+		ID           string
+		Num          int
+		Min          int
+		Max          int
+		ErrorMatcher func(err error) bool
+	}{
+		// Case 1 ensures valid arguments are accepted.
+		{
+			Namespace:    "test-namespace",
+			ID:           "test-id",
+			Num:          3,
+			Min:          2,
+			Max:          4,
+			ErrorMatcher: nil,
+		},
+		// Case 2 ensures the namespace must not be empty.
+		{
+			Namespace:    "",
+			ID:           "test-id",
+			Num:          1,
+			Min:          2,
+			Max:          4,
+			ErrorMatcher: IsInvalidArgument,
+		},
+		// Case 3 ensures the namespace must not contain slashes.
+		{
+			Namespace:    "test/namespace",
+			ID:           "test-id",
+			Num:          1,
+			Min:          2,
+			Max:          4,
+			ErrorMatcher: IsInvalidArgument,
+		},
+		// Case 4 ensures the ID must not be empty.
+		{
+			Namespace:    "test-namespace",
+			ID:           "",
+			Num:          1,
+			Min:          2,
+			Max:          4,
+			ErrorMatcher: IsInvalidArgument,
+		},
+		// Case 5 ensures the ID must not contain slashes.
+		{
+			Namespace:    "test-namespace",
+			ID:           "test/id",
+			Num:          1,
+			Min:          2,
+			Max:          4,
+			ErrorMatcher: IsInvalidArgument,
+		},
+		// Case 6 ensures num must be positive.
+		{
+			Namespace:    "test-namespace",
+			ID:           "test-id",
+			Num:          0,
+			Min:          2,
+			Max:          4,
+			ErrorMatcher: IsInvalidArgument,
+		},
+		// Case 7 ensures num must not exceed the capacity of the range.
+		{
+			Namespace:    "test-namespace",
+			ID:           "test-id",
+			Num:          4,
+			Min:          2,
+			Max:          4,
+			ErrorMatcher: IsInvalidArgument,
+		},
+		// Case 8 ensures invalid ranges are rejected.
+		{
+			Namespace:    "test-namespace",
+			ID:           "test-id",
+			Num:          1,
+			Min:          4,
+			Max:          2,
+			ErrorMatcher: IsInvalidRange,
+		},
+	}
+
+	for i, tc := range testCases {
+		err := Validate(tc.Namespace, tc.ID, tc.Num, tc.Min, tc.Max)
+
+		if err != nil && tc.ErrorMatcher == nil {
+			t.Fatal("case", i+1, "expected", nil, "got", err)
+		}
+		if tc.ErrorMatcher != nil && !tc.ErrorMatcher(err) {
+			t.Fatal("case", i+1, "expected", true, "got", false)
+		}
+	}
+}
+
+func Test_Service_Create_Num(t *testing.T) {
+	newStorage, err := newMemoryStorage()
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+	newService, err := NewWithOptions(newStorage)
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+
+	// Create must reject the num rejected by Validate with the same error.
+	for _, num := range []int{0, -3} {
+		err := Validate("test-namespace", "test-id", num, 2, 4)
+		if !IsInvalidArgument(err) {
+			t.Fatal("expected", true, "got", false)
+		}
+
+		items, err := newService.Create(context.Background(), "test-namespace", "test-id", num, 2, 4)
+		if !IsInvalidArgument(err) {
+			t.Fatal("expected", true, "got", false)
+		}
+		if len(items) != 0 {
+			t.Fatal("expected", 0, "got", len(items))
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package rangepool
+
+import (
+	"context"
+	"sort"
+
+	"github.com/giantswarm/microerror"
+)
+
+// VerifyReport describes the invariants of a namespace violated at the time of
+// Service.Verify. Its JSON encoding is meant to be consumed by monitoring
+// jobs.
+type VerifyReport struct {
+	// Latest is the latest item allocated within the namespace. It is -1 in
+	// case no item has ever been allocated.
+	Latest int `json:"latest"`
+	// LatestOutOfRange is true in case Latest is not within the range.
+	LatestOutOfRange bool `json:"latestOutOfRange"`
+	// Max is the upper boundary of the verified range.
+	Max int `json:"max"`
+	// Min is the lower boundary of the verified range.
+	Min int `json:"min"`
+	// Namespace is the namespace the report belongs to.
+	Namespace string `json:"namespace"`
+	// OutOfRange are the items used or owned within the namespace which are
+	// not within the range, in ascending order.
+	OutOfRange []int `json:"outOfRange"`
+	// SharedItems are the items owned by more than one ID, in ascending order.
+	SharedItems []SharedItem `json:"sharedItems"`
+	// Valid is true in case no invariant is violated.
+	Valid bool `json:"valid"`
+}
+
+// SharedItem is an item owned by more than one ID, see VerifyReport.
+type SharedItem struct {
+	// IDs are the IDs owning the item, in ascending order.
+	IDs []string `json:"ids"`
+	// Item is the item owned by the IDs.
+	Item int `json:"item"`
+}
+
+// Verify checks the invariants of the given namespace against the range
+// defined by min and max, both inclusive. Every item must be within the range,
+// no item must be owned by more than one ID and the latest item must be within
+// the range. Violations are reported, not returned as error. Verify does not
+// modify the namespace, see Service.GC and Service.Compact for repairing it.
+func (s *Service) Verify(ctx context.Context, namespace string, min, max int) (VerifyReport, error) {
+	err := validateBoundaries(min, max, latestItemException)
+	if err != nil {
+		return VerifyReport{}, microerror.Mask(err)
+	}
+
+	d, err := s.Dump(ctx, namespace)
+	if err != nil {
+		return VerifyReport{}, microerror.Mask(err)
+	}
+
+	owners := map[int][]string{}
+	for ID, items := range d.IDs {
+		for _, item := range items {
+			owners[item] = append(owners[item], ID)
+		}
+	}
+
+	r := VerifyReport{
+		Latest:           d.Latest,
+		LatestOutOfRange: d.Latest != latestItemException && (d.Latest < min || d.Latest > max),
+		Max:              max,
+		Min:              min,
+		Namespace:        namespace,
+		OutOfRange:       []int{},
+		SharedItems:      []SharedItem{},
+	}
+
+	outOfRange := map[int]struct{}{}
+	for _, item := range d.Used {
+		if item < min || item > max {
+			outOfRange[item] = struct{}{}
+		}
+	}
+	for item, IDs := range owners {
+		if item < min || item > max {
+			outOfRange[item] = struct{}{}
+		}
+		if len(IDs) > 1 {
+			sort.Strings(IDs)
+			r.SharedItems = append(r.SharedItems, SharedItem{IDs: IDs, Item: item})
+		}
+	}
+	for item := range outOfRange {
+		r.OutOfRange = append(r.OutOfRange, item)
+	}
+	sort.Ints(r.OutOfRange)
+	sort.Slice(r.SharedItems, func(i, j int) bool {
+		return r.SharedItems[i].Item < r.SharedItems[j].Item
+	})
+
+	r.Valid = !r.LatestOutOfRange && len(r.OutOfRange) == 0 && len(r.SharedItems) == 0
+
+	return r, nil
+}
@@ -0,0 +1,102 @@
+package rangepool
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/giantswarm/micrologger/microloggertest"
+)
+
+func Test_Service_Verify(t *testing.T) {
+	var err error
+	var newService *Service
+	{
+		newStorage, err := newMemoryStorage()
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		config := DefaultConfig()
+		config.Logger = microloggertest.New()
+		config.Storage = newStorage
+		newService, err = New(config)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+	}
+
+	ctx := context.TODO()
+
+	_, err = newService.Create(ctx, namespace, "test-id-1", 2, 2, 10)
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+	_, err = newService.Create(ctx, namespace, "test-id-2", 1, 2, 10)
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+
+	// A consistent namespace must be valid.
+	{
+		r, err := newService.Verify(ctx, namespace, 2, 10)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		if !r.Valid {
+			t.Fatal("expected", true, "got", r.Valid)
+		}
+	}
+
+	// Shrinking the range must report the items and the latest item out of
+	// range.
+	{
+		r, err := newService.Verify(ctx, namespace, 2, 3)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		b, err := json.Marshal(r)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		expected := `{"latest":4,"latestOutOfRange":true,"max":3,"min":2,"namespace":"test-namespace","outOfRange":[4],"sharedItems":[],"valid":false}`
+		if string(b) != expected {
+			t.Fatal("expected", expected, "got", string(b))
+		}
+	}
+
+	// Items owned by multiple IDs must be reported.
+	{
+		err = newService.storage.Create(ctx, fmt.Sprintf(IDKeyFormat, namespace, "test-id-3", "3"), "3")
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		r, err := newService.Verify(ctx, namespace, 2, 10)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		b, err := json.Marshal(r.SharedItems)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		expected := `[{"ids":["test-id-1","test-id-3"],"item":3}]`
+		if string(b) != expected {
+			t.Fatal("expected", expected, "got", string(b))
+		}
+		if r.Valid {
+			t.Fatal("expected", false, "got", r.Valid)
+		}
+	}
+
+	// Invalid ranges must be rejected.
+	{
+		_, err := newService.Verify(ctx, namespace, 10, 2)
+		if !IsInvalidRange(err) {
+			t.Fatal("expected", true, "got", false)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package rangepool
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/giantswarm/microerror"
+)
+
+// waiterTimeoutIntervals is the number of intervals configured using
+// Config.WatchInterval after which waiters which did not refresh their entry
+// are considered abandoned, e.g. because their process crashed, and are
+// removed from the queue.
+const waiterTimeoutIntervals = 3
+
+// waiter describes a caller of Service.CreateWait queued for capacity.
+type waiter struct {
+	ExpiresAt time.Time `json:"expiresAt"`
+	ID        string    `json:"id"`
+	Num       int       `json:"num"`
+}
+
+// CreateWait allocates num items in between min and max, both inclusive, like
+// Create, but waits for capacity instead of failing in case the range is
+// exhausted. Waiting callers are queued within the storage, so that freed
+// items are granted in the order the callers started waiting, even across
+// processes. Only the caller at the head of the queue allocates, so large
+// requests are not starved by smaller ones retrying more often. Allocations
+// using Create bypass the queue. The queue is inspected in the interval
+// configured using Config.WatchInterval. Waiters which do not refresh their
+// entry for three intervals are removed from the queue. CreateWait returns
+// once the items are allocated, the given context is done or the Service is
+// closed, see Service.Close.
+func (s *Service) CreateWait(ctx context.Context, namespace, ID string, num, min, max int) ([]int, error) {
+	ctx = withOperation(ctx, "CreateWait", namespace, ID)
+
+	err := s.checkReadOnly()
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	ctx, done, err := s.goContext(ctx)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+	defer done()
+
+	var key string
+	{
+		b := make([]byte, 8)
+		_, err = rand.Read(b)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		// The key starts with the zero padded time the caller started waiting
+		// at, so that the queue is ordered by the keys.
+		key = fmt.Sprintf("%020d-%s", s.clock.Now().UnixNano(), hex.EncodeToString(b))
+	}
+
+	w := waiter{
+		ID:  ID,
+		Num: num,
+	}
+
+	defer func() {
+		// The given context might be done already, so the entry is removed
+		// using a context of its own, which is used for logging as well.
+		ctx := withOperation(context.Background(), "CreateWait", namespace, ID)
+		err := s.storage.Delete(ctx, s.key(WaiterKeyFormat, namespace, key))
+		if err != nil {
+			s.logger.LogCtx(ctx, "level", "error", "message", fmt.Sprintf("failed removing waiter '%s' of namespace '%s'", key, namespace), "stack", fmt.Sprintf("%#v", err))
+		}
+	}()
+
+	notify := s.poll(ctx)
+
+	for {
+		// The entry is refreshed right before the queue is inspected, so that
+		// it cannot expire before Create is called. In case other callers
+		// removed it in the meantime, e.g. because this caller was blocked
+		// for too long, it is inserted again. It keeps its position within
+		// the queue, since the key does not change.
+		err = s.refreshWaiter(ctx, namespace, key, w)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		head, err := s.searchWaiterHead(ctx, namespace)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		if head == key {
+			items, err := s.Create(ctx, namespace, ID, num, min, max)
+			if err == nil {
+				return items, nil
+			} else if !IsCapacityReached(err) {
+				return nil, microerror.Mask(err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, microerror.Mask(ctx.Err())
+		case <-notify:
+		}
+	}
+}
+
+// refreshWaiter persists the given waiter under the given key and extends its
+// expiry, see waiterTimeoutIntervals.
+func (s *Service) refreshWaiter(ctx context.Context, namespace, key string, w waiter) error {
+	w.ExpiresAt = s.clock.Now().UTC().Add(waiterTimeoutIntervals * s.watchInterval)
+
+	b, err := json.Marshal(w)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = s.storage.Create(ctx, s.key(WaiterKeyFormat, namespace, key), string(b))
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// searchWaiterHead returns the key of the waiter at the head of the queue of
+// the given namespace. Expired waiters are removed on the way. The returned
+// key is empty in case there are no waiters.
+func (s *Service) searchWaiterHead(ctx context.Context, namespace string) (string, error) {
+	waiters := map[string]waiter{}
+	var keys []string
+
+	err := walk(ctx, s.storage, s.key(WaiterListKeyFormat, namespace), func(kv KV) error {
+		var w waiter
+		err := json.Unmarshal([]byte(kv.Value), &w)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
+		waiters[kv.Key] = w
+		keys = append(keys, kv.Key)
+
+		return nil
+	})
+	if err != nil {
+		return "", microerror.Mask(err)
+	}
+
+	sort.Strings(keys)
+
+	now := s.clock.Now()
+	for _, k := range keys {
+		if now.Before(waiters[k].ExpiresAt) {
+			return k, nil
+		}
+
+		err := s.storage.Delete(ctx, s.key(WaiterKeyFormat, namespace, k))
+		if err != nil {
+			return "", microerror.Mask(err)
+		}
+
+		s.logger.LogCtx(ctx, "level", "info", "message", fmt.Sprintf("removed expired waiter '%s' of ID '%s' from namespace '%s'", k, waiters[k].ID, namespace))
+	}
+
+	return "", nil
+}
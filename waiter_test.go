@@ -0,0 +1,203 @@
+package rangepool
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/giantswarm/microerror"
+)
+
+func Test_Service_CreateWait(t *testing.T) {
+	ctx := context.Background()
+
+	newStorage, err := newMemoryStorage()
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+	newService, err := NewWithOptions(newStorage, WithWatchInterval(time.Millisecond))
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+
+	_, err = newService.Create(ctx, namespace, "test-id-1", 2, 1, 2)
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+
+	type result struct {
+		err   error
+		items []int
+	}
+
+	// The large request starts waiting first, so it must be served first, even
+	// though the small request would fit earlier.
+	results := map[string]chan result{}
+	for i, num := range []int{2, 1} {
+		ID := fmt.Sprintf("test-id-%d", i+2)
+		results[ID] = make(chan result, 1)
+
+		go func(ID string, num int, results chan<- result) {
+			items, err := newService.CreateWait(ctx, namespace, ID, num, 1, 2)
+			results <- result{err: err, items: items}
+		}(ID, num, results[ID])
+
+		waitForWaiters(t, newStorage, i+1)
+	}
+
+	err = newService.Delete(ctx, namespace, "test-id-1")
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+
+	r := <-results["test-id-2"]
+	if r.err != nil {
+		t.Fatal("expected", nil, "got", r.err)
+	}
+	if !reflect.DeepEqual(r.items, []int{1, 2}) {
+		t.Fatal("expected", []int{1, 2}, "got", r.items)
+	}
+
+	select {
+	case r := <-results["test-id-3"]:
+		t.Fatal("expected", "waiting", "got", r)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	err = newService.Delete(ctx, namespace, "test-id-2")
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+
+	r = <-results["test-id-3"]
+	if r.err != nil {
+		t.Fatal("expected", nil, "got", r.err)
+	}
+	if len(r.items) != 1 {
+		t.Fatal("expected", 1, "got", len(r.items))
+	}
+
+	waitForWaiters(t, newStorage, 0)
+}
+
+func Test_Service_CreateWait_Expired(t *testing.T) {
+	ctx := context.Background()
+
+	newStorage, err := newMemoryStorage()
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+	newService, err := NewWithOptions(newStorage, WithWatchInterval(time.Millisecond))
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+
+	// A waiter which crashed must not block the queue forever.
+	err = newStorage.Create(ctx, fmt.Sprintf(WaiterKeyFormat, namespace, "00000000000000000001-crashed"), `{"expiresAt":"2000-01-01T00:00:00Z","id":"test-id-1","num":1}`)
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+
+	items, err := newService.CreateWait(ctx, namespace, "test-id-2", 1, 1, 2)
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+	if !reflect.DeepEqual(items, []int{1}) {
+		t.Fatal("expected", []int{1}, "got", items)
+	}
+
+	waitForWaiters(t, newStorage, 0)
+}
+
+func Test_Service_CreateWait_Canceled(t *testing.T) {
+	newStorage, err := newMemoryStorage()
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+	newService, err := NewWithOptions(newStorage, WithWatchInterval(time.Millisecond))
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+
+	_, err = newService.Create(context.Background(), namespace, "test-id-1", 2, 1, 2)
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	_, err = newService.CreateWait(ctx, namespace, "test-id-2", 1, 1, 2)
+	if microerror.Cause(err) != context.DeadlineExceeded {
+		t.Fatal("expected", context.DeadlineExceeded, "got", err)
+	}
+
+	waitForWaiters(t, newStorage, 0)
+}
+
+func Test_Service_CreateWait_Missing(t *testing.T) {
+	s, err := newMemoryStorage()
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+	newStorage := &testHiddenWaitersStorage{Storage: s}
+	newService, err := NewWithOptions(newStorage, WithWatchInterval(time.Millisecond))
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	// A caller which cannot find its own entry within the queue must not
+	// consider the queue empty and allocate, since it is not known whether it
+	// is at the head of the queue.
+	_, err = newService.CreateWait(ctx, namespace, "test-id-1", 1, 1, 2)
+	if microerror.Cause(err) != context.DeadlineExceeded {
+		t.Fatal("expected", context.DeadlineExceeded, "got", err)
+	}
+
+	items, err := newService.Create(context.Background(), namespace, "test-id-2", 2, 1, 2)
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+	if !reflect.DeepEqual(items, []int{1, 2}) {
+		t.Fatal("expected", []int{1, 2}, "got", items)
+	}
+}
+
+// waitForWaiters waits until the given number of waiters is queued within the
+// test namespace.
+func waitForWaiters(t *testing.T, storage Storage, num int) {
+	t.Helper()
+
+	for i := 0; i < 100; i++ {
+		kvs, err := storage.List(context.Background(), fmt.Sprintf(WaiterListKeyFormat, namespace))
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+		if len(kvs) == num {
+			return
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Fatal("expected", num, "waiters", "got", "timeout")
+}
+
+// testHiddenWaitersStorage hides the waiters queued within the test namespace
+// of the given Storage from listings.
+type testHiddenWaitersStorage struct {
+	Storage
+}
+
+func (s *testHiddenWaitersStorage) List(ctx context.Context, key string) ([]KV, error) {
+	if key == fmt.Sprintf(WaiterListKeyFormat, namespace) {
+		return nil, nil
+	}
+
+	return s.Storage.List(ctx, key)
+}
@@ -0,0 +1,52 @@
+package rangepool
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/giantswarm/microerror"
+)
+
+// Warm preloads the state of the given namespaces into the memory of the
+// Service, e.g. at process start, so that the first allocation after a deploy
+// does not pay for scanning the storage. The schema of every namespace is
+// verified and migrated if needed, see SchemaKeyFormat, and the used items are
+// cached in case Config.CacheTTL is set. In case no namespace is given, all
+// namespaces of the storage are warmed up. The latest items are not
+// preloaded, since the Service does not cache them. Caching them would let
+// processes sharing the storage continue from outdated positions.
+func (s *Service) Warm(ctx context.Context, namespaces ...string) error {
+	ctx = withOperation(ctx, "Warm", "", "")
+
+	if len(namespaces) == 0 {
+		var err error
+		namespaces, err = s.searchNamespaces(ctx)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	for _, namespace := range namespaces {
+		p, err := s.withPolicy(ctx, namespace)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
+		err = p.ensureSchema(ctx, namespace)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
+		// Namespaces persisted as bitmap are not cached, see Config.CacheTTL.
+		if p.cache != nil && !p.bitmap {
+			_, err = p.searchUsed(NewConsistencyContext(ctx, ConsistencyLinearizable), namespace)
+			if err != nil {
+				return microerror.Mask(err)
+			}
+		}
+	}
+
+	s.logger.LogCtx(ctx, "level", "debug", "message", fmt.Sprintf("warmed up namespaces %v", namespaces))
+
+	return nil
+}
@@ -0,0 +1,85 @@
+package rangepool
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/giantswarm/micrologger/microloggertest"
+)
+
+func Test_Service_Warm(t *testing.T) {
+	newStorage, err := newMemoryStorage()
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+
+	var writer *Service
+	var reader *Service
+	{
+		config := DefaultConfig()
+		config.Logger = microloggertest.New()
+		config.Storage = newStorage
+		writer, err = New(config)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		config.CacheTTL = time.Hour
+		reader, err = New(config)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+	}
+
+	ctx := context.TODO()
+
+	_, err = writer.Create(ctx, "ns-1", "test-id", 2, 1, 5)
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+	_, err = writer.Create(ctx, "ns-2", "test-id", 1, 1, 5)
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+
+	// Warming up a single namespace must only cache that namespace.
+	{
+		err = reader.Warm(ctx, "ns-2")
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		if !reader.schemas.Has("ns-2") {
+			t.Fatal("expected", true, "got", false)
+		}
+		_, ok := reader.cache.Get("ns-1")
+		if ok {
+			t.Fatal("expected", false, "got", true)
+		}
+	}
+
+	// Warming up without namespaces must cache all namespaces.
+	{
+		err = reader.Warm(ctx)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		for ns, expected := range map[string][]int{"ns-1": {1, 2}, "ns-2": {1}} {
+			if !reader.schemas.Has(ns) {
+				t.Fatal("expected", true, "got", false)
+			}
+			used, ok := reader.cache.Get(ns)
+			if !ok {
+				t.Fatal("expected", true, "got", false)
+			}
+			sort.Ints(used)
+			if !reflect.DeepEqual(used, expected) {
+				t.Fatal("expected", expected, "got", used)
+			}
+		}
+	}
+}
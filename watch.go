@@ -0,0 +1,243 @@
+package rangepool
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/giantswarm/microerror"
+)
+
+const (
+	// EventTypeAllocated is the type of events emitted for items being
+	// allocated to an ID.
+	EventTypeAllocated = "allocated"
+	// EventTypeReleased is the type of events emitted for items being released
+	// by an ID.
+	EventTypeReleased = "released"
+)
+
+// Event describes a change of the items of an ID, see Service.Watch.
+type Event struct {
+	ID        string
+	Items     []int
+	Namespace string
+	Type      string
+}
+
+// Watch emits events for items being allocated and released within the given
+// namespace. Only changes happening after Watch was called are emitted. In
+// case the storage implements WatchStorage, the namespace is inspected on
+// every notification of the storage, otherwise it is polled in the interval
+// configured using Config.WatchInterval. The returned channel is closed once
+// the given context is done or the Service is closed, see Service.Close.
+func (s *Service) Watch(ctx context.Context, namespace string) (<-chan Event, error) {
+	ctx = withOperation(ctx, "Watch", namespace, "")
+
+	ctx, done, err := s.goContext(ctx)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	owned, err := s.searchOwnedByID(ctx, namespace)
+	if err != nil {
+		done()
+		return nil, microerror.Mask(err)
+	}
+
+	var notify <-chan struct{}
+	{
+		w, ok := unwrapStorage(s.storage).(WatchStorage)
+		if ok {
+			notify, err = w.Watch(ctx, s.key(NamespaceKeyFormat, namespace))
+			if err != nil {
+				done()
+				return nil, microerror.Mask(err)
+			}
+		} else {
+			notify = s.poll(ctx)
+		}
+	}
+
+	events := make(chan Event)
+
+	go func() {
+		defer done()
+		defer close(events)
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case _, ok := <-notify:
+				if !ok {
+					return
+				}
+			}
+
+			current, err := s.searchOwnedByID(ctx, namespace)
+			if err != nil {
+				s.logger.LogCtx(ctx, "level", "error", "message", fmt.Sprintf("failed watching namespace '%s'", namespace), "stack", fmt.Sprintf("%#v", err))
+				continue
+			}
+
+			for _, e := range diffOwned(namespace, owned, current) {
+				select {
+				case <-ctx.Done():
+					return
+				case events <- e:
+				}
+			}
+
+			owned = current
+		}
+	}()
+
+	return events, nil
+}
+
+// NotifyFree signals items being released within the given namespace, so that
+// callers waiting for capacity can retry their allocations right away instead
+// of polling. Releases are detected like the events of Watch. Releases
+// happening while the previous signal was not received yet are coalesced into
+// a single signal. The returned channel is closed once the given context is
+// done or the Service is closed, see Service.Close.
+func (s *Service) NotifyFree(ctx context.Context, namespace string) (<-chan struct{}, error) {
+	ctx = withOperation(ctx, "NotifyFree", namespace, "")
+
+	events, err := s.Watch(ctx, namespace)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	free := make(chan struct{}, 1)
+
+	go func() {
+		defer close(free)
+
+		for e := range events {
+			if e.Type != EventTypeReleased {
+				continue
+			}
+
+			select {
+			case free <- struct{}{}:
+			default:
+			}
+		}
+	}()
+
+	return free, nil
+}
+
+// poll returns a channel receiving a value in the interval configured using
+// Config.WatchInterval. The channel is closed once the given context is done.
+func (s *Service) poll(ctx context.Context) <-chan struct{} {
+	notify := make(chan struct{})
+
+	go func() {
+		defer close(notify)
+
+		ticker := time.NewTicker(s.watchInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				select {
+				case <-ctx.Done():
+					return
+				case notify <- struct{}{}:
+				}
+			}
+		}
+	}()
+
+	return notify
+}
+
+// searchOwnedByID fetches the items of all IDs of the given namespace, grouped
+// by ID.
+func (s *Service) searchOwnedByID(ctx context.Context, namespace string) (map[string][]int, error) {
+	owned := map[string][]int{}
+
+	err := walk(ctx, s.storage, s.key(IDPrefixKeyFormat, namespace), func(kv KV) error {
+		// The keys are relative to the ID prefix, e.g. ${id1}/item/${item1}.
+		i := strings.LastIndex(kv.Key, "/item/")
+		if i == -1 {
+			return nil
+		}
+
+		item, err := strconv.Atoi(kv.Value)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+		owned[kv.Key[:i]] = append(owned[kv.Key[:i]], item)
+
+		return nil
+	})
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	for _, items := range owned {
+		sort.Ints(items)
+	}
+
+	return owned, nil
+}
+
+// diffOwned returns the events describing the changes between the given items
+// grouped by ID. Events are ordered by ID, releases before allocations.
+func diffOwned(namespace string, previous, current map[string][]int) []Event {
+	var IDs []string
+	for ID := range previous {
+		IDs = append(IDs, ID)
+	}
+	for ID := range current {
+		_, ok := previous[ID]
+		if !ok {
+			IDs = append(IDs, ID)
+		}
+	}
+	sort.Strings(IDs)
+
+	var events []Event
+	for _, ID := range IDs {
+		released := subtractInts(previous[ID], current[ID])
+		if len(released) != 0 {
+			events = append(events, Event{ID: ID, Items: released, Namespace: namespace, Type: EventTypeReleased})
+		}
+
+		allocated := subtractInts(current[ID], previous[ID])
+		if len(allocated) != 0 {
+			events = append(events, Event{ID: ID, Items: allocated, Namespace: namespace, Type: EventTypeAllocated})
+		}
+	}
+
+	return events
+}
+
+// subtractInts returns the items of a which are not part of b, keeping the
+// order of a.
+func subtractInts(a, b []int) []int {
+	set := map[int]struct{}{}
+	for _, i := range b {
+		set[i] = struct{}{}
+	}
+
+	var result []int
+	for _, i := range a {
+		_, ok := set[i]
+		if !ok {
+			result = append(result, i)
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,186 @@
+package rangepool
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/giantswarm/micrologger/microloggertest"
+)
+
+func Test_Service_Watch(t *testing.T) {
+	var err error
+	var newService *Service
+	{
+		newStorage, err := newMemoryStorage()
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		config := DefaultConfig()
+		config.Logger = microloggertest.New()
+		config.Storage = newStorage
+		config.WatchInterval = time.Millisecond
+		newService, err = New(config)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events, err := newService.Watch(ctx, namespace)
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+
+	next := func() Event {
+		select {
+		case e := <-events:
+			return e
+		case <-time.After(5 * time.Second):
+			t.Fatal("expected", "event", "got", "timeout")
+		}
+		return Event{}
+	}
+
+	// Allocations must be emitted.
+	{
+		_, err := newService.Create(ctx, namespace, "test-id", 2, 2, 10)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		e := next()
+		expected := Event{ID: "test-id", Items: []int{2, 3}, Namespace: namespace, Type: EventTypeAllocated}
+		if !reflect.DeepEqual(e, expected) {
+			t.Fatal("expected", expected, "got", e)
+		}
+	}
+
+	// Releases must be emitted.
+	{
+		err := newService.Delete(ctx, namespace, "test-id")
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		e := next()
+		expected := Event{ID: "test-id", Items: []int{2, 3}, Namespace: namespace, Type: EventTypeReleased}
+		if !reflect.DeepEqual(e, expected) {
+			t.Fatal("expected", expected, "got", e)
+		}
+	}
+}
+
+func Test_Service_NotifyFree(t *testing.T) {
+	newStorage, err := newMemoryStorage()
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+	newService, err := NewWithOptions(newStorage, WithWatchInterval(time.Millisecond))
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	free, err := newService.NotifyFree(ctx, namespace)
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+
+	// Allocations must not be signalled.
+	{
+		_, err := newService.Create(ctx, namespace, "test-id", 2, 2, 10)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		select {
+		case <-free:
+			t.Fatal("expected", "no signal", "got", "signal")
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+
+	// Releases must be signalled.
+	{
+		err := newService.Delete(ctx, namespace, "test-id")
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+
+		select {
+		case <-free:
+		case <-time.After(5 * time.Second):
+			t.Fatal("expected", "signal", "got", "timeout")
+		}
+	}
+
+	// The channel must be closed once the context is done.
+	{
+		cancel()
+
+		select {
+		case _, ok := <-free:
+			if ok {
+				t.Fatal("expected", false, "got", ok)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("expected", "closed channel", "got", "timeout")
+		}
+	}
+}
+
+func Test_diffOwned(t *testing.T) {
+	testCases := []struct {
+		Previous       map[string][]int
+		Current        map[string][]int
+		ExpectedEvents []Event
+	}{
+		// Case 1 ensures no events are emitted in case nothing changed.
+		{
+			Previous:       map[string][]int{"id-1": {2, 3}},
+			Current:        map[string][]int{"id-1": {2, 3}},
+			ExpectedEvents: nil,
+		},
+		// Case 2 ensures allocations of new IDs are emitted.
+		{
+			Previous: map[string][]int{},
+			Current:  map[string][]int{"id-1": {2, 3}},
+			ExpectedEvents: []Event{
+				{ID: "id-1", Items: []int{2, 3}, Namespace: "ns", Type: EventTypeAllocated},
+			},
+		},
+		// Case 3 ensures releases of removed IDs are emitted.
+		{
+			Previous: map[string][]int{"id-1": {2, 3}},
+			Current:  map[string][]int{},
+			ExpectedEvents: []Event{
+				{ID: "id-1", Items: []int{2, 3}, Namespace: "ns", Type: EventTypeReleased},
+			},
+		},
+		// Case 4 ensures releases are emitted before allocations and events are
+		// ordered by ID.
+		{
+			Previous: map[string][]int{"id-1": {2}, "id-2": {3}},
+			Current:  map[string][]int{"id-1": {4}, "id-3": {3}},
+			ExpectedEvents: []Event{
+				{ID: "id-1", Items: []int{2}, Namespace: "ns", Type: EventTypeReleased},
+				{ID: "id-1", Items: []int{4}, Namespace: "ns", Type: EventTypeAllocated},
+				{ID: "id-2", Items: []int{3}, Namespace: "ns", Type: EventTypeReleased},
+				{ID: "id-3", Items: []int{3}, Namespace: "ns", Type: EventTypeAllocated},
+			},
+		},
+	}
+
+	for i, tc := range testCases {
+		events := diffOwned("ns", tc.Previous, tc.Current)
+		if !reflect.DeepEqual(events, tc.ExpectedEvents) {
+			t.Fatal("case", i+1, "expected", tc.ExpectedEvents, "got", events)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package webhook
+
+import (
+	"github.com/giantswarm/microerror"
+)
+
+var closedError = &microerror.Error{
+	Kind: "closedError",
+}
+
+// IsClosed asserts closedError.
+func IsClosed(err error) bool {
+	return microerror.Cause(err) == closedError
+}
+
+var deliveryFailedError = &microerror.Error{
+	Kind: "deliveryFailedError",
+}
+
+// IsDeliveryFailed asserts deliveryFailedError.
+func IsDeliveryFailed(err error) bool {
+	return microerror.Cause(err) == deliveryFailedError
+}
+
+var invalidConfigError = &microerror.Error{
+	Kind: "invalidConfigError",
+}
+
+// IsInvalidConfig asserts invalidConfigError.
+func IsInvalidConfig(err error) bool {
+	return microerror.Cause(err) == invalidConfigError
+}
+
+var queueFullError = &microerror.Error{
+	Kind: "queueFullError",
+}
+
+// IsQueueFull asserts queueFullError.
+func IsQueueFull(err error) bool {
+	return microerror.Cause(err) == queueFullError
+}
@@ -0,0 +1,301 @@
+// Package webhook implements a rangepool.Notifier POSTing a JSON payload to
+// the configured URLs whenever items are allocated or released, so inventory
+// systems outside the cluster stay in sync. Payloads are delivered in order by
+// a background worker and retried with exponential backoff. In case a secret
+// is configured, every request carries the HMAC-SHA256 signature of its body
+// in the SignatureHeader.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+
+	"github.com/giantswarm/rangepool"
+)
+
+const (
+	// EventHeader is the HTTP header carrying the type of the event, see
+	// rangepool.EventTypeAllocated and rangepool.EventTypeReleased.
+	EventHeader = "X-Rangepool-Event"
+	// SignatureHeader is the HTTP header carrying the signature of the request
+	// body in case Config.Secret is configured. The signature is the hex
+	// encoded HMAC-SHA256 of the body prefixed with "sha256=".
+	SignatureHeader = "X-Rangepool-Signature"
+)
+
+// Payload is the JSON body POSTed to the configured URLs.
+type Payload struct {
+	Caller    string    `json:"caller,omitempty"`
+	ID        string    `json:"id"`
+	Items     []int     `json:"items"`
+	Namespace string    `json:"namespace"`
+	Time      time.Time `json:"time"`
+	Type      string    `json:"type"`
+}
+
+// Config represents the configuration used to create a new webhook notifier.
+type Config struct {
+	// Dependencies.
+
+	// HTTPClient is the client used to deliver payloads. It defaults to a
+	// client with a timeout of 10 seconds.
+	HTTPClient *http.Client
+	Logger     micrologger.Logger
+
+	// Settings.
+
+	// QueueSize is the number of payloads buffered for delivery. Notify fails
+	// once the queue is full, so that allocations never block on slow
+	// receivers.
+	QueueSize int
+	// RetryAttempts is the number of attempts made to deliver a payload to a
+	// URL before it is dropped.
+	RetryAttempts int
+	// RetryBackoff is the delay before the first retry of a failed delivery.
+	// It doubles with every further retry.
+	RetryBackoff time.Duration
+	// Secret is the key used to sign request bodies, see SignatureHeader.
+	// Requests are not signed in case it is empty.
+	Secret string
+	// URLs are the URLs every payload is POSTed to.
+	URLs []string
+}
+
+// DefaultConfig provides a default configuration to create a new webhook
+// notifier by best effort.
+func DefaultConfig() Config {
+	return Config{
+		// Dependencies.
+		HTTPClient: &http.Client{Timeout: 10 * time.Second},
+		Logger:     nil,
+
+		// Settings.
+		QueueSize:     100,
+		RetryAttempts: 3,
+		RetryBackoff:  time.Second,
+		Secret:        "",
+		URLs:          nil,
+	}
+}
+
+// New creates a new configured webhook notifier and starts delivering
+// payloads in the background. Close must be called to deliver the queued
+// payloads and stop the background delivery.
+func New(config Config) (*Notifier, error) {
+	// Dependencies.
+	if config.HTTPClient == nil {
+		return nil, microerror.Maskf(invalidConfigError, "HTTP client must not be empty")
+	}
+	if config.Logger == nil {
+		return nil, microerror.Maskf(invalidConfigError, "logger must not be empty")
+	}
+
+	// Settings.
+	if config.QueueSize < 1 {
+		return nil, microerror.Maskf(invalidConfigError, "queue size must be at least 1")
+	}
+	if config.RetryAttempts < 1 {
+		return nil, microerror.Maskf(invalidConfigError, "retry attempts must be at least 1")
+	}
+	if config.RetryBackoff < 0 {
+		return nil, microerror.Maskf(invalidConfigError, "retry backoff must not be negative")
+	}
+	if len(config.URLs) == 0 {
+		return nil, microerror.Maskf(invalidConfigError, "URLs must not be empty")
+	}
+
+	n := &Notifier{
+		// Dependencies.
+		httpClient: config.HTTPClient,
+		logger:     config.Logger,
+
+		// Internals.
+		done:  make(chan struct{}),
+		queue: make(chan delivery, config.QueueSize),
+
+		// Settings.
+		retryAttempts: config.RetryAttempts,
+		retryBackoff:  config.RetryBackoff,
+		secret:        config.Secret,
+		urls:          append([]string{}, config.URLs...),
+	}
+
+	n.wait.Add(1)
+	go n.deliverLoop()
+
+	return n, nil
+}
+
+type Notifier struct {
+	// Dependencies.
+	httpClient *http.Client
+	logger     micrologger.Logger
+
+	// Internals.
+	closeOnce sync.Once
+	done      chan struct{}
+	queue     chan delivery
+	wait      sync.WaitGroup
+
+	// Settings.
+	retryAttempts int
+	retryBackoff  time.Duration
+	secret        string
+	urls          []string
+}
+
+var _ rangepool.Notifier = &Notifier{}
+
+// delivery is a payload queued for delivery.
+type delivery struct {
+	Body []byte
+	Type string
+}
+
+// Close stops the background delivery once the queued payloads have been
+// delivered, including their retries. Once Close is called, retries are made
+// right away instead of after their backoff, so that Close blocks for at most
+// RetryAttempts requests per queued payload and URL. Payloads notified
+// concurrently with Close may be dropped.
+func (n *Notifier) Close() error {
+	n.closeOnce.Do(func() {
+		close(n.done)
+	})
+	n.wait.Wait()
+
+	return nil
+}
+
+// Notify queues the payload of the given event for delivery. It fails in case
+// the queue is full or the notifier has been closed.
+func (n *Notifier) Notify(ctx context.Context, e rangepool.Event) error {
+	p := Payload{
+		ID:        e.ID,
+		Items:     e.Items,
+		Namespace: e.Namespace,
+		Time:      time.Now().UTC(),
+		Type:      e.Type,
+	}
+	p.Caller, _ = rangepool.CallerFromContext(ctx)
+
+	b, err := json.Marshal(p)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	select {
+	case <-n.done:
+		return microerror.Maskf(closedError, "notifier has been closed")
+	default:
+	}
+
+	select {
+	case n.queue <- delivery{Body: b, Type: e.Type}:
+	default:
+		return microerror.Maskf(queueFullError, "dropping %s event of ID '%s' in namespace '%s'", e.Type, e.ID, e.Namespace)
+	}
+
+	return nil
+}
+
+func (n *Notifier) deliverLoop() {
+	defer n.wait.Done()
+
+	for {
+		select {
+		case d := <-n.queue:
+			n.deliver(d)
+		case <-n.done:
+			for {
+				select {
+				case d := <-n.queue:
+					n.deliver(d)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// deliver POSTs the given delivery to all URLs, retrying failed requests with
+// exponential backoff.
+func (n *Notifier) deliver(d delivery) {
+	for _, u := range n.urls {
+		delay := n.retryBackoff
+
+		for i := 1; ; i++ {
+			err := n.post(u, d)
+			if err == nil {
+				break
+			}
+
+			if i >= n.retryAttempts {
+				n.logger.Log("level", "error", "message", fmt.Sprintf("failed delivering %s event to '%s' after %d attempts", d.Type, u, i), "stack", fmt.Sprintf("%#v", err))
+				break
+			}
+
+			n.logger.Log("level", "warning", "message", fmt.Sprintf("retrying delivery of %s event to '%s' in %s after attempt %d of %d failed", d.Type, u, delay, i, n.retryAttempts), "stack", fmt.Sprintf("%#v", err))
+
+			// Close must not block for the whole retry schedule, so the
+			// remaining attempts are made right away once it is called.
+			timer := time.NewTimer(delay)
+			select {
+			case <-timer.C:
+			case <-n.done:
+				timer.Stop()
+			}
+			delay *= 2
+		}
+	}
+}
+
+func (n *Notifier) post(u string, d delivery) error {
+	req, err := http.NewRequest(http.MethodPost, u, bytes.NewReader(d.Body))
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, d.Type)
+	if n.secret != "" {
+		req.Header.Set(SignatureHeader, Sign(n.secret, d.Body))
+	}
+
+	res, err := n.httpClient.Do(req)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	defer res.Body.Close()
+
+	// The body is drained so that the connection can be reused.
+	_, _ = io.Copy(ioutil.Discard, res.Body)
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return microerror.Maskf(deliveryFailedError, "unexpected status code %d", res.StatusCode)
+	}
+
+	return nil
+}
+
+// Sign returns the signature of the given body as carried by the
+// SignatureHeader. Receivers use it to verify requests, ideally comparing
+// signatures using hmac.Equal.
+func Sign(secret string, body []byte) string {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write(body)
+
+	return "sha256=" + hex.EncodeToString(h.Sum(nil))
+}
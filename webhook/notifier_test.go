@@ -0,0 +1,170 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/giantswarm/micrologger/microloggertest"
+
+	"github.com/giantswarm/rangepool"
+)
+
+func Test_Notifier(t *testing.T) {
+	var mutex sync.Mutex
+	var attempts int
+	var payloads []Payload
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		b, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Error("expected", nil, "got", err)
+		}
+		if r.Header.Get(SignatureHeader) != Sign("secret", b) {
+			t.Error("expected", Sign("secret", b), "got", r.Header.Get(SignatureHeader))
+		}
+
+		// The first attempt fails, so that the payload must be retried.
+		attempts++
+		if attempts == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		var p Payload
+		err = json.Unmarshal(b, &p)
+		if err != nil {
+			t.Error("expected", nil, "got", err)
+		}
+		payloads = append(payloads, p)
+	}))
+	defer server.Close()
+
+	var err error
+	var n *Notifier
+	{
+		c := DefaultConfig()
+		c.Logger = microloggertest.New()
+		c.RetryBackoff = time.Millisecond
+		c.Secret = "secret"
+		c.URLs = []string{server.URL}
+		n, err = New(c)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+	}
+
+	ctx := rangepool.NewCallerContext(context.TODO(), "test-caller")
+
+	events := []rangepool.Event{
+		{ID: "test-id", Items: []int{1, 2}, Namespace: "test-namespace", Type: rangepool.EventTypeAllocated},
+		{ID: "test-id", Items: []int{1, 2}, Namespace: "test-namespace", Type: rangepool.EventTypeReleased},
+	}
+	for _, e := range events {
+		err := n.Notify(ctx, e)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+	}
+
+	// Closing the notifier must deliver the queued payloads.
+	err = n.Close()
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if attempts != 3 {
+		t.Fatal("expected", 3, "got", attempts)
+	}
+	if len(payloads) != 2 {
+		t.Fatal("expected", 2, "got", len(payloads))
+	}
+	for i, p := range payloads {
+		if p.Caller != "test-caller" {
+			t.Fatal("case", i+1, "expected", "test-caller", "got", p.Caller)
+		}
+		if p.Type != events[i].Type {
+			t.Fatal("case", i+1, "expected", events[i].Type, "got", p.Type)
+		}
+		if !reflect.DeepEqual(p.Items, events[i].Items) {
+			t.Fatal("case", i+1, "expected", events[i].Items, "got", p.Items)
+		}
+	}
+
+	// Notifying after Close must fail.
+	err = n.Notify(ctx, events[0])
+	if !IsClosed(err) {
+		t.Fatal("expected", true, "got", false)
+	}
+}
+
+func Test_Notifier_Close(t *testing.T) {
+	var mutex sync.Mutex
+	var attempts int
+	attempted := make(chan struct{}, 10)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		attempts++
+		mutex.Unlock()
+
+		attempted <- struct{}{}
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	var err error
+	var n *Notifier
+	{
+		c := DefaultConfig()
+		c.Logger = microloggertest.New()
+		c.RetryAttempts = 3
+		c.RetryBackoff = time.Hour
+		c.URLs = []string{server.URL}
+		n, err = New(c)
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+	}
+
+	err = n.Notify(context.TODO(), rangepool.Event{ID: "test-id", Items: []int{1}, Namespace: "test-namespace", Type: rangepool.EventTypeAllocated})
+	if err != nil {
+		t.Fatal("expected", nil, "got", err)
+	}
+	<-attempted
+
+	// Closing the notifier must not wait for the backoff of the retries, but
+	// make the remaining attempts right away.
+	closed := make(chan error, 1)
+	go func() {
+		closed <- n.Close()
+	}()
+
+	select {
+	case err := <-closed:
+		if err != nil {
+			t.Fatal("expected", nil, "got", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected", "closed", "got", "timeout")
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if attempts != 3 {
+		t.Fatal("expected", 3, "got", attempts)
+	}
+}
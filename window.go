@@ -0,0 +1,91 @@
+package rangepool
+
+import (
+	"sort"
+
+	"github.com/giantswarm/microerror"
+)
+
+// Window is a part of the range of a namespace allocations prefer, see
+// Policy.Windows.
+type Window struct {
+	// Max is the max boundary of the window, inclusive.
+	Max int `json:"max"`
+	// Min is the min boundary of the window, inclusive.
+	Min int `json:"min"`
+	// Weight defines the order in which windows are used. Windows of higher
+	// weight are used first.
+	Weight int `json:"weight"`
+}
+
+// nextWindowItem finds the next item using the given function, which is
+// either nextItem or nextBitmapItem. The windows of the policy of the
+// namespace are tried first, ordered by weight, see Policy.Windows. Only once
+// all of them are exhausted, the item is looked up in the whole range. The
+// latest item is only taken into account for windows containing it.
+func (s *Service) nextWindowItem(min, max, latest int, next func(min, max, latest int) (int, error)) (int, error) {
+	err := validateBoundaries(min, max, latest)
+	if err != nil {
+		return 0, microerror.Mask(err)
+	}
+
+	for _, w := range s.windows {
+		lo, hi := w.Min, w.Max
+		if lo < min {
+			lo = min
+		}
+		if hi > max {
+			hi = max
+		}
+		// Windows overlapping the range by less than two items are skipped,
+		// since ranges must span at least two items.
+		if lo >= hi {
+			continue
+		}
+
+		l := latest
+		if l < lo || l > hi {
+			l = latestItemException
+		}
+
+		item, err := next(lo, hi, l)
+		if IsCapacityReached(err) {
+			continue
+		} else if err != nil {
+			return 0, microerror.Mask(err)
+		}
+
+		return item, nil
+	}
+
+	item, err := next(min, max, latest)
+	if err != nil {
+		return 0, microerror.Mask(err)
+	}
+
+	return item, nil
+}
+
+// sortWindows returns a copy of the given windows ordered by descending
+// weight. Windows of the same weight keep their order.
+func sortWindows(windows []Window) []Window {
+	sorted := append([]Window{}, windows...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Weight > sorted[j].Weight
+	})
+
+	return sorted
+}
+
+func validateWindows(windows []Window) error {
+	for _, w := range windows {
+		if w.Min < 0 || w.Max <= w.Min {
+			return microerror.Maskf(invalidArgumentError, "window %d-%d must define 0 <= min < max", w.Min, w.Max)
+		}
+		if w.Weight < 1 {
+			return microerror.Maskf(invalidArgumentError, "weight of window %d-%d must be at least 1", w.Min, w.Max)
+		}
+	}
+
+	return nil
+}
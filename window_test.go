@@ -0,0 +1,97 @@
+package rangepool
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/giantswarm/micrologger/microloggertest"
+)
+
+func Test_Service_Create_Windows(t *testing.T) {
+	for _, b := range []bool{false, true} {
+		var err error
+		var newService *Service
+		{
+			newStorage, err := newMemoryStorage()
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+
+			config := DefaultConfig()
+			config.Logger = microloggertest.New()
+			config.Storage = newStorage
+			config.Bitmap = b
+			config.LatestMode = LatestModeLowestFree
+			newService, err = New(config)
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+		}
+
+		ctx := context.TODO()
+
+		// Windows must span at least two items and carry a weight.
+		for _, w := range []Window{{Min: 3, Max: 3, Weight: 1}, {Min: 3, Max: 5}} {
+			err := newService.SetPolicy(ctx, namespace, Policy{Windows: []Window{w}})
+			if !IsInvalidArgument(err) {
+				t.Fatal("expected", true, "got", false)
+			}
+		}
+
+		{
+			p := Policy{
+				Windows: []Window{
+					{Min: 6, Max: 7, Weight: 1},
+					{Min: 3, Max: 4, Weight: 2},
+				},
+			}
+			err = newService.SetPolicy(ctx, namespace, p)
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+		}
+
+		// The window of the highest weight must be used first, then the other
+		// window, and only then the rest of the range.
+		{
+			items, err := newService.Create(ctx, namespace, "test-id-1", 6, 0, 9)
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+			expected := []int{3, 4, 6, 7, 0, 1}
+			if !reflect.DeepEqual(items, expected) {
+				t.Fatal("expected", expected, "got", items)
+			}
+		}
+
+		// Windows outside of the requested range must be ignored.
+		{
+			items, err := newService.Create(ctx, namespace, "test-id-2", 1, 8, 9)
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+			expected := []int{8}
+			if !reflect.DeepEqual(items, expected) {
+				t.Fatal("expected", expected, "got", items)
+			}
+		}
+
+		// Freed items of a window must be preferred again.
+		{
+			err := newService.Delete(ctx, namespace, "test-id-1")
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+
+			items, err := newService.Create(ctx, namespace, "test-id-3", 1, 0, 9)
+			if err != nil {
+				t.Fatal("expected", nil, "got", err)
+			}
+			expected := []int{3}
+			if !reflect.DeepEqual(items, expected) {
+				t.Fatal("expected", expected, "got", items)
+			}
+		}
+	}
+}